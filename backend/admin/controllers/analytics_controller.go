@@ -2,15 +2,28 @@ package controllers
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"strings"
+
+	"gd/apierror"
 )
 
-func GetQualificationRates(w http.ResponseWriter, r *http.Request) {
-	data := map[string]interface{}{
-		"cs":   65.2,
-		"ece":  58.7,
-		"mech": 72.1,
+// GetQualificationRates reports, per department, the percentage of finalized
+// session results that qualified.
+func (h *Handlers) GetQualificationRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.store.Reports().QualificationRates(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "loading qualification rates failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+
+	data := map[string]float64{}
+	for department, rate := range rates {
+		data[strings.ToLower(department)] = rate
 	}
+
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
-
@@ -3,11 +3,11 @@ package controllers
 
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"gd/admin/utils"
-	"gd/database"
+	"gd/apierror"
+	"gd/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -16,48 +16,33 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-func AdminLogin(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) AdminLogin(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid request", err))
 		return
 	}
 
-	// Query the database for admin user
-	var (
-		id           string
-		passwordHash string
-	)
-	
-	err := database.GetDB().QueryRow(
-		"SELECT id, password_hash FROM admin_users WHERE email = ?", 
-		req.Email,
-	).Scan(&id, &passwordHash)
-
+	id, passwordHash, err := h.store.Admins().Credentials(r.Context(), req.Email)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
+		if err == repository.ErrNotFound {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Invalid credentials"))
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+			apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
 		}
 		return
 	}
 
 	// Compare the provided password with the hashed password
 	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusUnauthorized, "Invalid credentials", err))
 		return
 	}
 
 	// Generate JWT token
 	token, err := jwt.GenerateToken(id, "admin")
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate token"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to generate token", err))
 		return
 	}
 
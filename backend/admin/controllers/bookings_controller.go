@@ -3,9 +3,10 @@ package controllers
 import (
 	// "database/sql"
 	"encoding/json"
-	"gd/database"
-	"log"
+	"gd/apierror"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 type BookingInfo struct {
@@ -18,48 +19,25 @@ type BookingInfo struct {
 	BookedAt     string `json:"booked_at"`
 }
 
-func GetStudentBookings(w http.ResponseWriter, r *http.Request) {
-    rows, err := database.GetDB().Query(`
-        SELECT 
-            su.id as student_id,
-            su.full_name as student_name,
-            v.id as venue_id,
-            v.name as venue_name,
-            s.id as session_id,
-            s.level as session_level,
-            sp.joined_at as booked_at
-        FROM session_participants sp
-        JOIN student_users su ON sp.student_id = su.id
-        JOIN gd_sessions s ON sp.session_id = s.id
-        JOIN venues v ON s.venue_id = v.id
-        WHERE s.status = 'pending' AND sp.is_dummy = FALSE
-        ORDER BY sp.joined_at DESC
-    `)
-    
+func (h *Handlers) GetStudentBookings(w http.ResponseWriter, r *http.Request) {
+    pending, err := h.store.Reports().PendingBookings(r.Context())
     if err != nil {
-        log.Printf("Database error: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode([]BookingInfo{}) // Return empty array instead of error message
+        slog.ErrorContext(r.Context(), "listing student bookings failed", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
-    defer rows.Close()
 
     var bookings []BookingInfo
-    for rows.Next() {
-        var booking BookingInfo
-        if err := rows.Scan(
-            &booking.StudentID,
-            &booking.StudentName,
-            &booking.VenueID,
-            &booking.VenueName,
-            &booking.SessionID,
-            &booking.SessionLevel,
-            &booking.BookedAt,
-        ); err != nil {
-            log.Printf("Error scanning booking row: %v", err)
-            continue
-        }
-        bookings = append(bookings, booking)
+    for _, b := range pending {
+        bookings = append(bookings, BookingInfo{
+            StudentID:    b.StudentID,
+            StudentName:  b.StudentName,
+            VenueID:      b.VenueID,
+            VenueName:    b.VenueName,
+            SessionID:    b.SessionID,
+            SessionLevel: b.SessionLevel,
+            BookedAt:     b.BookedAt.Format(time.RFC3339Nano),
+        })
     }
 
     // Ensure we always return an array, even if empty
@@ -69,58 +47,8 @@ func GetStudentBookings(w http.ResponseWriter, r *http.Request) {
 
     w.Header().Set("Content-Type", "application/json")
     if err := json.NewEncoder(w).Encode(bookings); err != nil {
-        log.Printf("Error encoding bookings: %v", err)
+        slog.ErrorContext(r.Context(), "encoding bookings failed", "error", err)
         w.WriteHeader(http.StatusInternalServerError)
         json.NewEncoder(w).Encode([]BookingInfo{}) // Fallback to empty array
     }
 }
-
-
-
-// func GetStudentBookings(w http.ResponseWriter, r *http.Request) {
-// 	rows, err := database.GetDB().Query(`
-// 		SELECT 
-// 			su.id as student_id,
-// 			su.full_name as student_name,
-// 			v.id as venue_id,
-// 			v.name as venue_name,
-// 			s.id as session_id,
-// 			s.level as session_level,
-// 			sp.created_at as booked_at
-// 		FROM session_participants sp
-// 		JOIN student_users su ON sp.student_id = su.id
-// 		JOIN gd_sessions s ON sp.session_id = s.id
-// 		JOIN venues v ON s.venue_id = v.id
-// 		WHERE s.status = 'pending'
-// 		ORDER BY sp.created_at DESC
-// 	`)
-	
-// 	if err != nil {
-// 		log.Printf("Database error: %v", err)
-// 		w.WriteHeader(http.StatusInternalServerError)
-// 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-// 		return
-// 	}
-// 	defer rows.Close()
-
-// 	var bookings []BookingInfo
-// 	for rows.Next() {
-// 		var booking BookingInfo
-// 		if err := rows.Scan(
-// 			&booking.StudentID,
-// 			&booking.StudentName,
-// 			&booking.VenueID,
-// 			&booking.VenueName,
-// 			&booking.SessionID,
-// 			&booking.SessionLevel,
-// 			&booking.BookedAt,
-// 		); err != nil {
-// 			log.Printf("Error scanning booking row: %v", err)
-// 			continue
-// 		}
-// 		bookings = append(bookings, booking)
-// 	}
-
-// 	w.Header().Set("Content-Type", "application/json")
-// 	json.NewEncoder(w).Encode(bookings)
-// }
\ No newline at end of file
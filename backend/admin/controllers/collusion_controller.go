@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"gd/apierror"
+	"gd/repository"
+)
+
+// CollusionFlag is an entry in the review queue: responders whose rankings
+// in a session look coordinated. The session is not finalized until every
+// flag on it is voided or dismissed.
+type CollusionFlag struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Level     int    `json:"level"`
+	// Kind is first_place_ring or identical_rankings.
+	Kind         string     `json:"kind"`
+	ResponderIDs []string   `json:"responder_ids"`
+	Detail       string     `json:"detail"`
+	Status       string     `json:"status"`
+	ReviewNote   string     `json:"review_note"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+}
+
+// CollusionReview resolves a flag. Voiding strikes out the responses of
+// ResponderIDs, or of every flagged responder when it is empty, limited to
+// QuestionIDs when given. Voided responses score nothing and are left out
+// of the consensus.
+type CollusionReview struct {
+	ID           string   `json:"id"`
+	Action       string   `json:"action"`
+	ResponderIDs []string `json:"responder_ids"`
+	QuestionIDs  []string `json:"question_ids"`
+	Note         string   `json:"note"`
+}
+
+// GetCollusionFlags lists the review queue, pending flags by default.
+func (h *Handlers) GetCollusionFlags(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = repository.FlagPending
+	}
+	if status != repository.FlagPending && status != repository.FlagVoided && status != repository.FlagDismissed {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "status must be pending, voided or dismissed"))
+		return
+	}
+	queue, err := h.store.Flags().Queue(r.Context(), status, r.URL.Query().Get("session_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "listing collusion flags failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+
+	flags := []CollusionFlag{}
+	for _, q := range queue {
+		f := CollusionFlag{
+			ID: q.ID, SessionID: q.SessionID, Level: q.Level, Kind: q.Kind, ResponderIDs: q.ResponderIDs,
+			Detail: q.Detail, Status: q.Status, ReviewNote: q.ReviewNote, CreatedAt: q.CreatedAt,
+		}
+		if !q.ReviewedAt.IsZero() {
+			f.ReviewedAt = &q.ReviewedAt
+		}
+		flags = append(flags, f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flags)
+}
+
+// ReviewCollusionFlag voids the flagged responses or dismisses the flag.
+// The scheduler finalizes the session once its last flag is reviewed.
+func (h *Handlers) ReviewCollusionFlag(w http.ResponseWriter, r *http.Request) {
+	var req CollusionReview
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	adminID := r.Context().Value("userID").(string)
+
+	var sessionID string
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		flag, err := tx.Flags().Lock(r.Context(), req.ID)
+		if err == repository.ErrNotFound {
+			return apierror.New(http.StatusNotFound, "Flag not found")
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		if flag.Status != repository.FlagPending {
+			return apierror.New(http.StatusConflict, "Flag has already been reviewed")
+		}
+		sessionID = flag.SessionID
+
+		if req.Action == "void" {
+			if len(req.ResponderIDs) == 0 {
+				req.ResponderIDs = flag.ResponderIDs
+			}
+			var problems apierror.Problems
+			for _, id := range req.ResponderIDs {
+				problems.Check(slices.Contains(flag.ResponderIDs, id), "responder_ids", "may only name flagged responders")
+			}
+			if err := problems.Err(); err != nil {
+				return err
+			}
+			err = tx.Flags().Void(r.Context(), flag.SessionID, req.ResponderIDs, req.QuestionIDs)
+		}
+		if err == nil {
+			status := map[string]string{"void": repository.FlagVoided, "dismiss": repository.FlagDismissed}[req.Action]
+			err = tx.Flags().Review(r.Context(), req.ID, status, adminID, req.Note)
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to review flag", err)
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "reviewed collusion flag", "id", req.ID, "action", req.Action, "session_id", sessionID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
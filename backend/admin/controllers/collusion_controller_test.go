@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReviewCollusionFlag(t *testing.T) {
+	const void = "UPDATE survey_results SET voided = TRUE"
+	tests := []struct {
+		name     string
+		status   string
+		body     string
+		wantCode int
+		wantVoid bool
+	}{
+		{"void all flagged", "pending", `{"action": "void"}`, http.StatusOK, true},
+		{"dismiss", "pending", `{"action": "dismiss"}`, http.StatusOK, false},
+		{"unflagged responder", "pending", `{"action": "void", "responder_ids": ["s9"]}`, http.StatusBadRequest, false},
+		{"already reviewed", "dismissed", `{"action": "void"}`, http.StatusConflict, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := scriptStore(t)
+			db.Rows("FROM collusion_flags WHERE id = ? FOR UPDATE",
+				[]string{"session_id", "kind", "responder_ids", "detail", "status"},
+				[]any{"sess1", "first_place_ring", "s1,s2", "", tt.status})
+
+			r := httptest.NewRequest("PUT", "/api/v1/admin/collusion-flags/f1", strings.NewReader(tt.body))
+			r.SetPathValue("id", "f1")
+			r = r.WithContext(context.WithValue(r.Context(), "userID", "admin1"))
+			w := httptest.NewRecorder()
+			h.ReviewCollusionFlag(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			ok := tt.wantCode == http.StatusOK
+			if db.Ran(void) != tt.wantVoid || db.Ran("UPDATE collusion_flags") != ok || db.Ran("COMMIT") != ok {
+				t.Errorf("statements = %q", db.Statements())
+			}
+		})
+	}
+}
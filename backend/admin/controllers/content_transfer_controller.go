@@ -0,0 +1,582 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gd/apierror"
+	"gd/repository"
+)
+
+// Topics and questions are imported and exported as CSV, for faculty who
+// author them in spreadsheets, or JSON, for moving them between
+// deployments. Rows are matched to existing ones by external key.
+
+// maxImportSize bounds an uploaded import file.
+const maxImportSize = 5 << 20
+
+// listSeparator separates the values of a list, such as a topic's tags, in
+// one CSV cell.
+const listSeparator = ";"
+
+// TopicRow is a topic as it is imported and exported.
+type TopicRow struct {
+	// ExternalKey identifies the topic across deployments. A topic that was
+	// never imported is exported with its id as its key.
+	ExternalKey string   `json:"external_key"`
+	Level       int      `json:"level"`
+	TopicText   string   `json:"topic_text"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	Difficulty  string   `json:"difficulty"`
+	Source      string   `json:"source"`
+	// IsActive defaults to true for a new topic and leaves an existing one
+	// as it is.
+	IsActive *bool `json:"is_active"`
+}
+
+// TopicColumns are the CSV columns of topics, in export order.
+var TopicColumns = []string{"external_key", "level", "topic_text", "category", "tags", "difficulty", "source", "is_active"}
+
+func (t TopicRow) topic() Topic {
+	return Topic{Level: t.Level, TopicText: t.TopicText, Category: t.Category, Tags: t.Tags, Difficulty: t.Difficulty, Source: t.Source}
+}
+
+func topicRowFromCSV(cells map[string]string, p *apierror.Problems) TopicRow {
+	return TopicRow{
+		ExternalKey: cells["external_key"],
+		Level:       csvInt(p, "level", cells["level"]),
+		TopicText:   cells["topic_text"],
+		Category:    cells["category"],
+		Tags:        splitList(cells["tags"]),
+		Difficulty:  cells["difficulty"],
+		Source:      cells["source"],
+		IsActive:    csvBool(p, "is_active", cells["is_active"]),
+	}
+}
+
+func (t TopicRow) cells() []string {
+	return []string{t.ExternalKey, strconv.Itoa(t.Level), t.TopicText, t.Category,
+		strings.Join(t.Tags, listSeparator), t.Difficulty, t.Source, strconv.FormatBool(*t.IsActive)}
+}
+
+// QuestionRow is a survey question as it is imported and exported.
+type QuestionRow struct {
+	// ExternalKey identifies the question across deployments. A question
+	// that was never imported is exported with its id as its key.
+	ExternalKey string `json:"external_key"`
+	Text        string `json:"text"`
+	// Weight defaults to 1.
+	Weight float64 `json:"weight"`
+	Levels []int   `json:"levels"`
+	// Bank is the name of the question's bank, if any, which unlike its id
+	// is the same in every deployment.
+	Bank string `json:"bank"`
+	// IsActive defaults to true for a new question and leaves an existing
+	// one as it is. Setting it restores an archived question.
+	IsActive *bool `json:"is_active"`
+}
+
+// QuestionColumns are the CSV columns of questions, in export order.
+var QuestionColumns = []string{"external_key", "text", "weight", "levels", "bank", "is_active"}
+
+func questionRowFromCSV(cells map[string]string, p *apierror.Problems) QuestionRow {
+	q := QuestionRow{
+		ExternalKey: cells["external_key"],
+		Text:        cells["text"],
+		Bank:        cells["bank"],
+		IsActive:    csvBool(p, "is_active", cells["is_active"]),
+	}
+	if cells["weight"] != "" {
+		weight, err := strconv.ParseFloat(cells["weight"], 64)
+		p.Check(err == nil, "weight", "must be a number")
+		q.Weight = weight
+	}
+	for _, level := range splitList(cells["levels"]) {
+		q.Levels = append(q.Levels, csvInt(p, "levels", level))
+	}
+	return q
+}
+
+func (q QuestionRow) cells() []string {
+	levels := make([]string, len(q.Levels))
+	for i, level := range q.Levels {
+		levels[i] = strconv.Itoa(level)
+	}
+	return []string{q.ExternalKey, q.Text, strconv.FormatFloat(q.Weight, 'f', -1, 64),
+		strings.Join(levels, listSeparator), q.Bank, strconv.FormatBool(*q.IsActive)}
+}
+
+// ImportReport says what an import did, or with dry_run would do.
+type ImportReport struct {
+	DryRun  bool        `json:"dry_run"`
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// ImportRow is the outcome for one row of an import.
+type ImportRow struct {
+	// Row is the row's line in a CSV file, counting the header, or its
+	// position from 1 in a JSON array.
+	Row         int    `json:"row"`
+	ExternalKey string `json:"external_key"`
+	// Action is create or update, or empty when the row is invalid.
+	Action string                `json:"action"`
+	Errors []apierror.FieldError `json:"errors"`
+}
+
+// ExportTopics downloads every topic, inactive ones included, as JSON or,
+// with format=csv, CSV.
+func (h *Handlers) ExportTopics(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	list, err := h.store.Topics().Export(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "exporting topics failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to export topics"))
+		return
+	}
+
+	topics := []TopicRow{}
+	for _, t := range list {
+		active := t.IsActive
+		topics = append(topics, TopicRow{ExternalKey: t.ExternalKey, Level: t.Level, TopicText: t.Text, Category: t.Category,
+			Tags: t.Tags, Difficulty: t.Difficulty, Source: t.Source, IsActive: &active})
+	}
+	writeExport(w, r, format, "topics", TopicColumns, topics, TopicRow.cells)
+}
+
+// ExportQuestions downloads every question as JSON or, with format=csv,
+// CSV. Archived questions are left out unless archived=true.
+func (h *Handlers) ExportQuestions(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	list, err := h.store.Questions().Export(r.Context(), r.URL.Query().Get("archived") == "true")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "exporting questions failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to export questions"))
+		return
+	}
+
+	questions := []QuestionRow{}
+	for _, q := range list {
+		active := q.IsActive
+		questions = append(questions, QuestionRow{ExternalKey: q.ExternalKey, Text: q.Text, Weight: q.Weight,
+			Levels: q.Levels, Bank: q.BankName, IsActive: &active})
+	}
+	writeExport(w, r, format, "questions", QuestionColumns, questions, QuestionRow.cells)
+}
+
+// ImportTopics creates and updates topics from an uploaded CSV or JSON file.
+// A row updates the topic with its external key, replacing its fields and
+// tags, and otherwise creates one. level_map, such as 1:2,2:3, moves the
+// file's levels to others. Nothing is saved unless every row is valid; with
+// dry_run=true nothing is saved at all and the report previews the import.
+func (h *Handlers) ImportTopics(w http.ResponseWriter, r *http.Request) {
+	levels, err := parseLevelMap(r.URL.Query().Get("level_map"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	rows, err := readImport(w, r, TopicColumns, topicRowFromCSV)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	for i := range rows {
+		rows[i].row.Level = levels.apply(rows[i].row.Level)
+		rows[i].row.Tags = normalizeTags(rows[i].row.Tags)
+	}
+
+	// texts finds rows repeating a topic of their level.
+	texts := map[string]bool{}
+	check := func(tx repository.AdminStore, t TopicRow, p *apierror.Problems) (string, error) {
+		topic := t.topic()
+		p.Nested("", topic.Validate())
+		if t.ExternalKey == "" {
+			return "", nil
+		}
+		id, err := tx.Topics().ImportedID(r.Context(), t.ExternalKey)
+		if err != nil {
+			return "", err
+		}
+		topic.ID = id
+		text := strconv.Itoa(t.Level) + " " + strings.Join(topicWords(t.TopicText), " ")
+		p.Check(!texts[text], "topic_text", "is repeated at this level in the file")
+		texts[text] = true
+		if err := checkDuplicateTopic(r.Context(), tx, topic, true); err != nil {
+			var conflict *apierror.Error
+			if !errors.As(err, &conflict) || conflict.Status != http.StatusConflict {
+				return "", err
+			}
+			p.Add("topic_text", conflict.Message)
+		}
+		return id, nil
+	}
+	save := func(tx repository.AdminStore, id string, t TopicRow) error {
+		id, err := tx.Topics().SaveImported(r.Context(), repository.TopicImport{ID: id, ExternalKey: t.ExternalKey, Level: t.Level,
+			Text: t.TopicText, Category: t.Category, Difficulty: t.Difficulty, Source: t.Source, IsActive: t.IsActive})
+		if err != nil {
+			return err
+		}
+		return tx.Topics().SetTags(r.Context(), id, t.Tags)
+	}
+	report, err := importRows(r, h.store, rows, TopicRow.key, check, save)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if !report.DryRun {
+		slog.InfoContext(r.Context(), "imported topics", "created", report.Created, "updated", report.Updated)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ImportQuestions creates and updates questions from an uploaded CSV or
+// JSON file, like ImportTopics. A row replaces its question's levels, which
+// level_map can move to others.
+func (h *Handlers) ImportQuestions(w http.ResponseWriter, r *http.Request) {
+	levels, err := parseLevelMap(r.URL.Query().Get("level_map"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	rows, err := readImport(w, r, QuestionColumns, questionRowFromCSV)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	for i := range rows {
+		q := &rows[i].row
+		if q.Weight == 0 {
+			q.Weight = 1
+		}
+		// Two levels may map onto one; the question is then on it once.
+		var mapped []int
+		for _, level := range q.Levels {
+			if level = levels.apply(level); !slices.Contains(mapped, level) {
+				mapped = append(mapped, level)
+			}
+		}
+		q.Levels = mapped
+	}
+
+	// bankIDs caches the ids of the banks rows name.
+	bankIDs := map[string]string{}
+	check := func(tx repository.AdminStore, q QuestionRow, p *apierror.Problems) (string, error) {
+		p.Nested("", QuestionRequest{Text: q.Text, Weight: q.Weight, Levels: q.Levels}.Validate())
+		if q.Bank != "" {
+			if _, ok := bankIDs[q.Bank]; !ok {
+				id, err := tx.Banks().IDByName(r.Context(), q.Bank)
+				if err != nil && err != repository.ErrNotFound {
+					return "", err
+				}
+				bankIDs[q.Bank] = id
+			}
+			p.Check(bankIDs[q.Bank] != "", "bank", "is not a question bank")
+		}
+		if q.ExternalKey == "" {
+			return "", nil
+		}
+		return tx.Questions().ImportedID(r.Context(), q.ExternalKey)
+	}
+	save := func(tx repository.AdminStore, id string, q QuestionRow) error {
+		id, err := tx.Questions().SaveImported(r.Context(), repository.QuestionImport{ID: id, ExternalKey: q.ExternalKey,
+			Text: q.Text, Weight: q.Weight, BankID: bankIDs[q.Bank], IsActive: q.IsActive})
+		if err != nil {
+			return err
+		}
+		return tx.Questions().SetLevels(r.Context(), id, q.Levels)
+	}
+	report, err := importRows(r, h.store, rows, QuestionRow.key, check, save)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if !report.DryRun {
+		slog.InfoContext(r.Context(), "imported questions", "created", report.Created, "updated", report.Updated)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (t TopicRow) key() string    { return t.ExternalKey }
+func (q QuestionRow) key() string { return q.ExternalKey }
+
+// parsedRow is one row read from an import file.
+type parsedRow[T any] struct {
+	line int
+	row  T
+	// problems lists the cells that could not be parsed.
+	problems apierror.Problems
+}
+
+// readImport reads the rows of the uploaded file. A .json file, or one
+// starting with [, holds an array of rows. Otherwise it is CSV, with a
+// header naming each of columns, is_active being optional, and fromCSV
+// converts each line's cells.
+func readImport[T any](w http.ResponseWriter, r *http.Request, columns []string, fromCSV func(cells map[string]string, p *apierror.Problems) T) ([]parsedRow[T], error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, header, err := r.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, apierror.New(http.StatusRequestEntityTooLarge, "Import files must be at most 5 MB")
+	}
+	if err != nil {
+		return nil, apierror.Wrap(http.StatusBadRequest, "A file is required", err)
+	}
+	defer file.Close()
+	content := bufio.NewReader(file)
+
+	if isJSONImport(header.Filename, content) {
+		var rows []T
+		if err := json.NewDecoder(content).Decode(&rows); err != nil {
+			return nil, apierror.Wrap(http.StatusBadRequest, "The file is not a JSON array of rows: "+err.Error(), err)
+		}
+		parsed := make([]parsedRow[T], len(rows))
+		for i, row := range rows {
+			parsed[i] = parsedRow[T]{line: i + 1, row: row}
+		}
+		return parsed, nil
+	}
+
+	cr := csv.NewReader(content)
+	names, err := cr.Read()
+	if err == io.EOF {
+		return nil, apierror.New(http.StatusBadRequest, "The file is empty")
+	}
+	if err != nil {
+		return nil, apierror.Wrap(http.StatusBadRequest, "Invalid CSV: "+err.Error(), err)
+	}
+	var p apierror.Problems
+	index := map[string]int{}
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		_, repeated := index[name]
+		p.Check(slices.Contains(columns, name), "columns", "has unknown column "+strconv.Quote(name))
+		p.Check(!repeated, "columns", "has column "+strconv.Quote(name)+" more than once")
+		index[name] = i
+	}
+	for _, name := range columns {
+		_, ok := index[name]
+		p.Check(ok || name == "is_active", "columns", "is missing column "+strconv.Quote(name))
+	}
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+
+	var parsed []parsedRow[T]
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, apierror.Wrap(http.StatusBadRequest, "Invalid CSV: "+err.Error(), err)
+		}
+		line, _ := cr.FieldPos(0)
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue // spreadsheets often end in empty lines
+		}
+		cells := map[string]string{}
+		for name, i := range index {
+			cells[name] = strings.TrimSpace(record[i])
+		}
+		row := parsedRow[T]{line: line}
+		row.row = fromCSV(cells, &row.problems)
+		parsed = append(parsed, row)
+	}
+	return parsed, nil
+}
+
+func isJSONImport(name string, content *bufio.Reader) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return true
+	case ".csv":
+		return false
+	}
+	start, _ := content.Peek(512)
+	start = bytes.TrimLeft(start, " \t\r\n")
+	return len(start) > 0 && start[0] == '['
+}
+
+// importRows checks every row in one transaction and, unless the request
+// is a dry run or a row is invalid, saves them in it. check records a row's
+// problems and returns the id of the record it updates, or empty to create
+// one; save writes the row.
+func importRows[T any](r *http.Request, store repository.AdminStore, rows []parsedRow[T], key func(T) string,
+	check func(tx repository.AdminStore, row T, p *apierror.Problems) (string, error),
+	save func(tx repository.AdminStore, id string, row T) error) (ImportReport, error) {
+	report := ImportReport{DryRun: r.URL.Query().Get("dry_run") == "true", Rows: []ImportRow{}}
+
+	err := store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		ids := make([]string, len(rows))
+		seen := map[string]bool{}
+		var invalid apierror.Problems
+		for i, parsed := range rows {
+			p := parsed.problems
+			k := key(parsed.row)
+			p.Required("external_key", k)
+			p.Check(len(k) <= 100, "external_key", "must be at most 100 characters")
+			p.Check(!seen[k], "external_key", "is repeated in the file")
+			seen[k] = true
+			var err error
+			ids[i], err = check(tx, parsed.row, &p)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "checking import row failed", "row", parsed.line, "error", err)
+				return apierror.New(http.StatusInternalServerError, "Failed to check the import")
+			}
+
+			out := ImportRow{Row: parsed.line, ExternalKey: k, Errors: []apierror.FieldError{}}
+			switch err := p.Err(); {
+			case err != nil:
+				out.Errors = err.(*apierror.Error).Fields
+				invalid.Nested(fmt.Sprintf("rows[%d]", i), err)
+			case ids[i] == "":
+				out.Action = "create"
+				report.Created++
+			default:
+				out.Action = "update"
+				report.Updated++
+			}
+			report.Rows = append(report.Rows, out)
+		}
+		if report.DryRun {
+			return nil
+		}
+		if err := invalid.Err(); err != nil {
+			return err
+		}
+
+		for i, parsed := range rows {
+			err := save(tx, ids[i], parsed.row)
+			if err == repository.ErrDuplicate {
+				return apierror.New(http.StatusConflict, fmt.Sprintf("Row %d conflicts with an existing record", parsed.line))
+			}
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Failed to save the import", err)
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// levelMap moves the levels of an import file to levels of this
+// deployment.
+type levelMap map[int]int
+
+// parseLevelMap reads a level map written as from:to pairs separated by
+// commas, such as 1:2,2:3.
+func parseLevelMap(s string) (levelMap, error) {
+	m := levelMap{}
+	if s == "" {
+		return m, nil
+	}
+	var p apierror.Problems
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		fromLevel, errFrom := strconv.Atoi(from)
+		toLevel, errTo := strconv.Atoi(to)
+		_, repeated := m[fromLevel]
+		p.Check(ok && errFrom == nil && errTo == nil, "level_map", strconv.Quote(pair)+" must be two levels separated by a colon")
+		p.Check(!repeated, "level_map", fmt.Sprintf("maps level %d more than once", fromLevel))
+		m[fromLevel] = toLevel
+	}
+	return m, p.Err()
+}
+
+func (m levelMap) apply(level int) int {
+	if to, ok := m[level]; ok {
+		return to
+	}
+	return level
+}
+
+// exportFormat returns the requested export format, json unless format=csv.
+func exportFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		return "json", nil
+	case "csv":
+		return format, nil
+	}
+	var p apierror.Problems
+	p.Add("format", "must be csv or json")
+	return "", p.Err()
+}
+
+// writeExport sends rows as a download named after name, as a JSON array
+// or as CSV with a header of columns and the cells of each row.
+func writeExport[T any](w http.ResponseWriter, r *http.Request, format, name string, columns []string, rows []T, cells func(T) []string) {
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rows); err != nil {
+			slog.ErrorContext(r.Context(), "writing export failed", "error", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	for _, row := range rows {
+		cw.Write(cells(row))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.ErrorContext(r.Context(), "writing export failed", "error", err)
+	}
+}
+
+// splitList splits a CSV cell into its non-empty values.
+func splitList(cell string) []string {
+	values := []string{}
+	for _, v := range strings.Split(cell, listSeparator) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func csvInt(p *apierror.Problems, field, cell string) int {
+	if cell == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(cell)
+	p.Check(err == nil, field, "must be a whole number")
+	return n
+}
+
+// csvBool parses an optional true or false cell.
+func csvBool(p *apierror.Problems, field, cell string) *bool {
+	if cell == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(cell)
+	p.Check(err == nil, field, "must be true or false")
+	return &b
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gd/apierror"
+)
+
+func importRequest(t *testing.T, name, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/admin/topics/import", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestReadImportCSV(t *testing.T) {
+	csv := "\ufeffExternal_Key,level,topic_text,category,tags,difficulty,source\n" +
+		"ai-hiring,2,Should AI screen job applicants?,Technology,AI; Ethics,medium,\n" +
+		",,,,,,\n" +
+		"remote,two,\"Remote work, for good?\",,,,\n"
+	rows, err := readImport(httptest.NewRecorder(), importRequest(t, "topics.csv", csv), TopicColumns, topicRowFromCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("read %d rows, want 2 without the blank line", len(rows))
+	}
+	want := TopicRow{ExternalKey: "ai-hiring", Level: 2, TopicText: "Should AI screen job applicants?", Category: "Technology", Tags: []string{"AI", "Ethics"}, Difficulty: "medium"}
+	if !reflect.DeepEqual(rows[0].row, want) || rows[0].line != 2 || rows[0].problems.Err() != nil {
+		t.Errorf("row 1 = %+v on line %d, want %+v on line 2", rows[0].row, rows[0].line, want)
+	}
+	if rows[1].line != 4 || rows[1].row.TopicText != "Remote work, for good?" {
+		t.Errorf("row 2 = %+v on line %d", rows[1].row, rows[1].line)
+	}
+	if fields := rows[1].problems.Err().(*apierror.Error).Fields; len(fields) != 1 || fields[0].Field != "level" {
+		t.Errorf("row 2 problems = %v, want level", fields)
+	}
+}
+
+func TestReadImportRejectsColumns(t *testing.T) {
+	csv := "external_key,text,weight,levels,bnak\nq1,Was clear,1,1;2,\n"
+	_, err := readImport(httptest.NewRecorder(), importRequest(t, "questions.csv", csv), QuestionColumns, questionRowFromCSV)
+	e, ok := err.(*apierror.Error)
+	if !ok || len(e.Fields) != 2 {
+		t.Fatalf("err = %v, want an unknown and a missing column", err)
+	}
+}
+
+func TestReadImportJSON(t *testing.T) {
+	json := ` [{"external_key": "q1", "text": "Listened to others", "levels": [1, 3], "is_active": false}]`
+	rows, err := readImport(httptest.NewRecorder(), importRequest(t, "export", json), QuestionColumns, questionRowFromCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].line != 1 || rows[0].row.Text != "Listened to others" || !reflect.DeepEqual(rows[0].row.Levels, []int{1, 3}) ||
+		rows[0].row.IsActive == nil || *rows[0].row.IsActive {
+		t.Errorf("rows = %+v", rows)
+	}
+}
+
+func TestParseLevelMap(t *testing.T) {
+	m, err := parseLevelMap("1:2, 2:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []int{m.apply(1), m.apply(2), m.apply(3)}; !reflect.DeepEqual(got, []int{2, 3, 3}) {
+		t.Errorf("mapped 1, 2, 3 to %v, want 2, 3, 3", got)
+	}
+	for _, bad := range []string{"1-2", "1:x", "1:2,1:3"} {
+		if _, err := parseLevelMap(bad); err == nil {
+			t.Errorf("parseLevelMap(%q) succeeded", bad)
+		}
+	}
+}
@@ -1,30 +1,19 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"gd/database"
+	"gd/apierror"
 	"net/http"
 )
 
-func GetSessionFeedbacks(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetSessionFeedbacks(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.URL.Query().Get("session_id")
 
-	rows, err := database.GetDB().Query(`
-		SELECT 
-			sf.id, sf.rating, sf.comments, sf.created_at,
-			su.full_name, su.department, su.year
-		FROM session_feedback sf
-		JOIN student_users su ON sf.student_id = su.id
-		WHERE sf.session_id = ?
-		ORDER BY sf.created_at DESC`, sessionID)
-	
+	entries, err := h.store.Reports().SessionFeedback(r.Context(), sessionID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
 		return
 	}
-	defer rows.Close()
 
 	type Feedback struct {
 		ID        string `json:"id"`
@@ -39,18 +28,11 @@ func GetSessionFeedbacks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var feedbacks []Feedback
-	for rows.Next() {
-		var f Feedback
-		var createdAt sql.NullTime
-		err := rows.Scan(
-			&f.ID, &f.Rating, &f.Comments, &createdAt,
-			&f.Student.Name, &f.Student.Department, &f.Student.Year,
-		)
-		if err != nil {
-			continue
-		}
-		if createdAt.Valid {
-			f.CreatedAt = createdAt.Time.Format("2006-01-02 15:04:05")
+	for _, e := range entries {
+		f := Feedback{ID: e.ID, Rating: e.Rating, Comments: e.Comments}
+		f.Student.Name, f.Student.Department, f.Student.Year = e.Name, e.Department, e.Year
+		if !e.CreatedAt.IsZero() {
+			f.CreatedAt = e.CreatedAt.Format("2006-01-02 15:04:05")
 		}
 		feedbacks = append(feedbacks, f)
 	}
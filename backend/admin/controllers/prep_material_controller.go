@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+
+	"gd/apierror"
+	"gd/repository"
+	"gd/storage"
+
+	"github.com/google/uuid"
+)
+
+// maxAttachmentSize bounds an uploaded prep material file.
+const maxAttachmentSize = 20 << 20
+
+// attachmentTypes are the file types faculty may attach, as sniffed from
+// the upload rather than taken from the client.
+var attachmentTypes = []string{"application/pdf", "image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// PrepMaterial is one item of a topic's preparation: a reading link, a key
+// point, an argument for or against, or an attached PDF or image. Students
+// see it from the visible_from phase through visible_until, if set.
+type PrepMaterial struct {
+	ID           string `json:"id"`
+	TopicID      string `json:"topic_id"`
+	Kind         string `json:"kind"`
+	Title        string `json:"title"`
+	Body         string `json:"body,omitempty"`
+	URL          string `json:"url,omitempty"`
+	FileName     string `json:"file_name,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	VisibleFrom  string `json:"visible_from"`
+	VisibleUntil string `json:"visible_until,omitempty"`
+	DisplayOrder int    `json:"display_order"`
+}
+
+// visibleFrom defaults materials to being revealed in the prep phase.
+func (m PrepMaterial) visibleFrom() string {
+	if m.VisibleFrom == "" {
+		return "prep"
+	}
+	return m.VisibleFrom
+}
+
+// prepMaterial is the response for a stored material; its file key is not
+// shown.
+func prepMaterial(m repository.PrepMaterial) PrepMaterial {
+	return PrepMaterial{
+		ID: m.ID, TopicID: m.TopicID, Kind: m.Kind, Title: m.Title, Body: m.Body, URL: m.URL,
+		FileName: m.FileName, ContentType: m.ContentType, Size: m.Size,
+		VisibleFrom: m.VisibleFrom, VisibleUntil: m.VisibleUntil, DisplayOrder: m.DisplayOrder,
+	}
+}
+
+// GetPrepMaterials lists a topic's materials in display order.
+func (h *Handlers) GetPrepMaterials(w http.ResponseWriter, r *http.Request) {
+	topicID := r.URL.Query().Get("topic_id")
+	list, err := h.store.Materials().List(r.Context(), topicID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "listing prep materials failed", "topic_id", topicID, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+
+	materials := []PrepMaterial{}
+	for _, m := range list {
+		materials = append(materials, prepMaterial(m))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(materials)
+}
+
+// CreatePrepMaterial adds a link, key point or argument to the end of a
+// topic's materials. Attachments are added by UploadPrepMaterial.
+func (h *Handlers) CreatePrepMaterial(w http.ResponseWriter, r *http.Request) {
+	var m PrepMaterial
+	if err := apierror.Decode(r, &m); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if m.Kind == repository.MaterialAttachment {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Upload attachments as files to the topic's attachments"))
+		return
+	}
+	m.ID = uuid.New().String()
+	if err := h.insertPrepMaterial(r.Context(), &m, ""); err != nil {
+		slog.ErrorContext(r.Context(), "creating prep material failed", "topic_id", m.TopicID, "error", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// UploadPrepMaterial attaches a PDF or image, sent as the multipart field
+// "file", to the end of a topic's materials. The form's title,
+// visible_from and visible_until fields describe it.
+func (h *Handlers) UploadPrepMaterial(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1<<20)
+	file, header, err := r.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apierror.Write(w, r, apierror.New(http.StatusRequestEntityTooLarge, "Attachments must be at most 20 MB"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "A file is required", err))
+		return
+	}
+	defer file.Close()
+
+	m := PrepMaterial{
+		ID:           uuid.New().String(),
+		TopicID:      r.URL.Query().Get("topic_id"),
+		Kind:         repository.MaterialAttachment,
+		Title:        r.FormValue("title"),
+		FileName:     path.Base(header.Filename),
+		Size:         header.Size,
+		VisibleFrom:  r.FormValue("visible_from"),
+		VisibleUntil: r.FormValue("visible_until"),
+	}
+	if m.Title == "" {
+		m.Title = m.FileName
+	}
+	if err := m.Validate(); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if m.Size > maxAttachmentSize {
+		apierror.Write(w, r, apierror.New(http.StatusRequestEntityTooLarge, "Attachments must be at most 20 MB"))
+		return
+	}
+	content := bufio.NewReader(file)
+	sniff, _ := content.Peek(512)
+	m.ContentType = http.DetectContentType(sniff)
+	if !slices.Contains(attachmentTypes, m.ContentType) {
+		apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, "Attachments must be PDFs or PNG, JPEG, GIF or WebP images"))
+		return
+	}
+
+	key := attachmentKey(m)
+	if err := h.files.Put(r.Context(), key, content); err != nil {
+		slog.ErrorContext(r.Context(), "storing attachment failed", "topic_id", m.TopicID, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to store attachment"))
+		return
+	}
+	if err := h.insertPrepMaterial(r.Context(), &m, key); err != nil {
+		slog.ErrorContext(r.Context(), "creating attachment failed", "topic_id", m.TopicID, "error", err)
+		// The material was not saved, so nothing refers to the file.
+		if err := h.files.Delete(r.Context(), key); err != nil {
+			slog.WarnContext(r.Context(), "removing orphaned attachment failed", "key", key, "error", err)
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// attachmentKey is where an attachment is kept in the storage backend.
+func attachmentKey(m PrepMaterial) string {
+	return "prep-materials/" + m.TopicID + "/" + m.ID
+}
+
+// insertPrepMaterial saves m after the topic's other materials.
+func (h *Handlers) insertPrepMaterial(ctx context.Context, m *PrepMaterial, fileKey string) error {
+	m.VisibleFrom = m.visibleFrom()
+	err := h.store.InTx(ctx, func(tx repository.AdminStore) error {
+		var err error
+		m.DisplayOrder, err = tx.Materials().Append(ctx, repository.PrepMaterial{
+			ID: m.ID, TopicID: m.TopicID, Kind: m.Kind, Title: m.Title, Body: m.Body, URL: m.URL,
+			FileKey: fileKey, FileName: m.FileName, ContentType: m.ContentType, Size: m.Size,
+			VisibleFrom: m.VisibleFrom, VisibleUntil: m.VisibleUntil,
+		})
+		return err
+	})
+	if err == repository.ErrNotFound {
+		return apierror.New(http.StatusNotFound, "Topic not found")
+	}
+	if err != nil {
+		return apierror.Wrap(http.StatusInternalServerError, "Failed to create material", err)
+	}
+	return nil
+}
+
+// UpdatePrepMaterial changes a material's content, visibility and position.
+// Its kind, and an attachment's file, stay as they are.
+func (h *Handlers) UpdatePrepMaterial(w http.ResponseWriter, r *http.Request) {
+	var req PrepMaterial
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	m, err := h.store.Materials().Get(r.Context(), req.ID)
+	if err == repository.ErrNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Material not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+		return
+	}
+	if req.Kind != m.Kind {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, "A material's kind cannot be changed; delete it and add a new one"))
+		return
+	}
+
+	m.Title, m.Body, m.URL = req.Title, req.Body, req.URL
+	if m.Kind == repository.MaterialAttachment && m.Title == "" {
+		m.Title = m.FileName
+	}
+	m.VisibleFrom, m.VisibleUntil, m.DisplayOrder = req.visibleFrom(), req.VisibleUntil, req.DisplayOrder
+	if err := h.store.Materials().Update(r.Context(), m); err != nil {
+		slog.ErrorContext(r.Context(), "updating prep material failed", "id", m.ID, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to update material"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prepMaterial(m))
+}
+
+// DeletePrepMaterial deletes a material and any attached file.
+func (h *Handlers) DeletePrepMaterial(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	fileKey, err := h.store.Materials().Delete(r.Context(), id)
+	if err == repository.ErrNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Material not found"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "deleting prep material failed", "id", id, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to delete material"))
+		return
+	}
+	if fileKey != "" {
+		if err := h.files.Delete(r.Context(), fileKey); err != nil {
+			slog.WarnContext(r.Context(), "deleting attachment failed", "key", fileKey, "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// DownloadPrepMaterial serves an attachment's file.
+func (h *Handlers) DownloadPrepMaterial(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	m, err := h.store.Materials().Get(r.Context(), id)
+	if err == repository.ErrNotFound || (err == nil && m.FileKey == "") {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Attachment not found"))
+		return
+	}
+	if err == nil {
+		err = storage.Serve(w, r, h.files, m.FileKey, m.FileName, m.ContentType)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Attachment not found"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "serving attachment failed", "id", id, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to load attachment"))
+	}
+}
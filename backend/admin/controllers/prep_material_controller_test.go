@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gd/storage"
+)
+
+func TestCreatePrepMaterial(t *testing.T) {
+	const insert = "INSERT INTO prep_materials"
+	tests := []struct {
+		name     string
+		topic    bool
+		wantCode int
+	}{
+		{"appended", true, http.StatusCreated},
+		{"missing topic", false, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := scriptStore(t)
+			if tt.topic {
+				db.Rows("FROM gd_topics", []string{"id"}, []any{"t1"})
+			}
+			db.Rows("MAX(display_order)", []string{"next"}, []any{int64(3)})
+
+			body := `{"topic_id": "t1", "kind": "key_point", "title": "Costs", "body": "Who pays?"}`
+			w := httptest.NewRecorder()
+			h.CreatePrepMaterial(w, httptest.NewRequest("POST", "/api/v1/admin/topics/t1/materials", strings.NewReader(body)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if db.Ran(insert) != tt.topic || db.Ran("COMMIT") != tt.topic {
+				t.Errorf("statements = %q", db.Statements())
+			}
+			if tt.topic && !strings.Contains(w.Body.String(), `"display_order":3`) {
+				t.Errorf("body = %s, want display_order 3", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeletePrepMaterial(t *testing.T) {
+	h, db := scriptStore(t)
+	files := storage.NewMemory()
+	h.files = files
+	files.Put(context.Background(), "prep-materials/t1/m1", strings.NewReader("%PDF-1.4"))
+	db.Rows("SELECT file_key", []string{"file_key"}, []any{"prep-materials/t1/m1"})
+
+	w := httptest.NewRecorder()
+	h.DeletePrepMaterial(w, httptest.NewRequest("DELETE", "/api/v1/admin/prep-materials/m1?id=m1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if !db.Ran("DELETE FROM prep_materials") {
+		t.Errorf("statements = %q", db.Statements())
+	}
+	if _, err := files.Open(context.Background(), "prep-materials/t1/m1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("attachment still stored: %v", err)
+	}
+}
@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	qr "gd/admin/utils"
-	"gd/database"
+	"gd/apierror"
+	"gd/repository"
 	"net/http"
 	"time"
 
@@ -11,11 +13,10 @@ import (
 )
 
 
-func GenerateQR(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GenerateQR(w http.ResponseWriter, r *http.Request) {
     venueID := r.URL.Query().Get("venue_id")
     if venueID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "venue_id parameter is required"})
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "venue_id parameter is required"))
         return
     }
 
@@ -24,25 +25,7 @@ func GenerateQR(w http.ResponseWriter, r *http.Request) {
 
     // If not forcing new, check for existing active QR codes with available capacity
     if !forceNew {
-        var availableQR struct {
-            ID           string
-            QRData       string
-            ExpiresAt    time.Time
-            MaxCapacity  int
-            CurrentUsage int
-        }
-        
-        err := database.GetDB().QueryRow(`
-            SELECT id, qr_data, expires_at, max_capacity, current_usage
-            FROM venue_qr_codes 
-            WHERE venue_id = ? AND is_active = TRUE 
-            AND expires_at > NOW()
-            AND current_usage < max_capacity
-            ORDER BY created_at DESC LIMIT 1`,
-            venueID,
-        ).Scan(&availableQR.ID, &availableQR.QRData, &availableQR.ExpiresAt, 
-              &availableQR.MaxCapacity, &availableQR.CurrentUsage)
-
+        availableQR, err := h.store.QRCodes().Current(r.Context(), venueID, false)
         if err == nil {
             // Found available QR code - return it
             w.Header().Set("Content-Type", "application/json")
@@ -60,59 +43,26 @@ func GenerateQR(w http.ResponseWriter, r *http.Request) {
             return
         }
         
-        // If we get here, no available QR was found (either expired or full)
-        // Check if there are any full QR codes that should trigger new generation
-        var fullQRCount int
-        database.GetDB().QueryRow(`
-            SELECT COUNT(*) FROM venue_qr_codes 
-            WHERE venue_id = ? AND is_active = TRUE 
-            AND expires_at > NOW()
-            AND current_usage >= max_capacity`,
-            venueID).Scan(&fullQRCount)
-            
-        if fullQRCount > 0 {
-            // There are full QR codes, so we should generate a new one
-            // BUT only if force_new is explicitly requested or this is an auto-generation scenario
-            // For manual requests, we should return the full QR unless force_new=true
-            if r.URL.Query().Get("auto_generate") == "true" {
-                forceNew = true
-            } else {
-                // Return the full QR code for manual requests
-                var fullQR struct {
-                    ID           string
-                    QRData       string
-                    ExpiresAt    time.Time
-                    MaxCapacity  int
-                    CurrentUsage int
-                }
-                
-                err := database.GetDB().QueryRow(`
-                    SELECT id, qr_data, expires_at, max_capacity, current_usage
-                    FROM venue_qr_codes 
-                    WHERE venue_id = ? AND is_active = TRUE 
-                    AND expires_at > NOW()
-                    AND current_usage >= max_capacity
-                    ORDER BY created_at DESC LIMIT 1`,
-                    venueID,
-                ).Scan(&fullQR.ID, &fullQR.QRData, &fullQR.ExpiresAt, 
-                      &fullQR.MaxCapacity, &fullQR.CurrentUsage)
-
-                if err == nil {
-                    w.Header().Set("Content-Type", "application/json")
-                    json.NewEncoder(w).Encode(map[string]interface{}{
-                        "success":        true,
-                        "qr_string":      fullQR.QRData,
-                        "expires_in":     time.Until(fullQR.ExpiresAt).Minutes(),
-                        "expires_at":     fullQR.ExpiresAt.Format(time.RFC3339),
-                        "qr_id":          fullQR.ID,
-                        "max_capacity":   fullQR.MaxCapacity,
-                        "current_usage":  fullQR.CurrentUsage,
-                        "remaining_slots": 0,
-                        "is_new":         false,
-                        "is_full":        true, // Indicate this QR is full
-                    })
-                    return
-                }
+        // If we get here, no available QR was found (either expired or full).
+        // A full one triggers a new QR when auto-generating; manual requests
+        // get the full QR back unless force_new=true.
+        if r.URL.Query().Get("auto_generate") != "true" {
+            fullQR, err := h.store.QRCodes().Current(r.Context(), venueID, true)
+            if err == nil {
+                w.Header().Set("Content-Type", "application/json")
+                json.NewEncoder(w).Encode(map[string]interface{}{
+                    "success":        true,
+                    "qr_string":      fullQR.QRData,
+                    "expires_in":     time.Until(fullQR.ExpiresAt).Minutes(),
+                    "expires_at":     fullQR.ExpiresAt.Format(time.RFC3339),
+                    "qr_id":          fullQR.ID,
+                    "max_capacity":   fullQR.MaxCapacity,
+                    "current_usage":  fullQR.CurrentUsage,
+                    "remaining_slots": 0,
+                    "is_new":         false,
+                    "is_full":        true, // Indicate this QR is full
+                })
+                return
             }
         }
     }
@@ -121,8 +71,7 @@ func GenerateQR(w http.ResponseWriter, r *http.Request) {
     expiresAt := time.Now().Add(240 * time.Minute)
     qrData, err := qr.GenerateSecureQR(venueID, 240*time.Minute)
     if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "failed to generate QR code"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "failed to generate QR code", err))
         return
     }
 
@@ -134,14 +83,15 @@ func GenerateQR(w http.ResponseWriter, r *http.Request) {
     maxCapacity := 13 // 15 // Keep 15 commented near 2
 
     // Store the new QR code with fixed capacity of 2
-    _, err = database.GetDB().Exec(`
-        INSERT INTO venue_qr_codes 
-        (id, venue_id, qr_data, expires_at, is_active, max_capacity, current_usage, qr_group_id) 
-        VALUES (?, ?, ?, NOW() + INTERVAL 240 MINUTE, TRUE, ?, 0, ?)`,
-        qrID, venueID, qrData, maxCapacity, qrGroupID)
+    err = h.store.QRCodes().Create(r.Context(), repository.QRCode{
+        ID:          qrID,
+        VenueID:     venueID,
+        QRData:      qrData,
+        MaxCapacity: maxCapacity,
+        QRGroupID:   qrGroupID,
+    }, 240*time.Minute)
     if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "failed to store QR code"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "failed to store QR code", err))
         return
     }
 
@@ -160,54 +110,7 @@ func GenerateQR(w http.ResponseWriter, r *http.Request) {
     })
 }
 
-
-
-func IncrementQRUsage(qrID string) error {
-    _, err := database.GetDB().Exec(`
-        UPDATE venue_qr_codes 
-        SET current_usage = current_usage + 1 
-        WHERE id = ? AND is_active = TRUE 
-        AND current_usage < max_capacity
-        AND expires_at > NOW()`,
-        qrID)
-    return err
-}
-
-
-func GetQRDetails(qrID string) (map[string]interface{}, error) {
-    var details struct {
-        VenueID      string
-        MaxCapacity  int
-        CurrentUsage int
-        IsActive     bool
-        ExpiresAt    time.Time
-    }
-    
-    err := database.GetDB().QueryRow(`
-        SELECT venue_id, max_capacity, current_usage, is_active, expires_at
-        FROM venue_qr_codes 
-        WHERE id = ?`,
-        qrID).Scan(&details.VenueID, &details.MaxCapacity, 
-                   &details.CurrentUsage, &details.IsActive, &details.ExpiresAt)
-    
-    if err != nil {
-        return nil, err
-    }
-    
-    return map[string]interface{}{
-        "venue_id":      details.VenueID,
-        "max_capacity":  details.MaxCapacity,
-        "current_usage": details.CurrentUsage,
-        "is_active":     details.IsActive,
-        "expires_at":    details.ExpiresAt,
-        "is_full":       details.CurrentUsage >= details.MaxCapacity,
-        "is_expired":    time.Now().After(details.ExpiresAt),
-    }, nil
-}
-
-func CleanupExpiredQRCodes() error {
-    _, err := database.GetDB().Exec(
-        "UPDATE venue_qr_codes SET is_active = FALSE WHERE expires_at < UTC_TIMESTAMP() AND is_active = TRUE",
-    )
-    return err
+// CleanupExpiredQRCodes deactivates QR codes past their expiry.
+func (h *Handlers) CleanupExpiredQRCodes(ctx context.Context) error {
+    return h.store.QRCodes().DeactivateExpired(ctx)
 }
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var qrColumns = []string{"id", "venue_id", "qr_data", "max_capacity", "current_usage", "is_active", "qr_group_id", "expires_at", "created_at"}
+
+func TestGenerateQR(t *testing.T) {
+	const (
+		open   = "current_usage < max_capacity"
+		full   = "current_usage >= max_capacity"
+		insert = "INSERT INTO venue_qr_codes"
+	)
+	expires := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		target  string
+		open    bool
+		full    bool
+		wantID  string
+		wantNew bool
+	}{
+		{"reuses an open code", "/admin/qr?venue_id=v1", true, true, "open", false},
+		{"returns a full code", "/admin/qr?venue_id=v1", false, true, "full", false},
+		{"replaces a full code when auto-generating", "/admin/qr?venue_id=v1&auto_generate=true", false, true, "", true},
+		{"creates the first code", "/admin/qr?venue_id=v1", false, false, "", true},
+		{"creates a code when forced", "/admin/qr?venue_id=v1&force_new=true", true, true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := scriptStore(t)
+			if tt.open {
+				db.Rows(open, qrColumns, []any{"open", "v1", "payload", 13, 4, true, "g1", expires, time.Now()})
+			}
+			if tt.full {
+				db.Rows(full, qrColumns, []any{"full", "v1", "payload", 13, 13, true, "g1", expires, time.Now()})
+			}
+			w := httptest.NewRecorder()
+			h.GenerateQR(w, httptest.NewRequest("POST", tt.target, nil))
+
+			var out map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("decoding %q: %v", w.Body.String(), err)
+			}
+			if out["is_new"] != tt.wantNew || db.Ran(insert) != tt.wantNew {
+				t.Fatalf("is_new = %v, inserted = %v, want %v", out["is_new"], db.Ran(insert), tt.wantNew)
+			}
+			if !tt.wantNew && out["qr_id"] != tt.wantID {
+				t.Errorf("qr_id = %v, want %s", out["qr_id"], tt.wantID)
+			}
+		})
+	}
+}
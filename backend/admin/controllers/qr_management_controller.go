@@ -3,62 +3,37 @@ package controllers
 
 import (
 	"encoding/json"
-	"gd/database"
+	"gd/apierror"
 	"net/http"
+	"time"
 )
 
-func GetVenueQRCodes(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetVenueQRCodes(w http.ResponseWriter, r *http.Request) {
     venueID := r.URL.Query().Get("venue_id")
     if venueID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "venue_id parameter is required"})
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "venue_id parameter is required"))
         return
     }
 
-    rows, err := database.GetDB().Query(`
-        SELECT id, qr_data, expires_at, is_active, max_capacity, current_usage, qr_group_id, created_at
-        FROM venue_qr_codes 
-        WHERE venue_id = ? AND is_active = TRUE
-        AND expires_at > NOW()
-        ORDER BY created_at DESC`,
-        venueID)
-    
+    codes, err := h.store.QRCodes().Active(r.Context(), venueID)
     if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
         return
     }
-    defer rows.Close()
 
     var qrCodes []map[string]interface{}
-    for rows.Next() {
-        var qr struct {
-            ID          string
-            QRData      string
-            ExpiresAt   string
-            IsActive    bool
-            MaxCapacity int
-            CurrentUsage int
-            QRGroupID   string
-            CreatedAt   string
-        }
-        
-        if err := rows.Scan(&qr.ID, &qr.QRData, &qr.ExpiresAt, &qr.IsActive, 
-                          &qr.MaxCapacity, &qr.CurrentUsage, &qr.QRGroupID, &qr.CreatedAt); err != nil {
-            continue
-        }
-
+    for _, qr := range codes {
         qrCodes = append(qrCodes, map[string]interface{}{
             "id":            qr.ID,
             "qr_data_short": qr.QRData[:20] + "...", // Show partial data for security
-            "expires_at":    qr.ExpiresAt,
+            "expires_at":    qr.ExpiresAt.Format(time.RFC3339Nano),
             "is_active":     qr.IsActive,
             "max_capacity":  qr.MaxCapacity,
             "current_usage": qr.CurrentUsage,
             "remaining":     qr.MaxCapacity - qr.CurrentUsage,
             "is_full":       qr.CurrentUsage >= qr.MaxCapacity,
             "qr_group_id":   qr.QRGroupID,
-            "created_at":    qr.CreatedAt,
+            "created_at":    qr.CreatedAt.Format(time.RFC3339Nano),
         })
     }
 
@@ -66,23 +41,15 @@ func GetVenueQRCodes(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(qrCodes)
 }
 
-func DeactivateQR(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) DeactivateQR(w http.ResponseWriter, r *http.Request) {
     qrID := r.URL.Query().Get("qr_id")
     if qrID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "qr_id parameter is required"})
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "qr_id parameter is required"))
         return
     }
 
-    _, err := database.GetDB().Exec(`
-        UPDATE venue_qr_codes 
-        SET is_active = FALSE 
-        WHERE id = ?`,
-        qrID)
-    
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to deactivate QR code"})
+    if err := h.store.QRCodes().Deactivate(r.Context(), qrID); err != nil {
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to deactivate QR code", err))
         return
     }
 
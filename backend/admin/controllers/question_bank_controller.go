@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gd/apierror"
+	"gd/repository"
+
+	"github.com/google/uuid"
+)
+
+// QuestionBank groups questions by the competency they assess, such as
+// clarity or leadership.
+type QuestionBank struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Questions counts the active questions in the bank.
+	Questions int `json:"questions"`
+}
+
+// QuestionTemplate says how many questions a level's sessions draw from
+// each bank. Each session draws its own questions, the same for all of its
+// participants; a level without a template asks all of its questions.
+type QuestionTemplate struct {
+	Level int        `json:"level"`
+	Draws []BankDraw `json:"draws"`
+}
+
+// BankDraw is one bank's share of a QuestionTemplate.
+type BankDraw struct {
+	BankID string `json:"bank_id"`
+	Count  int    `json:"count"`
+}
+
+// GetQuestionBanks lists the question banks by name.
+func (h *Handlers) GetQuestionBanks(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.Banks().List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "listing question banks failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+
+	banks := []QuestionBank{}
+	for _, b := range list {
+		banks = append(banks, QuestionBank{ID: b.ID, Name: b.Name, Description: b.Description, Questions: b.Questions})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(banks)
+}
+
+// SaveQuestionBank creates a bank, or renames the one in the path.
+func (h *Handlers) SaveQuestionBank(w http.ResponseWriter, r *http.Request) {
+	var req QuestionBank
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	var err error
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+		err = h.store.Banks().Create(r.Context(), repository.QuestionBank{ID: req.ID, Name: req.Name, Description: req.Description})
+	} else {
+		err = h.store.Banks().Update(r.Context(), repository.QuestionBank{ID: req.ID, Name: req.Name, Description: req.Description})
+	}
+	if err == repository.ErrDuplicate {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, "A bank with that name already exists"))
+		return
+	}
+	if err == repository.ErrNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Bank not found"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "saving question bank failed", "id", req.ID, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to save bank"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// DeleteQuestionBank deletes a bank no level template draws from. Its
+// questions are kept without a bank.
+func (h *Handlers) DeleteQuestionBank(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		levels, err := tx.Banks().TemplateLevels(r.Context(), id)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		if len(levels) > 0 {
+			list := make([]string, len(levels))
+			for i, level := range levels {
+				list[i] = strconv.Itoa(level)
+			}
+			return apierror.New(http.StatusConflict, "Bank is drawn from by the templates of levels "+strings.Join(list, ","))
+		}
+
+		err = tx.Banks().Delete(r.Context(), id)
+		if err == repository.ErrNotFound {
+			return apierror.New(http.StatusNotFound, "Bank not found")
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to delete bank", err)
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// GetQuestionTemplate returns the template for the level in the path. A
+// level without one has no draws.
+func (h *Handlers) GetQuestionTemplate(w http.ResponseWriter, r *http.Request) {
+	level, err := strconv.Atoi(r.URL.Query().Get("level"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid level", err))
+		return
+	}
+	draws, err := h.store.Banks().Template(r.Context(), level)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "loading question template failed", "level", level, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+
+	template := QuestionTemplate{Level: level, Draws: []BankDraw{}}
+	for _, d := range draws {
+		template.Draws = append(template.Draws, BankDraw{BankID: d.BankID, Count: d.Count})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// UpdateQuestionTemplate replaces a level's template. Each bank must hold
+// enough active questions at the level for its draw. Sessions that have
+// already drawn their questions keep them.
+func (h *Handlers) UpdateQuestionTemplate(w http.ResponseWriter, r *http.Request) {
+	var req QuestionTemplate
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		var problems apierror.Problems
+		draws := make([]repository.BankDraw, len(req.Draws))
+		for i, d := range req.Draws {
+			exists, err := tx.Banks().Exists(r.Context(), d.BankID)
+			var available int
+			if err == nil && exists {
+				available, err = tx.Banks().Available(r.Context(), d.BankID, req.Level)
+			}
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+			}
+			problems.Check(exists, fmt.Sprintf("draws[%d].bank_id", i), "is not a question bank")
+			problems.Check(!exists || d.Count <= available, fmt.Sprintf("draws[%d].count", i),
+				fmt.Sprintf("bank has only %d active questions at level %d", available, req.Level))
+			draws[i] = repository.BankDraw{BankID: d.BankID, Count: d.Count}
+		}
+		if err := problems.Err(); err != nil {
+			return err
+		}
+
+		if err := tx.Banks().SetTemplate(r.Context(), req.Level, draws); err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to save template", err)
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if req.Draws == nil {
+		req.Draws = []BankDraw{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
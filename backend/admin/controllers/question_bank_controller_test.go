@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSaveQuestionBank(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		taken    bool
+		affected int64
+		exists   bool
+		wantCode int
+	}{
+		{"created", "", false, 1, false, http.StatusOK},
+		{"name taken", "", true, 1, false, http.StatusConflict},
+		{"renamed", "b1", false, 1, true, http.StatusOK},
+		{"unchanged", "b1", false, 0, true, http.StatusOK},
+		{"missing", "b9", false, 0, false, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := scriptStore(t)
+			if tt.taken {
+				db.Fail("INSERT INTO question_banks", errors.New("Error 1062: Duplicate entry 'Clarity' for key 'name'"))
+			}
+			db.Affects("UPDATE question_banks", tt.affected)
+			db.Rows("SELECT EXISTS", []string{"exists"}, []any{tt.exists})
+
+			body := `{"id": "` + tt.id + `", "name": "Clarity"}`
+			w := httptest.NewRecorder()
+			h.SaveQuestionBank(w, httptest.NewRequest("PUT", "/api/v1/admin/question-banks", strings.NewReader(body)))
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
@@ -1,14 +1,15 @@
 package controllers
 
 import (
-	// "database/sql"
-	"database/sql"
+	"context"
 	"encoding/json"
-	"gd/database"
-	"log"
+	"fmt"
+	"gd/apierror"
+	"gd/repository"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	// "strings"
 
@@ -20,120 +21,85 @@ type Question struct {
 	Text    string  `json:"text"`
 	Weight  float64 `json:"weight"`
 	Levels  []int   `json:"levels"`
+	BankID  string  `json:"bank_id"`
 }
 
 type QuestionRequest struct {
 	Text   string  `json:"text"`
 	Weight float64 `json:"weight"`
 	Levels []int   `json:"levels"`
+	// BankID is the question bank the question is drawn from, if any.
+	BankID string  `json:"bank_id"`
 }
 
-func GetQuestions(w http.ResponseWriter, r *http.Request) {
-    rows, err := database.GetDB().Query(`
-        SELECT 
-            q.id,
-            q.question_text,
-            q.weight,
-            q.is_active,
-            GROUP_CONCAT(ql.level) as levels
-        FROM survey_questions q
-        LEFT JOIN question_levels ql ON q.id = ql.question_id
-        GROUP BY q.id
-        ORDER BY q.created_at DESC`)
-    
+// GetQuestions lists every question, newest first. With a level it lists
+// that level's questions in the order students are served them. Archived
+// questions are left out unless archived=true.
+func (h *Handlers) GetQuestions(w http.ResponseWriter, r *http.Request) {
+    level := 0
+    if levelStr := r.URL.Query().Get("level"); levelStr != "" {
+        var err error
+        level, err = strconv.Atoi(levelStr)
+        if err != nil {
+            apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid level", err))
+            return
+        }
+    }
+    list, err := h.store.Questions().List(r.Context(), level, r.URL.Query().Get("archived") == "true")
     if err != nil {
-        log.Printf("Database error: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(r.Context(), "listing questions failed", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
-    defer rows.Close()
 
     type Question struct {
         ID       string  `json:"id"`
         Text     string  `json:"text"`
         Weight   float32 `json:"weight"`
         IsActive bool    `json:"is_active"`
+        BankID   string  `json:"bank_id"`
         Levels   []int   `json:"levels"`
+        ArchivedAt *time.Time `json:"archived_at"`
     }
 
     var questions []Question
-    for rows.Next() {
-        var q Question
-        var levelsStr sql.NullString
-        if err := rows.Scan(&q.ID, &q.Text, &q.Weight, &q.IsActive, &levelsStr); err != nil {
-            log.Printf("Error scanning question: %v", err)
-            continue
+    for _, q := range list {
+        question := Question{ID: q.ID, Text: q.Text, Weight: float32(q.Weight), IsActive: q.IsActive, BankID: q.BankID, Levels: q.Levels}
+        if !q.ArchivedAt.IsZero() {
+            question.ArchivedAt = &q.ArchivedAt
         }
-        
-        // Parse levels
-        if levelsStr.Valid {
-            levels := strings.Split(levelsStr.String, ",")
-            for _, l := range levels {
-                if level, err := strconv.Atoi(l); err == nil {
-                    q.Levels = append(q.Levels, level)
-                }
-            }
-        }
-        
-        questions = append(questions, q)
+        questions = append(questions, question)
     }
 
     w.Header().Set("Content-Type", "application/json")
     if err := json.NewEncoder(w).Encode(questions); err != nil {
-        log.Printf("Error encoding response: %v", err)
+        slog.ErrorContext(r.Context(), "encoding response failed", "error", err)
     }
 }
 
-func CreateQuestion(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CreateQuestion(w http.ResponseWriter, r *http.Request) {
 	var req QuestionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
-		return
-	}
-
-	if req.Text == "" || len(req.Levels) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Text and levels are required"})
-		return
-	}
-
-	tx, err := database.GetDB().Begin()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
-	defer tx.Rollback()
 
 	questionID := uuid.New().String()
-	_, err = tx.Exec(`
-		INSERT INTO survey_questions (id, question_text, weight)
-		VALUES (?, ?, ?)`,
-		questionID, req.Text, req.Weight)
-	
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create question"})
-		return
-	}
-
-	for _, level := range req.Levels {
-		_, err = tx.Exec(`
-			INSERT INTO question_levels (question_id, level)
-			VALUES (?, ?)`,
-			questionID, level)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to assign levels"})
-			return
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		if err := checkBank(r.Context(), tx, req.BankID); err != nil {
+			return err
 		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save question"})
+		question := repository.ManagedQuestion{Question: repository.Question{ID: questionID, Text: req.Text, Weight: req.Weight, BankID: req.BankID}}
+		if err := tx.Questions().Create(r.Context(), question); err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to create question", err)
+		}
+		if err := tx.Questions().SetLevels(r.Context(), questionID, req.Levels); err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to assign levels", err)
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -144,11 +110,10 @@ func CreateQuestion(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func UpdateQuestion(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) UpdateQuestion(w http.ResponseWriter, r *http.Request) {
 	questionID := r.URL.Query().Get("id")
 	if questionID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Question ID is required"})
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Question ID is required"))
 		return
 	}
 
@@ -158,106 +123,208 @@ func UpdateQuestion(w http.ResponseWriter, r *http.Request) {
         Weight  *float64 `json:"weight"`
         Levels  []int    `json:"levels"`
         Active  *bool    `json:"is_active"`
+        BankID  *string  `json:"bank_id"`
     }
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid request", err))
         return
     }
 
-    if req.ID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Question ID is required"})
+    var problems apierror.Problems
+    problems.Required("id", req.ID)
+    if req.Text != nil {
+        problems.Required("text", *req.Text)
+    }
+    if req.Weight != nil {
+        problems.Check(*req.Weight > 0, "weight", "must be greater than zero")
+    }
+    if req.Levels != nil {
+        problems.Check(len(req.Levels) > 0, "levels", "must list at least one level")
+        validateLevels(&problems, req.Levels)
+    }
+    if err := problems.Err(); err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
-	tx, err := database.GetDB().Begin()
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		if req.BankID != nil {
+			if err := checkBank(r.Context(), tx, *req.BankID); err != nil {
+				return err
+			}
+		}
+
+		change := repository.QuestionChange{Text: req.Text, Weight: req.Weight, IsActive: req.Active, BankID: req.BankID}
+		if err := tx.Questions().Update(r.Context(), questionID, change); err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to update question", err)
+		}
+
+		// Update levels if provided
+		if req.Levels != nil {
+			if err := tx.Questions().SetLevels(r.Context(), questionID, req.Levels); err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Failed to update levels", err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// DeleteQuestion removes a question. One that students have been asked is
+// archived instead, so the results and reports that refer to it keep
+// working: it leaves the question list and is never served again. With
+// purge=true a used question is refused rather than archived.
+func (h *Handlers) DeleteQuestion(w http.ResponseWriter, r *http.Request) {
+	questionID := r.URL.Query().Get("id")
+	if questionID == "" {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Question ID is required"))
 		return
 	}
-	defer tx.Rollback()
-
-	// Update question fields if provided
-	if req.Text != nil || req.Weight != nil || req.Active != nil {
-		query := "UPDATE survey_questions SET "
-		var args []interface{}
-		
-		if req.Text != nil {
-			query += "question_text = ?, "
-			args = append(args, *req.Text)
+
+	status := "deleted"
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		usage, err := tx.Questions().Usage(r.Context(), questionID)
+		if err == repository.ErrNotFound {
+			return apierror.New(http.StatusNotFound, "Question not found")
 		}
-		if req.Weight != nil {
-			query += "weight = ?, "
-			args = append(args, *req.Weight)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
 		}
-		if req.Active != nil {
-			query += "is_active = ?, "
-			args = append(args, *req.Active)
+
+		if usage.Sessions > 0 {
+			if r.URL.Query().Get("purge") == "true" {
+				return apierror.New(http.StatusConflict, fmt.Sprintf(
+					"Question was asked in %d sessions and has %d responses; it can only be archived",
+					usage.Sessions, usage.Responses))
+			}
+			status = "archived"
+			err = tx.Questions().Archive(r.Context(), questionID)
+		} else {
+			err = tx.Questions().Delete(r.Context(), questionID)
 		}
-		
-		query = query[:len(query)-2] + " WHERE id = ?"
-		args = append(args, questionID)
-		
-		_, err = tx.Exec(query, args...)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update question"})
-			return
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to delete question", err)
 		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
 	}
 
-	// Update levels if provided
-	if req.Levels != nil {
-		// First delete existing level mappings
-		_, err = tx.Exec("DELETE FROM question_levels WHERE question_id = ?", questionID)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update levels"})
-			return
-		}
+	slog.InfoContext(r.Context(), "removed question", "id", questionID, "status", status)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
 
-		// Add new level mappings
-		for _, level := range req.Levels {
-			_, err = tx.Exec(`
-				INSERT INTO question_levels (question_id, level)
-				VALUES (?, ?)`,
-				questionID, level)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update levels"})
-				return
-			}
-		}
+// QuestionUsage is how much a question has been used.
+type QuestionUsage struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	// Sessions counts the sessions the question was drawn for or answered in.
+	Sessions int `json:"sessions"`
+	// Responses counts the students who answered it, once per session.
+	Responses  int        `json:"responses"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ArchivedAt *time.Time `json:"archived_at"`
+}
+
+func questionUsage(u repository.QuestionUsage) QuestionUsage {
+	usage := QuestionUsage{ID: u.ID, Text: u.Text, Sessions: u.Sessions, Responses: u.Responses}
+	if !u.LastUsedAt.IsZero() {
+		usage.LastUsedAt = &u.LastUsedAt
 	}
+	if !u.ArchivedAt.IsZero() {
+		usage.ArchivedAt = &u.ArchivedAt
+	}
+	return usage
+}
 
-	if err := tx.Commit(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update question"})
+// GetQuestionUsage lists every question, archived ones included, with how
+// many sessions and responses it has, most used first.
+func (h *Handlers) GetQuestionUsage(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.Questions().UsageList(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "listing question usage failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
 		return
 	}
 
+	usage := []QuestionUsage{}
+	for _, u := range list {
+		usage = append(usage, questionUsage(u))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(usage)
 }
 
-func DeleteQuestion(w http.ResponseWriter, r *http.Request) {
-	questionID := r.URL.Query().Get("id")
-	if questionID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Question ID is required"})
+// checkBank returns a 400 unless bankID is empty or names a question bank.
+func checkBank(ctx context.Context, store repository.AdminStore, bankID string) error {
+	if bankID == "" {
+		return nil
+	}
+	exists, err := store.Banks().Exists(ctx, bankID)
+	if err != nil {
+		return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+	}
+	var problems apierror.Problems
+	problems.Check(exists, "bank_id", "is not a question bank")
+	return problems.Err()
+}
+
+// QuestionOrder is the order a level's questions are served in.
+type QuestionOrder struct {
+	Level int `json:"level"`
+	// QuestionIDs lists every question of the level, first to last.
+	QuestionIDs []string `json:"question_ids"`
+}
+
+// ReorderQuestions sets the order of a level's questions. Sessions whose
+// students were already served keep the order they saw.
+func (h *Handlers) ReorderQuestions(w http.ResponseWriter, r *http.Request) {
+	var req QuestionOrder
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	_, err := database.GetDB().Exec("DELETE FROM survey_questions WHERE id = ?", questionID)
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		ids, err := tx.Questions().LevelIDs(r.Context(), req.Level)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		mapped := map[string]bool{}
+		for _, id := range ids {
+			mapped[id] = true
+		}
+		var problems apierror.Problems
+		for i, id := range req.QuestionIDs {
+			problems.Check(mapped[id], fmt.Sprintf("question_ids[%d]", i), fmt.Sprintf("is not a question of level %d", req.Level))
+		}
+		problems.Check(len(req.QuestionIDs) == len(mapped), "question_ids",
+			fmt.Sprintf("must list all %d questions of level %d", len(mapped), req.Level))
+		if err := problems.Err(); err != nil {
+			return err
+		}
+
+		if err := tx.Questions().SetOrder(r.Context(), req.Level, req.QuestionIDs); err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to reorder questions", err)
+		}
+		return nil
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete question"})
+		apierror.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
-}
\ No newline at end of file
+	json.NewEncoder(w).Encode(req)
+}
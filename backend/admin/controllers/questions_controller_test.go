@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gd/database/dbtest"
+	"gd/repository"
+)
+
+// scriptStore returns handlers whose store runs against a scripted
+// database.
+func scriptStore(t *testing.T) (*Handlers, *dbtest.DB) {
+	t.Helper()
+	db, script := dbtest.Open()
+	t.Cleanup(func() { db.Close() })
+	return NewHandlers(repository.NewMySQLAdminStore(db), nil), script
+}
+
+var usageColumns = []string{"id", "question_text", "archived_at", "sessions", "responses", "last_used_at"}
+
+func TestDeleteQuestion(t *testing.T) {
+	const (
+		archive = "UPDATE survey_questions SET is_active = FALSE, archived_at"
+		remove  = "DELETE FROM survey_questions"
+	)
+	tests := []struct {
+		name       string
+		target     string
+		usage      []any // nil when the question doesn't exist
+		wantCode   int
+		wantStatus string
+		wantRan    string
+	}{
+		{"unused is deleted", "/admin/questions?id=q1", []any{"q1", "Clarity", nil, 0, 0, nil}, http.StatusOK, "deleted", remove},
+		{"served is archived", "/admin/questions?id=q1", []any{"q1", "Clarity", nil, 1, 0, nil}, http.StatusOK, "archived", archive},
+		{"answered is archived", "/admin/questions?id=q1", []any{"q1", "Clarity", nil, 3, 12, nil}, http.StatusOK, "archived", archive},
+		{"answered is never purged", "/admin/questions?id=q1&purge=true", []any{"q1", "Clarity", nil, 3, 12, nil}, http.StatusConflict, "", ""},
+		{"missing", "/admin/questions?id=q9", nil, http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := scriptStore(t)
+			if tt.usage != nil {
+				db.Rows("FROM survey_questions q", usageColumns, tt.usage)
+			}
+			w := httptest.NewRecorder()
+			h.DeleteQuestion(w, httptest.NewRequest("DELETE", tt.target, nil))
+
+			var out map[string]string
+			json.Unmarshal(w.Body.Bytes(), &out)
+			if w.Code != tt.wantCode || out["status"] != tt.wantStatus {
+				t.Fatalf("got %d %v, want %d %q", w.Code, out, tt.wantCode, tt.wantStatus)
+			}
+			for _, stmt := range []string{archive, remove} {
+				if got := db.Ran(stmt); got != (stmt == tt.wantRan) {
+					t.Errorf("ran %q = %v, want %v", stmt, got, !got)
+				}
+			}
+			if committed := db.Ran("COMMIT"); committed != (tt.wantRan != "") {
+				t.Errorf("committed = %v", committed)
+			}
+		})
+	}
+}
+
+func TestGetQuestionUsage(t *testing.T) {
+	h, db := scriptStore(t)
+	db.Rows("FROM survey_questions q", usageColumns,
+		[]any{"q1", "Clarity", nil, 3, 12, nil},
+		[]any{"q2", "Leadership", nil, 0, 0, nil})
+	w := httptest.NewRecorder()
+	h.GetQuestionUsage(w, httptest.NewRequest("GET", "/api/v1/admin/question-usage", nil))
+
+	var usage []QuestionUsage
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if len(usage) != 2 || usage[0].Sessions != 3 || usage[0].Responses != 12 || usage[1].Sessions != 0 {
+		t.Errorf("usage = %+v, want q1 with 3 sessions and 12 responses, then unused q2", usage)
+	}
+	if !db.Ran("ORDER BY sessions DESC, responses DESC") {
+		t.Errorf("usage not listed most used first: %v", db.Statements())
+	}
+}
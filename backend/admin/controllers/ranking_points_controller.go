@@ -1,120 +1,183 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
-	"gd/database"
-	"log"
+	"gd/apierror"
+	"gd/repository"
+	"gd/scoring"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// maxRankingDepth caps the points array: groups have at most 13 members, so
+// nobody ranks more than 12 others.
+const maxRankingDepth = 12
+
+// RankingPointsConfig is one version of a level's ranking points. Versions
+// are immutable: saving a change adds the next version, and a session keeps
+// the version it was first scored with. At most one version per level is
+// active.
 type RankingPointsConfig struct {
-	ID                string  `json:"id"`
-	FirstPlacePoints  float64 `json:"first_place_points"`
-	SecondPlacePoints float64 `json:"second_place_points"`
-	ThirdPlacePoints  float64 `json:"third_place_points"`
-	Level             int     `json:"level"`
-	IsActive          bool    `json:"is_active"`
+	ID string `json:"id"`
+	// Points awards each rank, best first; its length is how many students
+	// a responder ranks. When it is omitted the three place fields are used.
+	Points            []float64 `json:"points"`
+	FirstPlacePoints  float64   `json:"first_place_points"`
+	SecondPlacePoints float64   `json:"second_place_points"`
+	ThirdPlacePoints  float64   `json:"third_place_points"`
+	Level             int       `json:"level"`
+	IsActive          bool      `json:"is_active"`
+	// ConsensusMethod names the gd/scoring strategy used to judge peer
+	// rankings at this level.
+	ConsensusMethod string `json:"consensus_method"`
+	// Version numbers the configurations of a level, starting at 1.
+	Version int `json:"version"`
+	// EffectiveFrom is when this version replaces the level's active one.
+	// Omitted or past means immediately; a future version waits for
+	// ActivateDueRankingConfigs.
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// rankPoints returns Points, or the three place fields when it is empty.
+func (c RankingPointsConfig) rankPoints() []float64 {
+	if len(c.Points) > 0 {
+		return c.Points
+	}
+	return []float64{c.FirstPlacePoints, c.SecondPlacePoints, c.ThirdPlacePoints}
+}
+
+// normalizePoints fills Points from the three place fields when it was not
+// given, and the place fields from Points so older clients keep working.
+func (c *RankingPointsConfig) normalizePoints() {
+	c.Points = c.rankPoints()
+	places := []*float64{&c.FirstPlacePoints, &c.SecondPlacePoints, &c.ThirdPlacePoints}
+	for i, p := range places {
+		*p = 0
+		if i < len(c.Points) {
+			*p = c.Points[i]
+		}
+	}
+}
+
+// rankingPointsConfig is the response for a stored configuration.
+func rankingPointsConfig(c repository.RankingConfig) RankingPointsConfig {
+	config := RankingPointsConfig{
+		ID:              c.ID,
+		Points:          c.Points,
+		Level:           c.Level,
+		IsActive:        c.IsActive,
+		ConsensusMethod: c.Method,
+		Version:         c.Version,
+		EffectiveFrom:   c.EffectiveFrom,
+	}
+	config.normalizePoints()
+	return config
 }
 
 // Get all configurations or specific level
-func GetRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
 	levelStr := r.URL.Query().Get("level")
 	id := r.URL.Query().Get("id")
 	
-	var query string
-	var args []interface{}
+	var list []repository.RankingConfig
+	var err error
 
 	if id != "" {
 		// Get specific config by ID
-		query = "SELECT id, first_place_points, second_place_points, third_place_points, level, is_active FROM ranking_points_config WHERE id = ?"
-		args = []interface{}{id}
-	} else if levelStr != "" {
-		// Get config for specific level
-		level, err := strconv.Atoi(levelStr)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level"})
-			return
+		var c repository.RankingConfig
+		c, err = h.store.RankingConfigs().Get(r.Context(), id)
+		if err == nil {
+			list = append(list, c)
+		} else if err == repository.ErrNotFound {
+			err = nil
 		}
-		query = "SELECT id, first_place_points, second_place_points, third_place_points, level, is_active FROM ranking_points_config WHERE level = ? ORDER BY created_at DESC"
-		args = []interface{}{level}
 	} else {
-		// Get all configurations
-		query = "SELECT id, first_place_points, second_place_points, third_place_points, level, is_active FROM ranking_points_config ORDER BY level, created_at DESC"
+		// Get config for specific level, or all configurations
+		level := 0
+		if levelStr != "" {
+			level, err = strconv.Atoi(levelStr)
+			if err != nil {
+				apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid level", err))
+				return
+			}
+		}
+		list, err = h.store.RankingConfigs().List(r.Context(), level)
 	}
-
-	rows, err := database.GetDB().Query(query, args...)
 	if err != nil {
-		log.Printf("Database error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		slog.ErrorContext(r.Context(), "listing ranking points configs failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
 		return
 	}
-	defer rows.Close()
 
 	var configs []RankingPointsConfig
-	for rows.Next() {
-		var config RankingPointsConfig
-		if err := rows.Scan(&config.ID, &config.FirstPlacePoints, &config.SecondPlacePoints, &config.ThirdPlacePoints, &config.Level, &config.IsActive); err != nil {
-			log.Printf("Error scanning config: %v", err)
-			continue
-		}
-		configs = append(configs, config)
+	for _, c := range list {
+		configs = append(configs, rankingPointsConfig(c))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(configs)
 }
 
-// Create or update configuration
-func UpdateRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
+// UpdateRankingPointsConfig saves a new configuration version. Without an ID
+// it starts or continues the body's level; with one it supersedes that
+// configuration, keeping its level. Existing versions are never changed.
+func (h *Handlers) UpdateRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
 	var config RankingPointsConfig
-	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+	if err := apierror.Decode(r, &config); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	// Validate points
-	if config.FirstPlacePoints <= 0 || config.SecondPlacePoints <= 0 || config.ThirdPlacePoints <= 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Points must be positive values"})
-		return
+	config.normalizePoints()
+	if config.ConsensusMethod == "" {
+		config.ConsensusMethod = scoring.Default
 	}
-
-	if config.FirstPlacePoints <= config.SecondPlacePoints || config.SecondPlacePoints <= config.ThirdPlacePoints {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Points must be in descending order (1st > 2nd > 3rd)"})
-		return
+	now := time.Now()
+	if config.EffectiveFrom.IsZero() {
+		config.EffectiveFrom = now
 	}
+	config.IsActive = !config.EffectiveFrom.After(now)
 
 	userID := r.Context().Value("userID").(string)
-	var err error
 
-	if config.ID == "" {
-		// Create new config
-		config.ID = uuid.New().String()
-		_, err = database.GetDB().Exec(`
-			INSERT INTO ranking_points_config 
-			(id, first_place_points, second_place_points, third_place_points, level, is_active, created_by)
-			VALUES (?, ?, ?, ?, ?, TRUE, ?)`,
-			config.ID, config.FirstPlacePoints, config.SecondPlacePoints, config.ThirdPlacePoints, config.Level, userID)
-	} else {
-		// Update existing config
-		_, err = database.GetDB().Exec(`
-			UPDATE ranking_points_config 
-			SET first_place_points = ?, second_place_points = ?, third_place_points = ?, level = ?, updated_at = NOW()
-			WHERE id = ?`,
-			config.FirstPlacePoints, config.SecondPlacePoints, config.ThirdPlacePoints, config.Level, config.ID)
-	}
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		if config.ID != "" {
+			previous, err := tx.RankingConfigs().Get(r.Context(), config.ID)
+			if err == repository.ErrNotFound {
+				return apierror.New(http.StatusNotFound, "Configuration not found")
+			}
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+			}
+			config.Level = previous.Level
+		}
 
+		var err error
+		config.Version, err = tx.RankingConfigs().NextVersion(r.Context(), config.Level)
+		if err == nil {
+			config.ID = uuid.New().String()
+			err = tx.RankingConfigs().Create(r.Context(), repository.RankingConfig{
+				ScoringConfig: repository.ScoringConfig{
+					ID: config.ID, Level: config.Level, Version: config.Version, Points: config.Points,
+					Method: config.ConsensusMethod, EffectiveFrom: config.EffectiveFrom,
+				},
+			}, userID)
+		}
+		if err == nil && config.IsActive {
+			err = tx.RankingConfigs().SetActive(r.Context(), config.ID, true)
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to save configuration", err)
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("Database error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save configuration"})
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -125,41 +188,73 @@ func UpdateRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// setRankingConfigActive switches a configuration on, replacing its level's
+// active one, or off, leaving the level on the built-in defaults. It returns
+// repository.ErrNotFound when the configuration does not exist.
+func (h *Handlers) setRankingConfigActive(ctx context.Context, id string, active bool) error {
+	return h.store.InTx(ctx, func(tx repository.AdminStore) error {
+		return tx.RankingConfigs().SetActive(ctx, id, active)
+	})
+}
+
+// ActivateDueRankingConfigs activates versions whose effective date has
+// passed. Each version is activated once; an admin may switch it off again
+// afterwards without the job undoing that. When several versions of a level
+// fall due together the newest wins.
+func (h *Handlers) ActivateDueRankingConfigs(ctx context.Context) error {
+	configs, err := h.store.RankingConfigs().Due(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, c := range configs {
+		if err := h.setRankingConfigActive(ctx, c.ID, true); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "activated ranking points config", "id", c.ID, "level", c.Level)
+	}
+	return nil
+}
+
 // Delete configuration
-func DeleteRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) DeleteRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Configuration ID is required"})
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Configuration ID is required"))
 		return
 	}
 
 	// Check if config exists
-	var exists bool
-	err := database.GetDB().QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM ranking_points_config WHERE id = ?)",
-		id,
-	).Scan(&exists)
+	_, err := h.store.RankingConfigs().Get(r.Context(), id)
+
+	if err == repository.ErrNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Configuration not found"))
+		return
+	}
 
 	if err != nil {
-		log.Printf("Database error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		slog.ErrorContext(r.Context(), "loading ranking points config failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
 		return
 	}
 
-	if !exists {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Configuration not found"})
+	// Versions that scored a session are kept so the result can be explained.
+	used, err := h.store.RankingConfigs().Used(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "checking ranking points config usage failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+	if used {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, "Configuration has scored sessions; deactivate it instead"))
 		return
 	}
 
 	// Delete the configuration
-	_, err = database.GetDB().Exec("DELETE FROM ranking_points_config WHERE id = ?", id)
+	err = h.store.RankingConfigs().Delete(r.Context(), id)
 	if err != nil {
-		log.Printf("Database error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete configuration"})
+		slog.ErrorContext(r.Context(), "deleting ranking points config failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to delete configuration"))
 		return
 	}
 
@@ -168,37 +263,28 @@ func DeleteRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 // Toggle configuration active status
-func ToggleRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) ToggleRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Configuration ID is required"})
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Configuration ID is required"))
 		return
 	}
 
-	var isActive bool
-	err := database.GetDB().QueryRow(
-		"SELECT is_active FROM ranking_points_config WHERE id = ?",
-		id,
-	).Scan(&isActive)
+	config, err := h.store.RankingConfigs().Get(r.Context(), id)
 
 	if err != nil {
-		log.Printf("Database error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		slog.ErrorContext(r.Context(), "loading ranking points config failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
 		return
 	}
+	isActive := config.IsActive
 
 	// Toggle the active status
-	_, err = database.GetDB().Exec(
-		"UPDATE ranking_points_config SET is_active = ?, updated_at = NOW() WHERE id = ?",
-		!isActive, id,
-	)
+	err = h.setRankingConfigActive(r.Context(), id, !isActive)
 
 	if err != nil {
-		log.Printf("Database error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update configuration"})
+		slog.ErrorContext(r.Context(), "toggling ranking points config failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to update configuration"))
 		return
 	}
 
@@ -207,4 +293,40 @@ func ToggleRankingPointsConfig(w http.ResponseWriter, r *http.Request) {
 		"status": "success",
 		"is_active": !isActive,
 	})
-}
\ No newline at end of file
+}
+// SetRankingPointsConfigActive sets a configuration's active flag explicitly;
+// it replaces the toggle endpoint, which two concurrent clicks could undo.
+func (h *Handlers) SetRankingPointsConfigActive(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID       string `json:"id"`
+		IsActive *bool  `json:"is_active"`
+	}
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	var problems apierror.Problems
+	problems.Required("id", req.ID)
+	problems.Check(req.IsActive != nil, "is_active", "is required")
+	if err := problems.Err(); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	err := h.setRankingConfigActive(r.Context(), req.ID, *req.IsActive)
+	if err == repository.ErrNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Configuration not found"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "updating ranking points config failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to update configuration"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"is_active": *req.IsActive,
+	})
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gd/apierror"
+)
+
+func TestDecodeRankingPointsUpdateWithoutLevel(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/api/v1/admin/ranking-points/cfg1", strings.NewReader(`{"points":[5,3,1]}`))
+	r.SetPathValue("id", "cfg1")
+	var config RankingPointsConfig
+	if err := apierror.Decode(r, &config); err != nil {
+		t.Fatalf("update without a level rejected: %v", err)
+	}
+	if config.ID != "cfg1" || config.Level != 0 {
+		t.Errorf("config = %+v, want ID cfg1 and the level left to the stored one", config)
+	}
+}
+
+func TestSetRankingPointsConfigActive(t *testing.T) {
+	const replace = "WHERE level = ? AND id <> ? AND is_active = TRUE"
+	tests := []struct {
+		name     string
+		exists   bool
+		wantCode int
+	}{
+		{"activated", true, http.StatusOK},
+		{"missing", false, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := scriptStore(t)
+			if tt.exists {
+				db.Rows("SELECT level FROM ranking_points_config", []string{"level"}, []any{int64(2)})
+			}
+
+			r := httptest.NewRequest("PUT", "/api/v1/admin/ranking-points/cfg1/active", strings.NewReader(`{"is_active": true}`))
+			r.SetPathValue("id", "cfg1")
+			w := httptest.NewRecorder()
+			h.SetRankingPointsConfigActive(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			// The level's active version is switched off in the same
+			// transaction that switches this one on.
+			if db.Ran(replace) != tt.exists || db.Ran("COMMIT") != tt.exists {
+				t.Errorf("statements = %q", db.Statements())
+			}
+		})
+	}
+}
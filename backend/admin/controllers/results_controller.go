@@ -4,12 +4,12 @@ import (
 	// "database/sql"
 	"encoding/json"
 	"net/http"
-	"gd/database"
-	"log"
+	"gd/apierror"
+	"log/slog"
 	"strconv"
 )
 
-func GetTopParticipants(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetTopParticipants(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json")
     
     levelStr := r.URL.Query().Get("level")
@@ -19,44 +19,17 @@ func GetTopParticipants(w http.ResponseWriter, r *http.Request) {
     if levelStr != "" {
         level, err = strconv.Atoi(levelStr)
         if err != nil {
-            w.WriteHeader(http.StatusBadRequest)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level parameter"})
+            apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid level parameter", err))
             return
         }
     }
 
-    query := `
-        SELECT 
-            sr.responder_id as id,
-            su.full_name as name,
-            su.current_gd_level as level,
-            COUNT(DISTINCT sr.session_id) as session_count,
-            SUM(sr.score) as total_score,
-            AVG(sr.score) as avg_score
-        FROM survey_results sr
-        JOIN student_users su ON sr.responder_id = su.id
-        LEFT JOIN gd_sessions s ON sr.session_id = s.id
-        WHERE su.is_active = TRUE
-    `
-
-    if level > 0 {
-        query += " AND su.current_gd_level = " + strconv.Itoa(level)  // Filter by student's level
-    }
-
-    query += `
-        GROUP BY sr.responder_id, su.full_name, su.current_gd_level
-        ORDER BY total_score DESC
-        LIMIT 20
-    `
-
-    rows, err := database.GetDB().Query(query)
+    standings, err := h.store.Reports().TopParticipants(r.Context(), level, 20)
     if err != nil {
-        log.Printf("Error fetching top participants: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(r.Context(), "fetching top participants failed", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
-    defer rows.Close()
 
     type TopParticipant struct {
         ID           string  `json:"id"`
@@ -68,13 +41,15 @@ func GetTopParticipants(w http.ResponseWriter, r *http.Request) {
     }
 
     var results []TopParticipant
-    for rows.Next() {
-        var r TopParticipant
-        if err := rows.Scan(&r.ID, &r.Name, &r.Level, &r.SessionCount, &r.TotalScore, &r.AvgScore); err != nil {
-            log.Printf("Error scanning result: %v", err)
-            continue
-        }
-        results = append(results, r)
+    for _, s := range standings {
+        results = append(results, TopParticipant{
+            ID:           s.StudentID,
+            Name:         s.Name,
+            Level:        s.Level,
+            SessionCount: s.Sessions,
+            TotalScore:   s.TotalScore,
+            AvgScore:     s.AverageScore,
+        })
     }
 
     json.NewEncoder(w).Encode(map[string]interface{}{
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"gd/apierror"
+	"gd/repository"
+
+	"github.com/google/uuid"
+)
+
+// Rubric is a level's moderator rubric: the criteria staff mark
+// participants on, and how much those marks count towards the final score.
+type Rubric struct {
+	Level int `json:"level"`
+	// ModeratorWeight is the share of the final score taken from moderator
+	// marks, from 0 (peer ranking only) to 1 (moderators only). It applies to
+	// sessions where at least one participant was marked.
+	ModeratorWeight float64           `json:"moderator_weight"`
+	Criteria        []RubricCriterion `json:"criteria"`
+}
+
+// RubricCriterion is one thing moderators mark, such as leadership. A
+// criterion without an ID is created; one left out of an update is retired
+// but kept for the sessions already marked on it.
+type RubricCriterion struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Weight   float64 `json:"weight"`
+	MaxScore float64 `json:"max_score"`
+}
+
+// RubricScoreRequest marks one participant of a session.
+type RubricScoreRequest struct {
+	SessionID string `json:"session_id"`
+	StudentID string `json:"student_id"`
+	// Scores maps criterion ID to a mark between 0 and its max_score.
+	// Criteria not listed keep their previous mark.
+	Scores   map[string]float64 `json:"scores"`
+	Feedback string             `json:"feedback"`
+}
+
+// ModeratorReview is what moderators recorded for one participant.
+type ModeratorReview struct {
+	StudentID string             `json:"student_id"`
+	Name      string             `json:"name"`
+	Scores    map[string]float64 `json:"scores"`
+	Feedback  string             `json:"feedback"`
+}
+
+// loadRubric returns a level's rubric and its current criteria. A level
+// that was never configured has weight 0 and no criteria.
+func loadRubric(ctx context.Context, store repository.AdminStore, level int) (Rubric, error) {
+	rubric := Rubric{Level: level, Criteria: []RubricCriterion{}}
+	stored, err := store.Rubrics().Get(ctx, level)
+	if err != nil {
+		return rubric, err
+	}
+	rubric.ModeratorWeight = stored.ModeratorWeight
+	criteria, err := store.Rubrics().Criteria(ctx, level)
+	for _, c := range criteria {
+		rubric.Criteria = append(rubric.Criteria, RubricCriterion{ID: c.ID, Name: c.Name, Weight: c.Weight, MaxScore: c.MaxScore})
+	}
+	return rubric, err
+}
+
+// GetRubric returns the moderator rubric for the level in the path.
+func (h *Handlers) GetRubric(w http.ResponseWriter, r *http.Request) {
+	level, err := strconv.Atoi(r.URL.Query().Get("level"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid level", err))
+		return
+	}
+	rubric, err := loadRubric(r.Context(), h.store, level)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "loading rubric failed", "level", level, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rubric)
+}
+
+// UpdateRubric replaces a level's rubric. Criteria are matched by ID, so
+// renaming or reweighting one keeps the marks already given on it.
+func (h *Handlers) UpdateRubric(w http.ResponseWriter, r *http.Request) {
+	var req Rubric
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		known, err := tx.Rubrics().CriterionIDs(r.Context(), req.Level)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		var problems apierror.Problems
+		for i, c := range req.Criteria {
+			problems.Check(c.ID == "" || slices.Contains(known, c.ID), fmt.Sprintf("criteria[%d].id", i), "is not a criterion of this level")
+		}
+		if err := problems.Err(); err != nil {
+			return err
+		}
+
+		err = tx.Rubrics().Save(r.Context(), repository.Rubric{Level: req.Level, ModeratorWeight: req.ModeratorWeight})
+		if err == nil {
+			err = tx.Rubrics().Retire(r.Context(), req.Level)
+		}
+		for i := range req.Criteria {
+			if err != nil {
+				break
+			}
+			c := &req.Criteria[i]
+			criterion := repository.RubricCriterion{ID: c.ID, Name: c.Name, Weight: c.Weight, MaxScore: c.MaxScore}
+			if c.ID == "" {
+				c.ID = uuid.New().String()
+				criterion.ID = c.ID
+				err = tx.Rubrics().AddCriterion(r.Context(), req.Level, i, criterion)
+			} else {
+				err = tx.Rubrics().UpdateCriterion(r.Context(), i, criterion)
+			}
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to save rubric", err)
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if req.Criteria == nil {
+		req.Criteria = []RubricCriterion{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// ScoreParticipant records a moderator's marks and feedback for one
+// participant. Marks can be given during or after the discussion, up to
+// the moment the session's results are finalized.
+func (h *Handlers) ScoreParticipant(w http.ResponseWriter, r *http.Request) {
+	var req RubricScoreRequest
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	moderatorID := r.Context().Value("userID").(string)
+
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		level, finalized, err := tx.Rubrics().MarkingSession(r.Context(), req.SessionID)
+		if err == repository.ErrNotFound {
+			return apierror.New(http.StatusNotFound, "Session not found")
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		if finalized {
+			return apierror.New(http.StatusConflict, "Session results are already final")
+		}
+
+		isParticipant, err := tx.Rubrics().Participant(r.Context(), req.SessionID, req.StudentID)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		if !isParticipant {
+			return apierror.New(http.StatusNotFound, "Student is not a participant of the session")
+		}
+
+		rubric, err := loadRubric(r.Context(), tx, level)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		maxScores := make(map[string]float64, len(rubric.Criteria))
+		for _, c := range rubric.Criteria {
+			maxScores[c.ID] = c.MaxScore
+		}
+		var problems apierror.Problems
+		for id, score := range req.Scores {
+			field := "scores." + id
+			limit, ok := maxScores[id]
+			problems.Check(ok, field, fmt.Sprintf("is not a criterion of level %d", level))
+			problems.Check(!ok || score <= limit, field, fmt.Sprintf("must be at most %g", limit))
+		}
+		if err := problems.Err(); err != nil {
+			return err
+		}
+
+		for id, score := range req.Scores {
+			if err = tx.Rubrics().SaveMark(r.Context(), req.SessionID, req.StudentID, id, score, moderatorID); err != nil {
+				break
+			}
+		}
+		if err == nil && req.Feedback != "" {
+			err = tx.Rubrics().SaveFeedback(r.Context(), req.SessionID, req.StudentID, req.Feedback, moderatorID)
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to save scores", err)
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// GetRubricScores lists every participant of a session with the marks and
+// feedback moderators have recorded so far.
+func (h *Handlers) GetRubricScores(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	list, err := h.store.Rubrics().Reviews(r.Context(), sessionID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "listing moderator scores failed", "session_id", sessionID, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+
+	reviews := []ModeratorReview{}
+	for _, m := range list {
+		reviews = append(reviews, ModeratorReview{StudentID: m.StudentID, Name: m.Name, Scores: m.Scores, Feedback: m.Feedback})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reviews)
+}
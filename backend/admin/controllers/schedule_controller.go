@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gd/admin/models"
+	"gd/apierror"
+	"gd/repository"
+
+	"github.com/google/uuid"
+)
+
+// maxGenerationDays bounds the dates one GenerateSessions call covers.
+const maxGenerationDays = 92
+
+// SessionGeneration asks for sessions to be generated from the
+// availability of venues.
+type SessionGeneration struct {
+	// VenueIDs limits generation to some venues. By default every active
+	// venue with availability is used.
+	VenueIDs []string `json:"venue_ids"`
+	// From and To are the first and last dates, as YYYY-MM-DD.
+	From          string                 `json:"from"`
+	To            string                 `json:"to"`
+	Agenda        map[string]interface{} `json:"agenda"`
+	SurveyWeights map[string]float64     `json:"survey_weights"`
+}
+
+// GeneratedSessions lists what GenerateSessions created, or with dry_run
+// would create, and what it left out.
+type GeneratedSessions struct {
+	DryRun   bool               `json:"dry_run"`
+	Sessions []GeneratedSession `json:"sessions"`
+	Skipped  []SkippedSlot      `json:"skipped"`
+	Closures []VenueClosure     `json:"closures"`
+}
+
+type GeneratedSession struct {
+	// ID is empty on a dry run.
+	ID        string    `json:"id"`
+	VenueID   string    `json:"venue_id"`
+	Level     int       `json:"level"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// SkippedSlot is a slot no session was generated for.
+type SkippedSlot struct {
+	VenueID   string    `json:"venue_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+// VenueClosure is a date a venue would have had slots on, had it not been
+// a holiday or one of its blackout dates.
+type VenueClosure struct {
+	VenueID string `json:"venue_id"`
+	Date    string `json:"date"`
+	Reason  string `json:"reason"`
+}
+
+// Holiday is a date no sessions are generated on at any venue.
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// SetVenueAvailability replaces the availability sessions are generated from
+// at a venue. Sessions already generated are unchanged.
+func (h *Handlers) SetVenueAvailability(w http.ResponseWriter, r *http.Request) {
+	var availability models.VenueAvailability
+	if err := apierror.Decode(r, &availability); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	venueID := r.URL.Query().Get("venue_id")
+	availabilityJSON, err := json.Marshal(availability)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to process availability", err))
+		return
+	}
+
+	err = h.store.Venues().SetAvailability(r.Context(), venueID, availabilityJSON)
+	if err == repository.ErrNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Venue not found"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "saving venue availability failed", "venue_id", venueID, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to save availability"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(availability)
+}
+
+// GenerateSessions creates a pending session for every slot of the venues'
+// availability from one date to another. Slots on holidays and blackout
+// dates, in the past, or overlapping a session already at the venue are
+// skipped, so generating a range twice adds nothing. With dry_run=true
+// nothing is created and the response previews what would be.
+func (h *Handlers) GenerateSessions(w http.ResponseWriter, r *http.Request) {
+	var req SessionGeneration
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	from, _ := time.Parse(models.DateLayout, req.From)
+	to, _ := time.Parse(models.DateLayout, req.To)
+	if req.Agenda == nil {
+		req.Agenda = map[string]interface{}{}
+	}
+	agendaJSON, err := json.Marshal(req.Agenda)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to marshal agenda", err))
+		return
+	}
+	if req.SurveyWeights == nil {
+		req.SurveyWeights = map[string]float64{}
+	}
+	surveyWeightsJSON, err := json.Marshal(req.SurveyWeights)
+	if err != nil {
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to marshal survey weights", err))
+		return
+	}
+
+	result := GeneratedSessions{
+		DryRun:   r.URL.Query().Get("dry_run") == "true",
+		Sessions: []GeneratedSession{},
+		Skipped:  []SkippedSlot{},
+		Closures: []VenueClosure{},
+	}
+	err = h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		var problems apierror.Problems
+		venues, err := scheduledVenues(r.Context(), tx, req.VenueIDs, &problems)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		if err := problems.Err(); err != nil {
+			return err
+		}
+		holidays, err := tx.Schedule().HolidaysBetween(r.Context(), from, to)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		closedOn := map[string]string{}
+		for _, h := range holidays {
+			closedOn[h.Date] = h.Name
+		}
+		closed := func(date string) string {
+			if name, ok := closedOn[date]; ok {
+				return "holiday: " + name
+			}
+			return ""
+		}
+
+		now := time.Now()
+		for _, v := range venues {
+			slots, closures := v.availability.Slots(from, to, closed)
+			for _, c := range closures {
+				result.Closures = append(result.Closures, VenueClosure{VenueID: v.id, Date: c.Date, Reason: c.Reason})
+			}
+			if len(slots) == 0 {
+				continue
+			}
+			booked, err := tx.Schedule().Booked(r.Context(), v.id, slots[0].Start, slots[len(slots)-1].End)
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+			}
+
+			for _, slot := range slots {
+				reason := ""
+				if slot.Start.Before(now) {
+					reason = "in the past"
+				}
+				for _, b := range booked {
+					if reason == "" && overlaps(slot.Start, slot.End, b.StartTime, b.EndTime) {
+						reason = "overlaps session " + b.ID
+					}
+				}
+				if reason != "" {
+					result.Skipped = append(result.Skipped, SkippedSlot{VenueID: v.id, StartTime: slot.Start, EndTime: slot.End, Reason: reason})
+					continue
+				}
+
+				session := GeneratedSession{VenueID: v.id, Level: v.level, StartTime: slot.Start, EndTime: slot.End}
+				if !result.DryRun {
+					session.ID = uuid.New().String()
+					err := tx.Schedule().Create(r.Context(), repository.NewSession{
+						ID: session.ID, VenueID: v.id, Level: v.level, StartTime: slot.Start, EndTime: slot.End,
+						Agenda: agendaJSON, SurveyWeights: surveyWeightsJSON, MaxCapacity: v.capacity,
+					})
+					if err != nil {
+						return apierror.Wrap(http.StatusInternalServerError, "Failed to create sessions", err)
+					}
+				}
+				result.Sessions = append(result.Sessions, session)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if !result.DryRun {
+		slog.InfoContext(r.Context(), "generated sessions", "from", req.From, "to", req.To,
+			"created", len(result.Sessions), "skipped", len(result.Skipped))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// scheduledVenue is a venue sessions are generated at.
+type scheduledVenue struct {
+	id, name     string
+	level        int
+	capacity     int
+	availability models.VenueAvailability
+}
+
+// scheduledVenues loads the active venues with availability, or those of
+// ids, recording the ids that are not such a venue.
+func scheduledVenues(ctx context.Context, store repository.AdminStore, ids []string, p *apierror.Problems) ([]scheduledVenue, error) {
+	all, err := store.Venues().Scheduled(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var venues []scheduledVenue
+	for _, sv := range all {
+		if len(ids) > 0 && !slices.Contains(ids, sv.ID) {
+			continue
+		}
+		v := scheduledVenue{id: sv.ID, name: sv.Name, level: sv.Level, capacity: sv.Capacity}
+		if err := json.Unmarshal(sv.Availability, &v.availability); err == nil {
+			err = v.availability.Validate()
+		}
+		if err != nil {
+			p.Add("venue_ids", fmt.Sprintf("venue %s has invalid availability: %v", v.name, err))
+			continue
+		}
+		venues = append(venues, v)
+	}
+
+	for i, id := range ids {
+		p.Check(slices.ContainsFunc(venues, func(v scheduledVenue) bool { return v.id == id }),
+			fmt.Sprintf("venue_ids[%d]", i), "is not an active venue with availability")
+	}
+	if len(ids) == 0 && len(venues) == 0 {
+		p.Add("venue_ids", "no active venue has availability")
+	}
+	return venues, nil
+}
+
+// checkSessionConflicts records against sessions[i] a venue that is not
+// active or is of another level in p, and, in conflicts, an overlap with a
+// session already at the venue or an earlier one of sessions.
+func checkSessionConflicts(ctx context.Context, store repository.AdminStore, sessions []SessionRequest, p, conflicts *apierror.Problems) error {
+	venues := map[string]*repository.Venue{} // nil for unknown IDs
+	for i, s := range sessions {
+		field := fmt.Sprintf("sessions[%d]", i)
+		v, seen := venues[s.VenueID]
+		if !seen {
+			venue, err := store.Venues().Get(ctx, s.VenueID)
+			if err == nil {
+				v = &venue
+			} else if err != repository.ErrNotFound {
+				return err
+			}
+			venues[s.VenueID] = v
+		}
+		if v == nil || !v.IsActive {
+			p.Add(field+".venue_id", "is not an active venue")
+			continue
+		}
+		booked, err := store.Schedule().Booked(ctx, s.VenueID, s.StartTime, s.EndTime)
+		if err != nil {
+			return err
+		}
+		checkSession(field, s, v.Level, booked, sessions[:i], p, conflicts)
+	}
+	return nil
+}
+
+// checkSession records against field, in p, a level of s other than its
+// venue's and, in conflicts, each of booked and of the earlier sessions at
+// its venue that s overlaps.
+func checkSession(field string, s SessionRequest, venueLevel int, booked []repository.Session, earlier []SessionRequest, p, conflicts *apierror.Problems) {
+	p.Check(s.Level == venueLevel, field+".level", fmt.Sprintf("does not match the venue's level %d", venueLevel))
+	for _, b := range booked {
+		if overlaps(s.StartTime, s.EndTime, b.StartTime, b.EndTime) {
+			conflicts.Add(field+".start_time", fmt.Sprintf("overlaps session %s at the venue, from %s to %s",
+				b.ID, b.StartTime.UTC().Format(time.DateTime), b.EndTime.UTC().Format(time.DateTime)))
+		}
+	}
+	for j, other := range earlier {
+		if other.VenueID == s.VenueID && overlaps(s.StartTime, s.EndTime, other.StartTime, other.EndTime) {
+			conflicts.Add(field+".start_time", fmt.Sprintf("overlaps sessions[%d] at the venue", j))
+		}
+	}
+}
+
+// overlaps reports whether two periods share time. Back-to-back periods,
+// one ending as the other starts, don't.
+func overlaps(start, end, otherStart, otherEnd time.Time) bool {
+	return start.Before(otherEnd) && otherStart.Before(end)
+}
+
+// GetHolidays lists the holidays, by date.
+func (h *Handlers) GetHolidays(w http.ResponseWriter, r *http.Request) {
+	holidays, err := h.store.Schedule().Holidays(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "listing holidays failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+		return
+	}
+
+	list := []Holiday{}
+	for _, holiday := range holidays {
+		list = append(list, Holiday{Date: holiday.Date, Name: holiday.Name})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// SaveHoliday adds the holiday on the date in the path, or renames it.
+// Sessions already generated on the date are unchanged.
+func (h *Handlers) SaveHoliday(w http.ResponseWriter, r *http.Request) {
+	var holiday Holiday
+	if err := apierror.Decode(r, &holiday); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	holiday.Name = strings.TrimSpace(holiday.Name)
+	err := h.store.Schedule().SaveHoliday(r.Context(), repository.Holiday{Date: holiday.Date, Name: holiday.Name})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "saving holiday failed", "date", holiday.Date, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to save holiday"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holiday)
+}
+
+func (h *Handlers) DeleteHoliday(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	err := h.store.Schedule().DeleteHoliday(r.Context(), date)
+	if err == repository.ErrNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, "Holiday not found"))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "deleting holiday failed", "date", date, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to delete holiday"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gd/apierror"
+	"gd/repository"
+)
+
+func TestCheckSession(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 11, 2, hour, minute, 0, 0, time.UTC) }
+	session := func(venue string, level, from, to int) SessionRequest {
+		return SessionRequest{VenueID: venue, Level: level, StartTime: at(from, 0), EndTime: at(to, 0)}
+	}
+	booked := []repository.Session{{ID: "s1", StartTime: at(10, 0), EndTime: at(11, 0)}}
+
+	tests := []struct {
+		name    string
+		session SessionRequest
+		earlier []SessionRequest
+		want    []string
+	}{
+		{"free", session("v1", 1, 12, 13), nil, nil},
+		{"back to back before", session("v1", 1, 9, 10), nil, nil},
+		{"back to back after", session("v1", 1, 11, 12), nil, nil},
+		{"overlaps booked", SessionRequest{VenueID: "v1", Level: 1, StartTime: at(10, 30), EndTime: at(11, 30)}, nil,
+			[]string{"conflict sessions[1].start_time"}},
+		{"inside booked", SessionRequest{VenueID: "v1", Level: 1, StartTime: at(10, 15), EndTime: at(10, 45)}, nil,
+			[]string{"conflict sessions[1].start_time"}},
+		{"level mismatch", session("v1", 2, 12, 13), nil, []string{"invalid sessions[1].level"}},
+		{"overlaps earlier", session("v1", 1, 12, 14), []SessionRequest{session("v1", 1, 13, 15)},
+			[]string{"conflict sessions[1].start_time"}},
+		{"earlier back to back", session("v1", 1, 12, 13), []SessionRequest{session("v1", 1, 13, 14)}, nil},
+		{"earlier at another venue", session("v1", 1, 12, 13), []SessionRequest{session("v2", 1, 12, 13)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p, conflicts apierror.Problems
+			checkSession("sessions[1]", tt.session, 1, booked, tt.earlier, &p, &conflicts)
+			var got []string
+			for kind, problems := range map[string]*apierror.Problems{"invalid": &p, "conflict": &conflicts} {
+				if err, _ := problems.Err().(*apierror.Error); err != nil {
+					for _, f := range err.Fields {
+						got = append(got, kind+" "+f.Field)
+					}
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("problems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateBulkSessions(t *testing.T) {
+	const (
+		body   = `{"sessions":[{"venue_id":"v1","level":1,"start_time":"2026-11-02T10:00:00Z","end_time":"2026-11-02T11:00:00Z"}]}`
+		insert = "INSERT INTO gd_sessions"
+	)
+	tests := []struct {
+		name       string
+		booked     bool
+		wantStatus int
+	}{
+		{"free venue", false, http.StatusOK},
+		{"double-booked venue", true, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := scriptStore(t)
+			db.Rows("FROM venues WHERE id = ?", []string{"name", "capacity", "level", "is_active", "availability"},
+				[]any{"Hall", 10, 1, true, nil})
+			if tt.booked {
+				db.Rows("FROM gd_sessions", []string{"id", "start_time", "end_time"},
+					[]any{"s1", time.Date(2026, 11, 2, 10, 30, 0, 0, time.UTC), time.Date(2026, 11, 2, 11, 30, 0, 0, time.UTC)})
+			}
+			w := httptest.NewRecorder()
+			h.CreateBulkSessions(w, httptest.NewRequest("POST", "/admin/sessions/bulk", strings.NewReader(body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			ok := tt.wantStatus == http.StatusOK
+			if db.Ran(insert) != ok || db.Ran("COMMIT") != ok {
+				t.Errorf("inserted = %v, committed = %v, want %v", db.Ran(insert), db.Ran("COMMIT"), ok)
+			}
+		})
+	}
+}
@@ -2,15 +2,16 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	// "time"
 
-	"gd/database"
+	"gd/apierror"
+	"gd/repository"
 
 	"github.com/google/uuid"
 )
@@ -31,80 +32,84 @@ type SessionRequest struct {
 	Agenda        map[string]interface{} `json:"agenda"`
 	SurveyWeights map[string]float64     `json:"survey_weights"`
 }
-func CreateBulkSessions(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CreateBulkSessions(w http.ResponseWriter, r *http.Request) {
 	var request struct {
 		Sessions []SessionRequest `json:"sessions"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid request format", err))
 		return
 	}
 
 	if len(request.Sessions) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "No sessions provided"})
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "No sessions provided"))
 		return
 	}
-
-	tx, err := database.GetDB().Begin()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+	var problems apierror.Problems
+	for i, session := range request.Sessions {
+		problems.Nested(fmt.Sprintf("sessions[%d]", i), session.Validate())
+	}
+	if err := problems.Err(); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
-	defer tx.Rollback()
 
 	var createdSessions []map[string]interface{}
 
-	for _, session := range request.Sessions {
-		sessionID := uuid.New().String()
-		
-		agendaJSON, err := json.Marshal(session.Agenda)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to marshal agenda"})
-			return
+	err := h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		// Venues can't be double-booked, and sessions must suit their venue
+		var conflicts apierror.Problems
+		if err := checkSessionConflicts(r.Context(), tx, request.Sessions, &problems, &conflicts); err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
 		}
-
-		surveyWeightsJSON, err := json.Marshal(session.SurveyWeights)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to marshal survey weights"})
-			return
+		if err := problems.Err(); err != nil {
+			return err
 		}
-
-		_, err = tx.Exec(`
-			INSERT INTO gd_sessions 
-			(id, venue_id, level, start_time, end_time, agenda, survey_weights, status) 
-			VALUES (?, ?, ?, ?, ?, ?, ?, 'pending')`,
-			sessionID,
-			session.VenueID,
-			session.Level,
-			session.StartTime,
-			session.EndTime,
-			agendaJSON,
-			surveyWeightsJSON,
-		)
-
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create session: " + err.Error()})
-			return
+		if err := conflicts.Err(); err != nil {
+			conflict := err.(*apierror.Error).WithCode(apierror.CodeScheduleConflict)
+			conflict.Status = http.StatusConflict
+			conflict.Message = "Sessions overlap at their venue"
+			return conflict
 		}
 
-		createdSessions = append(createdSessions, map[string]interface{}{
-			"id":         sessionID,
-			"venue_id":   session.VenueID,
-			"start_time": session.StartTime,
-			"end_time":   session.EndTime,
-		})
-	}
-
-	if err := tx.Commit(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to commit transaction"})
+		for _, session := range request.Sessions {
+			sessionID := uuid.New().String()
+
+			agendaJSON, err := json.Marshal(session.Agenda)
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Failed to marshal agenda", err)
+			}
+
+			surveyWeightsJSON, err := json.Marshal(session.SurveyWeights)
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Failed to marshal survey weights", err)
+			}
+
+			err = tx.Schedule().Create(r.Context(), repository.NewSession{
+				ID:            sessionID,
+				VenueID:       session.VenueID,
+				Level:         session.Level,
+				StartTime:     session.StartTime,
+				EndTime:       session.EndTime,
+				Agenda:        agendaJSON,
+				SurveyWeights: surveyWeightsJSON,
+			})
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Failed to create session", err)
+			}
+
+			createdSessions = append(createdSessions, map[string]interface{}{
+				"id":         sessionID,
+				"venue_id":   session.VenueID,
+				"start_time": session.StartTime,
+				"end_time":   session.EndTime,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -116,28 +121,20 @@ func CreateBulkSessions(w http.ResponseWriter, r *http.Request) {
 }
 
 
-func GetSessionRules(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetSessionRules(w http.ResponseWriter, r *http.Request) {
     sessionID := r.URL.Query().Get("session_id")
     if sessionID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "session_id is required"})
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "session_id is required"))
         return
     }
 
     // Get session details including agenda
-    var agendaJSON []byte
-    err := database.GetDB().QueryRow(`
-        SELECT agenda FROM gd_sessions WHERE id = ?`,
-        sessionID,
-    ).Scan(&agendaJSON)
-
+    agendaJSON, err := h.store.Schedule().Agenda(r.Context(), sessionID)
     if err != nil {
-        if err == sql.ErrNoRows {
-            w.WriteHeader(http.StatusNotFound)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Session not found"})
+        if err == repository.ErrNotFound {
+            apierror.Write(w, r, apierror.New(http.StatusNotFound, "Session not found"))
         } else {
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+            apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         }
         return
     }
@@ -156,7 +153,7 @@ func GetSessionRules(w http.ResponseWriter, r *http.Request) {
     
     if len(agendaJSON) > 0 {
         if err := json.Unmarshal(agendaJSON, &agenda); err != nil {
-            log.Printf("Error parsing agenda JSON: %v", err)
+            slog.WarnContext(r.Context(), "parsing agenda JSON failed", "error", err)
             // Use defaults if parsing fails
         }
     }
@@ -170,17 +167,10 @@ func GetSessionRules(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(response)
 }
-func UpdateSessionRules(w http.ResponseWriter, r *http.Request) {
-    var request struct {
-        SessionID string `json:"session_id"`
-        PrepTime  int    `json:"prep_time"`
-        Discussion int   `json:"discussion_time"`
-        Survey    int    `json:"survey_time"`
-    }
-
-    if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+func (h *Handlers) UpdateSessionRules(w http.ResponseWriter, r *http.Request) {
+    var request SessionRulesRequest
+    if err := apierror.Decode(r, &request); err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
@@ -193,8 +183,7 @@ func UpdateSessionRules(w http.ResponseWriter, r *http.Request) {
 
     agendaJSON, err := json.Marshal(newAgenda)
     if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create agenda"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to create agenda", err))
         return
     }
 
@@ -202,20 +191,11 @@ func UpdateSessionRules(w http.ResponseWriter, r *http.Request) {
     totalMinutes := request.PrepTime + request.Discussion + request.Survey
 
     // Update session with new agenda and recalculated end time
-    _, err = database.GetDB().Exec(`
-        UPDATE gd_sessions 
-        SET agenda = ?,
-            end_time = DATE_ADD(start_time, INTERVAL ? MINUTE)
-        WHERE id = ?`,
-        string(agendaJSON),
-        totalMinutes,
-        request.SessionID,
-    )
+    err = h.store.Schedule().SetAgenda(r.Context(), request.SessionID, agendaJSON, totalMinutes)
 
     if err != nil {
-        log.Printf("Error updating session rules: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update rules"})
+        slog.ErrorContext(r.Context(), "updating session rules failed", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to update rules"))
         return
     }
 
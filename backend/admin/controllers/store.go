@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"gd/repository"
+	"gd/storage"
+)
+
+// Handlers serves the admin endpoints, which reach their data through a
+// repository.AdminStore. main builds one over MySQL; tests build one over
+// scripted SQL from gd/database/dbtest.
+type Handlers struct {
+	store repository.AdminStore
+	// files holds prep material attachments.
+	files storage.Backend
+}
+
+func NewHandlers(store repository.AdminStore, files storage.Backend) *Handlers {
+	return &Handlers{store: store, files: files}
+}
@@ -2,115 +2,157 @@
 package controllers
 
 import (
-	// "database/sql"
+	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode"
 
-	"gd/database"
+	"gd/apierror"
+	"gd/repository"
 
 	"github.com/google/uuid"
 )
 
+// topicDifficulties are the difficulties a topic may be tagged with.
+var topicDifficulties = []string{"easy", "medium", "hard"}
+
+// similarTopicShare is the share of their distinct words two topics must
+// have in common to be reported as possible duplicates.
+const similarTopicShare = 0.8
+
+// maxTopicPageSize bounds one page of GetTopics.
+const maxTopicPageSize = 200
+
 type Topic struct {
-	ID           string          `json:"id"`
-	Level        int             `json:"level"`
-	TopicText    string          `json:"topic_text"`
+	ID            string                 `json:"id"`
+	Level         int                    `json:"level"`
+	TopicText     string                 `json:"topic_text"`
 	PrepMaterials map[string]interface{} `json:"prep_materials"`
-	IsActive     bool            `json:"is_active"`
+	IsActive      bool                   `json:"is_active"`
+	Category      string                 `json:"category"`
+	Tags          []string               `json:"tags"`
+	// Difficulty is easy, medium or hard, or empty when unrated.
+	Difficulty string `json:"difficulty"`
+	// Source says where the topic came from, such as a book or news item.
+	Source string `json:"source"`
+	// TimesUsed counts the sessions that discussed the topic and
+	// AverageRating is their mean feedback rating, null without feedback.
+	// Both are ignored on input.
+	TimesUsed     int      `json:"times_used"`
+	AverageRating *float64 `json:"average_rating"`
 }
 
-func GetTopics(w http.ResponseWriter, r *http.Request) {
-	level := r.URL.Query().Get("level")
-	
-	var query string
-	var args []interface{}
-	
-	if level != "" {
-		query = "SELECT id, level, topic_text, prep_materials, is_active FROM gd_topics WHERE level = ? AND is_active = TRUE ORDER BY level, created_at DESC"
+// GetTopics lists topics, newest first within each level, or by relevance
+// to the full-text query q. level, category, tag and difficulty filter the
+// list and inactive=true includes deactivated topics. With page or
+// page_size the list is paged; X-Total-Count always gives the number of
+// matches.
+func (h *Handlers) GetTopics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := repository.TopicFilter{
+		Inactive:   query.Get("inactive") == "true",
+		Category:   strings.TrimSpace(query.Get("category")),
+		Difficulty: query.Get("difficulty"),
+		Tag:        normalizeTag(query.Get("tag")),
+		Query:      strings.TrimSpace(query.Get("q")),
+	}
+	var p apierror.Problems
+	if level := query.Get("level"); level != "" {
 		levelInt, err := strconv.Atoi(level)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level"})
+			apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid level", err))
 			return
 		}
-		args = []interface{}{levelInt}
-	} else {
-		query = "SELECT id, level, topic_text, prep_materials, is_active FROM gd_topics WHERE is_active = TRUE ORDER BY level, created_at DESC"
+		filter.Level = levelInt
+	}
+	if filter.Difficulty != "" {
+		p.Check(slices.Contains(topicDifficulties, filter.Difficulty), "difficulty", "must be one of "+strings.Join(topicDifficulties, ", "))
+	}
+	if query.Has("page") || query.Has("page_size") {
+		page, size := 1, 50
+		var err error
+		if query.Get("page") != "" {
+			page, err = strconv.Atoi(query.Get("page"))
+			p.Check(err == nil && page >= 1, "page", "must be a whole number from 1")
+		}
+		if query.Get("page_size") != "" {
+			size, err = strconv.Atoi(query.Get("page_size"))
+			p.Check(err == nil && size >= 1 && size <= maxTopicPageSize, "page_size", fmt.Sprintf("must be from 1 to %d", maxTopicPageSize))
+		}
+		filter.Limit, filter.Offset = size, (page-1)*size
+	}
+	if err := p.Err(); err != nil {
+		apierror.Write(w, r, err)
+		return
 	}
 
-	rows, err := database.GetDB().Query(query, args...)
+	list, total, err := h.store.Topics().List(r.Context(), filter)
 	if err != nil {
-		log.Printf("Error fetching topics: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch topics"})
+		slog.ErrorContext(r.Context(), "fetching topics failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to fetch topics"))
 		return
 	}
-	defer rows.Close()
 
-	var topics []Topic
-	for rows.Next() {
-		var topic Topic
-		var prepMaterialsJSON []byte
-		
-		if err := rows.Scan(&topic.ID, &topic.Level, &topic.TopicText, &prepMaterialsJSON, &topic.IsActive); err != nil {
-			log.Printf("Error scanning topic: %v", err)
-			continue
+	topics := []Topic{}
+	for _, t := range list {
+		topic := Topic{ID: t.ID, Level: t.Level, TopicText: t.Text, IsActive: t.IsActive, Category: t.Category,
+			Tags: t.Tags, Difficulty: t.Difficulty, Source: t.Source, TimesUsed: t.Uses}
+		if t.Rated {
+			topic.AverageRating = &t.AverageRating
 		}
-		
-		if len(prepMaterialsJSON) > 0 {
-			if err := json.Unmarshal(prepMaterialsJSON, &topic.PrepMaterials); err != nil {
-				log.Printf("Error parsing prep materials: %v", err)
+		if len(t.PrepMaterials) > 0 {
+			if err := json.Unmarshal(t.PrepMaterials, &topic.PrepMaterials); err != nil {
+				slog.WarnContext(r.Context(), "parsing prep materials failed", "error", err)
 			}
 		}
-		
 		topics = append(topics, topic)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(topics)
 }
 
-func CreateTopic(w http.ResponseWriter, r *http.Request) {
+// CreateTopic adds a topic. A topic whose text matches another at the level,
+// ignoring case, spacing and punctuation, is refused; one that shares most
+// of its words with another is refused with code similar_topic unless
+// force=true.
+func (h *Handlers) CreateTopic(w http.ResponseWriter, r *http.Request) {
 	var topic Topic
-	if err := json.NewDecoder(r.Body).Decode(&topic); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
-		return
-	}
-
-	if topic.Level < 1 || topic.Level > 3 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Level must be between 1 and 3"})
-		return
-	}
-
-	if topic.TopicText == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Topic text is required"})
+	if err := apierror.Decode(r, &topic); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
 	topic.ID = uuid.New().String()
-	
+	topic.IsActive = true
+	topic.Tags = normalizeTags(topic.Tags)
+	topic.TimesUsed, topic.AverageRating = 0, nil
+
 	prepMaterialsJSON, err := json.Marshal(topic.PrepMaterials)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to process preparation materials"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to process preparation materials", err))
 		return
 	}
 
-	_, err = database.GetDB().Exec(`
-		INSERT INTO gd_topics (id, level, topic_text, prep_materials, is_active)
-		VALUES (?, ?, ?, ?, ?)`,
-		topic.ID, topic.Level, topic.TopicText, prepMaterialsJSON, true,
-	)
-
+	err = h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		if err := checkDuplicateTopic(r.Context(), tx, topic, r.URL.Query().Get("force") == "true"); err != nil {
+			return err
+		}
+		err := tx.Topics().Create(r.Context(), managedTopic(topic, prepMaterialsJSON))
+		if err == nil {
+			err = tx.Topics().SetTags(r.Context(), topic.ID, topic.Tags)
+		}
+		return topicSaveError(err, "Failed to create topic")
+	})
 	if err != nil {
-		log.Printf("Error creating topic: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create topic"})
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -119,38 +161,40 @@ func CreateTopic(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(topic)
 }
 
-func UpdateTopic(w http.ResponseWriter, r *http.Request) {
+// UpdateTopic replaces a topic's fields and tags. Its new text is checked
+// for duplicates like CreateTopic's.
+func (h *Handlers) UpdateTopic(w http.ResponseWriter, r *http.Request) {
 	var topic Topic
-	if err := json.NewDecoder(r.Body).Decode(&topic); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+	if err := apierror.Decode(r, &topic); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	if topic.ID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Topic ID is required"})
+	var problems apierror.Problems
+	problems.Required("id", topic.ID)
+	if err := problems.Err(); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
 	prepMaterialsJSON, err := json.Marshal(topic.PrepMaterials)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to process preparation materials"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to process preparation materials", err))
 		return
 	}
 
-	_, err = database.GetDB().Exec(`
-		UPDATE gd_topics 
-		SET level = ?, topic_text = ?, prep_materials = ?, is_active = ?
-		WHERE id = ?`,
-		topic.Level, topic.TopicText, prepMaterialsJSON, topic.IsActive, topic.ID,
-	)
-
+	err = h.store.InTx(r.Context(), func(tx repository.AdminStore) error {
+		if err := checkDuplicateTopic(r.Context(), tx, topic, r.URL.Query().Get("force") == "true"); err != nil {
+			return err
+		}
+		err := tx.Topics().Update(r.Context(), managedTopic(topic, prepMaterialsJSON))
+		if err == nil {
+			err = tx.Topics().SetTags(r.Context(), topic.ID, normalizeTags(topic.Tags))
+		}
+		return topicSaveError(err, "Failed to update topic")
+	})
 	if err != nil {
-		log.Printf("Error updating topic: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update topic"})
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -158,22 +202,115 @@ func UpdateTopic(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Topic updated successfully"})
 }
 
-func DeleteTopic(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) DeleteTopic(w http.ResponseWriter, r *http.Request) {
 	topicID := r.URL.Query().Get("id")
 	if topicID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Topic ID is required"})
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Topic ID is required"))
 		return
 	}
 
-	_, err := database.GetDB().Exec("UPDATE gd_topics SET is_active = FALSE WHERE id = ?", topicID)
-	if err != nil {
-		log.Printf("Error deleting topic: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete topic"})
+	if err := h.store.Topics().Deactivate(r.Context(), topicID); err != nil {
+		slog.ErrorContext(r.Context(), "deleting topic failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to delete topic"))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Topic deleted successfully"})
-}
\ No newline at end of file
+}
+
+// managedTopic is the row saved for topic.
+func managedTopic(topic Topic, prepMaterialsJSON []byte) repository.ManagedTopic {
+	return repository.ManagedTopic{
+		Topic:    repository.Topic{ID: topic.ID, Level: topic.Level, Text: topic.TopicText, PrepMaterials: prepMaterialsJSON},
+		IsActive: topic.IsActive, Category: topic.Category, Difficulty: topic.Difficulty, Source: topic.Source,
+	}
+}
+
+// topicSaveError is the response to an error saving a topic: a conflict
+// when another topic at the level has its text.
+func topicSaveError(err error, message string) error {
+	if err == repository.ErrDuplicate {
+		return apierror.New(http.StatusConflict, "A topic with this text already exists at this level")
+	}
+	if err != nil {
+		return apierror.Wrap(http.StatusInternalServerError, message, err)
+	}
+	return nil
+}
+
+// checkDuplicateTopic returns a conflict if another topic at the level has
+// the same words as topic in the same order, or, unless force, shares
+// similarTopicShare of its distinct words.
+func checkDuplicateTopic(ctx context.Context, store repository.AdminStore, topic Topic, force bool) error {
+	others, err := store.Topics().AtLevel(ctx, topic.Level, topic.ID)
+	if err != nil {
+		return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+	}
+
+	words := topicWords(topic.TopicText)
+	var similarID, similarText string
+	best := 0.0
+	for _, t := range others {
+		other := topicWords(t.Text)
+		if strings.Join(other, " ") == strings.Join(words, " ") {
+			return apierror.New(http.StatusConflict, fmt.Sprintf("Topic %s at this level has the same text", t.ID))
+		}
+		if share := sharedWords(words, other); share >= similarTopicShare && share > best {
+			best, similarID, similarText = share, t.ID, t.Text
+		}
+	}
+	if similarID != "" && !force {
+		return apierror.New(http.StatusConflict,
+			fmt.Sprintf("Topic %s at this level is similar: %q. Resend with force=true to save anyway", similarID, similarText)).
+			WithCode(apierror.CodeSimilarTopic)
+	}
+	return nil
+}
+
+// topicWords splits text into lower-case words, dropping punctuation.
+func topicWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// sharedWords is the Jaccard similarity of two word lists' distinct words.
+func sharedWords(a, b []string) float64 {
+	set := map[string]bool{}
+	for _, w := range a {
+		set[w] = true
+	}
+	common, union := 0, len(set)
+	seen := map[string]bool{}
+	for _, w := range b {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			common++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// normalizeTags lower-cases tags, collapses their spacing and drops repeats.
+func normalizeTags(tags []string) []string {
+	out := []string{}
+	for _, tag := range tags {
+		if tag = normalizeTag(tag); tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
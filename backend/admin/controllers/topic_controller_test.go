@@ -0,0 +1,21 @@
+package controllers
+
+import "testing"
+
+func TestSharedWords(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		similar bool
+	}{
+		{"Should AI replace recruiters?", "should AI replace recruiters", true},
+		{"Is remote work here to stay?", "Is remote work here to stay for good?", false},
+		{"Social media does more harm than good", "Social media does more good than harm", true},
+		{"Electric cars", "Public transport", false},
+	}
+	for _, tt := range tests {
+		share := sharedWords(topicWords(tt.a), topicWords(tt.b))
+		if got := share >= similarTopicShare; got != tt.similar {
+			t.Errorf("sharedWords(%q, %q) = %.2f, want similar %v", tt.a, tt.b, share, tt.similar)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gd/admin/models"
+	"gd/apierror"
+	"gd/repository"
+	"gd/scoring"
+)
+
+// agendaPhases are the minute counts an agenda may carry.
+var agendaPhases = []string{"prep_time", "discussion", "survey"}
+
+func (s SessionRequest) Validate() error {
+	var p apierror.Problems
+	p.Required("venue_id", s.VenueID)
+	p.Level("level", s.Level)
+	p.Check(!s.StartTime.IsZero(), "start_time", "is required")
+	p.Check(!s.EndTime.IsZero(), "end_time", "is required")
+	if !s.StartTime.IsZero() && !s.EndTime.IsZero() {
+		p.Check(s.EndTime.After(s.StartTime), "end_time", "must be after start_time")
+	}
+	validateAgenda(&p, s.Agenda, s.SurveyWeights)
+	return p.Err()
+}
+
+// validateAgenda checks the phase minutes and survey weights of sessions.
+func validateAgenda(p *apierror.Problems, agenda map[string]interface{}, surveyWeights map[string]float64) {
+	for _, phase := range agendaPhases {
+		v, ok := agenda[phase]
+		if !ok {
+			continue
+		}
+		minutes, isNumber := v.(float64)
+		p.Check(isNumber && minutes >= 0, "agenda."+phase, "must be a non-negative number of minutes")
+	}
+	for name, weight := range surveyWeights {
+		p.Check(weight >= 0, "survey_weights."+name, "must not be negative")
+	}
+}
+
+// SessionRulesRequest changes the phase durations of one session.
+type SessionRulesRequest struct {
+	SessionID  string `json:"session_id"`
+	PrepTime   int    `json:"prep_time"`
+	Discussion int    `json:"discussion_time"`
+	Survey     int    `json:"survey_time"`
+}
+
+func (s SessionRulesRequest) Validate() error {
+	var p apierror.Problems
+	p.Required("session_id", s.SessionID)
+	p.Check(s.PrepTime >= 0, "prep_time", "must not be negative")
+	p.Check(s.Discussion >= 0, "discussion_time", "must not be negative")
+	p.Check(s.Survey >= 0, "survey_time", "must not be negative")
+	p.Check(s.PrepTime+s.Discussion+s.Survey > 0, "discussion_time", "session must last at least one minute")
+	return p.Err()
+}
+
+// A range is at most maxGenerationDays long so one request cannot flood the
+// calendar.
+func (g SessionGeneration) Validate() error {
+	var p apierror.Problems
+	from, errFrom := time.Parse(models.DateLayout, g.From)
+	to, errTo := time.Parse(models.DateLayout, g.To)
+	p.Check(errFrom == nil, "from", "must be a date as YYYY-MM-DD")
+	p.Check(errTo == nil, "to", "must be a date as YYYY-MM-DD")
+	if errFrom == nil && errTo == nil {
+		p.Check(!to.Before(from), "to", "must not be before from")
+		p.Check(to.Sub(from) < maxGenerationDays*24*time.Hour, "to", fmt.Sprintf("must be within %d days of from", maxGenerationDays))
+	}
+	seen := make(map[string]bool)
+	for i, id := range g.VenueIDs {
+		field := fmt.Sprintf("venue_ids[%d]", i)
+		p.Required(field, id)
+		p.Check(!seen[id], field, "is listed more than once")
+		seen[id] = true
+	}
+	validateAgenda(&p, g.Agenda, g.SurveyWeights)
+	return p.Err()
+}
+
+func (h Holiday) Validate() error {
+	var p apierror.Problems
+	_, err := time.Parse(models.DateLayout, h.Date)
+	p.Check(err == nil, "date", "must be a date as YYYY-MM-DD")
+	p.Required("name", h.Name)
+	p.Check(len(h.Name) <= 100, "name", "must be at most 100 characters")
+	return p.Err()
+}
+
+func (q QuestionRequest) Validate() error {
+	var p apierror.Problems
+	p.Required("text", q.Text)
+	p.Check(q.Weight > 0, "weight", "must be greater than zero")
+	p.Check(len(q.Levels) > 0, "levels", "must list at least one level")
+	validateLevels(&p, q.Levels)
+	return p.Err()
+}
+
+func (q QuestionOrder) Validate() error {
+	var p apierror.Problems
+	p.Level("level", q.Level)
+	p.Check(len(q.QuestionIDs) > 0, "question_ids", "must list at least one question")
+	seen := make(map[string]bool)
+	for i, id := range q.QuestionIDs {
+		field := fmt.Sprintf("question_ids[%d]", i)
+		p.Required(field, id)
+		p.Check(!seen[id], field, "is listed more than once")
+		seen[id] = true
+	}
+	return p.Err()
+}
+
+func (b QuestionBank) Validate() error {
+	var p apierror.Problems
+	p.Required("name", b.Name)
+	p.Check(len(b.Name) <= 100, "name", "must be at most 100 characters")
+	return p.Err()
+}
+
+func (t QuestionTemplate) Validate() error {
+	var p apierror.Problems
+	p.Level("level", t.Level)
+	seen := make(map[string]bool)
+	for i, d := range t.Draws {
+		field := fmt.Sprintf("draws[%d]", i)
+		p.Required(field+".bank_id", d.BankID)
+		p.Check(!seen[d.BankID], field+".bank_id", "is listed more than once")
+		p.Check(d.Count >= 1, field+".count", "must be at least 1")
+		seen[d.BankID] = true
+	}
+	return p.Err()
+}
+
+// validateLevels checks every entry is a GD level and none repeats.
+func validateLevels(p *apierror.Problems, levels []int) {
+	seen := make(map[int]bool)
+	for i, level := range levels {
+		field := fmt.Sprintf("levels[%d]", i)
+		p.Level(field, level)
+		p.Check(!seen[level], field, "is listed more than once")
+		seen[level] = true
+	}
+}
+
+func (t Topic) Validate() error {
+	var p apierror.Problems
+	p.Level("level", t.Level)
+	p.Required("topic_text", t.TopicText)
+	p.Check(len(t.Category) <= 100, "category", "must be at most 100 characters")
+	p.Check(t.Difficulty == "" || slices.Contains(topicDifficulties, t.Difficulty), "difficulty", "must be one of "+strings.Join(topicDifficulties, ", "))
+	p.Check(len(t.Source) <= 255, "source", "must be at most 255 characters")
+	seen := map[string]bool{}
+	for i, tag := range t.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		tag = normalizeTag(tag)
+		p.Required(field, tag)
+		p.Check(len(tag) <= 50, field, "must be at most 50 characters")
+		p.Check(!strings.Contains(tag, listSeparator), field, "must not contain "+listSeparator)
+		p.Check(!seen[tag], field, "is listed more than once")
+		seen[tag] = true
+	}
+	return p.Err()
+}
+
+// Links need an http or https URL and key points and arguments a body;
+// attachments carry an uploaded file instead. A material must not be hidden
+// before the phase it is revealed in.
+func (m PrepMaterial) Validate() error {
+	var p apierror.Problems
+	p.Check(slices.Contains(repository.MaterialKinds, m.Kind), "kind", "must be one of "+strings.Join(repository.MaterialKinds, ", "))
+	p.Check(len(m.Title) <= 255, "title", "must be at most 255 characters")
+	switch m.Kind {
+	case repository.MaterialLink:
+		u, err := url.Parse(m.URL)
+		p.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an http or https URL")
+	case repository.MaterialKeyPoint, repository.MaterialArgumentFor, repository.MaterialArgumentAgainst:
+		p.Required("body", m.Body)
+	}
+	from := slices.Index(repository.MaterialPhases, m.visibleFrom())
+	p.Check(from >= 0, "visible_from", "must be one of "+strings.Join(repository.MaterialPhases, ", "))
+	if m.VisibleUntil != "" {
+		until := slices.Index(repository.MaterialPhases, m.VisibleUntil)
+		p.Check(until >= 0, "visible_until", "must be one of "+strings.Join(repository.MaterialPhases, ", "))
+		p.Check(until < 0 || from < 0 || until >= from, "visible_until", "must not be before visible_from")
+	}
+	return p.Err()
+}
+
+// Points must be positive and strictly decreasing so a better rank always
+// earns more; the consensus method, when given, must be a known one. Only a
+// new configuration needs a level: an update keeps the level of the one it
+// replaces.
+func (c RankingPointsConfig) Validate() error {
+	var p apierror.Problems
+	if c.ID == "" {
+		p.Level("level", c.Level)
+	}
+	points := c.rankPoints()
+	p.Check(len(points) <= maxRankingDepth, "points", fmt.Sprintf("must have at most %d entries", maxRankingDepth))
+	for i, pts := range points {
+		field := fmt.Sprintf("points[%d]", i)
+		p.Check(pts > 0, field, "must be positive")
+		if i > 0 {
+			p.Check(pts < points[i-1], field, "must be less than the points for the rank above")
+		}
+	}
+	if c.ConsensusMethod != "" {
+		if _, err := scoring.Lookup(c.ConsensusMethod); err != nil {
+			p.Add("consensus_method", err.Error())
+		}
+	}
+	return p.Err()
+}
+
+func (r Rubric) Validate() error {
+	var p apierror.Problems
+	p.Level("level", r.Level)
+	p.Check(r.ModeratorWeight >= 0 && r.ModeratorWeight <= 1, "moderator_weight", "must be between 0 and 1")
+	p.Check(r.ModeratorWeight == 0 || len(r.Criteria) > 0, "criteria", "must list at least one criterion when moderator_weight is set")
+	names := make(map[string]bool)
+	for i, c := range r.Criteria {
+		field := fmt.Sprintf("criteria[%d]", i)
+		p.Required(field+".name", c.Name)
+		key := strings.ToLower(strings.TrimSpace(c.Name))
+		p.Check(key == "" || !names[key], field+".name", "is listed more than once")
+		names[key] = true
+		p.Check(c.Weight > 0, field+".weight", "must be greater than zero")
+		p.Check(c.MaxScore > 0, field+".max_score", "must be greater than zero")
+	}
+	return p.Err()
+}
+
+func (r RubricScoreRequest) Validate() error {
+	var p apierror.Problems
+	p.Required("session_id", r.SessionID)
+	p.Required("student_id", r.StudentID)
+	p.Check(len(r.Scores) > 0 || r.Feedback != "", "scores", "must mark at least one criterion or give feedback")
+	for id, score := range r.Scores {
+		p.Check(score >= 0, "scores."+id, "must not be negative")
+	}
+	return p.Err()
+}
+
+func (c CollusionReview) Validate() error {
+	var p apierror.Problems
+	p.Required("id", c.ID)
+	p.Check(c.Action == "void" || c.Action == "dismiss", "action", "must be void or dismiss")
+	p.Check(c.Action == "void" || len(c.ResponderIDs)+len(c.QuestionIDs) == 0, "responder_ids", "only apply when voiding")
+	return p.Err()
+}
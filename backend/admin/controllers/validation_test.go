@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gd/admin/models"
+	"gd/apierror"
+)
+
+func fieldsOf(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var e *apierror.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("error %v is not an *apierror.Error", err)
+	}
+	var fields []string
+	for _, f := range e.Fields {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+func TestRequestValidation(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	session := SessionRequest{VenueID: "venue1", Level: 1, StartTime: start, EndTime: start.Add(time.Hour)}
+
+	tests := []struct {
+		name       string
+		v          apierror.Validator
+		wantFields []string
+	}{
+		{"valid session", session, nil},
+		{"session ends before start", func() SessionRequest { s := session; s.EndTime = start.Add(-time.Minute); return s }(), []string{"end_time"}},
+		{"session negative agenda", func() SessionRequest {
+			s := session
+			s.Agenda = map[string]interface{}{"prep_time": -5.0, "discussion": 20.0}
+			return s
+		}(), []string{"agenda.prep_time"}},
+		{"session missing everything", SessionRequest{}, []string{"venue_id", "level", "start_time", "end_time"}},
+		{"rules negative minutes", SessionRulesRequest{SessionID: "s1", PrepTime: -1, Discussion: 20}, []string{"prep_time"}},
+		{"rules all zero", SessionRulesRequest{SessionID: "s1"}, []string{"discussion_time"}},
+		{"question", QuestionRequest{Text: "Clarity", Weight: 1, Levels: []int{1, 2}}, nil},
+		{"question bad levels", QuestionRequest{Text: "Clarity", Weight: 0, Levels: []int{1, 1, 5}}, []string{"weight", "levels[1]", "levels[2]"}},
+		{"question order", QuestionOrder{Level: 2, QuestionIDs: []string{"q2", "q1"}}, nil},
+		{"question order blank", QuestionOrder{Level: 4, QuestionIDs: []string{"q1", ""}}, []string{"level", "question_ids[1]"}},
+		{"question bank", QuestionBank{Name: "Leadership"}, nil},
+		{"question bank blank", QuestionBank{Name: " "}, []string{"name"}},
+		{"question template", QuestionTemplate{Level: 1, Draws: []BankDraw{{BankID: "b1", Count: 2}, {BankID: "b2", Count: 1}}}, nil},
+		{"question template bad draws", QuestionTemplate{Level: 1, Draws: []BankDraw{{BankID: "b1", Count: 2}, {BankID: "b1", Count: 0}}}, []string{"draws[1].bank_id", "draws[1].count"}},
+		{"topic", Topic{Level: 2, TopicText: "AI in hiring"}, nil},
+		{"topic blank", Topic{Level: 0, TopicText: " "}, []string{"level", "topic_text"}},
+		{"topic metadata", Topic{Level: 2, TopicText: "AI in hiring", Category: "Technology", Difficulty: "medium", Tags: []string{"AI", "ethics"}}, nil},
+		{"topic bad metadata", Topic{Level: 2, TopicText: "AI in hiring", Difficulty: "tricky", Tags: []string{"Ethics", "ethics ", " "}}, []string{"difficulty", "tags[1]", "tags[2]"}},
+		{"link", PrepMaterial{Kind: "link", URL: "https://example.org/ai-hiring"}, nil},
+		{"link not http", PrepMaterial{Kind: "link", URL: "javascript:alert(1)"}, []string{"url"}},
+		{"key point during prep", PrepMaterial{Kind: "key_point", Body: "Bias in training data", VisibleFrom: "prep", VisibleUntil: "prep"}, nil},
+		{"argument hidden before shown", PrepMaterial{Kind: "argument_for", Body: " ", VisibleFrom: "survey", VisibleUntil: "prep"}, []string{"body", "visible_until"}},
+		{"material unknown kind and phase", PrepMaterial{Kind: "video", VisibleFrom: "lobby"}, []string{"kind", "visible_from"}},
+		{"venue zero capacity", models.Venue{Name: "Room 1", Capacity: 0, Level: 1}, []string{"capacity"}},
+		{"venue bad availability", models.Venue{Name: "Room 1", Capacity: 8, Level: 1, Availability: &models.VenueAvailability{SlotMinutes: 5}}, []string{"availability.slot_minutes", "availability.windows"}},
+		{"session generation", SessionGeneration{From: "2026-11-02", To: "2026-11-30", VenueIDs: []string{"v1"}}, nil},
+		{"session generation bad range", SessionGeneration{From: "2026-11-02", To: "2027-03-01", VenueIDs: []string{"v1", "v1"}}, []string{"to", "venue_ids[1]"}},
+		{"holiday", Holiday{Date: "2026-12-25", Name: "Christmas"}, nil},
+		{"holiday blank", Holiday{Date: "25 Dec", Name: " "}, []string{"date", "name"}},
+		{"points top three", RankingPointsConfig{Level: 1, FirstPlacePoints: 4, SecondPlacePoints: 3, ThirdPlacePoints: 2}, nil},
+		{"points top five", RankingPointsConfig{Level: 3, Points: []float64{10, 7, 5, 3, 1}, ConsensusMethod: "schulze"}, nil},
+		{"points not decreasing", RankingPointsConfig{Level: 3, Points: []float64{5, 5, 4, 0}}, []string{"points[1]", "points[3]"}},
+		{"points too deep", RankingPointsConfig{Level: 1, Points: []float64{13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}}, []string{"points"}},
+		{"points without level", RankingPointsConfig{Points: []float64{3, 2, 1}}, []string{"level"}},
+		{"points update without level", RankingPointsConfig{ID: "cfg1", Points: []float64{3, 2, 1}}, nil},
+		{"points unknown method", RankingPointsConfig{Level: 1, Points: []float64{2, 1}, ConsensusMethod: "plurality"}, []string{"consensus_method"}},
+		{"rubric", Rubric{Level: 2, ModeratorWeight: 0.4, Criteria: []RubricCriterion{{Name: "Leadership", Weight: 2, MaxScore: 10}, {Name: "Teamwork", Weight: 1, MaxScore: 5}}}, nil},
+		{"rubric weight without criteria", Rubric{Level: 1, ModeratorWeight: 0.5}, []string{"criteria"}},
+		{"rubric bad criteria", Rubric{Level: 1, ModeratorWeight: 1.5, Criteria: []RubricCriterion{{Name: "Clarity", Weight: 1, MaxScore: 10}, {Name: " clarity", Weight: 0, MaxScore: -1}}},
+			[]string{"moderator_weight", "criteria[1].name", "criteria[1].weight", "criteria[1].max_score"}},
+		{"rubric score", RubricScoreRequest{SessionID: "s1", StudentID: "alice", Scores: map[string]float64{"c1": 7}}, nil},
+		{"rubric score feedback only", RubricScoreRequest{SessionID: "s1", StudentID: "alice", Feedback: "Kept the group on track"}, nil},
+		{"rubric score empty", RubricScoreRequest{SessionID: "s1"}, []string{"student_id", "scores"}},
+		{"void flag", CollusionReview{ID: "f1", Action: "void", ResponderIDs: []string{"alice"}}, nil},
+		{"dismiss flag", CollusionReview{ID: "f1", Action: "dismiss", Note: "Close friends, honest votes"}, nil},
+		{"dismiss flag naming responders", CollusionReview{ID: "f1", Action: "dismiss", ResponderIDs: []string{"alice"}}, []string{"responder_ids"}},
+		{"flag unknown action", CollusionReview{Action: "delete"}, []string{"id", "action"}},
+		{"rubric score negative", RubricScoreRequest{SessionID: "s1", StudentID: "alice", Scores: map[string]float64{"c1": -1}}, []string{"scores.c1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fieldsOf(t, tt.v.Validate())
+			if len(got) != len(tt.wantFields) {
+				t.Fatalf("fields = %v, want %v", got, tt.wantFields)
+			}
+			for i := range got {
+				if got[i] != tt.wantFields[i] {
+					t.Errorf("fields = %v, want %v", got, tt.wantFields)
+				}
+			}
+		})
+	}
+}
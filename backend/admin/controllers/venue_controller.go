@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"gd/admin/models"
 	qr "gd/admin/utils"
-	"gd/database"
-	"log"
+	"gd/apierror"
+	"gd/repository"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -16,35 +17,22 @@ import (
 	"github.com/google/uuid"
 )
 
-// var db *sql.DB // Make sure this is properly initialized in main.go
-// func SetDB(database *sql.DB) {
-//     db = database
-// }
-func GetVenues(w http.ResponseWriter, r *http.Request) {
-	// Ensure db connection is available
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database connection error"})
-		return
-	}
-
-	rows, err := db.Query("SELECT id, name, capacity, level, session_timing, table_details FROM venues WHERE is_active = TRUE")
+func (h *Handlers) GetVenues(w http.ResponseWriter, r *http.Request) {
+	active, err := h.store.Venues().Active(r.Context())
 	if err != nil {
-		log.Printf("Error fetching venues: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch venues"})
+		slog.ErrorContext(r.Context(), "fetching venues failed", "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to fetch venues"))
 		return
 	}
-	defer rows.Close()
 
 	var venues []models.Venue
-	for rows.Next() {
-		var v models.Venue
-	if err := rows.Scan(&v.ID, &v.Name, &v.Capacity, &v.Level, &v.SessionTiming, &v.TableDetails); err != nil {
-			log.Printf("Error scanning venue: %v", err)
-			continue
+	for _, a := range active {
+		v := models.Venue{ID: a.ID, Name: a.Name, Capacity: a.Capacity, Level: a.Level,
+			SessionTiming: a.SessionTiming, TableDetails: a.TableDetails}
+		if len(a.Availability) > 0 {
+			if err := json.Unmarshal(a.Availability, &v.Availability); err != nil {
+				slog.WarnContext(r.Context(), "parsing venue availability failed", "venue_id", v.ID, "error", err)
+			}
 		}
 		venues = append(venues, v)
 	}
@@ -53,14 +41,7 @@ func GetVenues(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(venues)
 }
 
-func UpdateVenue(w http.ResponseWriter, r *http.Request) {
-    db := database.GetDB()
-    if db == nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database connection error"})
-        return
-    }
-
+func (h *Handlers) UpdateVenue(w http.ResponseWriter, r *http.Request) {
     // Extract ID from URL path if present
     var venueID string
     if strings.HasPrefix(r.URL.Path, "/admin/venues/") && len(r.URL.Path) > 13 {
@@ -77,9 +58,8 @@ func UpdateVenue(w http.ResponseWriter, r *http.Request) {
         TableDetails  string `json:"table_details"`
     }
     
-    if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+    if err := apierror.Decode(r, &requestBody); err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
@@ -88,21 +68,26 @@ func UpdateVenue(w http.ResponseWriter, r *http.Request) {
         requestBody.ID = venueID
     }
 
-    if requestBody.ID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Venue ID is required"})
+    var problems apierror.Problems
+    problems.Required("id", requestBody.ID)
+    problems.Required("name", requestBody.Name)
+    problems.Check(requestBody.Capacity > 0, "capacity", "must be greater than zero")
+    problems.Level("level", requestBody.Level)
+    if err := problems.Err(); err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
-    _, err := db.Exec(
-    `UPDATE venues 
-    SET name = ?, capacity = ?, level = ?, session_timing = ?, table_details = ?
-    WHERE id = ? AND is_active = TRUE`,
-    requestBody.Name, requestBody.Capacity, requestBody.Level, requestBody.SessionTiming, requestBody.TableDetails, requestBody.ID)
-    
+    err := h.store.Venues().Update(r.Context(), repository.Venue{
+        ID:            requestBody.ID,
+        Name:          requestBody.Name,
+        Capacity:      requestBody.Capacity,
+        Level:         requestBody.Level,
+        SessionTiming: requestBody.SessionTiming,
+        TableDetails:  requestBody.TableDetails,
+    })
     if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update venue"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to update venue", err))
         return
     }
 
@@ -110,20 +95,10 @@ func UpdateVenue(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(map[string]string{"message": "Venue updated successfully"})
 }
 
-func CreateVenue(w http.ResponseWriter, r *http.Request) {
-    db := database.GetDB()
-    if db == nil {
-        log.Println("Database connection is nil")
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database connection error"})
-        return
-    }
-
+func (h *Handlers) CreateVenue(w http.ResponseWriter, r *http.Request) {
     var venue models.Venue
-    if err := json.NewDecoder(r.Body).Decode(&venue); err != nil {
-        log.Printf("Error decoding venue data: %v", err)
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+    if err := apierror.Decode(r, &venue); err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
@@ -135,9 +110,8 @@ func CreateVenue(w http.ResponseWriter, r *http.Request) {
     // Generate secure QR payload (modified part)
     qrData, err := qr.GenerateSecureQR(venue.ID, 5*time.Minute)
     if err != nil {
-        log.Printf("Error generating QR secret: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate venue QR"})
+        slog.ErrorContext(r.Context(), "generating QR secret failed", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to generate venue QR"))
         return
     }
     venue.QRSecret = qrData
@@ -145,10 +119,28 @@ func CreateVenue(w http.ResponseWriter, r *http.Request) {
     venue.IsActive = true
     venue.CreatedBy = "admin1"
 
-    if err := models.CreateVenue(db, venue); err != nil {
-        log.Printf("Error creating venue: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Venue creation failed: " + err.Error()})
+    var availability []byte
+    if venue.Availability != nil {
+        if availability, err = json.Marshal(venue.Availability); err != nil {
+            apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to process availability", err))
+            return
+        }
+    }
+    err = h.store.Venues().Create(r.Context(), repository.Venue{
+        ID:            venue.ID,
+        Name:          venue.Name,
+        Capacity:      venue.Capacity,
+        Level:         venue.Level,
+        IsActive:      venue.IsActive,
+        SessionTiming: venue.SessionTiming,
+        TableDetails:  venue.TableDetails,
+        QRSecret:      venue.QRSecret,
+        CreatedBy:     venue.CreatedBy,
+        Availability:  availability,
+    })
+    if err != nil {
+        slog.ErrorContext(r.Context(), "creating venue failed", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Venue creation failed"))
         return
     }
 
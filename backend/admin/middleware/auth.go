@@ -3,9 +3,10 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"gd/admin/utils"
-	"log"
+	"gd/apierror"
+	"gd/logging"
+	"log/slog"
 	"net/http"
 	"strings"
 )
@@ -15,39 +16,36 @@ func AdminOnly(next http.Handler) http.Handler {
         // Get Authorization header
         authHeader := r.Header.Get("Authorization")
         if authHeader == "" {
-            log.Println("Authorization header is required")
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Authorization header is required"})
+            slog.WarnContext(r.Context(), "admin request without authorization header")
+            apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Authorization header is required"))
             return
         }
 
         // Check if it's Bearer token
         splitToken := strings.Split(authHeader, "Bearer ")
         if len(splitToken) != 2 {
-            log.Println("Invalid token format")
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Invalid token format"})
+            slog.WarnContext(r.Context(), "admin token has invalid format")
+            apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Invalid token format"))
             return
         }
 
         token := splitToken[1]
         claims, err := jwt.VerifyToken(token)
         if err != nil {
-            log.Printf("Token verification failed: %v", err)
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Invalid token"})
+            slog.WarnContext(r.Context(), "admin token verification failed", "error", err)
+            apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Invalid token"))
             return
         }
         
         if claims.Role != "admin" {
-            log.Printf("Unauthorized role: %s", claims.Role)
-            w.WriteHeader(http.StatusForbidden)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Insufficient permissions"})
+            slog.WarnContext(r.Context(), "admin token has wrong role", "role", claims.Role)
+            apierror.Write(w, r, apierror.New(http.StatusForbidden, "Insufficient permissions"))
             return
         }
         
         // Add user ID to context for downstream handlers
         ctx := context.WithValue(r.Context(), "userID", claims.UserID)
+        ctx = logging.With(ctx, slog.String("user_id", claims.UserID))
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
\ No newline at end of file
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	_ "time/tzdata" // venues name IANA zones; hosts may lack a zoneinfo database
+
+	"gd/apierror"
+)
+
+// DateLayout is how dates, such as blackout dates and holidays, are written.
+const DateLayout = "2006-01-02"
+
+// clockLayout is how the times of day of availability windows are written.
+const clockLayout = "15:04"
+
+// VenueAvailability is when a venue can hold sessions. Sessions are
+// generated from it a slot at a time.
+type VenueAvailability struct {
+	// Timezone is the IANA zone, such as Asia/Kolkata, of the windows. It
+	// defaults to the server's.
+	Timezone string `json:"timezone"`
+	// SlotMinutes is the length of each session.
+	SlotMinutes int                  `json:"slot_minutes"`
+	Windows     []AvailabilityWindow `json:"windows"`
+	// BlackoutDates are the dates the venue is closed on.
+	BlackoutDates []string `json:"blackout_dates"`
+}
+
+// AvailabilityWindow is a time of day the venue is open on some weekdays.
+// It holds as many back-to-back slots as fit.
+type AvailabilityWindow struct {
+	// Weekdays run from 0 for Sunday to 6 for Saturday.
+	Weekdays []int `json:"weekdays"`
+	// Start and End are times of day written as HH:MM.
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Slot is the time of one session.
+type Slot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Closure is a date a venue would have had slots on but is closed.
+type Closure struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
+
+func (a VenueAvailability) Validate() error {
+	var p apierror.Problems
+	_, err := a.Location()
+	p.Check(err == nil, "timezone", "is not a known time zone")
+	p.Check(a.SlotMinutes >= 10 && a.SlotMinutes <= 480, "slot_minutes", "must be from 10 to 480")
+	p.Check(len(a.Windows) > 0, "windows", "must list at least one window")
+
+	// open collects each weekday's windows to find overlaps.
+	open := map[int][][2]time.Duration{}
+	for i, w := range a.Windows {
+		field := fmt.Sprintf("windows[%d]", i)
+		p.Check(len(w.Weekdays) > 0, field+".weekdays", "must list at least one weekday")
+		start, errStart := clock(w.Start)
+		end, errEnd := clock(w.End)
+		p.Check(errStart == nil, field+".start", "must be a time of day as HH:MM")
+		p.Check(errEnd == nil, field+".end", "must be a time of day as HH:MM")
+		if errStart == nil && errEnd == nil {
+			p.Check(end-start >= time.Duration(a.SlotMinutes)*time.Minute, field+".end", "must leave room for at least one slot after start")
+		}
+		seen := map[int]bool{}
+		for j, day := range w.Weekdays {
+			dayField := fmt.Sprintf("%s.weekdays[%d]", field, j)
+			p.Check(day >= 0 && day <= 6, dayField, "must be from 0 (Sunday) to 6 (Saturday)")
+			p.Check(!seen[day], dayField, "is listed more than once")
+			if seen[day] {
+				continue
+			}
+			seen[day] = true
+			if errStart != nil || errEnd != nil {
+				continue
+			}
+			for _, other := range open[day] {
+				p.Check(end <= other[0] || start >= other[1], field, fmt.Sprintf("overlaps another window on %s", time.Weekday(day)))
+			}
+			open[day] = append(open[day], [2]time.Duration{start, end})
+		}
+	}
+
+	blackouts := map[string]bool{}
+	for i, date := range a.BlackoutDates {
+		field := fmt.Sprintf("blackout_dates[%d]", i)
+		_, err := time.Parse(DateLayout, date)
+		p.Check(err == nil, field, "must be a date as YYYY-MM-DD")
+		p.Check(!blackouts[date], field, "is listed more than once")
+		blackouts[date] = true
+	}
+	return p.Err()
+}
+
+// Location returns the zone of the windows.
+func (a VenueAvailability) Location() (*time.Location, error) {
+	if a.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(a.Timezone)
+}
+
+// Slots lists the slots of the dates from first to last, in order, in UTC.
+// Only the year, month and day of first and last are used. Blackout dates, and dates closed
+// returns a reason for, have no slots; those the venue would have been open
+// on are returned as closures. a must be valid.
+func (a VenueAvailability) Slots(first, last time.Time, closed func(date string) string) ([]Slot, []Closure) {
+	loc, _ := a.Location()
+	length := time.Duration(a.SlotMinutes) * time.Minute
+	var slots []Slot
+	var closures []Closure
+	for day := dateOf(first); !day.After(dateOf(last)); day = day.AddDate(0, 0, 1) {
+		var windows []AvailabilityWindow
+		for _, w := range a.Windows {
+			if slices.Contains(w.Weekdays, int(day.Weekday())) {
+				windows = append(windows, w)
+			}
+		}
+		if len(windows) == 0 {
+			continue
+		}
+		date := day.Format(DateLayout)
+		reason := ""
+		if slices.Contains(a.BlackoutDates, date) {
+			reason = "blackout date"
+		} else if closed != nil {
+			reason = closed(date)
+		}
+		if reason != "" {
+			closures = append(closures, Closure{Date: date, Reason: reason})
+			continue
+		}
+
+		var daySlots []Slot
+		for _, w := range windows {
+			start, _ := clock(w.Start)
+			end, _ := clock(w.End)
+			for at := start; at+length <= end; at += length {
+				// Built from the wall clock so slots keep their time of day
+				// across daylight saving changes, then kept in UTC like
+				// every time in the database.
+				s := time.Date(day.Year(), day.Month(), day.Day(), 0, int(at/time.Minute), 0, 0, loc).UTC()
+				daySlots = append(daySlots, Slot{Start: s, End: s.Add(length)})
+			}
+		}
+		slices.SortFunc(daySlots, func(x, y Slot) int { return x.Start.Compare(y.Start) })
+		slots = append(slots, daySlots...)
+	}
+	return slots, closures
+}
+
+// clock parses a time of day into its offset from midnight.
+func clock(s string) (time.Duration, error) {
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gd/apierror"
+)
+
+func TestAvailabilitySlots(t *testing.T) {
+	a := VenueAvailability{
+		Timezone:    "Asia/Kolkata",
+		SlotMinutes: 45,
+		Windows: []AvailabilityWindow{
+			{Weekdays: []int{1, 3}, Start: "14:00", End: "15:40"},
+			{Weekdays: []int{1}, Start: "09:30", End: "10:15"},
+		},
+		BlackoutDates: []string{"2026-11-04"},
+	}
+	if err := a.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	closed := func(date string) string {
+		if date == "2026-11-09" {
+			return "holiday: Founders' Day"
+		}
+		return ""
+	}
+	// Monday 2 November to Monday 9 November 2026.
+	slots, closures := a.Slots(time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 9, 0, 0, 0, 0, time.UTC), closed)
+
+	ist := time.FixedZone("IST", 5*3600+1800)
+	want := []time.Time{
+		time.Date(2026, 11, 2, 9, 30, 0, 0, ist),
+		time.Date(2026, 11, 2, 14, 0, 0, 0, ist),
+		time.Date(2026, 11, 2, 14, 45, 0, 0, ist),
+	}
+	if len(slots) != len(want) {
+		t.Fatalf("got %d slots, want %d: %v", len(slots), len(want), slots)
+	}
+	for i, s := range slots {
+		if !s.Start.Equal(want[i]) || s.End.Sub(s.Start) != 45*time.Minute {
+			t.Errorf("slot %d = %v to %v, want 45 minutes from %v", i, s.Start, s.End, want[i])
+		}
+		if s.Start.Location() != time.UTC {
+			t.Errorf("slot %d is in %v, want UTC", i, s.Start.Location())
+		}
+	}
+	// 09:30 in Kolkata is stored as 04:00 UTC.
+	if got := slots[0].Start.Format(time.DateTime); got != "2026-11-02 04:00:00" {
+		t.Errorf("first slot = %s UTC, want 2026-11-02 04:00:00", got)
+	}
+	wantClosures := []Closure{{"2026-11-04", "blackout date"}, {"2026-11-09", "holiday: Founders' Day"}}
+	if len(closures) != 2 || closures[0] != wantClosures[0] || closures[1] != wantClosures[1] {
+		t.Errorf("closures = %v, want %v", closures, wantClosures)
+	}
+}
+
+func TestAvailabilityValidate(t *testing.T) {
+	a := VenueAvailability{
+		Timezone:    "Mars/Olympus",
+		SlotMinutes: 30,
+		Windows: []AvailabilityWindow{
+			{Weekdays: []int{1, 1}, Start: "09:00", End: "12:00"},
+			{Weekdays: []int{1, 7}, Start: "11:00", End: "11:20"},
+		},
+		BlackoutDates: []string{"25/12/2026"},
+	}
+	err, _ := a.Validate().(*apierror.Error)
+	if err == nil {
+		t.Fatal("invalid availability passed validation")
+	}
+	var got []string
+	for _, f := range err.Fields {
+		got = append(got, f.Field)
+	}
+	want := []string{"timezone", "windows[0].weekdays[1]", "windows[1].end", "windows[1]", "windows[1].weekdays[1]", "blackout_dates[0]"}
+	if len(got) != len(want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("fields = %v, want %v", got, want)
+			break
+		}
+	}
+}
@@ -1,9 +1,7 @@
 package models
 
 import (
-	"database/sql"
-	"time"
-	
+	"gd/apierror"
 )
 
 type Venue struct {
@@ -15,18 +13,19 @@ type Venue struct {
 	IsActive  bool   `json:"is_active"`
 	CreatedBy string `json:"created_by"`
 	SessionTiming string `json:"session_timing"`
-	AvailableDays string `json:"available_days"`
-	StartTime string `json:"start_time"`
-	EndTime   string `json:"end_time"`
+	// Availability is when sessions can be generated at the venue, if set.
+	Availability *VenueAvailability `json:"availability"`
 	TableDetails  string `json:"table_details"`
 }
 
-func CreateVenue(db *sql.DB, v Venue) error {
-	_, err := db.Exec(
-		`INSERT INTO venues 
-		(id, name, capacity, level, qr_secret, is_active, created_by, session_timing, table_details, created_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		v.ID, v.Name, v.Capacity, v.Level, v.QRSecret, v.IsActive, v.CreatedBy, v.SessionTiming, v.TableDetails, time.Now(),
-	)
-	return err
-}
\ No newline at end of file
+// Validate checks the fields an admin supplies when creating a venue.
+func (v Venue) Validate() error {
+	var p apierror.Problems
+	p.Required("name", v.Name)
+	p.Check(v.Capacity > 0, "capacity", "must be greater than zero")
+	p.Level("level", v.Level)
+	if v.Availability != nil {
+		p.Nested("availability", v.Availability.Validate())
+	}
+	return p.Err()
+}
@@ -4,43 +4,57 @@ package routes
 import (
 	"gd/admin/controllers"
 	"gd/admin/middleware"
-	"log"
+	"gd/apierror"
 	// "strings"
 	// "log"
 	"net/http"
 )
 
-func SetupAdminRoutes() *http.ServeMux {
+// Router is the part of *http.ServeMux the route tables use, so tests can
+// record what gets registered.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+func SetupAdminRoutes(h *controllers.Handlers) *http.ServeMux {
 	router := http.NewServeMux()
+	RegisterAdminRoutes(router, h)
+	return router
+}
+
+// RegisterAdminRoutes adds every admin endpoint to router, serving those
+// backed by the repository layer from h. Each one must be described in
+// gd/openapi.
+func RegisterAdminRoutes(router Router, h *controllers.Handlers) {
 
 	// Auth routes
-	router.Handle("/admin/login", http.HandlerFunc(controllers.AdminLogin))
+	router.Handle("/admin/login", http.HandlerFunc(h.AdminLogin))
 
 	// QR routes
-    router.Handle("/admin/qr", middleware.AdminOnly(http.HandlerFunc(controllers.GenerateQR)))
+    router.Handle("/admin/qr", middleware.AdminOnly(http.HandlerFunc(h.GenerateQR)))
     
     // Session routes
-    router.Handle("/admin/sessions/bulk", middleware.AdminOnly(http.HandlerFunc(controllers.CreateBulkSessions)))
+    router.Handle("/admin/sessions/bulk", middleware.AdminOnly(http.HandlerFunc(h.CreateBulkSessions)))
     
     // Venue routes - single handler for both GET and POST
     router.Handle("/admin/venues", middleware.AdminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
     switch r.Method {
     case http.MethodGet:
-        controllers.GetVenues(w, r)
+        h.GetVenues(w, r)
     case http.MethodPost:
-        controllers.CreateVenue(w, r)
+        h.CreateVenue(w, r)
     case http.MethodPut:
-        controllers.UpdateVenue(w, r)
+        h.UpdateVenue(w, r)
     default:
-        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        apierror.MethodNotAllowed(w, r)
     }
 })))
 
 router.Handle("/admin/venues/", middleware.AdminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
     if r.Method == http.MethodPut {
-        controllers.UpdateVenue(w, r)
+        h.UpdateVenue(w, r)
     } else {
-        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        apierror.MethodNotAllowed(w, r)
     }
 })))
 
@@ -48,7 +62,7 @@ router.Handle("/admin/venues/", middleware.AdminOnly(http.HandlerFunc(func(w htt
 	// http.HandlerFunc(controllers.UpdateSessionRules)))
 
 router.Handle("/admin/analytics/qualifications", middleware.AdminOnly(
-	http.HandlerFunc(controllers.GetQualificationRates)))
+	http.HandlerFunc(h.GetQualificationRates)))
 
     router.Handle("/admin/calendar", middleware.AdminOnly(
     http.HandlerFunc(controllers.GetSessionCalendar)))
@@ -60,15 +74,15 @@ router.Handle("/admin/questions", middleware.AdminOnly(
     http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         switch r.Method {
         case http.MethodGet:
-            controllers.GetQuestions(w, r)
+            h.GetQuestions(w, r)
         case http.MethodPost:
-            controllers.CreateQuestion(w, r)
+            h.CreateQuestion(w, r)
         case http.MethodPut:
-            controllers.UpdateQuestion(w, r)
+            h.UpdateQuestion(w, r)
         case http.MethodDelete:
-            controllers.DeleteQuestion(w, r)
+            h.DeleteQuestion(w, r)
         default:
-            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            apierror.MethodNotAllowed(w, r)
         }
     }),
 ))
@@ -76,15 +90,15 @@ router.Handle("/admin/topics", middleware.AdminOnly(
     http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         switch r.Method {
         case http.MethodGet:
-            controllers.GetTopics(w, r)
+            h.GetTopics(w, r)
         case http.MethodPost:
-            controllers.CreateTopic(w, r)
+            h.CreateTopic(w, r)
         case http.MethodPut:
-            controllers.UpdateTopic(w, r)
+            h.UpdateTopic(w, r)
         case http.MethodDelete:
-            controllers.DeleteTopic(w, r)
+            h.DeleteTopic(w, r)
         default:
-            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            apierror.MethodNotAllowed(w, r)
         }
     }),
 ))
@@ -92,34 +106,32 @@ router.Handle("/admin/ranking-points", middleware.AdminOnly(
     http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         switch r.Method {
         case http.MethodGet:
-            controllers.GetRankingPointsConfig(w, r)
+            h.GetRankingPointsConfig(w, r)
         case http.MethodPost:
-            controllers.UpdateRankingPointsConfig(w, r)
+            h.UpdateRankingPointsConfig(w, r)
         case http.MethodDelete:
-            controllers.DeleteRankingPointsConfig(w, r)
+            h.DeleteRankingPointsConfig(w, r)
         default:
-            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            apierror.MethodNotAllowed(w, r)
         }
     }),
 ))
 
 router.Handle("/admin/ranking-points/toggle", middleware.AdminOnly(
-    http.HandlerFunc(controllers.ToggleRankingPointsConfig),
+    http.HandlerFunc(h.ToggleRankingPointsConfig),
 ))
 router.Handle("/admin/bookings", middleware.AdminOnly(
-    http.HandlerFunc(controllers.GetStudentBookings)))
+    http.HandlerFunc(h.GetStudentBookings)))
 router.Handle("/admin/rules", middleware.AdminOnly(
-    http.HandlerFunc(controllers.UpdateSessionRules)))
-	log.Println("Venue routes setup complete")
+    http.HandlerFunc(h.UpdateSessionRules)))
 router.Handle("/admin/qr/manage", middleware.AdminOnly(
-    http.HandlerFunc(controllers.GetVenueQRCodes)))
+    http.HandlerFunc(h.GetVenueQRCodes)))
 router.Handle("/admin/qr/deactivate", middleware.AdminOnly(
-    http.HandlerFunc(controllers.DeactivateQR)))
+    http.HandlerFunc(h.DeactivateQR)))
 router.Handle("/admin/results/top", middleware.AdminOnly(
-	http.HandlerFunc(controllers.GetTopParticipants)))
+	http.HandlerFunc(h.GetTopParticipants)))
 router.Handle("/admin/feedbacks", middleware.AdminOnly(
-    http.HandlerFunc(controllers.GetSessionFeedbacks)))
-	return router
+    http.HandlerFunc(h.GetSessionFeedbacks)))
 
 
 }
\ No newline at end of file
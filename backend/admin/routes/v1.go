@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"net/http"
+
+	"gd/admin/controllers"
+	"gd/admin/middleware"
+	"gd/routing"
+)
+
+// RegisterAdminV1 adds the /api/v1/admin resources to router. Patterns carry
+// their method, so ServeMux answers 405 for the rest and no handler needs a
+// method switch. Each route must be described in gd/openapi. Handlers
+// backed by the repository layer are served from h.
+func RegisterAdminV1(router Router, h *controllers.Handlers) {
+	public := func(h http.HandlerFunc) http.Handler { return routing.PathParams(h) }
+	admin := func(h http.HandlerFunc) http.Handler { return middleware.AdminOnly(routing.PathParams(h)) }
+
+	router.Handle("POST /api/v1/admin/login", public(h.AdminLogin))
+
+	router.Handle("GET /api/v1/admin/venues", admin(h.GetVenues))
+	router.Handle("POST /api/v1/admin/venues", admin(h.CreateVenue))
+	router.Handle("PUT /api/v1/admin/venues/{id}", admin(h.UpdateVenue))
+	router.Handle("PUT /api/v1/admin/venues/{venue_id}/availability", admin(h.SetVenueAvailability))
+
+	// A QR group is the code students scan at a venue, with its seat quota.
+	router.Handle("GET /api/v1/admin/venues/{venue_id}/qr-groups", admin(h.GetVenueQRCodes))
+	router.Handle("POST /api/v1/admin/venues/{venue_id}/qr-groups", admin(h.GenerateQR))
+	router.Handle("DELETE /api/v1/admin/qr-groups/{qr_id}", admin(h.DeactivateQR))
+
+	router.Handle("GET /api/v1/admin/sessions", admin(controllers.GetSessionCalendar))
+	router.Handle("POST /api/v1/admin/sessions", admin(h.CreateBulkSessions))
+	// Sessions can also be generated from venue availability, around holidays.
+	router.Handle("POST /api/v1/admin/sessions/generate", admin(h.GenerateSessions))
+	router.Handle("GET /api/v1/admin/holidays", admin(h.GetHolidays))
+	router.Handle("PUT /api/v1/admin/holidays/{date}", admin(h.SaveHoliday))
+	router.Handle("DELETE /api/v1/admin/holidays/{date}", admin(h.DeleteHoliday))
+	router.Handle("GET /api/v1/admin/sessions/{session_id}/rules", admin(h.GetSessionRules))
+	router.Handle("PUT /api/v1/admin/sessions/{session_id}/rules", admin(h.UpdateSessionRules))
+	router.Handle("GET /api/v1/admin/sessions/{session_id}/feedback", admin(h.GetSessionFeedbacks))
+	// Moderators mark participants against their level's rubric.
+	router.Handle("GET /api/v1/admin/sessions/{session_id}/rubric-scores", admin(h.GetRubricScores))
+	router.Handle("PUT /api/v1/admin/sessions/{session_id}/rubric-scores/{student_id}", admin(h.ScoreParticipant))
+	// Responses that look coordinated wait here before the session is finalized.
+	router.Handle("GET /api/v1/admin/collusion-flags", admin(h.GetCollusionFlags))
+	router.Handle("PUT /api/v1/admin/collusion-flags/{id}", admin(h.ReviewCollusionFlag))
+	router.Handle("GET /api/v1/admin/bookings", admin(h.GetStudentBookings))
+
+	router.Handle("GET /api/v1/admin/students", admin(controllers.GetStudentProgress))
+	router.Handle("GET /api/v1/admin/leaderboard", admin(h.GetTopParticipants))
+	router.Handle("GET /api/v1/admin/analytics/qualifications", admin(h.GetQualificationRates))
+
+	router.Handle("GET /api/v1/admin/questions", admin(h.GetQuestions))
+	router.Handle("GET /api/v1/admin/question-usage", admin(h.GetQuestionUsage))
+	router.Handle("POST /api/v1/admin/questions", admin(h.CreateQuestion))
+	router.Handle("PUT /api/v1/admin/questions/{id}", admin(h.UpdateQuestion))
+	router.Handle("DELETE /api/v1/admin/questions/{id}", admin(h.DeleteQuestion))
+	router.Handle("PUT /api/v1/admin/question-order/{level}", admin(h.ReorderQuestions))
+	// Bulk transfer as CSV or JSON; imports upsert by external key.
+	router.Handle("GET /api/v1/admin/questions/export", admin(h.ExportQuestions))
+	router.Handle("POST /api/v1/admin/questions/import", admin(h.ImportQuestions))
+	// Banks group questions by competency; a level's template draws from them.
+	router.Handle("GET /api/v1/admin/question-banks", admin(h.GetQuestionBanks))
+	router.Handle("POST /api/v1/admin/question-banks", admin(h.SaveQuestionBank))
+	router.Handle("PUT /api/v1/admin/question-banks/{id}", admin(h.SaveQuestionBank))
+	router.Handle("DELETE /api/v1/admin/question-banks/{id}", admin(h.DeleteQuestionBank))
+	router.Handle("GET /api/v1/admin/question-templates/{level}", admin(h.GetQuestionTemplate))
+	router.Handle("PUT /api/v1/admin/question-templates/{level}", admin(h.UpdateQuestionTemplate))
+
+	router.Handle("GET /api/v1/admin/topics", admin(h.GetTopics))
+	router.Handle("POST /api/v1/admin/topics", admin(h.CreateTopic))
+	router.Handle("PUT /api/v1/admin/topics/{id}", admin(h.UpdateTopic))
+	router.Handle("DELETE /api/v1/admin/topics/{id}", admin(h.DeleteTopic))
+	router.Handle("GET /api/v1/admin/topics/export", admin(h.ExportTopics))
+	router.Handle("POST /api/v1/admin/topics/import", admin(h.ImportTopics))
+	// Structured prep materials; attachments are uploaded as multipart files.
+	router.Handle("GET /api/v1/admin/topics/{topic_id}/materials", admin(h.GetPrepMaterials))
+	router.Handle("POST /api/v1/admin/topics/{topic_id}/materials", admin(h.CreatePrepMaterial))
+	router.Handle("POST /api/v1/admin/topics/{topic_id}/attachments", admin(h.UploadPrepMaterial))
+	router.Handle("PUT /api/v1/admin/prep-materials/{id}", admin(h.UpdatePrepMaterial))
+	router.Handle("DELETE /api/v1/admin/prep-materials/{id}", admin(h.DeletePrepMaterial))
+	router.Handle("GET /api/v1/admin/prep-materials/{id}/file", admin(h.DownloadPrepMaterial))
+
+	router.Handle("GET /api/v1/admin/ranking-points", admin(h.GetRankingPointsConfig))
+	router.Handle("POST /api/v1/admin/ranking-points", admin(h.UpdateRankingPointsConfig))
+	router.Handle("PUT /api/v1/admin/ranking-points/{id}", admin(h.UpdateRankingPointsConfig))
+	router.Handle("DELETE /api/v1/admin/ranking-points/{id}", admin(h.DeleteRankingPointsConfig))
+	router.Handle("PUT /api/v1/admin/ranking-points/{id}/active", admin(h.SetRankingPointsConfigActive))
+
+	router.Handle("GET /api/v1/admin/rubrics/{level}", admin(h.GetRubric))
+	router.Handle("PUT /api/v1/admin/rubrics/{level}", admin(h.UpdateRubric))
+}
@@ -1,14 +1,17 @@
 package jwt
 
 import (
-	"log"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var secret = []byte(os.Getenv("JWT_SECRET"))
+var secret []byte
+
+// SetSecret installs the key used to sign and verify admin tokens.
+func SetSecret(key string) {
+	secret = []byte(key)
+}
 
 type Claims struct {
 	UserID string `json:"user_id"`
@@ -31,7 +34,6 @@ func GenerateToken(userID, role string) (string, error) {
 
 func VerifyToken(tokenString string) (*Claims, error) {
     if tokenString == "" {
-        log.Println("Empty token string provided")
         return nil, jwt.ErrInvalidKey
     }
 
@@ -40,7 +42,6 @@ func VerifyToken(tokenString string) (*Claims, error) {
     })
     
     if err != nil {
-        log.Printf("Token parsing error: %v", err)
         return nil, err
     }
     
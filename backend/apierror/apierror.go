@@ -0,0 +1,144 @@
+// Package apierror defines the JSON error envelope returned by every admin and
+// student endpoint:
+//
+//	{"error": "Capacity must be greater than zero", "code": "validation_failed",
+//	 "fields": [{"field": "capacity", "message": "must be greater than zero"}],
+//	 "request_id": "..."}
+//
+// "error" stays a human-readable string so existing clients keep working;
+// "code" is the stable value apps should switch on.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gd/logging"
+)
+
+// Machine-readable error codes. Handlers may use more specific codes where a
+// client needs to react differently, e.g. CodeQRFull.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeInternal         = "internal_error"
+
+	CodeQRInvalid  = "qr_invalid"
+	CodeQRInactive = "qr_inactive"
+	CodeQRFull     = "qr_full"
+
+	CodeSimilarTopic     = "similar_topic"
+	CodeScheduleConflict = "schedule_conflict"
+)
+
+// FieldError describes one invalid input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an HTTP error response. Err is the underlying cause; it is logged
+// but never sent to the client.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error with the default code for status.
+func New(status int, message string) *Error {
+	return &Error{Status: status, Code: codeFor(status), Message: message}
+}
+
+// Wrap is New with an underlying cause for the logs.
+func Wrap(status int, message string, err error) *Error {
+	e := New(status, message)
+	e.Err = err
+	return e
+}
+
+// WithCode returns a copy of e carrying a more specific code.
+func (e *Error) WithCode(code string) *Error {
+	c := *e
+	c.Code = code
+	return &c
+}
+
+func codeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+type envelope struct {
+	Error     string       `json:"error"`
+	Code      string       `json:"code"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Write sends err as the JSON envelope. Errors that are not an *Error are
+// reported as a generic 500 so internal details never reach the client.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Wrap(http.StatusInternalServerError, "Internal server error", err)
+	}
+
+	ctx := r.Context()
+	if e.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, e.Message, "status", e.Status, "code", e.Code, "error", e.Err)
+	} else {
+		slog.DebugContext(ctx, e.Message, "status", e.Status, "code", e.Code, "error", e.Err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(envelope{
+		Error:     e.Message,
+		Code:      e.Code,
+		Fields:    e.Fields,
+		RequestID: logging.RequestID(ctx),
+	})
+}
+
+// MethodNotAllowed is the shared response for routes hit with the wrong verb.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusMethodNotAllowed, fmt.Sprintf("Method %s not allowed", r.Method)))
+}
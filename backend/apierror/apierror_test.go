@@ -0,0 +1,132 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type body struct {
+	Error     string       `json:"error"`
+	Code      string       `json:"code"`
+	Fields    []FieldError `json:"fields"`
+	RequestID string       `json:"request_id"`
+}
+
+func write(t *testing.T, err error) (int, body) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Write(rec, httptest.NewRequest(http.MethodGet, "/", nil), err)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var b body
+	if err := json.NewDecoder(rec.Body).Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, b
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{"not found", New(http.StatusNotFound, "Session not found"), 404, CodeNotFound, "Session not found"},
+		{"custom code", New(http.StatusForbidden, "Full").WithCode(CodeQRFull), 403, CodeQRFull, "Full"},
+		{"wrapped cause hidden", Wrap(http.StatusInternalServerError, "Database error", errors.New("dial tcp: refused")), 500, CodeInternal, "Database error"},
+		{"plain error", errors.New("secret detail"), 500, CodeInternal, "Internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, b := write(t, tt.err)
+			if status != tt.wantStatus || b.Code != tt.wantCode || b.Error != tt.wantMsg {
+				t.Errorf("got %d %+v, want %d %s %q", status, b, tt.wantStatus, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestProblems(t *testing.T) {
+	var p Problems
+	if p.Err() != nil {
+		t.Fatal("empty Problems reported an error")
+	}
+	p.Required("name", "  ")
+	p.Check(0 > 0, "capacity", "must be greater than zero")
+	p.Level("level", 4)
+
+	var nested Problems
+	nested.Required("venue_id", "")
+	p.Nested("sessions[1]", nested.Err())
+
+	status, b := write(t, p.Err())
+	if status != http.StatusBadRequest || b.Code != CodeValidation {
+		t.Fatalf("got %d %s", status, b.Code)
+	}
+	var fields []string
+	for _, f := range b.Fields {
+		fields = append(fields, f.Field)
+	}
+	if got := strings.Join(fields, ","); got != "name,capacity,level,sessions[1].venue_id" {
+		t.Errorf("fields = %s", got)
+	}
+	if !strings.Contains(b.Error, "capacity must be greater than zero") {
+		t.Errorf("message = %q", b.Error)
+	}
+}
+
+type widget struct {
+	Size int `json:"size"`
+}
+
+func (w widget) Validate() error {
+	var p Problems
+	p.Check(w.Size > 0, "size", "must be positive")
+	return p.Err()
+}
+
+func TestDecode(t *testing.T) {
+	decode := func(s string) error {
+		var w widget
+		return Decode(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(s)), &w)
+	}
+	if err := decode(`{"size": 2}`); err != nil {
+		t.Errorf("valid body: %v", err)
+	}
+	var e *Error
+	if err := decode(`{`); !errors.As(err, &e) || e.Code != CodeBadRequest {
+		t.Errorf("malformed body: %v", err)
+	}
+	if err := decode(`{"size": 0}`); !errors.As(err, &e) || e.Code != CodeValidation {
+		t.Errorf("invalid body: %v", err)
+	}
+}
+
+func TestDecodeFillsFromPath(t *testing.T) {
+	var got struct {
+		SessionID  string `json:"session_id"`
+		QuestionID int    `json:"question_id"`
+	}
+	mux := http.NewServeMux()
+	var err error
+	mux.HandleFunc("POST /s/{session_id}/q/{question_id}", func(w http.ResponseWriter, r *http.Request) {
+		err = Decode(r, &got)
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/s/abc/q/7", strings.NewReader(`{"session_id":"body"}`)))
+	if err != nil || got.SessionID != "abc" || got.QuestionID != 7 {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	var e *Error
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/s/abc/q/x", nil))
+	if !errors.As(err, &e) || e.Code != CodeValidation {
+		t.Errorf("non-numeric question_id: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Validator is implemented by request bodies that can check themselves.
+type Validator interface {
+	Validate() error
+}
+
+// Problems collects field errors while a request body is being checked.
+type Problems struct {
+	fields []FieldError
+}
+
+// Add records a problem with field.
+func (p *Problems) Add(field, message string) {
+	p.fields = append(p.fields, FieldError{Field: field, Message: message})
+}
+
+// Check records message against field unless ok holds.
+func (p *Problems) Check(ok bool, field, message string) {
+	if !ok {
+		p.Add(field, message)
+	}
+}
+
+// Required records a problem when value is blank.
+func (p *Problems) Required(field, value string) {
+	p.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// Level records a problem unless level is one of the three GD levels.
+func (p *Problems) Level(field string, level int) {
+	p.Check(level >= 1 && level <= 3, field, "must be between 1 and 3")
+}
+
+// Err returns a validation error listing every problem, or nil.
+func (p *Problems) Err() error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	parts := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	msg := "Invalid request: " + strings.Join(parts, "; ")
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Message: msg, Fields: p.fields}
+}
+
+// Decode reads a JSON body into v and, if v is a Validator, validates it.
+// An empty body decodes as {}. Path wildcards override top-level fields with
+// the same JSON name, so a route like /sessions/{session_id}/feedback does not
+// need session_id repeated in the body.
+func Decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return Wrap(http.StatusBadRequest, "Invalid request format", err)
+	}
+	if err := fillFromPath(r, v); err != nil {
+		return err
+	}
+	if val, ok := v.(Validator); ok {
+		return val.Validate()
+	}
+	return nil
+}
+
+func fillFromPath(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		value := r.PathValue(name)
+		if value == "" {
+			continue
+		}
+		field := rv.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				var p Problems
+				p.Add(name, "must be a whole number")
+				return p.Err()
+			}
+			field.SetInt(n)
+		}
+	}
+	return nil
+}
+
+// Nested copies the field errors of err, a validation error from a nested
+// value, prefixing each field with prefix (e.g. "sessions[2]"). An empty
+// prefix merges the fields unchanged.
+func (p *Problems) Nested(prefix string, err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		if err != nil {
+			p.Add(prefix, err.Error())
+		}
+		return
+	}
+	for _, f := range e.Fields {
+		if prefix != "" {
+			f.Field = prefix + "." + f.Field
+		}
+		p.Add(f.Field, f.Message)
+	}
+}
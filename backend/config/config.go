@@ -0,0 +1,231 @@
+// Package config loads the server configuration from flags, the process
+// environment and an optional .env file, in that order of precedence.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	// DevStudentSecret is the student signing key used in development when
+	// JWT_SECRET_STUDENT is unset. It is rejected in production.
+	DevStudentSecret = "password123"
+
+	defaultEnvFile = "../.env"
+	minSecretLen   = 32
+)
+
+type Config struct {
+	// Env is "development" or "production" (APP_ENV).
+	Env  string
+	Port string
+	// DatabaseURL is a go-sql-driver/mysql DSN; a "mysql://" prefix is tolerated (DB_URL).
+	DatabaseURL string
+	// AdminJWTSecret signs admin tokens (JWT_SECRET).
+	AdminJWTSecret string
+	// StudentJWTSecret signs student tokens (JWT_SECRET_STUDENT).
+	StudentJWTSecret string
+
+	// Connection pool limits for the MySQL handle (DB_MAX_OPEN_CONNS,
+	// DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME).
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	// ShutdownTimeout bounds how long in-flight requests may take to drain
+	// after SIGTERM (SHUTDOWN_TIMEOUT).
+	ShutdownTimeout time.Duration
+
+	// MetricsToken, when set, must be presented as a bearer token to scrape
+	// /metrics (METRICS_TOKEN).
+	MetricsToken string
+
+	// UploadDir is the directory uploaded files, such as prep material
+	// attachments, are kept in (UPLOAD_DIR).
+	UploadDir string
+
+	// LogLevel is debug, info, warn or error (LOG_LEVEL). LogFormat is text
+	// or json (LOG_FORMAT) and defaults to json in production.
+	LogLevel  string
+	LogFormat string
+}
+
+// Load builds a Config from command-line args (without the program name),
+// the environment and the env file named by -env-file. A missing env file is
+// only an error when -env-file was given explicitly.
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("gd", flag.ContinueOnError)
+	envFile := fs.String("env-file", defaultEnvFile, "path to a .env file")
+	appEnv := fs.String("app-env", "", "development or production (overrides APP_ENV)")
+	port := fs.String("port", "", "HTTP listen port (overrides PORT)")
+	logLevel := fs.String("log-level", "", "debug, info, warn or error (overrides LOG_LEVEL)")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	explicit := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "env-file" {
+			explicit = true
+		}
+	})
+
+	fileVars, err := godotenv.Read(*envFile)
+	if err != nil {
+		if explicit || !errors.Is(err, os.ErrNotExist) {
+			return Config{}, fmt.Errorf("reading %s: %w", *envFile, err)
+		}
+		fileVars = map[string]string{}
+	}
+
+	lookup := func(key string) string {
+		if v, ok := os.LookupEnv(key); ok {
+			return strings.TrimSpace(v)
+		}
+		return strings.TrimSpace(fileVars[key])
+	}
+
+	cfg := Config{
+		Env:              lookup("APP_ENV"),
+		Port:             lookup("PORT"),
+		DatabaseURL:      strings.TrimPrefix(lookup("DB_URL"), "mysql://"),
+		AdminJWTSecret:   lookup("JWT_SECRET"),
+		StudentJWTSecret: lookup("JWT_SECRET_STUDENT"),
+		MetricsToken:     lookup("METRICS_TOKEN"),
+		UploadDir:        lookup("UPLOAD_DIR"),
+		LogLevel:         strings.ToLower(lookup("LOG_LEVEL")),
+		LogFormat:        strings.ToLower(lookup("LOG_FORMAT")),
+	}
+	var problems []string
+	intVar := func(key string, def int) int {
+		v := lookup(key)
+		if v == "" {
+			return def
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s must be an integer, got %q", key, v))
+		}
+		return n
+	}
+	durationVar := func(key string, def time.Duration) time.Duration {
+		v := lookup(key)
+		if v == "" {
+			return def
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s must be a duration such as 30s, got %q", key, v))
+		}
+		return d
+	}
+	cfg.DBMaxOpenConns = intVar("DB_MAX_OPEN_CONNS", 25)
+	cfg.DBMaxIdleConns = intVar("DB_MAX_IDLE_CONNS", 10)
+	cfg.DBConnMaxLifetime = durationVar("DB_CONN_MAX_LIFETIME", 5*time.Minute)
+	cfg.ShutdownTimeout = durationVar("SHUTDOWN_TIMEOUT", 30*time.Second)
+	if len(problems) > 0 {
+		return Config{}, errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+
+	if *appEnv != "" {
+		cfg.Env = *appEnv
+	}
+	if *port != "" {
+		cfg.Port = *port
+	}
+	if *logLevel != "" {
+		cfg.LogLevel = strings.ToLower(*logLevel)
+	}
+
+	if cfg.Env == "" {
+		cfg.Env = EnvDevelopment
+	}
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	if cfg.UploadDir == "" {
+		cfg.UploadDir = "uploads"
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = "text"
+		if cfg.Env == EnvProduction {
+			cfg.LogFormat = "json"
+		}
+	}
+	if cfg.StudentJWTSecret == "" && cfg.Env != EnvProduction {
+		cfg.StudentJWTSecret = DevStudentSecret
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports every problem with the configuration at once. Secrets are
+// only checked for strength in production.
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		problems = append(problems, fmt.Sprintf("APP_ENV must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+	if c.DatabaseURL == "" {
+		problems = append(problems, "DB_URL is required")
+	}
+	if c.AdminJWTSecret == "" {
+		problems = append(problems, "JWT_SECRET is required")
+	}
+	if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		problems = append(problems, "DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT must be positive")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT must be text or json, got %q", c.LogFormat))
+	}
+
+	if c.Env == EnvProduction {
+		secrets := []struct{ name, value string }{
+			{"JWT_SECRET", c.AdminJWTSecret},
+			{"JWT_SECRET_STUDENT", c.StudentJWTSecret},
+		}
+		for _, s := range secrets {
+			switch {
+			case s.value == "":
+				if s.name != "JWT_SECRET" { // already reported above
+					problems = append(problems, s.name+" is required in production")
+				}
+			case s.value == DevStudentSecret:
+				problems = append(problems, s.name+" must not use the development default in production")
+			case len(s.value) < minSecretLen:
+				problems = append(problems, fmt.Sprintf("%s must be at least %d characters in production", s.name, minSecretLen))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeEnvFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"APP_ENV", "PORT", "DB_URL", "JWT_SECRET", "JWT_SECRET_STUDENT",
+		"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "SHUTDOWN_TIMEOUT", "METRICS_TOKEN",
+		"UPLOAD_DIR", "LOG_LEVEL", "LOG_FORMAT"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeEnvFile(t, "DB_URL=mysql://root:pw@tcp(db:3306)/gd\nJWT_SECRET= from-file \nPORT=9000\n")
+	t.Setenv("PORT", "9100")
+
+	cfg, err := Load([]string{"-env-file", path, "-port", "9200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9200" {
+		t.Errorf("Port = %q, want flag value 9200", cfg.Port)
+	}
+	if cfg.DatabaseURL != "root:pw@tcp(db:3306)/gd" {
+		t.Errorf("DatabaseURL = %q, want mysql:// prefix stripped", cfg.DatabaseURL)
+	}
+	if cfg.AdminJWTSecret != "from-file" {
+		t.Errorf("AdminJWTSecret = %q, want trimmed file value", cfg.AdminJWTSecret)
+	}
+	if cfg.Env != EnvDevelopment || cfg.StudentJWTSecret != DevStudentSecret {
+		t.Errorf("got env %q student secret %q, want development defaults", cfg.Env, cfg.StudentJWTSecret)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	clearEnv(t)
+
+	if _, err := Load([]string{"-env-file", filepath.Join(t.TempDir(), "missing.env")}); err == nil {
+		t.Error("explicit missing env file accepted")
+	}
+
+	t.Chdir(t.TempDir())
+	t.Setenv("DB_URL", "root@/gd")
+	t.Setenv("JWT_SECRET", "dev")
+	if _, err := Load(nil); err != nil {
+		t.Errorf("missing default env file rejected: %v", err)
+	}
+}
+
+func TestValidateProduction(t *testing.T) {
+	strong := strings.Repeat("k", minSecretLen)
+	pool := func(c Config) Config {
+		c.DBMaxOpenConns, c.DBMaxIdleConns, c.ShutdownTimeout = 25, 10, time.Second
+		if c.LogLevel == "" {
+			c.LogLevel = "info"
+		}
+		c.LogFormat = "json"
+		return c
+	}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"valid", pool(Config{Env: EnvProduction, DatabaseURL: "dsn", AdminJWTSecret: strong, StudentJWTSecret: strong + "s"}), ""},
+		{"empty admin secret", pool(Config{Env: EnvProduction, DatabaseURL: "dsn", StudentJWTSecret: strong}), "JWT_SECRET is required"},
+		{"empty student secret", pool(Config{Env: EnvProduction, DatabaseURL: "dsn", AdminJWTSecret: strong}), "JWT_SECRET_STUDENT is required"},
+		{"default student secret", pool(Config{Env: EnvProduction, DatabaseURL: "dsn", AdminJWTSecret: strong, StudentJWTSecret: DevStudentSecret}), "development default"},
+		{"short secret", pool(Config{Env: EnvProduction, DatabaseURL: "dsn", AdminJWTSecret: "short", StudentJWTSecret: strong}), "at least"},
+		{"missing database", pool(Config{Env: EnvProduction, AdminJWTSecret: strong, StudentJWTSecret: strong}), "DB_URL is required"},
+		{"unknown env", pool(Config{Env: "staging", DatabaseURL: "dsn", AdminJWTSecret: "x"}), "APP_ENV"},
+		{"weak secrets in development", pool(Config{Env: EnvDevelopment, DatabaseURL: "dsn", AdminJWTSecret: "x", StudentJWTSecret: DevStudentSecret}), ""},
+		{"idle above open", Config{Env: EnvDevelopment, DatabaseURL: "dsn", AdminJWTSecret: "x", DBMaxOpenConns: 5, DBMaxIdleConns: 10, ShutdownTimeout: time.Second, LogLevel: "info", LogFormat: "text"}, "DB_MAX_IDLE_CONNS"},
+		{"bad log level", pool(Config{Env: EnvDevelopment, DatabaseURL: "dsn", AdminJWTSecret: "x", LogLevel: "verbose"}), "LOG_LEVEL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadRefusesProductionDefaults(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_URL", "root@/gd")
+	t.Setenv("JWT_SECRET", strings.Repeat("a", minSecretLen))
+
+	_, err := Load([]string{"-env-file", writeEnvFile(t, ""), "-app-env", "production"})
+	if err == nil || !strings.Contains(err.Error(), "JWT_SECRET_STUDENT") {
+		t.Fatalf("error = %v, want missing student secret", err)
+	}
+}
+
+func TestLoadPoolSettings(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_URL", "root@/gd")
+	t.Setenv("JWT_SECRET", "dev")
+	path := writeEnvFile(t, "")
+
+	cfg, err := Load([]string{"-env-file", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBMaxOpenConns != 25 || cfg.DBMaxIdleConns != 10 || cfg.DBConnMaxLifetime != 5*time.Minute || cfg.ShutdownTimeout != 30*time.Second ||
+		cfg.UploadDir != "uploads" {
+		t.Errorf("defaults = %+v", cfg)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "50")
+	t.Setenv("SHUTDOWN_TIMEOUT", "10s")
+	if cfg, err = Load([]string{"-env-file", path}); err != nil || cfg.DBMaxOpenConns != 50 || cfg.ShutdownTimeout != 10*time.Second {
+		t.Errorf("overrides = %+v, %v", cfg, err)
+	}
+
+	t.Setenv("DB_CONN_MAX_LIFETIME", "forever")
+	if _, err := Load([]string{"-env-file", path}); err == nil || !strings.Contains(err.Error(), "DB_CONN_MAX_LIFETIME") {
+		t.Errorf("error = %v, want bad duration reported", err)
+	}
+}
+
+func TestLoadLogging(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_URL", "root@/gd")
+	t.Setenv("JWT_SECRET", "dev")
+	path := writeEnvFile(t, "LOG_LEVEL=WARN\n")
+
+	cfg, err := Load([]string{"-env-file", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != "warn" || cfg.LogFormat != "text" {
+		t.Errorf("got level %q format %q, want warn/text", cfg.LogLevel, cfg.LogFormat)
+	}
+
+	if cfg, err = Load([]string{"-env-file", path, "-log-level", "debug"}); err != nil || cfg.LogLevel != "debug" {
+		t.Errorf("flag override = %q, %v", cfg.LogLevel, err)
+	}
+}
@@ -1,37 +1,37 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"log"
-	"os"
-	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 var DB *sql.DB
 
+// PoolConfig bounds the connection pool kept by database/sql.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
 // Initialize handles all database setup
-func Initialize() error {
-	// Load .env file
-	err := godotenv.Load("../.env")
+func Initialize(dsn string, pool PoolConfig) error {
+	dsn, err := connectionDSN(dsn)
 	if err != nil {
 		return err
 	}
 
-	// Get and parse database connection string
-	dbURL := os.Getenv("DB_URL")
-	dbURL = strings.TrimPrefix(dbURL, "mysql://")
-	if dbURL == "" {
-		log.Fatal("DB_URL not set in .env file")
-	}
-
 	// Create connection
-	db, err := sql.Open("mysql", dbURL)
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return err
 	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
 
 	// Verify connection
 	if err := db.Ping(); err != nil {
@@ -42,8 +42,38 @@ func Initialize() error {
 	return InitDB(db)
 }
 
+// connectionDSN sets the options every connection relies on. parseTime
+// makes the driver return DATETIME and TIMESTAMP columns as time.Time, which
+// the repository scans them into; without it session lookups fail. The rest
+// keeps times in UTC: the driver parses and writes DATETIME values as UTC,
+// and MySQL's NOW() and TIMESTAMP columns use UTC too, so times written by Go
+// and by SQL agree whatever the server's zone. Rows older versions wrote in
+// the server's zone are moved to UTC once by convertLocalDatetimes.
+func connectionDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	cfg.Params["time_zone"] = "'+00:00'"
+	return cfg.FormatDSN(), nil
+}
+
 // GetDB returns the global database connection
 func GetDB() *sql.DB {
 	return DB
 }
 
+
+// CleanupPhaseTracking removes phase tracking rows older than 30 minutes so
+// students who left without finishing no longer count as present.
+func CleanupPhaseTracking(ctx context.Context) error {
+	_, err := GetDB().ExecContext(ctx, `
+		DELETE FROM session_phase_tracking
+		WHERE start_time < DATE_SUB(NOW(), INTERVAL 30 MINUTE)`)
+	return err
+}
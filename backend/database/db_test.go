@@ -0,0 +1,25 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestConnectionDSN(t *testing.T) {
+	dsn, err := connectionDSN("gd:secret@tcp(db:3306)/gd?loc=Local&parseTime=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.ParseTime || cfg.Loc != time.UTC || cfg.Params["time_zone"] != "'+00:00'" {
+		t.Errorf("dsn %q: parseTime %v, loc %v, time_zone %q; want parsed times in UTC throughout", dsn, cfg.ParseTime, cfg.Loc, cfg.Params["time_zone"])
+	}
+	if cfg.User != "gd" || cfg.Addr != "db:3306" || cfg.DBName != "gd" {
+		t.Errorf("dsn %q lost the connection details", dsn)
+	}
+}
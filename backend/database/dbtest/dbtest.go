@@ -0,0 +1,175 @@
+// Package dbtest provides a scripted database/sql driver for testing code
+// that runs SQL directly. It does not interpret SQL: each query is answered
+// by the first scripted reply whose fragment it contains, and every
+// statement is recorded so tests can check what was run.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DB is a scripted database.
+type DB struct {
+	mu      sync.Mutex
+	replies []reply
+	log     []string
+}
+
+type reply struct {
+	fragment string
+	columns  []string
+	rows     [][]driver.Value
+	err      error
+	// affected is the rows affected reported by Exec, if set.
+	affected *int64
+}
+
+// Open returns a *sql.DB backed by a new script.
+func Open() (*sql.DB, *DB) {
+	d := &DB{}
+	return sql.OpenDB(connector{d}), d
+}
+
+// Rows answers queries containing fragment with the rows, whose values are
+// in the order of columns.
+func (d *DB) Rows(fragment string, columns []string, rows ...[]any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := reply{fragment: fragment, columns: columns}
+	for _, row := range rows {
+		values := make([]driver.Value, len(row))
+		for i, v := range row {
+			values[i] = v
+		}
+		r.rows = append(r.rows, values)
+	}
+	d.replies = append(d.replies, r)
+}
+
+// Fail makes statements containing fragment fail with err.
+func (d *DB) Fail(fragment string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.replies = append(d.replies, reply{fragment: fragment, err: err})
+}
+
+// Affects makes statements containing fragment report n rows affected
+// rather than 1.
+func (d *DB) Affects(fragment string, n int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.replies = append(d.replies, reply{fragment: fragment, affected: &n})
+}
+
+// Statements returns every statement run, in order, with its whitespace
+// collapsed. Transactions are logged as BEGIN, COMMIT and ROLLBACK.
+func (d *DB) Statements() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.log...)
+}
+
+// Ran reports whether a statement containing fragment was run.
+func (d *DB) Ran(fragment string) bool {
+	for _, stmt := range d.Statements() {
+		if strings.Contains(stmt, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DB) run(query string) (reply, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	query = strings.Join(strings.Fields(query), " ")
+	d.log = append(d.log, query)
+	for _, r := range d.replies {
+		if strings.Contains(query, r.fragment) {
+			return r, r.err
+		}
+	}
+	return reply{}, nil
+}
+
+type connector struct{ d *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn(c), nil }
+func (c connector) Driver() driver.Driver                        { return c }
+func (c connector) Open(string) (driver.Conn, error)             { return conn(c), nil }
+
+type conn struct{ d *DB }
+
+func (c conn) Prepare(query string) (driver.Stmt, error) { return stmt{c.d, query}, nil }
+func (c conn) Close() error                              { return nil }
+
+func (c conn) Begin() (driver.Tx, error) {
+	c.d.run("BEGIN")
+	return tx(c), nil
+}
+
+func (c conn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	r, err := c.d.run(query)
+	if r.affected != nil {
+		return driver.RowsAffected(*r.affected), err
+	}
+	return driver.RowsAffected(1), err
+}
+
+func (c conn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	r, err := c.d.run(query)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: r.columns, rows: r.rows}, nil
+}
+
+type tx struct{ d *DB }
+
+func (t tx) Commit() error {
+	t.d.run("COMMIT")
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.d.run("ROLLBACK")
+	return nil
+}
+
+type stmt struct {
+	d     *DB
+	query string
+}
+
+func (s stmt) Close() error  { return nil }
+func (s stmt) NumInput() int { return -1 }
+
+func (s stmt) Exec([]driver.Value) (driver.Result, error) {
+	return conn{s.d}.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s stmt) Query([]driver.Value) (driver.Rows, error) {
+	return conn{s.d}.QueryContext(context.Background(), s.query, nil)
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -54,15 +53,23 @@ func InitDB(db *sql.DB) error {
             is_active BOOLEAN DEFAULT TRUE,
             session_timing VARCHAR(50) NOT NULL,
             table_details VARCHAR(50) NOT NULL,
+            availability JSON NULL,
             created_by VARCHAR(36),
             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             FOREIGN KEY (created_by) REFERENCES admin_users(id) ON DELETE SET NULL
         )`,
 
+        // Dates no venue holds generated sessions on
+        `CREATE TABLE IF NOT EXISTS holidays (
+            date DATE PRIMARY KEY,
+            name VARCHAR(100) NOT NULL
+        )`,
+
         // Session tables
         `CREATE TABLE IF NOT EXISTS gd_sessions (
             id VARCHAR(36) PRIMARY KEY,
             topic TEXT NOT NULL,
+            topic_id VARCHAR(36) NULL,
             venue_id VARCHAR(36),
             level INT NOT NULL,
             start_time TIMESTAMP NOT NULL,
@@ -132,10 +139,43 @@ func InitDB(db *sql.DB) error {
     level INT NOT NULL,
     topic_text TEXT NOT NULL,
     prep_materials JSON,
+    category VARCHAR(100) NULL,
+    difficulty ENUM('easy', 'medium', 'hard') NULL,
+    source VARCHAR(255) NULL,
+    external_key VARCHAR(100) NULL,
     is_active BOOLEAN DEFAULT TRUE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    UNIQUE KEY unique_level_topic (level, topic_text(255))
+    UNIQUE KEY unique_level_topic (level, topic_text(255)),
+    UNIQUE KEY unique_topic_external_key (external_key),
+    FULLTEXT INDEX ft_topic_text (topic_text)
+)`,
+
+`CREATE TABLE IF NOT EXISTS topic_tags (
+    topic_id VARCHAR(36) NOT NULL,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (topic_id, tag),
+    INDEX idx_topic_tags_tag (tag),
+    FOREIGN KEY (topic_id) REFERENCES gd_topics(id) ON DELETE CASCADE
+)`,
+
+`CREATE TABLE IF NOT EXISTS prep_materials (
+    id VARCHAR(36) PRIMARY KEY,
+    topic_id VARCHAR(36) NOT NULL,
+    kind ENUM('link', 'key_point', 'argument_for', 'argument_against', 'attachment') NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    body TEXT NULL,
+    url VARCHAR(2048) NULL,
+    file_key VARCHAR(255) NULL,
+    file_name VARCHAR(255) NULL,
+    content_type VARCHAR(100) NULL,
+    size_bytes BIGINT NULL,
+    visible_from ENUM('booked', 'prep', 'discussion', 'survey') NOT NULL DEFAULT 'prep',
+    visible_until ENUM('booked', 'prep', 'discussion', 'survey') NULL,
+    display_order INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_prep_materials_topic (topic_id, display_order),
+    FOREIGN KEY (topic_id) REFERENCES gd_topics(id) ON DELETE CASCADE
 )`,
 
 `CREATE TABLE IF NOT EXISTS session_phase_tracking (
@@ -158,8 +198,7 @@ func InitDB(db *sql.DB) error {
     created_by VARCHAR(36),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    FOREIGN KEY (created_by) REFERENCES admin_users(id) ON DELETE SET NULL,
-    UNIQUE KEY unique_level_config (level)
+    FOREIGN KEY (created_by) REFERENCES admin_users(id) ON DELETE SET NULL
 );`,
 
 `CREATE TABLE IF NOT EXISTS survey_results (
@@ -222,7 +261,7 @@ INDEX idx_survey_results_session_completed (session_id, is_completed),
 
 `CREATE TABLE IF NOT EXISTS question_timers (
     session_id VARCHAR(36),
-    question_id INT,
+    question_id VARCHAR(36),
     end_time DATETIME NOT NULL,
     PRIMARY KEY (session_id, question_id),
     FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE
@@ -234,17 +273,37 @@ INDEX idx_survey_results_session_completed (session_id, is_completed),
     question_text TEXT NOT NULL,
     weight DECIMAL(3,1) DEFAULT 1.0,
     is_active BOOLEAN DEFAULT TRUE,
-    level INT DEFAULT 1 ,
-    display_order INT DEFAULT 0,
+    bank_id VARCHAR(36) NULL,
+    archived_at DATETIME NULL,
+    external_key VARCHAR(100) NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_question_external_key (external_key)
 )`,
 
 
+`CREATE TABLE IF NOT EXISTS question_banks (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_bank_name (name)
+)`,
+
+`CREATE TABLE IF NOT EXISTS question_templates (
+    level INT NOT NULL,
+    bank_id VARCHAR(36) NOT NULL,
+    draw_count INT NOT NULL,
+    PRIMARY KEY (level, bank_id),
+    FOREIGN KEY (bank_id) REFERENCES question_banks(id) ON DELETE CASCADE
+)`,
+
 `CREATE TABLE IF NOT EXISTS question_levels (
     question_id VARCHAR(36),
     level INT,
+    display_order INT NOT NULL DEFAULT 0,
     PRIMARY KEY (question_id, level),
+    INDEX idx_question_levels_order (level, display_order),
     FOREIGN KEY (question_id) REFERENCES survey_questions(id) ON DELETE CASCADE
 )`,
 
@@ -253,7 +312,7 @@ INDEX idx_survey_results_session_completed (session_id, is_completed),
   id VARCHAR(36) PRIMARY KEY,
   session_id VARCHAR(36) NOT NULL,
   student_id VARCHAR(36) NOT NULL,
-  question_id INT NOT NULL,
+  question_id VARCHAR(36) NOT NULL,
   is_biased BOOLEAN DEFAULT FALSE,
   penalty_points FLOAT NOT NULL DEFAULT 0.5,
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -262,6 +321,41 @@ INDEX idx_survey_results_session_completed (session_id, is_completed),
   UNIQUE KEY (session_id, student_id, question_id)
 );`,
 
+`CREATE TABLE IF NOT EXISTS survey_bias (
+    session_id VARCHAR(36) NOT NULL,
+    responder_id VARCHAR(36) NOT NULL,
+    question_id VARCHAR(36) NOT NULL,
+    consensus_method VARCHAR(20) NOT NULL,
+    deviation INT NOT NULL DEFAULT 0,
+    penalty_points DECIMAL(5,2) NOT NULL DEFAULT 0,
+    is_biased BOOLEAN NOT NULL DEFAULT FALSE,
+    computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, responder_id, question_id),
+    FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (responder_id) REFERENCES student_users(id) ON DELETE CASCADE
+)`,
+
+`CREATE TABLE IF NOT EXISTS session_results (
+    session_id VARCHAR(36) NOT NULL,
+    student_id VARCHAR(36) NOT NULL,
+    level INT NOT NULL,
+    total_score DECIMAL(7,2) NOT NULL DEFAULT 0,
+    penalty_points DECIMAL(7,2) NOT NULL DEFAULT 0,
+    final_score DECIMAL(7,2) NOT NULL DEFAULT 0,
+    rank_position INT NOT NULL,
+    first_places INT NOT NULL DEFAULT 0,
+    biased_questions INT NOT NULL DEFAULT 0,
+    qualified BOOLEAN NOT NULL DEFAULT FALSE,
+    config_id VARCHAR(36) NULL,
+    config_version INT NOT NULL DEFAULT 0,
+    consensus_method VARCHAR(20) NOT NULL,
+    finalized_at DATETIME NOT NULL,
+    PRIMARY KEY (session_id, student_id),
+    INDEX idx_session_results_student (student_id),
+    FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES student_users(id) ON DELETE CASCADE
+)`,
+
 `CREATE TABLE IF NOT EXISTS survey_completion (
     session_id VARCHAR(36) NOT NULL,
     student_id VARCHAR(36) NOT NULL,
@@ -271,6 +365,30 @@ INDEX idx_survey_results_session_completed (session_id, is_completed),
     FOREIGN KEY (student_id) REFERENCES student_users(id) ON DELETE CASCADE
 );`,
 
+`CREATE TABLE IF NOT EXISTS session_questions (
+    session_id VARCHAR(36) NOT NULL,
+    question_id VARCHAR(36) NOT NULL,
+    position INT NOT NULL,
+    question_text TEXT NOT NULL,
+    weight DECIMAL(3,1) NOT NULL,
+    bank_id VARCHAR(36) NULL,
+    PRIMARY KEY (session_id, question_id),
+    FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE
+);`,
+
+`CREATE TABLE IF NOT EXISTS served_questions (
+    session_id VARCHAR(36) NOT NULL,
+    student_id VARCHAR(36) NOT NULL,
+    question_id VARCHAR(36) NOT NULL,
+    position INT NOT NULL,
+    question_text TEXT NOT NULL,
+    weight DECIMAL(3,1) NOT NULL,
+    served_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, student_id, question_id),
+    FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES student_users(id) ON DELETE CASCADE
+);`,
+
 `CREATE TABLE IF NOT EXISTS survey_timing (
     session_id VARCHAR(36) NOT NULL,
     student_id VARCHAR(36) NOT NULL,
@@ -291,6 +409,72 @@ INDEX idx_survey_results_session_completed (session_id, is_completed),
     UNIQUE KEY (session_id, student_id)
 )`,
 
+`CREATE TABLE IF NOT EXISTS collusion_flags (
+    id VARCHAR(36) PRIMARY KEY,
+    session_id VARCHAR(36) NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    responder_ids VARCHAR(512) NOT NULL,
+    detail TEXT,
+    status ENUM('pending', 'voided', 'dismissed') NOT NULL DEFAULT 'pending',
+    reviewed_by VARCHAR(36),
+    reviewed_at DATETIME NULL,
+    review_note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_flag (session_id, kind, responder_ids),
+    INDEX idx_collusion_flags_status (status),
+    FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (reviewed_by) REFERENCES admin_users(id) ON DELETE SET NULL
+)`,
+
+`CREATE TABLE IF NOT EXISTS rubrics (
+    level INT PRIMARY KEY,
+    moderator_weight DECIMAL(3,2) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+)`,
+
+`CREATE TABLE IF NOT EXISTS rubric_criteria (
+    id VARCHAR(36) PRIMARY KEY,
+    level INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    weight DECIMAL(5,2) NOT NULL DEFAULT 1,
+    max_score DECIMAL(5,2) NOT NULL DEFAULT 10,
+    display_order INT NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_rubric_criteria_level (level, is_active)
+)`,
+
+`CREATE TABLE IF NOT EXISTS moderator_scores (
+    session_id VARCHAR(36) NOT NULL,
+    student_id VARCHAR(36) NOT NULL,
+    criterion_id VARCHAR(36) NOT NULL,
+    score DECIMAL(5,2) NOT NULL,
+    moderator_id VARCHAR(36),
+    scored_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, student_id, criterion_id),
+    FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES student_users(id) ON DELETE CASCADE,
+    FOREIGN KEY (criterion_id) REFERENCES rubric_criteria(id),
+    FOREIGN KEY (moderator_id) REFERENCES admin_users(id) ON DELETE SET NULL
+)`,
+
+`CREATE TABLE IF NOT EXISTS moderator_feedback (
+    session_id VARCHAR(36) NOT NULL,
+    student_id VARCHAR(36) NOT NULL,
+    feedback TEXT NOT NULL,
+    moderator_id VARCHAR(36),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, student_id),
+    FOREIGN KEY (session_id) REFERENCES gd_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES student_users(id) ON DELETE CASCADE,
+    FOREIGN KEY (moderator_id) REFERENCES admin_users(id) ON DELETE SET NULL
+)`,
+
+`CREATE TABLE IF NOT EXISTS schema_upgrades (
+    name VARCHAR(100) PRIMARY KEY,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`,
+
 
     }
 
@@ -299,6 +483,22 @@ INDEX idx_survey_results_session_completed (session_id, is_completed),
             return fmt.Errorf("error creating tables: %v", err)
         }
     }
+    if err := addMissingColumns(db, addedColumns); err != nil {
+        return err
+    }
+    if err := retypeColumns(db, retypedColumns); err != nil {
+        return err
+    }
+    if err := reconcileQuestionLevels(db); err != nil {
+        return err
+    }
+    if err := syncIndexes(db, changedIndexes); err != nil {
+        return err
+    }
+    if err := applyUpgrades(db, upgrades); err != nil {
+        return err
+    }
+    setSchemaTables(createTables)
 
     // Insert sample data with IGNORE to skip existing records
     sampleData := []string{
@@ -368,17 +568,5 @@ if err != nil {
     log.Printf("Error inserting test student: %v", err)
 }
 
- go func() {
-        for {
-            time.Sleep(30 * time.Minute) // Run every 30 minutes
-            _, err := db.Exec(`
-                DELETE FROM session_phase_tracking 
-                WHERE start_time < DATE_SUB(NOW(), INTERVAL 30 MINUTE)`)
-            if err != nil {
-                log.Printf("Error cleaning up phase tracking: %v", err)
-            }
-        }
-    }()
-
     return nil
 }
@@ -0,0 +1,437 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	schemaMu     sync.RWMutex
+	schemaTables []string
+
+	createTableName = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS\s+` + "`?" + `(\w+)`)
+)
+
+// setSchemaTables records the tables InitDB is responsible for so readiness
+// can later confirm they are all still present.
+func setSchemaTables(statements []string) {
+	var tables []string
+	for _, stmt := range statements {
+		if m := createTableName.FindStringSubmatch(stmt); m != nil {
+			tables = append(tables, m[1])
+		}
+	}
+	schemaMu.Lock()
+	schemaTables = tables
+	schemaMu.Unlock()
+}
+
+// CheckSchema reports an error unless InitDB has run and every table it
+// creates exists in the connected database.
+func CheckSchema(ctx context.Context) error {
+	schemaMu.RLock()
+	tables := schemaTables
+	schemaMu.RUnlock()
+	if len(tables) == 0 {
+		return errors.New("schema has not been initialised")
+	}
+
+	rows, err := GetDB().QueryContext(ctx, `
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = DATABASE()`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	present := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		present[strings.ToLower(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, t := range tables {
+		if !present[strings.ToLower(t)] {
+			missing = append(missing, t)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// CountActiveSessions returns the number of sessions currently in progress.
+func CountActiveSessions(ctx context.Context) (float64, error) {
+	var n int
+	err := GetDB().QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM gd_sessions WHERE status = 'active'`).Scan(&n)
+	return float64(n), err
+}
+
+// column is a column added after its table was first created. CREATE TABLE
+// IF NOT EXISTS leaves existing tables alone, so InitDB adds these itself.
+type column struct {
+	table, name, definition string
+}
+
+var addedColumns = []column{
+	{"survey_results", "penalty_points", "DECIMAL(5,2) NOT NULL DEFAULT 0"},
+	{"survey_results", "is_biased", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	// Struck out by an admin reviewing a collusion flag; see collusion_flags.
+	{"survey_results", "voided", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	// One of scoring.Names(); see gd/scoring.
+	{"ranking_points_config", "consensus_method", "VARCHAR(20) NOT NULL DEFAULT 'borda'"},
+	// Points per rank, best first; supersedes the three place columns.
+	{"ranking_points_config", "points", "JSON NULL"},
+	// Numbers a level's configurations; a saved version is never changed.
+	{"ranking_points_config", "version", "INT NOT NULL DEFAULT 1"},
+	{"ranking_points_config", "effective_from", "DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP"},
+	// Set when a version first becomes active, so it is only activated once.
+	{"ranking_points_config", "activated_at", "DATETIME NULL"},
+	// The level while active and NULL otherwise; unique_active_level keeps
+	// one active version per level.
+	{"ranking_points_config", "active_level", "INT AS (IF(is_active, level, NULL)) STORED"},
+	// Weekly windows, slot length and blackout dates sessions are
+	// generated from; see models.VenueAvailability.
+	{"venues", "availability", "JSON NULL"},
+	{"gd_sessions", "survey_end_time", "DATETIME NULL"},
+	// Set once the session's results are snapshotted into session_results.
+	{"gd_sessions", "finalized_at", "DATETIME NULL"},
+	// The ranking_points_config version that scores the session, pinned
+	// when it is first scored.
+	{"gd_sessions", "ranking_config_id", "VARCHAR(36) NULL"},
+	// Topic metadata for admin search and filtering; tags are in topic_tags.
+	{"gd_topics", "category", "VARCHAR(100) NULL"},
+	{"gd_topics", "difficulty", "ENUM('easy', 'medium', 'hard') NULL"},
+	{"gd_topics", "source", "VARCHAR(255) NULL"},
+	// The key bulk imports match rows by, so content moves between
+	// deployments without duplicating; see ImportTopics.
+	{"gd_topics", "external_key", "VARCHAR(100) NULL"},
+	{"survey_questions", "external_key", "VARCHAR(100) NULL"},
+	// The gd_topics row the session's topic was chosen from.
+	{"gd_sessions", "topic_id", "VARCHAR(36) NULL"},
+	{"gd_rules", "qualifying_places", "INT NOT NULL DEFAULT 3"},
+	// The competency bank a question is drawn from; see question_templates.
+	{"survey_questions", "bank_id", "VARCHAR(36) NULL"},
+	// Set instead of deleting a question that results refer to.
+	{"survey_questions", "archived_at", "DATETIME NULL"},
+	// A question's position within each of its levels.
+	{"question_levels", "display_order", "INT NOT NULL DEFAULT 0"},
+	// The peer and moderator parts of a blended final score; see rubrics.
+	{"session_results", "peer_score", "DECIMAL(7,2) NOT NULL DEFAULT 0"},
+	{"session_results", "moderator_score", "DECIMAL(5,2) NULL"},
+	{"session_results", "moderator_weight", "DECIMAL(3,2) NOT NULL DEFAULT 0"},
+}
+
+// addMissingColumns runs ALTER TABLE for every added column the connected
+// database does not have yet. MySQL has no ADD COLUMN IF NOT EXISTS.
+func addMissingColumns(db *sql.DB, columns []column) error {
+	for _, c := range columns {
+		var n int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`,
+			c.table, c.name).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("checking %s.%s: %v", c.table, c.name, err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE " + c.table + " ADD COLUMN " + c.name + " " + c.definition); err != nil {
+			return fmt.Errorf("adding %s.%s: %v", c.table, c.name, err)
+		}
+	}
+	return nil
+}
+
+// retypedColumn is a column whose type changed after its table was first
+// created.
+type retypedColumn struct {
+	table, name string
+	// dataType is the type information_schema reports once the column has
+	// been changed, in lower case.
+	dataType   string
+	definition string
+}
+
+var retypedColumns = []retypedColumn{
+	// Questions are identified by their survey_questions id rather than
+	// their position in the survey. Older rows keep their position as text.
+	{"question_timers", "question_id", "varchar", "VARCHAR(36) NOT NULL"},
+	{"survey_penalties", "question_id", "varchar", "VARCHAR(36) NOT NULL"},
+}
+
+// retypeColumns runs ALTER TABLE for every retyped column the connected
+// database still has with its old type.
+func retypeColumns(db *sql.DB, columns []retypedColumn) error {
+	for _, c := range columns {
+		var dataType string
+		err := db.QueryRow(`
+			SELECT data_type FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`,
+			c.table, c.name).Scan(&dataType)
+		if err != nil {
+			return fmt.Errorf("checking %s.%s: %v", c.table, c.name, err)
+		}
+		if strings.ToLower(dataType) == c.dataType {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE " + c.table + " MODIFY COLUMN " + c.name + " " + c.definition); err != nil {
+			return fmt.Errorf("retyping %s.%s: %v", c.table, c.name, err)
+		}
+	}
+	return nil
+}
+
+// index is an index added or dropped after its table was first
+// created.
+type index struct {
+	table, name string
+	// columns is empty for an index that should no longer exist.
+	columns string
+	// kind is the index type, UNIQUE unless set.
+	kind string
+	// prepare runs once before the index is created, to bring existing rows
+	// in line with it.
+	prepare func(db *sql.DB) error
+}
+
+var changedIndexes = []index{
+	// A level used to have exactly one configuration; now it has versions.
+	{table: "ranking_points_config", name: "unique_level_config"},
+	{table: "ranking_points_config", name: "unique_level_version", columns: "level, version"},
+	{table: "ranking_points_config", name: "unique_active_level", columns: "active_level", prepare: keepNewestActiveConfig},
+	// Admin topic search.
+	{table: "gd_topics", name: "ft_topic_text", columns: "topic_text", kind: "FULLTEXT"},
+	{table: "gd_topics", name: "unique_topic_external_key", columns: "external_key"},
+	{table: "survey_questions", name: "unique_question_external_key", columns: "external_key"},
+}
+
+// syncIndexes creates and drops the indexes in changedIndexes to match the
+// connected database.
+func syncIndexes(db *sql.DB, indexes []index) error {
+	for _, idx := range indexes {
+		var n int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`,
+			idx.table, idx.name).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("checking index %s.%s: %v", idx.table, idx.name, err)
+		}
+		switch {
+		case idx.columns == "" && n > 0:
+			_, err = db.Exec("ALTER TABLE " + idx.table + " DROP INDEX " + idx.name)
+		case idx.columns != "" && n == 0:
+			if idx.prepare != nil {
+				if err := idx.prepare(db); err != nil {
+					return fmt.Errorf("preparing index %s.%s: %v", idx.table, idx.name, err)
+				}
+			}
+			kind := idx.kind
+			if kind == "" {
+				kind = "UNIQUE"
+			}
+			_, err = db.Exec("ALTER TABLE " + idx.table + " ADD " + kind + " INDEX " + idx.name + " (" + idx.columns + ")")
+		}
+		if err != nil {
+			return fmt.Errorf("updating index %s.%s: %v", idx.table, idx.name, err)
+		}
+	}
+	return nil
+}
+
+// keepNewestActiveConfig leaves only the most recently changed active
+// configuration of each level active, and marks every existing
+// configuration as already activated so ActivateDueRankingConfigs does not
+// switch old ones back on.
+func keepNewestActiveConfig(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT id, level FROM ranking_points_config
+		WHERE is_active = TRUE ORDER BY level, updated_at DESC, id DESC`)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	seen := map[int]bool{}
+	for rows.Next() {
+		var id string
+		var level int
+		if err := rows.Scan(&id, &level); err != nil {
+			rows.Close()
+			return err
+		}
+		if seen[level] {
+			stale = append(stale, id)
+		}
+		seen[level] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range stale {
+		if _, err := db.Exec("UPDATE ranking_points_config SET is_active = FALSE WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	_, err = db.Exec("UPDATE ranking_points_config SET activated_at = COALESCE(updated_at, NOW()) WHERE activated_at IS NULL")
+	return err
+}
+
+// reconcileQuestionLevels moves the level and display order older databases
+// kept on survey_questions into question_levels, the only mapping read
+// since. Questions with no question_levels row keep the level they were
+// served at; the old columns are then dropped so the two cannot drift apart.
+//
+// The copy runs in one transaction and is idempotent, and the columns are
+// only dropped after it commits, so a run that fails part way can simply
+// be repeated; once the columns are gone it does nothing.
+func reconcileQuestionLevels(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = 'survey_questions'
+		  AND column_name IN ('level', 'display_order')`)
+	if err != nil {
+		return err
+	}
+	var drops []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		drops = append(drops, "DROP COLUMN "+strings.ToLower(name))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(drops) == 0 {
+		return err
+	}
+
+	// Without both columns there is nothing left to copy.
+	if len(drops) == 2 {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		_, err = tx.Exec(`
+			INSERT IGNORE INTO question_levels (question_id, level, display_order)
+			SELECT q.id, q.level, q.display_order FROM survey_questions q
+			WHERE NOT EXISTS (SELECT 1 FROM question_levels ql WHERE ql.question_id = q.id)`)
+		if err == nil {
+			_, err = tx.Exec(`
+				UPDATE question_levels ql
+				JOIN survey_questions q ON q.id = ql.question_id AND q.level = ql.level
+				SET ql.display_order = q.display_order`)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return fmt.Errorf("reconciling question levels: %v", err)
+		}
+	}
+	slices.Sort(drops)
+	if _, err := db.Exec("ALTER TABLE survey_questions " + strings.Join(drops, ", ")); err != nil {
+		return fmt.Errorf("reconciling question levels: %v", err)
+	}
+	return nil
+}
+
+// upgrade is a one-off change to existing rows. Each runs once per
+// database, in the transaction that records it in schema_upgrades.
+type upgrade struct {
+	name  string
+	apply func(tx *sql.Tx) error
+}
+
+var upgrades = []upgrade{
+	{"utc_datetimes", convertLocalDatetimes},
+}
+
+// applyUpgrades runs the upgrades the connected database has not had yet.
+func applyUpgrades(db *sql.DB, upgrades []upgrade) error {
+	for _, u := range upgrades {
+		if err := applyUpgrade(db, u); err != nil {
+			return fmt.Errorf("upgrade %s: %v", u.name, err)
+		}
+	}
+	return nil
+}
+
+func applyUpgrade(db *sql.DB, u upgrade) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("INSERT IGNORE INTO schema_upgrades (name) VALUES (?)", u.name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	if err := u.apply(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// localDatetimes are the DATETIME columns older versions filled from
+// MySQL's NOW() while connections used the server's time zone, so their
+// rows hold the server's local time. MySQL keeps TIMESTAMP columns in UTC
+// itself.
+var localDatetimes = []column{
+	{table: "venue_qr_codes", name: "expires_at"},
+	{table: "question_timers", name: "end_time"},
+	{table: "gd_sessions", name: "survey_end_time"},
+}
+
+// convertLocalDatetimes moves localDatetimes from the server's time zone to
+// UTC, which every connection has used since connectionDSN. If MySQL cannot
+// convert from the server's zone, as for a named zone whose time zone
+// tables are not loaded, it fails without changing anything; load the
+// tables (mysql_tzinfo_to_sql) and restart.
+//
+// Session start and end times an admin entered before the upgrade were
+// sent as UTC but read by MySQL as local time, so they now come back
+// shifted by the server's UTC offset. They cannot be told apart from the
+// sessions students started, which are right, so correct them by hand with
+// CONVERT_TZ(start_time, '+00:00', @@global.time_zone), and likewise
+// end_time.
+func convertLocalDatetimes(tx *sql.Tx) error {
+	var convertible bool
+	err := tx.QueryRow(`SELECT CONVERT_TZ('2000-01-01 00:00:00', @@global.time_zone, '+00:00') IS NOT NULL`).Scan(&convertible)
+	if err != nil {
+		return err
+	}
+	if !convertible {
+		return errors.New("MySQL cannot convert from the server's time zone; load its time zone tables")
+	}
+	for _, c := range localDatetimes {
+		_, err := tx.Exec("UPDATE " + c.table + " SET " + c.name + " = CONVERT_TZ(" + c.name + ", @@global.time_zone, '+00:00') WHERE " + c.name + " IS NOT NULL")
+		if err != nil {
+			return fmt.Errorf("converting %s.%s: %v", c.table, c.name, err)
+		}
+	}
+	return nil
+}
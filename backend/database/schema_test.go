@@ -0,0 +1,162 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gd/database/dbtest"
+)
+
+func TestReconcileQuestionLevels(t *testing.T) {
+	const (
+		columns = "information_schema.columns"
+		insert  = "INSERT IGNORE INTO question_levels"
+		order   = "UPDATE question_levels"
+		drop    = "ALTER TABLE survey_questions"
+	)
+	// run reconciles a database whose survey_questions still has the given
+	// old columns, returning the statements that ran after the column check.
+	run := func(t *testing.T, old []string, failing string) ([]string, error) {
+		t.Helper()
+		db, script := dbtest.Open()
+		defer db.Close()
+		var found [][]any
+		for _, name := range old {
+			found = append(found, []any{strings.ToUpper(name)})
+		}
+		script.Rows(columns, []string{"COLUMN_NAME"}, found...)
+		if failing != "" {
+			script.Fail(failing, errors.New("lock wait timeout"))
+		}
+		err := reconcileQuestionLevels(db)
+		var ran []string
+		for _, stmt := range script.Statements() {
+			// Repeating the copy must not add to questions already mapped.
+			if strings.HasPrefix(stmt, insert) && !strings.Contains(stmt, "WHERE NOT EXISTS") {
+				t.Errorf("copy %q would map questions twice", stmt)
+			}
+			for _, kind := range []string{"BEGIN", insert, order, "COMMIT", "ROLLBACK", drop} {
+				if strings.HasPrefix(stmt, kind) {
+					ran = append(ran, kind)
+				}
+			}
+			if strings.HasPrefix(stmt, drop) {
+				ran = append(ran, strings.TrimPrefix(stmt, drop+" "))
+			}
+		}
+		return ran, err
+	}
+
+	full := []string{"BEGIN", insert, order, "COMMIT", drop, "DROP COLUMN display_order, DROP COLUMN level"}
+	t.Run("old columns", func(t *testing.T) {
+		ran, err := run(t, []string{"level", "display_order"}, "")
+		if err != nil || !slices.Equal(ran, full) {
+			t.Errorf("ran %q (%v), want %q", ran, err, full)
+		}
+	})
+	t.Run("already reconciled", func(t *testing.T) {
+		ran, err := run(t, nil, "")
+		if err != nil || len(ran) != 0 {
+			t.Errorf("rerun ran %q (%v), want nothing", ran, err)
+		}
+	})
+	t.Run("failed copy", func(t *testing.T) {
+		ran, err := run(t, []string{"level", "display_order"}, order)
+		want := []string{"BEGIN", insert, order, "ROLLBACK"}
+		if err == nil || !slices.Equal(ran, want) {
+			t.Errorf("ran %q (%v), want %q and an error, keeping the columns", ran, err, want)
+		}
+		// The columns were kept, so the rerun repeats the whole move.
+		if ran, err := run(t, []string{"level", "display_order"}, ""); err != nil || !slices.Equal(ran, full) {
+			t.Errorf("rerun ran %q (%v), want %q", ran, err, full)
+		}
+	})
+	t.Run("only display_order left", func(t *testing.T) {
+		ran, err := run(t, []string{"display_order"}, "")
+		want := []string{drop, "DROP COLUMN display_order"}
+		if err != nil || !slices.Equal(ran, want) {
+			t.Errorf("ran %q (%v), want %q without copying", ran, err, want)
+		}
+	})
+}
+
+func TestRetypeColumns(t *testing.T) {
+	for _, tt := range []struct {
+		have string
+		want []string
+	}{
+		{"int", []string{
+			"ALTER TABLE question_timers MODIFY COLUMN question_id VARCHAR(36) NOT NULL",
+			"ALTER TABLE survey_penalties MODIFY COLUMN question_id VARCHAR(36) NOT NULL",
+		}},
+		{"VARCHAR", nil},
+	} {
+		db, script := dbtest.Open()
+		script.Rows("information_schema.columns", []string{"DATA_TYPE"}, []any{tt.have})
+		if err := retypeColumns(db, retypedColumns); err != nil {
+			t.Fatal(err)
+		}
+		var altered []string
+		for _, stmt := range script.Statements() {
+			if strings.HasPrefix(stmt, "ALTER TABLE") {
+				altered = append(altered, stmt)
+			}
+		}
+		if !slices.Equal(altered, tt.want) {
+			t.Errorf("columns typed %s: ran %q, want %q", tt.have, altered, tt.want)
+		}
+		db.Close()
+	}
+}
+
+func TestApplyUpgrades(t *testing.T) {
+	const (
+		marker = "INSERT IGNORE INTO schema_upgrades"
+		probe  = "SELECT CONVERT_TZ"
+	)
+	convert := []string{"BEGIN", marker, probe,
+		"UPDATE venue_qr_codes SET expires_at = CONVERT_TZ(expires_at, @@global.time_zone, '+00:00') WHERE expires_at IS NOT NULL",
+		"UPDATE question_timers SET end_time = CONVERT_TZ(end_time, @@global.time_zone, '+00:00') WHERE end_time IS NOT NULL",
+		"UPDATE gd_sessions SET survey_end_time = CONVERT_TZ(survey_end_time, @@global.time_zone, '+00:00') WHERE survey_end_time IS NOT NULL",
+		"COMMIT"}
+	for _, tt := range []struct {
+		name        string
+		applied     bool
+		convertible bool
+		want        []string
+		wantErr     bool
+	}{
+		{"first run", false, true, convert, false},
+		{"already applied", true, true, []string{"BEGIN", marker, "ROLLBACK"}, false},
+		{"zone tables missing", false, false, []string{"BEGIN", marker, probe, "ROLLBACK"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, script := dbtest.Open()
+			defer db.Close()
+			if tt.applied {
+				script.Affects(marker, 0)
+			}
+			script.Rows(probe, []string{"convertible"}, []any{tt.convertible})
+			err := applyUpgrades(db, upgrades)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			var ran []string
+			for _, stmt := range script.Statements() {
+				switch {
+				case strings.HasPrefix(stmt, marker):
+					ran = append(ran, marker)
+				case strings.HasPrefix(stmt, probe):
+					ran = append(ran, probe)
+				default:
+					ran = append(ran, stmt)
+				}
+			}
+			if !slices.Equal(ran, tt.want) {
+				t.Errorf("ran %q, want %q", ran, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Package health serves liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Check is one readiness dependency. Fn should return promptly once ctx is done.
+type Check struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// Liveness reports that the process is up and serving HTTP. It deliberately
+// ignores dependencies so an outage of MySQL does not get the pod restarted.
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readiness runs every check with a shared timeout and answers 503 if any of
+// them fails, listing the result of each.
+func Readiness(timeout time.Duration, checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		status := "ok"
+		results := make(map[string]string, len(checks))
+		for _, c := range checks {
+			if err := c.Fn(ctx); err != nil {
+				status = "unavailable"
+				results[c.Name] = err.Error()
+				continue
+			}
+			results[c.Name] = "ok"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": status,
+			"checks": results,
+		})
+	})
+}
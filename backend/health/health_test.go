@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := Check{Name: "database", Fn: func(context.Context) error { return nil }}
+	failing := Check{Name: "schema", Fn: func(context.Context) error { return errors.New("missing tables: venues") }}
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantStatus int
+		wantChecks map[string]string
+	}{
+		{"all passing", []Check{ok}, http.StatusOK, map[string]string{"database": "ok"}},
+		{"one failing", []Check{ok, failing}, http.StatusServiceUnavailable,
+			map[string]string{"database": "ok", "schema": "missing tables: venues"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Readiness(time.Second, tt.checks...).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body struct {
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.wantChecks {
+				if body.Checks[name] != want {
+					t.Errorf("check %s = %q, want %q", name, body.Checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessTimesOut(t *testing.T) {
+	slow := Check{Name: "database", Fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	rec := httptest.NewRecorder()
+	Readiness(10*time.Millisecond, slow).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel accepted verbose")
+	}
+	if _, err := New(&bytes.Buffer{}, slog.LevelInfo, "xml"); err == nil {
+		t.Error("New accepted xml format")
+	}
+}
+
+func TestMiddlewarePropagatesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelDebug, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var seen string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		ctx := With(r.Context(), slog.String("student_id", "alice"))
+		slog.InfoContext(ctx, "joined")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		reuse    bool
+	}{
+		{"generated", "", false},
+		{"reused", "abc-123", true},
+		{"rejected unsafe", "bad id\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodPost, "/student/sessions/join", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if id == "" || id != seen {
+				t.Fatalf("response ID %q, handler saw %q", id, seen)
+			}
+			if (id == tt.incoming) != tt.reuse {
+				t.Errorf("ID %q for incoming %q, reuse = %v", id, tt.incoming, tt.reuse)
+			}
+
+			records := decodeLines(t, &buf)
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want handler line and access line", len(records))
+			}
+			joined, access := records[0], records[1]
+			if joined["request_id"] != id || joined["student_id"] != "alice" {
+				t.Errorf("handler record = %v", joined)
+			}
+			if access["request_id"] != id || access["status"] != float64(http.StatusCreated) {
+				t.Errorf("access record = %v", access)
+			}
+			if _, ok := access["student_id"]; ok {
+				t.Errorf("handler fields leaked into access record: %v", access)
+			}
+		})
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelWarn, FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("quiet")
+	logger.Warn("loud")
+	if out := buf.String(); strings.Contains(out, "quiet") || !strings.Contains(out, "loud") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestNewStatusRecorderReusesOuter(t *testing.T) {
+	outer := NewStatusRecorder(httptest.NewRecorder())
+	inner := NewStatusRecorder(outer)
+	if inner != outer {
+		t.Fatal("wrapped a recorder in another")
+	}
+	inner.WriteHeader(http.StatusNotFound)
+	if outer.Status != http.StatusNotFound {
+		t.Errorf("outer status = %d, want 404", outer.Status)
+	}
+}
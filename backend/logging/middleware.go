@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is read from incoming requests and echoed on every response.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// RequestID returns the ID assigned to the request carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware assigns every request an ID, reusing a well-formed one supplied
+// by a proxy or the app, echoes it in the response and attaches it to all
+// logs written with the request context. It also writes one access log line
+// per request.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = With(ctx, slog.String("request_id", id))
+		r = r.WithContext(ctx)
+
+		start := time.Now()
+		rec := NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		// Handlers further down may have attached user or session IDs to
+		// their own copy of the context; the access line carries the request ID
+		// so the two can be joined.
+		level := slog.LevelInfo
+		if rec.Status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.Status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// StatusRecorder wraps a ResponseWriter to remember the status code the
+// handler sent, for middleware that reports it.
+type StatusRecorder struct {
+	http.ResponseWriter
+	// Status is the code sent, 200 until the handler sends another.
+	Status      int
+	wroteHeader bool
+}
+
+// NewStatusRecorder wraps w. If w is already a *StatusRecorder, set up by
+// an outer middleware, it is returned as is, so a chain of middlewares
+// shares one wrapper and sees the same status.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	if rec, ok := w.(*StatusRecorder); ok {
+		return rec
+	}
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *StatusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.Status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *StatusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *StatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -1,36 +1,136 @@
 package main
 
 import (
+	"context"
+	"errors"
+	adminControllers "gd/admin/controllers"
 	"gd/admin/middleware"
 	"gd/admin/routes"
+	adminJWT "gd/admin/utils"
+	"gd/config"
    studentRoutes "gd/student/routes"
+	studentControllers "gd/student/controllers"
 	"gd/database"
+	"gd/health"
+	"gd/logging"
+	"gd/metrics"
+	"gd/openapi"
+	"gd/repository"
+	"gd/routing"
+	"gd/scheduler"
+	"gd/storage"
+	studentJWT "gd/student/utils"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("starting", "env", cfg.Env, "log_level", cfg.LogLevel)
+	if cfg.StudentJWTSecret == config.DevStudentSecret {
+		slog.Warn("using default student JWT secret; configure JWT_SECRET_STUDENT for production")
+	}
+	adminJWT.SetSecret(cfg.AdminJWTSecret)
+	studentJWT.SetSecret(cfg.StudentJWTSecret)
+
 	// Initialize database
-	if err := database.Initialize(); err != nil {
-		log.Fatal("Database initialization failed:", err)
+	if err := database.Initialize(cfg.DatabaseURL, database.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	}); err != nil {
+		fatal("database initialization failed", err)
 	}
 	defer database.GetDB().Close()
+	files := storage.Dir(cfg.UploadDir)
+	admins := adminControllers.NewHandlers(repository.NewMySQLAdminStore(database.GetDB()), files)
+	students := studentControllers.NewHandlers(repository.NewMySQLStore(database.GetDB()), files)
+	metrics.RegisterDBStats(metrics.Default, database.GetDB())
+	metrics.Default.NewGaugeFunc("gd_active_sessions",
+		"Sessions currently in progress.", database.CountActiveSessions)
 
-	// Setup routes
-	adminRouter := routes.SetupAdminRoutes()
-	// Start server with CORS middleware
-	http.Handle("/admin/", middleware.EnableCORS(adminRouter))
-	http.Handle("/", middleware.EnableCORS(adminRouter))
-	// Student Side
-    studentRouter := studentRoutes.SetupStudentRoutes()
-http.Handle("/student/", middleware.EnableCORS(studentRouter))
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	mux := http.NewServeMux()
+	registerRoutes(mux, cfg, admins, students)
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           logging.Middleware(metrics.Middleware(mux)),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
 	}
 
-	log.Printf("Server starting on :%s...", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Background maintenance
+	jobs := scheduler.New()
+	jobs.Every("phase-tracking-cleanup", 30*time.Minute, database.CleanupPhaseTracking)
+	jobs.Every("qr-expiry-cleanup", 5*time.Minute, admins.CleanupExpiredQRCodes)
+	jobs.Every("session-finalization", time.Minute, students.FinalizeClosedSessions)
+	jobs.Every("ranking-config-activation", time.Minute, admins.ActivateDueRankingConfigs)
+	jobs.Start(ctx)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", srv.Addr)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			jobs.Stop()
+			fatal("server failed", err)
+		}
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining requests", "timeout", cfg.ShutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("graceful shutdown incomplete", "error", err)
+	}
+	jobs.Stop()
+	slog.Info("server stopped")
+}
+
+// registerRoutes mounts the /api/v1 resources, the legacy admin and student
+// routers and the operational endpoints on router. admins and students serve
+// the handlers backed by the repository layer.
+func registerRoutes(router routes.Router, cfg config.Config, admins *adminControllers.Handlers, students *studentControllers.Handlers) {
+	v1 := http.NewServeMux()
+	routes.RegisterAdminV1(v1, admins)
+	studentRoutes.RegisterStudentV1(v1, students)
+	router.Handle("/api/v1/", middleware.EnableCORS(v1))
+	// Unversioned paths, kept working until the app moves to /api/v1.
+	router.Handle("/admin/", routing.Legacy(middleware.EnableCORS(routes.SetupAdminRoutes(admins))))
+	router.Handle("/student/", routing.Legacy(middleware.EnableCORS(studentRoutes.SetupStudentRoutes(students))))
+	router.Handle("/", http.HandlerFunc(routing.NotFound))
+	// Probes, metrics and the API description
+	router.Handle("/healthz", http.HandlerFunc(health.Liveness))
+	router.Handle("/readyz", health.Readiness(2*time.Second,
+		health.Check{Name: "database", Fn: database.GetDB().PingContext},
+		health.Check{Name: "schema", Fn: database.CheckSchema},
+	))
+	router.Handle("/metrics", metrics.Handler(metrics.Default, cfg.MetricsToken))
+	router.Handle("/openapi.json", openapi.Handler(openapi.API()))
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	admincontrollers "gd/admin/controllers"
+	"gd/config"
+	"gd/openapi"
+	studentcontrollers "gd/student/controllers"
+)
+
+type recorder struct {
+	patterns []string
+}
+
+func (r *recorder) Handle(pattern string, _ http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+// The admin and student routers are checked in gd/openapi; this covers the
+// endpoints mounted directly on the server mux.
+func TestTopLevelRoutesAreDocumented(t *testing.T) {
+	var rec recorder
+	registerRoutes(&rec, config.Config{}, admincontrollers.NewHandlers(nil, nil), studentcontrollers.NewHandlers(nil, nil))
+
+	doc := openapi.API()
+	for _, pattern := range rec.patterns {
+		switch pattern {
+		case "/", "/api/v1/", "/admin/", "/student/":
+			continue
+		}
+		if _, ok := doc.Paths[pattern]; !ok {
+			t.Errorf("route %s is registered without an entry in openapi.API", pattern)
+		}
+	}
+}
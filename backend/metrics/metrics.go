@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gd/logging"
+)
+
+// Default is the registry served on /metrics.
+var Default = NewRegistry()
+
+var (
+	httpRequests = Default.NewCounterVec("gd_http_requests_total",
+		"HTTP requests by route pattern, method and status code.", "route", "method", "code")
+	httpDuration = Default.NewHistogramVec("gd_http_request_duration_seconds",
+		"HTTP request latency by route pattern and method.", DefaultBuckets, "route", "method")
+
+	// SessionJoins counts successful QR joins; rate() gives joins per minute.
+	SessionJoins = Default.NewCounterVec("gd_session_joins_total",
+		"Students who joined a session by scanning a venue QR code.")
+	// SurveySubmissions counts accepted survey answers.
+	SurveySubmissions = Default.NewCounterVec("gd_survey_submissions_total",
+		"Survey answers accepted from students.")
+	// QRRejections counts scans refused before a session was joined.
+	QRRejections = Default.NewCounterVec("gd_qr_rejections_total",
+		"Venue QR scans rejected, by reason (invalid, inactive, full).", "reason")
+	// LegacyRequests counts calls to the unversioned paths; it should reach
+	// zero before they are removed.
+	LegacyRequests = Default.NewCounterVec("gd_legacy_requests_total",
+		"Requests served by the pre-/api/v1 routes, by route pattern.", "route")
+)
+
+// Middleware records a request count and latency for every request under the
+// route pattern that served it. Patterns keep label cardinality bounded no
+// matter what paths clients send.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := logging.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		// ServeMux stores the matched pattern on the request it was given,
+		// so after nested muxes have run this holds the innermost match.
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(rec.Status))
+		httpDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// Handler serves the registry in the Prometheus text format. When token is
+// non-empty scrapers must send it as a bearer token.
+func Handler(reg *Registry, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			want := []byte("Bearer " + token)
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.Write(r.Context(), w)
+	})
+}
+
+// RegisterDBStats exposes the connection pool statistics of db.
+func RegisterDBStats(reg *Registry, db *sql.DB) {
+	sample := func(value func(sql.DBStats) float64) func(context.Context) (float64, error) {
+		return func(context.Context) (float64, error) { return value(db.Stats()), nil }
+	}
+	stat := func(name, help string, value func(sql.DBStats) float64) {
+		reg.NewGaugeFunc(name, help, sample(value))
+	}
+	total := func(name, help string, value func(sql.DBStats) float64) {
+		reg.NewCounterFunc(name, help, sample(value))
+	}
+	stat("gd_db_open_connections", "Established connections, in use and idle.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	stat("gd_db_in_use_connections", "Connections currently in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	stat("gd_db_idle_connections", "Idle connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	stat("gd_db_max_open_connections", "Configured connection limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	total("gd_db_wait_count_total", "Total times a caller waited for a free connection.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	total("gd_db_wait_duration_seconds_total", "Total time spent waiting for a free connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(reg *Registry) string {
+	var b strings.Builder
+	reg.Write(context.Background(), &b)
+	return b.String()
+}
+
+func TestRegistryExposition(t *testing.T) {
+	reg := NewRegistry()
+	joins := reg.NewCounterVec("joins_total", "Joins.")
+	rejections := reg.NewCounterVec("rejections_total", "Rejections.", "reason")
+	latency := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	reg.NewGaugeFunc("active", "Active.", func(context.Context) (float64, error) { return 3, nil })
+	reg.NewGaugeFunc("broken", "Broken.", func(context.Context) (float64, error) { return 0, errors.New("down") })
+
+	rejections.Inc(`full "A"`)
+	rejections.Add(2, "invalid")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+
+	out := render(reg)
+	for _, want := range []string{
+		"# TYPE joins_total counter\njoins_total 0\n",
+		`rejections_total{reason="full \"A\""} 1`,
+		`rejections_total{reason="invalid"} 2`,
+		`latency_seconds_bucket{route="/a",le="0.1"} 1`,
+		`latency_seconds_bucket{route="/a",le="1"} 2`,
+		`latency_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`latency_seconds_sum{route="/a"} 5.55`,
+		`latency_seconds_count{route="/a"} 3`,
+		"# TYPE active gauge\nactive 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "broken") {
+		t.Errorf("failed gauge was rendered:\n%s", out)
+	}
+	if joins.Value() != 0 || rejections.Value("invalid") != 2 {
+		t.Errorf("Value() disagrees with output")
+	}
+}
+
+func TestMiddlewareLabelsByInnermostPattern(t *testing.T) {
+	inner := http.NewServeMux()
+	inner.HandleFunc("/student/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	outer := http.NewServeMux()
+	outer.Handle("/student/", inner)
+	h := Middleware(outer)
+
+	for _, path := range []string{"/student/sessions/1", "/student/sessions/2", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := httpRequests.Value("/student/sessions/", "GET", "418"); got != 2 {
+		t.Errorf("requests for inner pattern = %v, want 2", got)
+	}
+	if got := httpRequests.Value("unmatched", "GET", "404"); got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("x_total", "X.")
+	h := Handler(reg, "s3cret")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "x_total 0") {
+		t.Errorf("with token: status %d body %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,460 @@
+package openapi
+
+import (
+	"net/http"
+
+	admin "gd/admin/controllers"
+	"gd/admin/models"
+	"gd/apierror"
+	"gd/repository"
+	"gd/scoring"
+	student "gd/student/controllers"
+)
+
+// Security scheme names. Admin and student tokens are signed with different
+// secrets and are not interchangeable.
+const (
+	AdminAuth   = "adminToken"
+	StudentAuth = "studentToken"
+)
+
+// API returns the document for every route the server registers. Add an
+// operation here whenever a route is added; TestEveryRouteIsDocumented
+// fails otherwise, and regenerate the TypeScript client.
+//
+//go:generate go run ./cmd/tsclient -o ../../frontend/src/api/gdClient.ts
+func API() *Document {
+	d := New("GD API", "1.0.0",
+		"Group discussion scheduling, participation and peer review. "+
+			"Use the /api/v1 paths; the unversioned ones are deprecated. "+
+			"Errors use the ApiError envelope; switch on its code, not on the message.")
+	d.Bearer(AdminAuth, "Issued by POST /api/v1/admin/login.")
+	d.Bearer(StudentAuth, "Issued by POST /api/v1/student/login.")
+
+	d.Define("ApiError", Object(
+		P("error", String().Describe("Human-readable message.")),
+		P("code", Enum(
+			apierror.CodeBadRequest, apierror.CodeValidation, apierror.CodeUnauthorized,
+			apierror.CodeForbidden, apierror.CodeNotFound, apierror.CodeMethodNotAllowed,
+			apierror.CodeConflict, apierror.CodeInternal,
+			apierror.CodeQRInvalid, apierror.CodeQRInactive, apierror.CodeQRFull,
+			apierror.CodeSimilarTopic, apierror.CodeScheduleConflict,
+		)),
+		Opt("fields", ArrayOf(d.Model(apierror.FieldError{}))),
+		Opt("request_id", String()),
+	))
+	status := d.Define("Status", Object(P("status", String())))
+	message := d.Define("Message", Object(P("message", String())))
+
+	adminRoutes(d, status, message)
+	studentRoutes(d, status)
+	adminV1(d, status, message)
+	studentV1(d, status)
+	opsRoutes(d)
+
+	// Reflection only sees a string; the valid methods live in gd/scoring.
+	for _, name := range []string{"RankingPointsConfig", "RankingPointsConfigInput"} {
+		d.Components.Schemas[name].Properties["consensus_method"] = Enum(scoring.Names()...)
+	}
+	return d
+}
+
+// adminRoutes documents the unversioned admin paths, which the app still
+// calls. They share schemas with adminV1.
+func adminRoutes(d *Document, status, message *Schema) {
+	public := d.Group("admin", "").Legacy()
+	public.Post("/admin/login", "AdminLogin", "Exchange admin credentials for a token").
+		Body(d.Input("AdminLoginRequest", admin.LoginRequest{}, "email", "password")).
+		Returns(d.Define("AdminToken", Object(P("token", String()), P("token_type", Enum("Bearer"))))).
+		Fails(http.StatusUnauthorized, "Invalid credentials")
+
+	g := d.Group("admin", AdminAuth).Legacy()
+
+	venue := d.Model(models.Venue{})
+	venueUpdate := d.Define("VenueUpdate", Object(
+		Opt("id", String().Describe("Defaults to the id in the path.")),
+		P("name", String()),
+		P("capacity", Integer()),
+		P("level", Level()),
+		Opt("session_timing", String()),
+		Opt("table_details", String()),
+	))
+	g.Get("/admin/venues", "GetVenues", "List active venues").
+		Returns(ArrayOf(venue))
+	g.Post("/admin/venues", "CreateVenue", "Create a venue").
+		Body(d.Input("VenueInput", models.Venue{}, "name", "capacity", "level")).
+		Returns(venue)
+	g.Put("/admin/venues", "UpdateVenue", "Update the venue named in the body").
+		Body(venueUpdate).
+		Returns(message)
+	g.Put("/admin/venues/{id}", "UpdateVenueByID", "Update a venue").
+		Body(venueUpdate).
+		Returns(message)
+
+	qr := d.Define("VenueQR", Object(
+		P("success", Boolean()),
+		P("qr_string", String().Describe("Encrypted payload students scan.")),
+		P("expires_in", Number().Describe("Minutes until expiry.")),
+		P("expires_at", DateTime()),
+		P("qr_id", String()),
+		P("max_capacity", Integer()),
+		P("current_usage", Integer()),
+		P("remaining_slots", Integer()),
+		P("is_new", Boolean()),
+		Opt("is_full", Boolean()),
+		Opt("qr_group_id", String()),
+	))
+	g.Get("/admin/qr", "GenerateQR", "Return the venue's usable QR code, generating one if needed").
+		Query("venue_id", String(), true, "").
+		Query("force_new", Boolean(), false, "Always generate a new code.").
+		Query("auto_generate", Boolean(), false, "Generate a new code when every active one is full.").
+		Returns(qr)
+	g.Get("/admin/qr/manage", "GetVenueQRCodes", "List a venue's active QR codes").
+		Query("venue_id", String(), true, "").
+		Returns(ArrayOf(d.Define("VenueQRSummary", Object(
+			P("id", String()),
+			P("qr_data_short", String()),
+			P("expires_at", String()),
+			P("is_active", Boolean()),
+			P("max_capacity", Integer()),
+			P("current_usage", Integer()),
+			P("remaining", Integer()),
+			P("is_full", Boolean()),
+			P("qr_group_id", String()),
+			P("created_at", String()),
+		))))
+	g.Post("/admin/qr/deactivate", "DeactivateQR", "Deactivate a QR code").
+		Query("qr_id", String(), true, "").
+		Returns(status)
+
+	g.Post("/admin/sessions/bulk", "CreateBulkSessions", "Schedule several sessions at once").
+		Body(Object(P("sessions", ArrayOf(d.Input("SessionRequest", admin.SessionRequest{},
+			"venue_id", "level", "start_time", "end_time"))))).
+		Returns(d.Define("CreatedSessions", Object(
+			P("status", String()),
+			P("sessions", ArrayOf(Object(
+				P("id", String()),
+				P("venue_id", String()),
+				P("start_time", DateTime()),
+				P("end_time", DateTime()),
+			))),
+		))).
+		Fails(http.StatusBadRequest, "A session is invalid, at a venue that is not active, or of another level than its venue").
+		Fails(http.StatusConflict, "A session overlaps another at its venue (code schedule_conflict); nothing was saved")
+	g.Put("/admin/rules", "UpdateSessionRules", "Change the phase durations of a session").
+		Body(d.Input("SessionRulesRequest", admin.SessionRulesRequest{}, "session_id")).
+		Returns(status)
+
+	g.Get("/admin/analytics/qualifications", "GetQualificationRates", "Qualification rate per department").
+		Returns(MapOf(Number()))
+	g.Get("/admin/calendar", "GetSessionCalendar", "Upcoming sessions").
+		Returns(ArrayOf(d.Model(admin.SessionSlot{})))
+	g.Get("/admin/students", "GetStudentProgress", "Student progress through the levels").
+		Returns(ArrayOf(d.Model(admin.StudentProgress{})))
+	g.Get("/admin/bookings", "GetStudentBookings", "Bookings for pending sessions").
+		Returns(ArrayOf(d.Model(admin.BookingInfo{})))
+
+	question := d.Define("Question", Object(
+		P("id", String()),
+		P("text", String()),
+		P("weight", Number()),
+		P("is_active", Boolean()),
+		P("bank_id", String().Describe("Empty when the question is in no bank.")),
+		P("levels", ArrayOf(Level())),
+		Opt("archived_at", DateTime().Describe("Set, and otherwise null, once a used question was deleted.")),
+	))
+	g.Get("/admin/questions", "GetQuestions", "List survey questions").
+		Query("archived", Boolean(), false, "Include archived questions.").
+		Returns(ArrayOf(question))
+	g.Post("/admin/questions", "CreateQuestion", "Create a survey question").
+		Body(d.Input("QuestionRequest", admin.QuestionRequest{}, "text", "weight", "levels")).
+		Returns(Object(P("status", String()), P("id", String())))
+	g.Put("/admin/questions", "UpdateQuestion", "Update a survey question; omitted fields are unchanged").
+		Query("id", String(), true, "").
+		Body(d.Define("QuestionUpdate", Object(
+			Opt("text", String()),
+			Opt("weight", Number()),
+			Opt("levels", ArrayOf(Level())),
+			Opt("is_active", Boolean().Describe("true also restores an archived question.")),
+			Opt("bank_id", String().Describe("Empty to take the question out of its bank.")),
+		))).
+		Returns(status)
+	removed := d.Define("QuestionRemoved", Object(
+		P("status", Enum("deleted", "archived").Describe("A question students were asked is archived rather than deleted.")),
+	))
+	g.Delete("/admin/questions", "DeleteQuestion", "Delete a survey question, or archive it if it was used").
+		Query("id", String(), true, "").
+		Query("purge", Boolean(), false, "Refuse rather than archive a used question.").
+		Returns(removed).
+		Fails(http.StatusNotFound, "Question not found").
+		Fails(http.StatusConflict, "Question was asked in sessions; it can only be archived")
+
+	topic := d.Model(admin.Topic{})
+	g.Get("/admin/topics", "GetTopics", "Search and filter topics with their usage").
+		Apply(topicFilters).
+		Returns(ArrayOf(topic))
+	g.Post("/admin/topics", "CreateTopic", "Create a topic").
+		Body(d.Input("TopicInput", admin.Topic{}, "level", "topic_text")).
+		Apply(topicForce).
+		Returns(topic)
+	g.Put("/admin/topics", "UpdateTopic", "Update a topic").
+		Body(d.Input("TopicUpdate", admin.Topic{}, "id", "level", "topic_text")).
+		Apply(topicForce).
+		Returns(message)
+	g.Delete("/admin/topics", "DeleteTopic", "Delete a topic").
+		Query("id", String(), true, "").
+		Returns(message)
+
+	points := d.Model(admin.RankingPointsConfig{})
+	g.Get("/admin/ranking-points", "GetRankingPointsConfig", "List ranking point configurations").
+		Query("level", Level(), false, "").
+		Query("id", String(), false, "").
+		Returns(ArrayOf(points))
+	g.Post("/admin/ranking-points", "UpdateRankingPointsConfig", "Save a new configuration version, superseding the one with the given id").
+		Body(d.Input("RankingPointsConfigInput", admin.RankingPointsConfig{}).
+			Describe("Give points, strictly decreasing, or the three place fields. A new configuration needs a level; a version superseding id keeps its level. The new version becomes the level's only active one at effective_from, or at once when it is omitted.")).
+		Returns(d.Define("RankingPointsConfigSaved", Object(P("status", String()), P("config", points))))
+	g.Delete("/admin/ranking-points", "DeleteRankingPointsConfig", "Delete a configuration").
+		Query("id", String(), true, "").
+		Returns(status).
+		Fails(http.StatusNotFound, "Configuration not found").
+		Fails(http.StatusConflict, "Configuration has scored sessions")
+	g.Put("/admin/ranking-points/toggle", "ToggleRankingPointsConfig", "Flip a configuration's active flag; activating deactivates the level's other versions").
+		Query("id", String(), true, "").
+		Returns(d.Define("RankingPointsConfigActive", Object(P("status", String()), P("is_active", Boolean()))))
+
+	g.Get("/admin/results/top", "GetTopParticipants", "Top 20 participants by total score").
+		Query("level", Level(), false, "").
+		Returns(d.Define("TopParticipants", Object(
+			P("level", Integer()),
+			P("top_participants", ArrayOf(Object(
+				P("id", String()),
+				P("name", String()),
+				P("level", Integer()),
+				P("session_count", Integer()),
+				P("total_score", Number()),
+				P("avg_score", Number()),
+			))),
+		)))
+	g.Get("/admin/feedbacks", "GetSessionFeedbacks", "Feedback left for a session").
+		Query("session_id", String(), true, "").
+		Returns(d.Define("SessionFeedbacks", Object(
+			P("count", Integer()),
+			P("feedbacks", ArrayOf(Object(
+				P("id", String()),
+				P("rating", Integer()),
+				P("comments", String()),
+				P("created_at", String()),
+				P("student", Object(P("name", String()), P("department", String()), P("year", Integer()))),
+			))),
+		)))
+}
+
+// studentRoutes documents the unversioned student paths. They share schemas
+// with studentV1.
+func studentRoutes(d *Document, status *Schema) {
+	public := d.Group("student", "").Legacy()
+	public.Post("/student/login", "StudentLogin", "Exchange student credentials for a token").
+		Body(d.Input("StudentLoginRequest", student.StudentLoginRequest{}, "email", "password")).
+		Returns(d.Define("StudentToken", Object(P("token", String()), P("level", Level()), P("user_id", String())))).
+		Fails(http.StatusUnauthorized, "Invalid credentials")
+
+	g := d.Group("student", StudentAuth).Legacy()
+	sessionID := func(o *Operation) *Operation { return o.Query("session_id", String(), true, "") }
+
+	g.Get("/student/sessions", "GetAvailableSessions", "Venues open for booking at a level").
+		Query("level", Level(), true, "").
+		Returns(ArrayOf(d.Define("AvailableVenue", Object(
+			P("id", String()),
+			P("venue_name", String()),
+			P("capacity", Integer()),
+			P("booked", Integer()),
+			P("remaining", Integer()),
+			P("session_timing", String()),
+			P("table_details", String()),
+			P("level", Level()),
+		))))
+	g.Post("/student/sessions/book", "BookVenue", "Book a seat at a venue").
+		Body(d.Input("BookingRequest", student.BookingRequest{}, "venue_id")).
+		Returns(d.Define("Booking", Object(
+			P("status", String()),
+			P("session_id", String()),
+			P("venue_id", String()),
+			P("booked_seats", Integer()),
+			P("remaining_seats", Integer()),
+		))).
+		Fails(http.StatusConflict, "Already booked, venue full, or overlapping another of the student's sessions (code schedule_conflict)")
+	g.Post("/student/sessions/join", "JoinSession", "Join a session by scanning its QR code").
+		Body(d.Define("CheckIn", Object(P("qr_data", String())))).
+		Returns(d.Define("CheckedIn", Object(P("status", String()), P("session_id", String())))).
+		Fails(http.StatusForbidden, "QR code full (code qr_full)").
+		Fails(http.StatusConflict, "The session overlaps another of the student's sessions (code schedule_conflict)")
+	g.Get("/student/session", "GetSessionDetails", "Session details and agenda").
+		Apply(sessionID).
+		Returns(d.Define("SessionDetails", Object(
+			P("id", String()),
+			P("venue", String()),
+			P("topic", String()),
+			P("prep_time", Integer()),
+			P("discussion_time", Integer()),
+			P("survey_time", Integer()),
+			P("start_time", DateTime()),
+		))).
+		Fails(http.StatusNotFound, "Session not found")
+	g.Get("/student/session/rules", "GetSessionRules", "Phase durations in minutes").
+		Apply(sessionID).
+		Returns(d.Define("SessionRules", Object(P("prep_time", Integer()), P("discussion_time", Integer()), P("survey_time", Integer()))))
+	g.Get("/student/session/check", "CheckBooking", "Whether the student has booked a venue").
+		Query("venue_id", String(), true, "").
+		Returns(d.Define("BookingCheck", Object(P("is_booked", Boolean()))))
+	g.Delete("/student/session/cancel", "CancelBooking", "Cancel a booking").
+		Body(Object(P("venue_id", String()))).
+		Returns(status)
+	g.Get("/student/session/participants", "GetSessionParticipants", "Other participants present in the session").
+		Apply(sessionID).
+		Returns(d.Define("SessionParticipants", Object(P("data", ArrayOf(Object(
+			P("id", String()),
+			P("name", String()),
+			P("email", String()),
+			P("department", String()),
+			P("profileImage", String()),
+		))))))
+	g.Put("/student/session/status", "UpdateSessionStatus", "Move a session to another phase").
+		Body(Object(
+			P("sessionId", String()),
+			P("status", Enum("pending", "lobby", "active", "completed")),
+		)).
+		Returns(status)
+	g.Get("/student/topic", "GetTopicForLevel", "A topic for the level with its preparation material").
+		Query("level", Level(), false, "Required without session_id; suggests a topic the student has not discussed, without recording it.").
+		Query("session_id", String(), false, "Returns the session's topic, chosen once for all of its participants.").
+		Returns(d.Define("TopicForLevel", Object(
+			P("topic_text", String()),
+			P("prep_materials", MapOf(Any()).Describe("The topic's free-form notes; superseded by materials.")),
+			P("materials", ArrayOf(d.Define("VisibleMaterial", Object(
+				P("id", String()),
+				P("kind", Enum(repository.MaterialKinds...)),
+				P("title", String()),
+				Opt("body", String()),
+				Opt("url", String()),
+				Opt("file_name", String()),
+				Opt("content_type", String()),
+				Opt("size", Integer()),
+				Opt("file_url", String().Describe("Where a participant downloads the attachment; only given with session_id.")),
+			))).Describe("The prep materials visible to the student at this point of the session.")),
+		))).
+		Fails(http.StatusForbidden, "Not a participant of the session")
+	g.Get("/student/questions", "GetQuestionsForStudent", "Survey questions in this student's order").
+		Query("level", Level(), false, "Required without session_id; a session uses its own level.").
+		Query("session_id", String(), false, "Returns the questions served to the student for this session, recorded on first request.").
+		Returns(ArrayOf(d.Define("SurveyQuestion", Object(
+			P("id", String()),
+			P("text", String()),
+			P("weight", Number()),
+			P("ranking_depth", Integer().Describe("How many students to rank.")),
+		)))).
+		Fails(http.StatusForbidden, "Not a participant of the session")
+
+	g.Post("/student/survey", "SubmitSurvey", "Submit rankings for one or more questions").
+		Body(d.Input("SurveySubmission", student.SurveySubmission{}, "session_id", "responses").
+			Describe("responses maps question id to rank to the ranked student's id; only questions served to the student for the session are accepted, and ranks go as deep as the level's ranking points.")).
+		Returns(d.Define("SurveyProgress", Object(
+			P("status", String()),
+			P("completed", Boolean()),
+			P("questions_answered", Integer()),
+			P("total_questions", Integer()),
+		))).
+		Fails(http.StatusForbidden, "Not a participant of the session").
+		Fails(http.StatusConflict, "Session results are already final or under review")
+	timeout := d.Define("Timeout", Object(P("remaining_seconds", Number()), P("is_timed_out", Boolean())))
+	g.Post("/student/survey/start", "StartSurveyTimer", "Start the survey timer").
+		Apply(sessionID).
+		Returns(status)
+	g.Get("/student/survey/timeout", "CheckSurveyTimeout", "Time left in the survey").
+		Apply(sessionID).
+		Returns(timeout)
+	g.Post("/student/survey/penalties", "ApplySurveyPenalties", "Finalize the session once the survey is over").
+		Apply(sessionID).
+		Returns(status).
+		Fails(http.StatusConflict, "Survey is still open")
+	g.Post("/student/survey/start-question", "StartQuestionTimer", "Start the timer for one question").
+		Body(Object(P("session_id", String()), P("question_id", String()))).
+		Returns(status)
+	g.Get("/student/survey/check-timeout", "CheckQuestionTimeout", "Time left on one question").
+		Apply(sessionID).
+		Query("question_id", String(), true, "").
+		Returns(timeout)
+	g.Post("/student/survey/apply-penalty", "ApplyQuestionPenalty", "Penalise a timed-out question").
+		Body(Object(P("session_id", String()), P("question_id", String()), Opt("student_id", String()))).
+		Returns(status)
+	g.Get("/student/survey/completion", "CheckSurveyCompletion", "How many participants have finished the survey").
+		Apply(sessionID).
+		Returns(d.Define("SurveyCompletion", Object(P("all_completed", Boolean()), P("completed", Integer()), P("total", Integer()))))
+	g.Post("/student/survey/mark-completed", "MarkSurveyCompleted", "Record that the student finished the survey").
+		Body(Object(P("session_id", String()))).
+		Returns(status)
+
+	g.Get("/student/results", "GetResults", "Ranked results for a session").
+		Apply(sessionID).
+		Returns(d.Define("SessionResults", Object(
+			P("session_id", String()),
+			P("finalized", Boolean().Describe("Whether the results are final; until then they are provisional.")),
+			Opt("under_review", Boolean().Describe("Until final: whether responses are held for collusion review.")),
+			Opt("rubric", ArrayOf(Object(P("criterion", String()), P("score", Number()), P("max_score", Number()))).
+				Describe("The requesting student's moderator marks, once the results are final.")),
+			Opt("feedback", String().Describe("The moderator's feedback for the requesting student.")),
+			P("results", ArrayOf(d.Define("SessionResult", Object(
+				P("student_id", String()),
+				P("name", String()),
+				P("photo_url", String()),
+				P("rank", Integer()),
+				Opt("qualified", Boolean().Describe("Only present once the results are final.")),
+				P("total_score", String().Describe("Decimal with two places.")),
+				P("penalty_points", String().Describe("Decimal with two places.")),
+				P("final_score", String().Describe("Decimal with two places.")),
+				P("first_places", Integer()),
+				P("biased_questions", Integer()),
+				Opt("peer_score", String().Describe("Total less penalties; present when moderator marks are blended in, final_score then being a percentage.")),
+				Opt("moderator_score", String().Describe("Rubric mark as a percentage, for students a moderator marked.")),
+				Opt("moderator_weight", Number().Describe("Share of final_score taken from moderator marks.")),
+			)))),
+		))).
+		Fails(http.StatusForbidden, "Not a participant of the session")
+
+	g.Post("/student/feedback", "SubmitFeedback", "Rate a session").
+		Body(d.Input("FeedbackRequest", student.FeedbackRequest{}, "session_id", "rating")).
+		Returns(status)
+	g.Get("/student/feedback/get", "GetFeedback", "The student's feedback for a session, or an empty object").
+		Apply(sessionID).
+		Returns(d.Define("Feedback", Object(Opt("rating", Integer()), Opt("comments", String()))))
+}
+
+func opsRoutes(d *Document) {
+	g := d.Group("ops", "")
+	g.Get("/healthz", "Liveness", "Liveness probe").
+		Returns(Object(P("status", String())))
+	g.Get("/readyz", "Readiness", "Readiness probe; 503 lists the failing checks").
+		Returns(Object(P("status", String()), Opt("checks", MapOf(String()))))
+	g.Get("/metrics", "Metrics", "Prometheus metrics; requires METRICS_TOKEN as a bearer token when set").
+		ReturnsText("Prometheus text exposition format")
+	g.Get("/openapi.json", "OpenAPI", "This document").
+		Returns(Object())
+}
+
+// topicFilters documents GetTopics' search, filter and paging parameters.
+func topicFilters(o *Operation) *Operation {
+	return o.Query("level", Level(), false, "").
+		Query("q", String(), false, "Full-text search on topic_text; results are ordered by relevance instead of newest first.").
+		Query("category", String(), false, "").
+		Query("tag", String(), false, "").
+		Query("difficulty", Enum("easy", "medium", "hard"), false, "").
+		Query("inactive", Boolean(), false, "Include deactivated topics.").
+		Query("page", Integer(), false, "From 1. Without page or page_size every match is listed; the X-Total-Count header always gives their number.").
+		Query("page_size", Integer(), false, "At most 200; defaults to 50.")
+}
+
+// topicForce documents the duplicate check on saving a topic.
+func topicForce(o *Operation) *Operation {
+	return o.Query("force", Boolean(), false, "Save even though a topic at the level shares most of its words.").
+		Fails(http.StatusConflict, "A topic at the level has the same text, or similar text (code similar_topic) without force")
+}
@@ -0,0 +1,27 @@
+// Command tsclient writes the TypeScript client for the API described in
+// gd/openapi. Run it from the backend directory after changing a route:
+//
+//	go run ./openapi/cmd/tsclient
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"gd/openapi"
+)
+
+func main() {
+	out := flag.String("o", "../frontend/src/api/gdClient.ts", "output file")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := openapi.WriteTypeScript(&buf, openapi.API()); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
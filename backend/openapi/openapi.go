@@ -0,0 +1,274 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document. The
+// document is built in Go next to the handlers it describes so request and
+// response schemas are reflected from the same structs the handlers encode,
+// and a contract test keeps it in step with the registered routes.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document is the subset of an OpenAPI 3.0 document this API needs.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations on one path, keyed by lower-case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+
+	method string
+	path   string
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// New starts an empty document.
+func New(title, version, description string) *Document {
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{},
+		},
+	}
+}
+
+// Bearer registers a JWT bearer security scheme.
+func (d *Document) Bearer(name, description string) {
+	d.Components.SecuritySchemes[name] = &SecurityScheme{
+		Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: description,
+	}
+}
+
+// Group adds operations that share a tag and a security requirement. An
+// empty scheme marks the operations as public.
+type Group struct {
+	doc    *Document
+	tag    string
+	scheme string
+	legacy bool
+}
+
+func (d *Document) Group(tag, scheme string) *Group {
+	return &Group{doc: d, tag: tag, scheme: scheme}
+}
+
+// Legacy returns a group for the unversioned paths. Its operations are
+// deprecated, their IDs are prefixed with "Legacy" and the generated client
+// leaves them out.
+func (g *Group) Legacy() *Group {
+	c := *g
+	c.legacy = true
+	return &c
+}
+
+func (g *Group) Get(path, id, summary string) *Operation {
+	return g.add(http.MethodGet, path, id, summary)
+}
+
+func (g *Group) Post(path, id, summary string) *Operation {
+	return g.add(http.MethodPost, path, id, summary)
+}
+
+func (g *Group) Put(path, id, summary string) *Operation {
+	return g.add(http.MethodPut, path, id, summary)
+}
+
+func (g *Group) Delete(path, id, summary string) *Operation {
+	return g.add(http.MethodDelete, path, id, summary)
+}
+
+func (g *Group) add(method, path, id, summary string) *Operation {
+	item := g.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		g.doc.Paths[path] = item
+	}
+	op := &Operation{
+		OperationID: id,
+		Summary:     summary,
+		Tags:        []string{g.tag},
+		Responses: map[string]*Response{
+			"default": jsonResponse("Error", Ref("ApiError")),
+		},
+		Security: []map[string][]string{},
+		method:   method,
+		path:     path,
+	}
+	if g.legacy {
+		op.OperationID = "Legacy" + id
+		op.Deprecated = true
+	}
+	if g.scheme != "" {
+		op.Security = []map[string][]string{{g.scheme: {}}}
+		op.Responses["401"] = jsonResponse("Missing or invalid token", Ref("ApiError"))
+	}
+	for _, name := range pathParams(path) {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: String()})
+	}
+	(*item)[strings.ToLower(method)] = op
+	return op
+}
+
+// Query documents a query string parameter.
+func (o *Operation) Query(name string, s *Schema, required bool, description string) *Operation {
+	o.Parameters = append(o.Parameters, Parameter{
+		Name: name, In: "query", Required: required, Description: description, Schema: s,
+	})
+	return o
+}
+
+// Body documents a required JSON request body.
+func (o *Operation) Body(s *Schema) *Operation {
+	o.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"application/json": {Schema: s}}}
+	return o
+}
+
+// Form documents a required multipart/form-data request body, for uploads.
+func (o *Operation) Form(s *Schema) *Operation {
+	o.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{"multipart/form-data": {Schema: s}}}
+	return o
+}
+
+// Returns documents the 200 JSON response.
+func (o *Operation) Returns(s *Schema) *Operation {
+	return o.Status(http.StatusOK, s)
+}
+
+// Status documents a JSON response for status.
+func (o *Operation) Status(status int, s *Schema) *Operation {
+	o.Responses[strconv.Itoa(status)] = jsonResponse(http.StatusText(status), s)
+	return o
+}
+
+// ReturnsText documents a 200 plain-text response.
+func (o *Operation) ReturnsText(description string) *Operation {
+	o.Responses["200"] = &Response{Description: description, Content: map[string]*MediaType{"text/plain": {Schema: String()}}}
+	return o
+}
+
+// ReturnsFile documents a 200 response carrying a stored file, of whatever
+// type it was uploaded as.
+func (o *Operation) ReturnsFile(description string) *Operation {
+	o.Responses["200"] = &Response{Description: description, Content: map[string]*MediaType{"application/octet-stream": {Schema: Binary()}}}
+	return o
+}
+
+// Fails documents an error status the handler returns with the error envelope.
+func (o *Operation) Fails(status int, description string) *Operation {
+	o.Responses[strconv.Itoa(status)] = jsonResponse(description, Ref("ApiError"))
+	return o
+}
+
+// Apply runs shared decorations, such as a common query parameter.
+func (o *Operation) Apply(fns ...func(*Operation) *Operation) *Operation {
+	for _, fn := range fns {
+		o = fn(o)
+	}
+	return o
+}
+
+// Method and Path report where the operation is served.
+func (o *Operation) Method() string { return o.method }
+func (o *Operation) Path() string   { return o.path }
+
+// Operations lists every operation sorted by path, then method.
+func (d *Document) Operations() []*Operation {
+	var ops []*Operation
+	for _, item := range d.Paths {
+		for _, op := range *item {
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].path != ops[j].path {
+			return ops[i].path < ops[j].path
+		}
+		return ops[i].method < ops[j].method
+	})
+	return ops
+}
+
+// Handler serves the document as JSON. It is encoded once up front.
+func Handler(d *Document) http.Handler {
+	body, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		panic("openapi: encoding document: " + err.Error())
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Write(body)
+	})
+}
+
+func jsonResponse(description string, s *Schema) *Response {
+	return &Response{Description: description, Content: map[string]*MediaType{"application/json": {Schema: s}}}
+}
+
+func pathParams(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "}"))
+		}
+	}
+	return names
+}
@@ -0,0 +1,184 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	admincontrollers "gd/admin/controllers"
+	adminroutes "gd/admin/routes"
+	studentcontrollers "gd/student/controllers"
+	studentroutes "gd/student/routes"
+)
+
+type recorder struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func newRecorder() *recorder {
+	return &recorder{ServeMux: http.NewServeMux()}
+}
+
+func (r *recorder) Handle(pattern string, h http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.Handle(pattern, h)
+}
+
+// concrete turns a templated spec path into one the mux can route.
+func concrete(path string) string {
+	return regexp.MustCompile(`\{[^}]+\}`).ReplaceAllString(path, "x")
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	doc := API()
+	routers := map[string]*recorder{"/admin/": newRecorder(), "/student/": newRecorder()}
+	adminroutes.RegisterAdminRoutes(routers["/admin/"], admincontrollers.NewHandlers(nil, nil))
+	studentroutes.RegisterStudentRoutes(routers["/student/"], studentcontrollers.NewHandlers(nil, nil))
+
+	for prefix, rec := range routers {
+		// Which registered pattern serves each documented path.
+		documented := map[string]bool{}
+		for path := range doc.Paths {
+			if !strings.HasPrefix(path, prefix) {
+				continue
+			}
+			req := httptest.NewRequest(http.MethodGet, concrete(path), nil)
+			_, pattern := rec.Handler(req)
+			if pattern == "" {
+				t.Errorf("%s is documented but no route serves it", path)
+				continue
+			}
+			documented[pattern] = true
+		}
+		for _, pattern := range rec.patterns {
+			if !documented[pattern] {
+				t.Errorf("route %s is registered without an entry in openapi.API", pattern)
+			}
+		}
+	}
+}
+
+// The /api/v1 patterns carry their method, so they must match the document
+// exactly, operation by operation.
+func TestV1RoutesMatchDocument(t *testing.T) {
+	doc := API()
+	rec := newRecorder()
+	adminroutes.RegisterAdminV1(rec, admincontrollers.NewHandlers(nil, nil))
+	studentroutes.RegisterStudentV1(rec, studentcontrollers.NewHandlers(nil, nil))
+
+	registered := map[string]bool{}
+	for _, pattern := range rec.patterns {
+		registered[pattern] = true
+		method, path, _ := strings.Cut(pattern, " ")
+		if item := doc.Paths[path]; item == nil || (*item)[strings.ToLower(method)] == nil {
+			t.Errorf("route %s is registered without an entry in openapi.API", pattern)
+		}
+	}
+	for _, op := range doc.Operations() {
+		if !strings.HasPrefix(op.Path(), "/api/v1/") {
+			continue
+		}
+		if op.Deprecated {
+			t.Errorf("%s %s is deprecated", op.Method(), op.Path())
+		}
+		if !registered[op.Method()+" "+op.Path()] {
+			t.Errorf("%s %s is documented but not registered", op.Method(), op.Path())
+		}
+	}
+}
+
+func TestDocumentIsConsistent(t *testing.T) {
+	doc := API()
+	ids := map[string]string{}
+	for _, op := range doc.Operations() {
+		where := op.Method() + " " + op.Path()
+		if prev, ok := ids[op.OperationID]; ok {
+			t.Errorf("operationId %s used by %s and %s", op.OperationID, prev, where)
+		}
+		ids[op.OperationID] = where
+		if _, ok := op.Responses["200"]; !ok {
+			t.Errorf("%s documents no success response", where)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	Handler(doc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var served map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("served document is not JSON: %v", err)
+	}
+	if served["openapi"] != "3.0.3" {
+		t.Errorf("openapi = %v", served["openapi"])
+	}
+
+	// Every $ref must resolve.
+	for _, m := range regexp.MustCompile(`"\$ref":\s*"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(rec.Body.String(), -1) {
+		if _, ok := doc.Components.Schemas[m[1]]; !ok {
+			t.Errorf("dangling reference to %s", m[1])
+		}
+	}
+}
+
+type inner struct {
+	Label string `json:"label"`
+}
+
+type sample struct {
+	Name     string            `json:"name"`
+	Count    int               `json:"count,omitempty"`
+	Tags     []string          `json:"tags"`
+	Inner    inner             `json:"inner"`
+	Extra    map[string]string `json:"extra"`
+	Optional *bool             `json:"optional"`
+	Ignored  string            `json:"-"`
+	hidden   string
+}
+
+func TestModelReflectsJSONTags(t *testing.T) {
+	d := New("t", "1", "")
+	if ref := d.Model(sample{}); ref.Ref != "#/components/schemas/sample" {
+		t.Fatalf("ref = %q", ref.Ref)
+	}
+	s := d.Components.Schemas["sample"]
+	if got := strings.Join(s.Required, ","); got != "extra,inner,name,tags" {
+		t.Errorf("required = %s", got)
+	}
+	if _, ok := s.Properties["Ignored"]; ok {
+		t.Error(`json:"-" field documented`)
+	}
+	if s.Properties["inner"].Ref != "#/components/schemas/inner" || d.Components.Schemas["inner"] == nil {
+		t.Error("nested struct not registered as a component")
+	}
+	if !s.Properties["optional"].Nullable || s.Properties["tags"].Items.Type != "string" {
+		t.Errorf("properties = %+v", s.Properties)
+	}
+
+	d.Input("sampleInput", sample{}, "name")
+	if got := d.Components.Schemas["sampleInput"].Required; len(got) != 1 || got[0] != "name" {
+		t.Errorf("input required = %v", got)
+	}
+}
+
+func TestTypeScriptClientIsCurrent(t *testing.T) {
+	const path = "../../frontend/src/api/gdClient.ts"
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Skip("frontend not checked out")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := WriteTypeScript(&got, API()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("%s is stale; run go generate ./openapi", path)
+	}
+}
@@ -0,0 +1,219 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema object as used by OpenAPI 3.0.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+
+	// goType records which Go type a reflected component came from so two
+	// types cannot silently claim the same name.
+	goType string
+}
+
+func String() *Schema   { return &Schema{Type: "string"} }
+func Integer() *Schema  { return &Schema{Type: "integer"} }
+func Number() *Schema   { return &Schema{Type: "number"} }
+func Boolean() *Schema  { return &Schema{Type: "boolean"} }
+func DateTime() *Schema { return &Schema{Type: "string", Format: "date-time"} }
+
+// Binary is raw file content, in an upload form or a download.
+func Binary() *Schema { return &Schema{Type: "string", Format: "binary"} }
+
+// Any matches every JSON value.
+func Any() *Schema { return &Schema{} }
+
+// Level is an integer GD level, 1 to 3.
+func Level() *Schema {
+	lo, hi := 1.0, 3.0
+	return &Schema{Type: "integer", Minimum: &lo, Maximum: &hi}
+}
+
+// Enum is a string restricted to values.
+func Enum(values ...string) *Schema { return &Schema{Type: "string", Enum: values} }
+
+// Ref points at a schema in components.
+func Ref(name string) *Schema { return &Schema{Ref: "#/components/schemas/" + name} }
+
+func ArrayOf(items *Schema) *Schema { return &Schema{Type: "array", Items: items} }
+
+// MapOf is an object whose keys are free-form and whose values match values.
+func MapOf(values *Schema) *Schema { return &Schema{Type: "object", AdditionalProperties: values} }
+
+// Prop is one named property of an Object.
+type Prop struct {
+	Name     string
+	Schema   *Schema
+	Optional bool
+}
+
+// P is a required property; Opt is an optional one.
+func P(name string, s *Schema) Prop   { return Prop{Name: name, Schema: s} }
+func Opt(name string, s *Schema) Prop { return Prop{Name: name, Schema: s, Optional: true} }
+
+// Object builds an inline object schema, used for the responses handlers
+// build from map literals.
+func Object(props ...Prop) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, p := range props {
+		s.Properties[p.Name] = p.Schema
+		if !p.Optional {
+			s.Required = append(s.Required, p.Name)
+		}
+	}
+	return s
+}
+
+// Describe returns a copy of s with a description.
+func (s *Schema) Describe(description string) *Schema {
+	c := *s
+	c.Description = description
+	return &c
+}
+
+// Define registers s under name in components and returns a reference to it.
+func (d *Document) Define(name string, s *Schema) *Schema {
+	if existing, ok := d.Components.Schemas[name]; ok && existing != s {
+		panic(fmt.Sprintf("openapi: schema %s defined twice", name))
+	}
+	d.Components.Schemas[name] = s
+	return Ref(name)
+}
+
+// Model reflects the JSON shape of v's type, registers it in components
+// under the Go type name and returns a reference. Fields without omitempty
+// are listed as required, which matches how handlers encode responses.
+// Named struct types reached from v are registered the same way.
+func (d *Document) Model(v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return d.ModelNamed(t.Name(), v)
+}
+
+// ModelNamed is Model with an explicit component name, for types whose Go
+// name is ambiguous or unexported.
+func (d *Document) ModelNamed(name string, v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return d.define(name, t, nil)
+}
+
+// Input reflects a request body type. Only the listed fields are required;
+// handlers fill in or ignore the rest.
+func (d *Document) Input(name string, v interface{}, required ...string) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if required == nil {
+		required = []string{}
+	}
+	return d.define(name, t, required)
+}
+
+func (d *Document) define(name string, t reflect.Type, required []string) *Schema {
+	if t.Kind() != reflect.Struct || name == "" {
+		panic(fmt.Sprintf("openapi: cannot name schema for %s", t))
+	}
+	if existing, ok := d.Components.Schemas[name]; ok {
+		if existing.goType != "" && existing.goType != t.String()+fmt.Sprint(required) {
+			panic(fmt.Sprintf("openapi: schema %s already describes %s", name, existing.goType))
+		}
+		return Ref(name)
+	}
+	// Reserve the name first so self-referencing types terminate.
+	placeholder := &Schema{}
+	d.Components.Schemas[name] = placeholder
+	*placeholder = *d.structSchema(t, required)
+	placeholder.goType = t.String() + fmt.Sprint(required)
+	return Ref(name)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return DateTime()
+	case t.Kind() == reflect.Ptr:
+		s := d.schemaFor(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return Number()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(d.schemaFor(t.Elem()))
+	case reflect.Map:
+		return MapOf(d.schemaFor(t.Elem()))
+	case reflect.Interface:
+		return Any()
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t, nil)
+		}
+		return d.define(t.Name(), t, nil)
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+func (d *Document) structSchema(t reflect.Type, required []string) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	derive := required == nil
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schemaFor(f.Type)
+		if derive && !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+	if !derive {
+		s.Required = append(s.Required, required...)
+	}
+	sort.Strings(s.Required)
+	return s
+}
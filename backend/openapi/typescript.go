@@ -0,0 +1,207 @@
+package openapi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteTypeScript generates a typed client for d: one interface per
+// component schema and one method per current (not deprecated) operation on
+// GdClient. The client wraps anything with axios' request signature, so the
+// app keeps its own instances, base URLs and auth interceptors.
+func WriteTypeScript(w io.Writer, d *Document) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "// Code generated by go run ./openapi/cmd/tsclient; DO NOT EDIT.")
+	fmt.Fprintf(b, "// %s %s\n\n", d.Info.Title, d.Info.Version)
+
+	names := make([]string, 0, len(d.Components.Schemas))
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := d.Components.Schemas[name]
+		if s.Type == "object" && s.AdditionalProperties == nil {
+			fmt.Fprintf(b, "export interface %s %s\n\n", name, tsObject(s, ""))
+		} else {
+			fmt.Fprintf(b, "export type %s = %s;\n\n", name, tsType(s, ""))
+		}
+	}
+
+	fmt.Fprint(b, `export interface HttpClient {
+  request<T>(config: {
+    method: string;
+    url: string;
+    params?: Record<string, unknown>;
+    data?: unknown;
+    responseType?: 'blob';
+  }): Promise<{ data: T }>;
+}
+
+export class GdClient {
+  constructor(private readonly http: HttpClient) {}
+`)
+	for _, op := range d.Operations() {
+		if !op.Deprecated {
+			writeMethod(b, op)
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+func writeMethod(b *bufio.Writer, op *Operation) {
+	var args, pathArgs []string
+	url := "'" + op.path + "'"
+	var query []Parameter
+	queryRequired := false
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			pathArgs = append(pathArgs, p.Name)
+			args = append(args, p.Name+": string")
+		case "query":
+			query = append(query, p)
+			queryRequired = queryRequired || p.Required
+		}
+	}
+	if len(pathArgs) > 0 {
+		url = "`" + op.path + "`"
+		for _, name := range pathArgs {
+			url = strings.Replace(url, "{"+name+"}", "${encodeURIComponent("+name+")}", 1)
+		}
+	}
+	if op.RequestBody != nil {
+		if mt := op.RequestBody.Content["application/json"]; mt != nil {
+			args = append(args, "body: "+tsType(mt.Schema, "  "))
+		} else {
+			args = append(args, "body: FormData")
+		}
+	}
+	if len(query) > 0 {
+		fields := make([]string, len(query))
+		for i, p := range query {
+			opt := "?"
+			if p.Required {
+				opt = ""
+			}
+			fields[i] = p.Name + opt + ": " + tsType(p.Schema, "")
+		}
+		arg := "query: { " + strings.Join(fields, "; ") + " }"
+		if !queryRequired {
+			arg += " = {}"
+		}
+		args = append(args, arg)
+	}
+
+	result := "void"
+	file := false
+	if resp := op.Responses["200"]; resp != nil {
+		if mt := resp.Content["application/json"]; mt != nil {
+			result = tsType(mt.Schema, "  ")
+		} else if resp.Content["text/plain"] != nil {
+			result = "string"
+		} else if resp.Content["application/octet-stream"] != nil {
+			result, file = "Blob", true
+		}
+	}
+
+	fmt.Fprintln(b)
+	if op.Summary != "" {
+		fmt.Fprintf(b, "  /** %s */\n", op.Summary)
+	}
+	fmt.Fprintf(b, "  %s(%s): Promise<%s> {\n", lowerFirst(op.OperationID), strings.Join(args, ", "), result)
+	fmt.Fprintf(b, "    return this.http\n      .request<%s>({ method: '%s', url: %s", result, op.method, url)
+	if len(query) > 0 {
+		fmt.Fprint(b, ", params: query")
+	}
+	if op.RequestBody != nil {
+		fmt.Fprint(b, ", data: body")
+	}
+	if file {
+		fmt.Fprint(b, ", responseType: 'blob'")
+	}
+	fmt.Fprintln(b, " })\n      .then(r => r.data);\n  }")
+}
+
+func tsType(s *Schema, indent string) string {
+	t := tsBase(s, indent)
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+func tsBase(s *Schema, indent string) string {
+	if s.Ref != "" {
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	}
+	if len(s.Enum) > 0 {
+		quoted := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			quoted[i] = "'" + v + "'"
+		}
+		return strings.Join(quoted, " | ")
+	}
+	switch s.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		item := tsType(s.Items, indent)
+		if strings.ContainsAny(item, " |{") {
+			return "Array<" + item + ">"
+		}
+		return item + "[]"
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "Record<string, " + tsType(s.AdditionalProperties, indent) + ">"
+		}
+		return tsObject(s, indent)
+	}
+	return "unknown"
+}
+
+func tsObject(s *Schema, indent string) string {
+	if len(s.Properties) == 0 {
+		return "Record<string, unknown>"
+	}
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for _, name := range names {
+		p := s.Properties[name]
+		if p.Description != "" {
+			fmt.Fprintf(&sb, "%s  /** %s */\n", indent, p.Description)
+		}
+		opt := "?"
+		if required[name] {
+			opt = ""
+		}
+		fmt.Fprintf(&sb, "%s  %s%s: %s;\n", indent, name, opt, tsType(p, indent+"  "))
+	}
+	sb.WriteString(indent + "}")
+	return sb.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
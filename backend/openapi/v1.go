@@ -0,0 +1,347 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+
+	admin "gd/admin/controllers"
+	"gd/admin/models"
+	"gd/repository"
+	student "gd/student/controllers"
+)
+
+// adminV1 documents gd/admin/routes.RegisterAdminV1. Identifiers in the path
+// replace the query parameters and body fields the legacy routes used; a body
+// field with the same name as a path wildcard is ignored.
+func adminV1(d *Document, status, message *Schema) {
+	d.Group("admin", "").Post("/api/v1/admin/login", "AdminLogin", "Exchange admin credentials for a token").
+		Body(Ref("AdminLoginRequest")).
+		Returns(Ref("AdminToken")).
+		Fails(http.StatusUnauthorized, "Invalid credentials")
+
+	g := d.Group("admin", AdminAuth)
+
+	venue := d.Model(models.Venue{})
+	g.Get("/api/v1/admin/venues", "ListVenues", "List active venues").
+		Returns(ArrayOf(venue))
+	g.Post("/api/v1/admin/venues", "CreateVenue", "Create a venue").
+		Body(Ref("VenueInput")).
+		Returns(venue)
+	g.Put("/api/v1/admin/venues/{id}", "UpdateVenue", "Update a venue").
+		Body(Ref("VenueUpdate")).
+		Returns(message)
+	availability := d.Model(models.VenueAvailability{})
+	g.Put("/api/v1/admin/venues/{venue_id}/availability", "SetVenueAvailability", "Set the weekly windows sessions are generated in at a venue").
+		Body(d.Input("VenueAvailabilityInput", models.VenueAvailability{}, "slot_minutes", "windows").
+			Describe("Each window holds as many back-to-back slots of slot_minutes as fit; windows on the same weekday must not overlap.")).
+		Returns(availability).
+		Fails(http.StatusNotFound, "Venue not found")
+
+	g.Get("/api/v1/admin/venues/{venue_id}/qr-groups", "ListVenueQRGroups", "List a venue's active QR codes").
+		Returns(ArrayOf(Ref("VenueQRSummary")))
+	g.Post("/api/v1/admin/venues/{venue_id}/qr-groups", "IssueVenueQRGroup", "Return the venue's usable QR code, generating one if needed").
+		Query("force_new", Boolean(), false, "Always generate a new code.").
+		Query("auto_generate", Boolean(), false, "Generate a new code when every active one is full.").
+		Returns(Ref("VenueQR"))
+	g.Delete("/api/v1/admin/qr-groups/{qr_id}", "DeactivateQRGroup", "Deactivate a QR code").
+		Returns(status)
+
+	g.Get("/api/v1/admin/sessions", "ListSessions", "Upcoming sessions").
+		Returns(ArrayOf(d.Model(admin.SessionSlot{})))
+	g.Post("/api/v1/admin/sessions", "CreateSessions", "Schedule several sessions at once").
+		Body(Object(P("sessions", ArrayOf(Ref("SessionRequest"))))).
+		Returns(Ref("CreatedSessions")).
+		Fails(http.StatusBadRequest, "A session is invalid, at a venue that is not active, or of another level than its venue").
+		Fails(http.StatusConflict, "A session overlaps another at its venue (code schedule_conflict); nothing was saved")
+	g.Post("/api/v1/admin/sessions/generate", "GenerateSessions", "Create a pending session for every free slot of the venues' availability").
+		Body(d.Input("SessionGenerationInput", admin.SessionGeneration{}, "from", "to").
+			Describe("Covers at most 92 dates. Slots on holidays and blackout dates, in the past, or overlapping a session at the venue are skipped, so repeating a range adds nothing.")).
+		Query("dry_run", Boolean(), false, "Report what would be created without creating it.").
+		Returns(d.Model(admin.GeneratedSessions{}))
+	holiday := d.Model(admin.Holiday{})
+	g.Get("/api/v1/admin/holidays", "ListHolidays", "Dates no sessions are generated on, by date").
+		Returns(ArrayOf(holiday))
+	g.Put("/api/v1/admin/holidays/{date}", "SaveHoliday", "Add or rename the holiday on a date, written YYYY-MM-DD").
+		Body(Object(P("name", String()))).
+		Returns(holiday)
+	g.Delete("/api/v1/admin/holidays/{date}", "DeleteHoliday", "Remove a holiday").
+		Returns(status).
+		Fails(http.StatusNotFound, "Holiday not found")
+	g.Get("/api/v1/admin/sessions/{session_id}/rules", "GetSessionRules", "Phase durations in minutes").
+		Returns(Ref("SessionRules")).
+		Fails(http.StatusNotFound, "Session not found")
+	g.Put("/api/v1/admin/sessions/{session_id}/rules", "UpdateSessionRules", "Change the phase durations of a session").
+		Body(d.Input("SessionRulesUpdate", admin.SessionRulesRequest{})).
+		Returns(status)
+	g.Get("/api/v1/admin/sessions/{session_id}/feedback", "ListSessionFeedback", "Feedback left for a session").
+		Returns(Ref("SessionFeedbacks"))
+	g.Get("/api/v1/admin/sessions/{session_id}/rubric-scores", "ListRubricScores", "Moderator marks and feedback for each participant").
+		Returns(ArrayOf(d.Model(admin.ModeratorReview{})))
+	g.Put("/api/v1/admin/sessions/{session_id}/rubric-scores/{student_id}", "ScoreParticipant", "Mark a participant against the level's rubric").
+		Body(d.Input("RubricScoreInput", admin.RubricScoreRequest{}).
+			Describe("scores maps criterion id to a mark between 0 and the criterion's max_score.")).
+		Returns(status).
+		Fails(http.StatusNotFound, "Session not found or student not a participant").
+		Fails(http.StatusConflict, "Session results are already final")
+	g.Get("/api/v1/admin/collusion-flags", "ListCollusionFlags", "Survey responses flagged as possibly coordinated").
+		Query("status", Enum("pending", "voided", "dismissed"), false, "Defaults to pending.").
+		Query("session_id", String(), false, "").
+		Returns(ArrayOf(d.Model(admin.CollusionFlag{})))
+	g.Put("/api/v1/admin/collusion-flags/{id}", "ReviewCollusionFlag", "Void the flagged responses or dismiss the flag").
+		Body(d.Input("CollusionReviewInput", admin.CollusionReview{}, "action").
+			Describe("Voiding strikes out the responses of responder_ids, or of every flagged responder, limited to question_ids when given. The session is finalized once its last flag is reviewed.")).
+		Returns(status).
+		Fails(http.StatusNotFound, "Flag not found").
+		Fails(http.StatusConflict, "Flag has already been reviewed")
+	g.Get("/api/v1/admin/bookings", "ListBookings", "Bookings for pending sessions").
+		Returns(ArrayOf(d.Model(admin.BookingInfo{})))
+
+	g.Get("/api/v1/admin/students", "ListStudentProgress", "Student progress through the levels").
+		Returns(ArrayOf(d.Model(admin.StudentProgress{})))
+	g.Get("/api/v1/admin/leaderboard", "GetLeaderboard", "Top 20 participants by total score").
+		Query("level", Level(), false, "").
+		Returns(Ref("TopParticipants"))
+	g.Get("/api/v1/admin/analytics/qualifications", "GetQualificationRates", "Qualification rate per department").
+		Returns(MapOf(Number()))
+
+	g.Get("/api/v1/admin/questions", "ListQuestions", "List survey questions").
+		Query("level", Level(), false, "Only this level's questions, in the order students are served them.").
+		Query("archived", Boolean(), false, "Include archived questions.").
+		Returns(ArrayOf(Ref("Question")))
+	g.Get("/api/v1/admin/question-usage", "ListQuestionUsage", "Every question, archived ones included, with the sessions and responses it has").
+		Returns(ArrayOf(d.Model(admin.QuestionUsage{})))
+	g.Post("/api/v1/admin/questions", "CreateQuestion", "Create a survey question").
+		Body(Ref("QuestionRequest")).
+		Returns(Object(P("status", String()), P("id", String())))
+	g.Put("/api/v1/admin/questions/{id}", "UpdateQuestion", "Update a survey question; omitted fields are unchanged").
+		Body(Ref("QuestionUpdate")).
+		Returns(status)
+	g.Delete("/api/v1/admin/questions/{id}", "DeleteQuestion", "Delete a survey question, or archive it if it was used").
+		Query("purge", Boolean(), false, "Refuse rather than archive a used question.").
+		Returns(Ref("QuestionRemoved")).
+		Fails(http.StatusNotFound, "Question not found").
+		Fails(http.StatusConflict, "Question was asked in sessions; it can only be archived")
+	g.Put("/api/v1/admin/question-order/{level}", "ReorderQuestions", "Set the order a level's questions are served in").
+		Body(d.Input("QuestionOrderInput", admin.QuestionOrder{}, "question_ids").
+			Describe("question_ids lists every question of the level, first to last. Students already served keep the order they saw.")).
+		Returns(d.Model(admin.QuestionOrder{}))
+	d.Model(admin.QuestionRow{})
+	report := d.Model(admin.ImportReport{})
+	g.Get("/api/v1/admin/questions/export", "ExportQuestions", "Download the questions for editing or for import elsewhere").
+		Apply(exportFormat).
+		Query("archived", Boolean(), false, "Include archived questions.").
+		ReturnsFile("A JSON array of QuestionRow, or CSV with columns " + strings.Join(admin.QuestionColumns, ", ") + "; levels are separated by semicolons")
+	g.Post("/api/v1/admin/questions/import", "ImportQuestions", "Create and update questions from a file, matching them by external_key").
+		Apply(importFile("QuestionRow", admin.QuestionColumns)).
+		Returns(report)
+
+	bank := d.Model(admin.QuestionBank{})
+	g.Get("/api/v1/admin/question-banks", "ListQuestionBanks", "Question banks by name, with their active question counts").
+		Returns(ArrayOf(bank))
+	g.Post("/api/v1/admin/question-banks", "CreateQuestionBank", "Create a question bank for a competency").
+		Body(d.Input("QuestionBankInput", admin.QuestionBank{}, "name")).
+		Returns(bank).
+		Fails(http.StatusConflict, "A bank with that name already exists")
+	g.Put("/api/v1/admin/question-banks/{id}", "UpdateQuestionBank", "Rename a question bank").
+		Body(Ref("QuestionBankInput")).
+		Returns(bank).
+		Fails(http.StatusNotFound, "Bank not found").
+		Fails(http.StatusConflict, "A bank with that name already exists")
+	g.Delete("/api/v1/admin/question-banks/{id}", "DeleteQuestionBank", "Delete a question bank; its questions are kept without one").
+		Returns(status).
+		Fails(http.StatusNotFound, "Bank not found").
+		Fails(http.StatusConflict, "Bank is drawn from by a level template")
+	template := d.Model(admin.QuestionTemplate{})
+	g.Get("/api/v1/admin/question-templates/{level}", "GetQuestionTemplate", "How many questions the level's sessions draw from each bank").
+		Returns(template)
+	g.Put("/api/v1/admin/question-templates/{level}", "UpdateQuestionTemplate", "Replace the level's question template").
+		Body(d.Input("QuestionTemplateInput", admin.QuestionTemplate{}, "draws").
+			Describe("Each session draws count questions from each bank, seeded by the session, and every participant answers the same set. A participant is not given a set they were asked before when another draw avoids it. An empty draws list asks every question of the level.")).
+		Returns(template)
+
+	topic := d.Model(admin.Topic{})
+	g.Get("/api/v1/admin/topics", "ListTopics", "Search and filter topics with their usage").
+		Apply(topicFilters).
+		Returns(ArrayOf(topic))
+	g.Post("/api/v1/admin/topics", "CreateTopic", "Create a topic").
+		Body(Ref("TopicInput")).
+		Apply(topicForce).
+		Returns(topic)
+	g.Put("/api/v1/admin/topics/{id}", "UpdateTopic", "Update a topic").
+		Body(Ref("TopicInput")).
+		Apply(topicForce).
+		Returns(message)
+	g.Delete("/api/v1/admin/topics/{id}", "DeleteTopic", "Delete a topic").
+		Returns(message)
+	d.Model(admin.TopicRow{})
+	g.Get("/api/v1/admin/topics/export", "ExportTopics", "Download every topic for editing or for import elsewhere").
+		Apply(exportFormat).
+		ReturnsFile("A JSON array of TopicRow, or CSV with columns " + strings.Join(admin.TopicColumns, ", ") + "; tags are separated by semicolons")
+	g.Post("/api/v1/admin/topics/import", "ImportTopics", "Create and update topics from a file, matching them by external_key").
+		Apply(importFile("TopicRow", admin.TopicColumns)).
+		Returns(report)
+	material := d.Model(admin.PrepMaterial{})
+	visibility := "Students see the material from visible_from (default prep) through visible_until, if set. The phases run booked, prep (from check-in, for the agenda's prep minutes), discussion, survey."
+	g.Get("/api/v1/admin/topics/{topic_id}/materials", "ListPrepMaterials", "A topic's prep materials in display order").
+		Returns(ArrayOf(material))
+	g.Post("/api/v1/admin/topics/{topic_id}/materials", "CreatePrepMaterial", "Add a link, key point or argument after the topic's other materials").
+		Body(d.Input("PrepMaterialInput", admin.PrepMaterial{}, "kind").
+			Describe("Links need an http or https url; key points and arguments need a body. "+visibility)).
+		Returns(material).
+		Fails(http.StatusNotFound, "Topic not found")
+	g.Post("/api/v1/admin/topics/{topic_id}/attachments", "UploadPrepMaterial", "Attach a PDF or image after the topic's other materials").
+		Form(Object(
+			P("file", Binary().Describe("A PDF or PNG, JPEG, GIF or WebP image of at most 20 MB.")),
+			Opt("title", String().Describe("Defaults to the file name.")),
+			Opt("visible_from", Enum(repository.MaterialPhases...)),
+			Opt("visible_until", Enum(repository.MaterialPhases...)),
+		).Describe(visibility)).
+		Returns(material).
+		Fails(http.StatusNotFound, "Topic not found").
+		Fails(http.StatusRequestEntityTooLarge, "File is larger than 20 MB").
+		Fails(http.StatusUnsupportedMediaType, "File is not a PDF or image")
+	g.Put("/api/v1/admin/prep-materials/{id}", "UpdatePrepMaterial", "Change a material's content, visibility and position").
+		Body(Ref("PrepMaterialInput").Describe("kind must stay the same; an attachment keeps its file.")).
+		Returns(material).
+		Fails(http.StatusNotFound, "Material not found").
+		Fails(http.StatusConflict, "kind differs from the material's")
+	g.Delete("/api/v1/admin/prep-materials/{id}", "DeletePrepMaterial", "Delete a material and any attached file").
+		Returns(status).
+		Fails(http.StatusNotFound, "Material not found")
+	g.Get("/api/v1/admin/prep-materials/{id}/file", "DownloadPrepMaterial", "An attachment's file").
+		ReturnsFile("The file, with the content type it was uploaded as").
+		Fails(http.StatusNotFound, "Attachment not found")
+
+	g.Get("/api/v1/admin/ranking-points", "ListRankingPointsConfigs", "List ranking point configurations").
+		Query("level", Level(), false, "").
+		Returns(ArrayOf(d.Model(admin.RankingPointsConfig{})))
+	g.Post("/api/v1/admin/ranking-points", "CreateRankingPointsConfig", "Add a ranking point configuration version for a level").
+		Body(Ref("RankingPointsConfigInput")).
+		Returns(Ref("RankingPointsConfigSaved"))
+	g.Put("/api/v1/admin/ranking-points/{id}", "UpdateRankingPointsConfig", "Supersede a configuration with a new version").
+		Body(Ref("RankingPointsConfigInput")).
+		Returns(Ref("RankingPointsConfigSaved")).
+		Fails(http.StatusNotFound, "Configuration not found")
+	g.Delete("/api/v1/admin/ranking-points/{id}", "DeleteRankingPointsConfig", "Delete a configuration").
+		Returns(status).
+		Fails(http.StatusNotFound, "Configuration not found").
+		Fails(http.StatusConflict, "Configuration has scored sessions")
+	g.Put("/api/v1/admin/ranking-points/{id}/active", "SetRankingPointsConfigActive", "Activate a configuration in place of its level's active one, or deactivate it").
+		Body(Object(P("is_active", Boolean()))).
+		Returns(Ref("RankingPointsConfigActive")).
+		Fails(http.StatusNotFound, "Configuration not found")
+
+	rubric := d.Model(admin.Rubric{})
+	g.Get("/api/v1/admin/rubrics/{level}", "GetRubric", "The level's moderator rubric").
+		Returns(rubric)
+	g.Put("/api/v1/admin/rubrics/{level}", "UpdateRubric", "Replace the level's moderator rubric").
+		Body(d.Input("RubricInput", admin.Rubric{}).
+			Describe("Criteria with an id are updated, those without are created and those left out are retired. moderator_weight is the share of the final score taken from moderator marks.")).
+		Returns(rubric)
+}
+
+// studentV1 documents gd/student/routes.RegisterStudentV1.
+func studentV1(d *Document, status *Schema) {
+	d.Group("student", "").Post("/api/v1/student/login", "StudentLogin", "Exchange student credentials for a token").
+		Body(Ref("StudentLoginRequest")).
+		Returns(Ref("StudentToken")).
+		Fails(http.StatusUnauthorized, "Invalid credentials")
+
+	g := d.Group("student", StudentAuth)
+	g.Get("/api/v1/student/venues", "ListAvailableVenues", "Venues open for booking at a level").
+		Query("level", Level(), true, "").
+		Returns(ArrayOf(Ref("AvailableVenue")))
+	g.Post("/api/v1/student/bookings", "CreateBooking", "Book a seat at a venue").
+		Body(Ref("BookingRequest")).
+		Returns(Ref("Booking")).
+		Fails(http.StatusConflict, "Already booked, venue full, or overlapping another of the student's sessions (code schedule_conflict)")
+	g.Get("/api/v1/student/bookings/{venue_id}", "GetBooking", "Whether the student has booked a venue").
+		Returns(Ref("BookingCheck"))
+	g.Delete("/api/v1/student/bookings/{venue_id}", "CancelBooking", "Cancel a booking").
+		Returns(status)
+	g.Post("/api/v1/student/check-ins", "CheckIn", "Join a session by scanning its QR code").
+		Body(Ref("CheckIn")).
+		Returns(Ref("CheckedIn")).
+		Fails(http.StatusForbidden, "QR code full (code qr_full)").
+		Fails(http.StatusConflict, "The session overlaps another of the student's sessions (code schedule_conflict)")
+	g.Get("/api/v1/student/topic", "GetTopicForLevel", "A topic for the level with its preparation material").
+		Query("level", Level(), true, "Suggests a topic the student has not discussed, without recording it.").
+		Returns(Ref("TopicForLevel"))
+
+	const session = "/api/v1/student/sessions/{session_id}"
+	g.Get(session, "GetSession", "Session details and agenda").
+		Returns(Ref("SessionDetails")).
+		Fails(http.StatusNotFound, "Session not found")
+	g.Get(session+"/rules", "GetStudentSessionRules", "Phase durations in minutes").
+		Returns(Ref("SessionRules"))
+	g.Get(session+"/participants", "ListSessionParticipants", "Other participants present in the session").
+		Returns(Ref("SessionParticipants"))
+	g.Put(session+"/status", "UpdateSessionStatus", "Move the session to another phase").
+		Body(Object(P("status", Enum("pending", "lobby", "active", "completed")))).
+		Returns(status)
+	g.Get(session+"/topic", "GetSessionTopic", "The session's topic, chosen once for all of its participants").
+		Returns(Ref("TopicForLevel")).
+		Fails(http.StatusForbidden, "Not a participant of the session")
+	g.Get(session+"/materials/{material_id}", "GetPrepMaterialFile", "An attachment of the session's topic, while it is visible to the student").
+		ReturnsFile("The file, with the content type it was uploaded as").
+		Fails(http.StatusForbidden, "Not a participant of the session").
+		Fails(http.StatusNotFound, "Attachment not found or not visible yet")
+	g.Get(session+"/questions", "ListSurveyQuestions", "Survey questions served to this student, in their order").
+		Returns(ArrayOf(Ref("SurveyQuestion"))).
+		Fails(http.StatusForbidden, "Not a participant of the session")
+	g.Get(session+"/results", "GetSessionResults", "Ranked results for the session").
+		Returns(Ref("SessionResults")).
+		Fails(http.StatusForbidden, "Not a participant of the session")
+	g.Get(session+"/feedback", "GetFeedback", "The student's feedback for the session, or an empty object").
+		Returns(Ref("Feedback"))
+	g.Post(session+"/feedback", "SubmitFeedback", "Rate the session").
+		Body(d.Input("FeedbackInput", student.FeedbackRequest{}, "rating")).
+		Returns(status)
+
+	timeout := Ref("Timeout")
+	g.Post(session+"/survey/responses", "SubmitSurveyResponses", "Submit rankings for one or more questions").
+		Body(d.Input("SurveyResponses", student.SurveySubmission{}, "responses").
+			Describe("responses maps question id to rank to the ranked student's id; only questions served to the student for the session are accepted, and ranks go as deep as the level's ranking points.")).
+		Returns(Ref("SurveyProgress")).
+		Fails(http.StatusForbidden, "Not a participant of the session").
+		Fails(http.StatusConflict, "Session results are already final or under review")
+	g.Get(session+"/survey/completion", "GetSurveyCompletion", "How many participants have finished the survey").
+		Returns(Ref("SurveyCompletion"))
+	g.Put(session+"/survey/completion", "MarkSurveyCompleted", "Record that the student finished the survey").
+		Returns(status)
+	g.Get(session+"/survey/timer", "GetSurveyTimer", "Time left in the survey").
+		Returns(timeout)
+	g.Post(session+"/survey/timer", "StartSurveyTimer", "Start the survey timer").
+		Returns(status)
+	g.Post(session+"/survey/penalties", "ApplySurveyPenalties", "Finalize the session once the survey is over").
+		Returns(status).
+		Fails(http.StatusConflict, "Survey is still open")
+	g.Get(session+"/survey/questions/{question_id}/timer", "GetQuestionTimer", "Time left on one question").
+		Returns(timeout)
+	g.Post(session+"/survey/questions/{question_id}/timer", "StartQuestionTimer", "Start the timer for one question").
+		Returns(status)
+	g.Post(session+"/survey/questions/{question_id}/penalties", "ApplyQuestionPenalty", "Penalise a timed-out question").
+		Body(Object(Opt("student_id", String()))).
+		Returns(status)
+}
+
+// exportFormat documents the format of an export.
+func exportFormat(o *Operation) *Operation {
+	return o.Query("format", Enum("json", "csv"), false, "Defaults to json.")
+}
+
+// importFile documents an import of rows, uploaded as JSON or as CSV with
+// columns.
+func importFile(row string, columns []string) func(*Operation) *Operation {
+	return func(o *Operation) *Operation {
+		return o.Form(Object(
+			P("file", Binary().Describe("A .json file holding an array of "+row+", or a .csv file whose header names the columns "+
+				strings.Join(columns, ", ")+"; is_active may be left out. Lists in a cell are separated by semicolons. At most 5 MB.")),
+		).Describe("A row replaces the fields of the record with its external_key, or of the record whose id it is, and otherwise creates one.")).
+			Query("dry_run", Boolean(), false, "Check every row and report what would change, without saving.").
+			Query("level_map", String(), false, "Moves the file's levels to others, as from:to pairs separated by commas, such as 1:2,2:3.").
+			Fails(http.StatusBadRequest, "The file is unreadable, or a row is invalid; nothing was saved").
+			Fails(http.StatusConflict, "A row collides with an existing record; nothing was saved").
+			Fails(http.StatusRequestEntityTooLarge, "File is larger than 5 MB")
+	}
+}
@@ -0,0 +1,480 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Standing is a student's record over their finalized sessions, as shown on
+// the leaderboard.
+type Standing struct {
+	StudentID    string
+	Name         string
+	Level        int
+	Sessions     int
+	TotalScore   float64
+	AverageScore float64
+}
+
+// Booking is a student's place in a pending session.
+type Booking struct {
+	StudentID    string
+	StudentName  string
+	VenueID      string
+	VenueName    string
+	SessionID    string
+	SessionLevel int
+	BookedAt     time.Time
+}
+
+// FeedbackEntry is a Feedback together with the student who left it, as
+// admins see it.
+type FeedbackEntry struct {
+	Feedback
+	ID         string
+	Name       string
+	Department string
+	Year       int
+	// CreatedAt is zero when the row has no creation time.
+	CreatedAt time.Time
+}
+
+// Holiday is a date, as YYYY-MM-DD, no sessions are generated on at any
+// venue.
+type Holiday struct {
+	Date string
+	Name string
+}
+
+// NewSession is a pending session an admin schedules at a venue.
+type NewSession struct {
+	ID        string
+	VenueID   string
+	Level     int
+	StartTime time.Time
+	EndTime   time.Time
+	// Agenda and SurveyWeights are raw JSON.
+	Agenda        []byte
+	SurveyWeights []byte
+	// MaxCapacity is the session's seat limit, or 0 for the default.
+	MaxCapacity int
+}
+
+// ManagedQuestion is a survey question as admins manage it.
+type ManagedQuestion struct {
+	Question
+	// ExternalKey identifies the question across deployments; a question
+	// that was never imported has its ID as its key. BankName is its bank's
+	// name. Only Export sets them.
+	ExternalKey string
+	BankName    string
+	IsActive    bool
+	Levels      []int
+	// ArchivedAt is zero unless the question is archived.
+	ArchivedAt time.Time
+}
+
+// QuestionChange updates some of a question's fields; nil ones are left
+// as they are.
+type QuestionChange struct {
+	Text   *string
+	Weight *float64
+	// IsActive set to true also restores an archived question.
+	IsActive *bool
+	BankID   *string
+}
+
+// QuestionImport is a question saved from an import file.
+type QuestionImport struct {
+	// ID is the question the row updates, or empty to create one.
+	ID          string
+	ExternalKey string
+	Text        string
+	Weight      float64
+	BankID      string
+	// IsActive defaults to true for a new question and keeps an existing
+	// one's. Setting it restores an archived question.
+	IsActive *bool
+}
+
+// QuestionUsage is how much a question has been used.
+type QuestionUsage struct {
+	ID   string
+	Text string
+	// Sessions counts the sessions the question was drawn for or answered
+	// in; Responses counts the students who answered it, once per session.
+	Sessions  int
+	Responses int
+	// LastUsedAt and ArchivedAt are zero when the question was never used
+	// or is not archived.
+	LastUsedAt time.Time
+	ArchivedAt time.Time
+}
+
+// QuestionBank groups questions by the competency they assess.
+type QuestionBank struct {
+	ID          string
+	Name        string
+	Description string
+	// Questions counts the active questions in the bank; only List sets it.
+	Questions int
+}
+
+// BankDraw is how many questions a level's sessions draw from a bank.
+type BankDraw struct {
+	BankID string
+	Count  int
+}
+
+// ManagedTopic is a discussion topic as admins manage it.
+type ManagedTopic struct {
+	Topic
+	// ExternalKey identifies the topic across deployments, like a
+	// ManagedQuestion's. Only Export sets it.
+	ExternalKey string
+	IsActive    bool
+	Category    string
+	Difficulty  string
+	Source      string
+	Tags        []string
+	// AverageRating is the mean feedback rating of the sessions that
+	// discussed the topic; it is only meaningful when Rated is set.
+	AverageRating float64
+	Rated         bool
+}
+
+// TopicFilter selects the topics List returns.
+type TopicFilter struct {
+	// Level is 0 for every level.
+	Level int
+	// Inactive includes deactivated topics.
+	Inactive   bool
+	Category   string
+	Difficulty string
+	Tag        string
+	// Query is a full-text search; matches are listed by relevance.
+	Query string
+	// Limit and Offset page the list; a zero Limit lists every match.
+	Limit  int
+	Offset int
+}
+
+// TopicImport is a topic saved from an import file.
+type TopicImport struct {
+	// ID is the topic the row updates, or empty to create one.
+	ID          string
+	ExternalKey string
+	Level       int
+	Text        string
+	Category    string
+	Difficulty  string
+	Source      string
+	// IsActive defaults to true for a new topic and keeps an existing one's.
+	IsActive *bool
+}
+
+type AdminRepository interface {
+	// Credentials returns the ID and password hash of the admin with the
+	// email, or ErrNotFound.
+	Credentials(ctx context.Context, email string) (id, passwordHash string, err error)
+}
+
+type AdminVenueRepository interface {
+	// Active returns the active venues with their Availability.
+	Active(ctx context.Context) ([]Venue, error)
+	// Create inserts a venue, with its QRSecret, CreatedBy and Availability.
+	Create(ctx context.Context, v Venue) error
+	// Update changes an active venue's name, capacity, level, session timing
+	// and table details. It does nothing if the venue is not active.
+	Update(ctx context.Context, v Venue) error
+	// Get returns a venue, active or not, with its Availability, or
+	// ErrNotFound.
+	Get(ctx context.Context, id string) (Venue, error)
+	// Scheduled returns the active venues with availability, by name.
+	Scheduled(ctx context.Context) ([]Venue, error)
+	// SetAvailability replaces an active venue's availability, or returns
+	// ErrNotFound.
+	SetAvailability(ctx context.Context, id string, availability []byte) error
+}
+
+type AdminQRCodeRepository interface {
+	// Current returns the venue's newest active, unexpired QR code with
+	// seats left, or, when full is set, the newest one without. It returns
+	// ErrNotFound when there is none.
+	Current(ctx context.Context, venueID string, full bool) (QRCode, error)
+	// Create stores a new active QR code expiring d from now.
+	Create(ctx context.Context, c QRCode, d time.Duration) error
+	// Active returns the venue's active, unexpired QR codes, newest first.
+	Active(ctx context.Context, venueID string) ([]QRCode, error)
+	Deactivate(ctx context.Context, id string) error
+	// DeactivateExpired deactivates every QR code past its expiry.
+	DeactivateExpired(ctx context.Context) error
+}
+
+type ReportRepository interface {
+	// QualificationRates returns, per department, the percentage of final
+	// results that qualified.
+	QualificationRates(ctx context.Context) (map[string]float64, error)
+	// TopParticipants returns the limit active students with the highest
+	// total final score, only those currently at level when it is not 0.
+	TopParticipants(ctx context.Context, level, limit int) ([]Standing, error)
+	// PendingBookings returns the students booked into pending sessions,
+	// latest first.
+	PendingBookings(ctx context.Context) ([]Booking, error)
+	// SessionFeedback returns the feedback left on a session, newest first.
+	SessionFeedback(ctx context.Context, sessionID string) ([]FeedbackEntry, error)
+}
+
+type ScheduleRepository interface {
+	// Holidays returns every holiday, by date.
+	Holidays(ctx context.Context) ([]Holiday, error)
+	// HolidaysBetween returns the holidays from one date to another.
+	HolidaysBetween(ctx context.Context, from, to time.Time) ([]Holiday, error)
+	// SaveHoliday adds a holiday, or renames the one on its date.
+	SaveHoliday(ctx context.Context, h Holiday) error
+	// DeleteHoliday removes the holiday on the date, or returns ErrNotFound.
+	DeleteHoliday(ctx context.Context, date string) error
+	// Booked returns the sessions at the venue, other than cancelled ones,
+	// that overlap the period, locking them. Only their ID, StartTime and
+	// EndTime are set.
+	Booked(ctx context.Context, venueID string, start, end time.Time) ([]Session, error)
+	// Create inserts a pending session without a topic.
+	Create(ctx context.Context, s NewSession) error
+	// Agenda returns a session's raw agenda JSON, which is nil when it has
+	// none, or ErrNotFound.
+	Agenda(ctx context.Context, sessionID string) ([]byte, error)
+	// SetAgenda replaces a session's agenda and moves its end to minutes
+	// after its start.
+	SetAgenda(ctx context.Context, sessionID string, agenda []byte, minutes int) error
+}
+
+type AdminQuestionRepository interface {
+	// List returns the questions, newest first, or, when level is not 0,
+	// that level's questions in the order they are served. Archived ones
+	// are left out unless archived is set.
+	List(ctx context.Context, level int, archived bool) ([]ManagedQuestion, error)
+	// Export returns the questions oldest first, with their ExternalKey and
+	// BankName. Archived ones are left out unless archived is set.
+	Export(ctx context.Context, archived bool) ([]ManagedQuestion, error)
+	// Create inserts a question with its ID, Text, Weight and BankID.
+	Create(ctx context.Context, q ManagedQuestion) error
+	Update(ctx context.Context, id string, c QuestionChange) error
+	// SetLevels maps the question to exactly levels. A level it keeps keeps
+	// its position; a new one places it last.
+	SetLevels(ctx context.Context, id string, levels []int) error
+	// Usage returns how much the question has been used, locking it, or
+	// ErrNotFound.
+	Usage(ctx context.Context, id string) (QuestionUsage, error)
+	// UsageList returns the usage of every question, most used first.
+	UsageList(ctx context.Context) ([]QuestionUsage, error)
+	// Archive deactivates the question and marks it archived.
+	Archive(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) error
+	// LevelIDs returns the IDs of the level's questions, locking them.
+	LevelIDs(ctx context.Context, level int) ([]string, error)
+	// SetOrder serves the level's questions in the order of ids.
+	SetOrder(ctx context.Context, level int, ids []string) error
+	// ImportedID returns the ID of the question with the external key, or,
+	// for one exported before it had a key, the ID key, locking it. It is
+	// empty when there is none.
+	ImportedID(ctx context.Context, key string) (string, error)
+	// SaveImported creates or updates a question from an import and
+	// returns its ID. It returns ErrDuplicate when the row clashes with
+	// another question.
+	SaveImported(ctx context.Context, q QuestionImport) (string, error)
+}
+
+type QuestionBankRepository interface {
+	// List returns the banks by name, with their active question counts.
+	List(ctx context.Context) ([]QuestionBank, error)
+	Exists(ctx context.Context, id string) (bool, error)
+	// IDByName returns the ID of the bank with the name, or ErrNotFound.
+	IDByName(ctx context.Context, name string) (string, error)
+	// Create inserts a bank, or returns ErrDuplicate if its name is taken.
+	Create(ctx context.Context, b QuestionBank) error
+	// Update renames a bank. It returns ErrDuplicate if the name is taken
+	// and ErrNotFound if there is no such bank.
+	Update(ctx context.Context, b QuestionBank) error
+	// Delete removes a bank, keeping its questions without one, or returns
+	// ErrNotFound.
+	Delete(ctx context.Context, id string) error
+	// TemplateLevels returns the levels whose templates draw from the bank.
+	TemplateLevels(ctx context.Context, id string) ([]int, error)
+	// Template returns the level's draws, by bank name.
+	Template(ctx context.Context, level int) ([]BankDraw, error)
+	// Available counts the bank's active questions at the level.
+	Available(ctx context.Context, bankID string, level int) (int, error)
+	// SetTemplate replaces the level's draws.
+	SetTemplate(ctx context.Context, level int, draws []BankDraw) error
+}
+
+type AdminTopicRepository interface {
+	// List returns the topics f selects, newest first within each level or
+	// by relevance to f.Query, with their tags, and how many match in all.
+	List(ctx context.Context, f TopicFilter) ([]ManagedTopic, int, error)
+	// Export returns every topic, inactive ones included, by level and age,
+	// with its ExternalKey and tags.
+	Export(ctx context.Context) ([]ManagedTopic, error)
+	// AtLevel returns the ID and Text of the level's topics other than
+	// excludeID.
+	AtLevel(ctx context.Context, level int, excludeID string) ([]Topic, error)
+	// Create inserts a topic, or returns ErrDuplicate if its text is taken
+	// at its level.
+	Create(ctx context.Context, t ManagedTopic) error
+	// Update replaces a topic's fields, or returns ErrDuplicate if its text
+	// is taken at its level.
+	Update(ctx context.Context, t ManagedTopic) error
+	// SetTags replaces the topic's tags.
+	SetTags(ctx context.Context, id string, tags []string) error
+	Deactivate(ctx context.Context, id string) error
+	// ImportedID is AdminQuestionRepository.ImportedID for topics.
+	ImportedID(ctx context.Context, key string) (string, error)
+	// SaveImported creates or updates a topic from an import and returns
+	// its ID. It returns ErrDuplicate when the row clashes with another
+	// topic.
+	SaveImported(ctx context.Context, t TopicImport) (string, error)
+}
+
+// AdminMaterialRepository manages topics' prep materials.
+type AdminMaterialRepository interface {
+	// List returns the topic's materials in display order.
+	List(ctx context.Context, topicID string) ([]PrepMaterial, error)
+	// Get returns ErrNotFound if there is no such material.
+	Get(ctx context.Context, id string) (PrepMaterial, error)
+	// Append adds m after its topic's other materials and returns its
+	// display order, locking the topic to serialise appends. It returns
+	// ErrNotFound if there is no such topic.
+	Append(ctx context.Context, m PrepMaterial) (int, error)
+	// Update saves a material's title, body, URL, visibility and display
+	// order.
+	Update(ctx context.Context, m PrepMaterial) error
+	// Delete removes a material and returns its FileKey, or ErrNotFound if
+	// there is no such material.
+	Delete(ctx context.Context, id string) (string, error)
+}
+
+// RankingConfig is a version of a level's ranking points as the admin
+// console sees it.
+type RankingConfig struct {
+	ScoringConfig
+	IsActive bool
+}
+
+type RankingConfigRepository interface {
+	// List returns the level's versions, newest first, or every level's by
+	// level when level is 0.
+	List(ctx context.Context, level int) ([]RankingConfig, error)
+	// Get returns ErrNotFound if there is no such version.
+	Get(ctx context.Context, id string) (RankingConfig, error)
+	// NextVersion returns the level's next version number, locking the
+	// level's versions to serialise saves.
+	NextVersion(ctx context.Context, level int) (int, error)
+	// Create inserts an inactive version; SetActive switches it on.
+	Create(ctx context.Context, c RankingConfig, createdBy string) error
+	// SetActive switches a version on, replacing its level's active one, or
+	// off, leaving the level on the built-in defaults. It returns
+	// ErrNotFound if there is no such version.
+	SetActive(ctx context.Context, id string, active bool) error
+	// Due returns the ID and Level of versions never activated whose
+	// effective date is not after now, by level and version.
+	Due(ctx context.Context, now time.Time) ([]RankingConfig, error)
+	// Used reports whether the version scored a session.
+	Used(ctx context.Context, id string) (bool, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// RubricCriterion is one thing moderators mark participants on.
+type RubricCriterion struct {
+	ID       string
+	Name     string
+	Weight   float64
+	MaxScore float64
+}
+
+// ModeratorReview is what moderators recorded for one participant: marks
+// by criterion ID and written feedback.
+type ModeratorReview struct {
+	StudentID string
+	Name      string
+	Scores    map[string]float64
+	Feedback  string
+}
+
+type RubricRepository interface {
+	// Get returns a level's rubric, with weight 0 if it was never
+	// configured.
+	Get(ctx context.Context, level int) (Rubric, error)
+	// Criteria returns the level's current criteria in display order.
+	Criteria(ctx context.Context, level int) ([]RubricCriterion, error)
+	// CriterionIDs returns the IDs of every criterion the level has had,
+	// retired ones included.
+	CriterionIDs(ctx context.Context, level int) ([]string, error)
+	// Save creates or updates the rubric's moderator weight.
+	Save(ctx context.Context, rubric Rubric) error
+	// Retire retires every criterion of the level; saving a criterion
+	// brings it back.
+	Retire(ctx context.Context, level int) error
+	// AddCriterion inserts c as the level's criterion at position order.
+	AddCriterion(ctx context.Context, level, order int, c RubricCriterion) error
+	// UpdateCriterion saves c at position order and brings it back if it
+	// was retired.
+	UpdateCriterion(ctx context.Context, order int, c RubricCriterion) error
+	// MarkingSession returns the level of a session and whether its
+	// results are final, locking it while marks are saved. It returns
+	// ErrNotFound if there is no such session.
+	MarkingSession(ctx context.Context, sessionID string) (int, bool, error)
+	// Participant reports whether the student took part in the session
+	// as a real participant.
+	Participant(ctx context.Context, sessionID, studentID string) (bool, error)
+	// SaveMark creates or replaces a participant's mark on a criterion.
+	SaveMark(ctx context.Context, sessionID, studentID, criterionID string, score float64, moderatorID string) error
+	// SaveFeedback creates or replaces a participant's written feedback.
+	SaveFeedback(ctx context.Context, sessionID, studentID, feedback, moderatorID string) error
+	// Reviews returns every real participant of the session by name, with
+	// the marks and feedback given so far.
+	Reviews(ctx context.Context, sessionID string) ([]ModeratorReview, error)
+}
+
+// QueuedFlag is a CollusionFlag in the admin review queue.
+type QueuedFlag struct {
+	CollusionFlag
+	Level      int
+	ReviewNote string
+	CreatedAt  time.Time
+	// ReviewedAt is zero while the flag is pending.
+	ReviewedAt time.Time
+}
+
+type FlagRepository interface {
+	// Queue returns the flags with the status, oldest first, limited to
+	// the session when sessionID is not empty.
+	Queue(ctx context.Context, status, sessionID string) ([]QueuedFlag, error)
+	// Lock returns a flag, locking it while it is reviewed, or ErrNotFound.
+	Lock(ctx context.Context, id string) (CollusionFlag, error)
+	// Void strikes out the responders' responses in the session, limited
+	// to questionIDs when given.
+	Void(ctx context.Context, sessionID string, responderIDs, questionIDs []string) error
+	// Review records the outcome of a flag's review.
+	Review(ctx context.Context, id, status, reviewerID, note string) error
+}
+
+// AdminStore groups the repositories behind the admin console and runs
+// units of work atomically. It is only implemented over MySQL; admin
+// handlers are tested against scripted SQL from gd/database/dbtest.
+type AdminStore interface {
+	Admins() AdminRepository
+	Venues() AdminVenueRepository
+	QRCodes() AdminQRCodeRepository
+	Reports() ReportRepository
+	Schedule() ScheduleRepository
+	Questions() AdminQuestionRepository
+	Banks() QuestionBankRepository
+	Topics() AdminTopicRepository
+	Materials() AdminMaterialRepository
+	RankingConfigs() RankingConfigRepository
+	Rubrics() RubricRepository
+	Flags() FlagRepository
+	// InTx runs fn against an AdminStore bound to a single transaction,
+	// committing when fn returns nil and rolling back otherwise.
+	InTx(ctx context.Context, fn func(AdminStore) error) error
+}
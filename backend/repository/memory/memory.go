@@ -0,0 +1,1338 @@
+// Package memory provides an in-memory repository.Store for tests.
+//
+// It mirrors the semantics of the MySQL queries closely enough to exercise the
+// controllers. InTx rolls back by restoring a copy of the data taken when the
+// unit of work began; units of work are not isolated from each other.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gd/repository"
+)
+
+type session struct {
+	repository.Session
+	seq       int
+	surveyEnd time.Time
+}
+
+// member keys per-student data within a session.
+type member struct {
+	sessionID, studentID string
+}
+
+// timeout keys a student's penalty for letting a question time out.
+type timeout struct {
+	member
+	questionID string
+}
+
+// questionKey keys per-question data within a session.
+type questionKey struct {
+	sessionID, questionID string
+}
+
+// Store holds all data behind a single mutex. The exported Add* helpers seed
+// fixtures; everything else is reached through the repository interfaces.
+type Store struct {
+	mu sync.Mutex
+	data
+}
+
+// data is everything a Store holds, so InTx can copy and restore it.
+type data struct {
+	seq          int
+	students     map[string]repository.Student
+	passwords    map[string]string // student ID -> password hash
+	venues       map[string]repository.Venue
+	qrCodes      map[string]repository.QRCode
+	sessions     map[string]*session
+	participants map[string][]string          // session ID -> student IDs in join order
+	phases       map[string]map[string]string // student ID -> session ID -> phase
+	phaseStarts  map[member]map[string]time.Time
+	questions    map[int][]repository.Question
+	served       map[member][]repository.Question
+	drawn        map[string][]repository.Question // session ID -> questions drawn
+	templates    map[int]map[string]int
+	results      []repository.SurveyResult
+	bias         []repository.Bias
+	timeouts     map[timeout]float64
+	deadlines    map[questionKey]time.Time
+	completions  map[member]time.Time
+	cleared      map[string]bool // sessions whose results are no longer current
+	configs      map[string]repository.ScoringConfig
+	activeConfig map[int]string // level -> active config ID
+	rules        map[int]repository.QualificationRule
+	final        []repository.SessionResult
+	rubrics      map[int]repository.Rubric
+	marks        []repository.RubricScore
+	markSessions []string          // session ID of each entry in marks
+	feedback     map[member]string // moderator feedback
+	ratings      map[member]repository.Feedback
+	flags        []repository.CollusionFlag
+	topics       []repository.Topic // in creation order
+	materials    []repository.PrepMaterial
+}
+
+func New() *Store {
+	return &Store{data: data{
+		students:     map[string]repository.Student{},
+		passwords:    map[string]string{},
+		venues:       map[string]repository.Venue{},
+		qrCodes:      map[string]repository.QRCode{},
+		sessions:     map[string]*session{},
+		participants: map[string][]string{},
+		phases:       map[string]map[string]string{},
+		phaseStarts:  map[member]map[string]time.Time{},
+		questions:    map[int][]repository.Question{},
+		served:       map[member][]repository.Question{},
+		drawn:        map[string][]repository.Question{},
+		templates:    map[int]map[string]int{},
+		timeouts:     map[timeout]float64{},
+		deadlines:    map[questionKey]time.Time{},
+		completions:  map[member]time.Time{},
+		cleared:      map[string]bool{},
+		configs:      map[string]repository.ScoringConfig{},
+		activeConfig: map[int]string{},
+		rules:        map[int]repository.QualificationRule{},
+		rubrics:      map[int]repository.Rubric{},
+		feedback:     map[member]string{},
+		ratings:      map[member]repository.Feedback{},
+	}}
+}
+
+func (s *Store) AddStudent(st repository.Student) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.students[st.ID] = st
+}
+
+// SetPassword sets the password hash a student logs in with.
+func (s *Store) SetPassword(studentID, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.passwords[studentID] = hash
+}
+
+func (s *Store) AddVenue(v repository.Venue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.venues[v.ID] = v
+}
+
+func (s *Store) AddQRCode(qr repository.QRCode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.qrCodes[qr.ID] = qr
+}
+
+func (s *Store) AddSession(sess repository.Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	s.sessions[sess.ID] = &session{Session: sess, seq: s.seq}
+}
+
+func (s *Store) AddParticipant(sessionID, studentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.participants[sessionID] = append(s.participants[sessionID], studentID)
+}
+
+// SetQuestions replaces the active questions for a level, in display order.
+func (s *Store) SetQuestions(level int, questions ...repository.Question) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.questions[level] = questions
+}
+
+// AddTopic adds an active topic to its level's pool.
+func (s *Store) AddTopic(t repository.Topic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topics = append(s.topics, t)
+}
+
+// AddMaterial adds a prep material to its topic, after the existing ones.
+func (s *Store) AddMaterial(m repository.PrepMaterial) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.materials = append(s.materials, m)
+}
+
+// SetPhaseStart records when a student started a phase of a session.
+func (s *Store) SetPhaseStart(sessionID, studentID, phase string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.phases[studentID] == nil {
+		s.phases[studentID] = map[string]string{}
+	}
+	s.phases[studentID][sessionID] = phase
+	key := member{sessionID, studentID}
+	if s.phaseStarts[key] == nil {
+		s.phaseStarts[key] = map[string]time.Time{}
+	}
+	s.phaseStarts[key][phase] = t
+}
+
+// SetTemplate sets how many questions a level draws from each bank.
+func (s *Store) SetTemplate(level int, draws map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[level] = draws
+}
+
+// AddScoringConfig adds a configuration version and makes it the active one
+// for its level. A missing ID or version is filled in.
+func (s *Store) AddScoringConfig(c repository.ScoringConfig) repository.ScoringConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addConfig(c)
+}
+
+func (s *Store) addConfig(c repository.ScoringConfig) repository.ScoringConfig {
+	if c.Version == 0 {
+		for _, existing := range s.configs {
+			if existing.Level == c.Level && existing.Version > c.Version {
+				c.Version = existing.Version
+			}
+		}
+		c.Version++
+	}
+	if c.ID == "" {
+		c.ID = fmt.Sprintf("cfg-%d-%d", c.Level, c.Version)
+	}
+	s.configs[c.ID] = c
+	s.activeConfig[c.Level] = c.ID
+	return c
+}
+
+// SetRankingPoints adds a version of the level's configuration with new
+// points, indexed from rank 1, keeping its consensus method.
+func (s *Store) SetRankingPoints(level int, points ...float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.configs[s.activeConfig[level]]
+	s.addConfig(repository.ScoringConfig{Level: level, Points: points, Method: c.Method})
+}
+
+// SetSurveyEnd sets when the session's survey window closes.
+func (s *Store) SetSurveyEnd(sessionID string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID].surveyEnd = t
+}
+
+// Finalized reports whether the session's results were finalized.
+func (s *Store) Finalized(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[sessionID].Finalized
+}
+
+// AddTimeoutPenalty charges a student for a question that timed out.
+func (s *Store) AddTimeoutPenalty(sessionID, studentID, questionID string, points float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeouts[timeout{member{sessionID, studentID}, questionID}] += points
+}
+
+// SetRule configures the qualification rule for a level.
+func (s *Store) SetRule(level int, rule repository.QualificationRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[level] = rule
+}
+
+// ReviewFlag records an admin's decision on a collusion flag. Voiding
+// strikes out every response of the flagged responders.
+func (s *Store) ReviewFlag(id, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.flags {
+		f := &s.flags[i]
+		if f.ID != id {
+			continue
+		}
+		f.Status = status
+		if status != repository.FlagVoided {
+			continue
+		}
+		for j := range s.results {
+			res := &s.results[j]
+			if res.SessionID == f.SessionID && slices.Contains(f.ResponderIDs, res.ResponderID) {
+				res.Voided = true
+			}
+		}
+	}
+}
+
+// SetRubric configures the moderator rubric for a level.
+func (s *Store) SetRubric(r repository.Rubric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rubrics[r.Level] = r
+}
+
+// AddRubricScore records a moderator's mark in a session.
+func (s *Store) AddRubricScore(sessionID string, sc repository.RubricScore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marks = append(s.marks, sc)
+	s.markSessions = append(s.markSessions, sessionID)
+}
+
+// SetRubricFeedback records a moderator's written feedback for a student.
+func (s *Store) SetRubricFeedback(sessionID, studentID, feedback string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feedback[member{sessionID, studentID}] = feedback
+}
+
+// SetConsensusMethod adds a version of the level's configuration with a new
+// consensus method, keeping its points.
+func (s *Store) SetConsensusMethod(level int, method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.configs[s.activeConfig[level]]
+	s.addConfig(repository.ScoringConfig{Level: level, Points: c.Points, Method: method})
+}
+
+// SurveyResults returns a copy of every stored survey result.
+func (s *Store) SurveyResults() []repository.SurveyResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]repository.SurveyResult(nil), s.results...)
+}
+
+// QRCode returns the current state of a QR code.
+func (s *Store) QRCode(id string) repository.QRCode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.qrCodes[id]
+}
+
+// Phase returns the tracked phase of a student in a session, if any.
+func (s *Store) Phase(sessionID, studentID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.phases[studentID][sessionID]
+}
+
+// IsCompleted reports whether the responder's survey was marked complete.
+func (s *Store) IsCompleted(sessionID, studentID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.completions[member{sessionID, studentID}]
+	return ok
+}
+
+func (s *Store) Students() repository.StudentRepository         { return students{s} }
+func (s *Store) Venues() repository.VenueRepository             { return venues{s} }
+func (s *Store) QRCodes() repository.QRCodeRepository           { return qrCodes{s} }
+func (s *Store) Sessions() repository.SessionRepository         { return sessions{s} }
+func (s *Store) Participants() repository.ParticipantRepository { return participants{s} }
+func (s *Store) Surveys() repository.SurveyRepository           { return surveys{s} }
+func (s *Store) Results() repository.ResultRepository           { return results{s} }
+func (s *Store) Topics() repository.TopicRepository             { return topics{s} }
+func (s *Store) Feedback() repository.FeedbackRepository        { return feedback{s} }
+
+// InTx runs fn against s and, if it fails, puts back the data s held
+// before, undoing fn's writes.
+func (s *Store) InTx(ctx context.Context, fn func(repository.Store) error) error {
+	s.mu.Lock()
+	saved := s.data.clone()
+	s.mu.Unlock()
+
+	err := fn(s)
+	if err != nil {
+		s.mu.Lock()
+		s.data = saved
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// clone copies d deeply enough that writes to either copy leave the other
+// unchanged.
+func (d *data) clone() data {
+	c := *d
+	c.students = maps.Clone(d.students)
+	c.passwords = maps.Clone(d.passwords)
+	c.venues = maps.Clone(d.venues)
+	c.qrCodes = maps.Clone(d.qrCodes)
+	c.sessions = cloneEach(d.sessions, func(s *session) *session {
+		copied := *s
+		return &copied
+	})
+	c.participants = cloneEach(d.participants, slices.Clone[[]string])
+	c.phases = cloneEach(d.phases, maps.Clone[map[string]string])
+	c.phaseStarts = cloneEach(d.phaseStarts, maps.Clone[map[string]time.Time])
+	c.questions = cloneEach(d.questions, slices.Clone[[]repository.Question])
+	c.served = cloneEach(d.served, slices.Clone[[]repository.Question])
+	c.drawn = cloneEach(d.drawn, slices.Clone[[]repository.Question])
+	c.templates = cloneEach(d.templates, maps.Clone[map[string]int])
+	c.results = slices.Clone(d.results)
+	c.bias = slices.Clone(d.bias)
+	c.timeouts = maps.Clone(d.timeouts)
+	c.deadlines = maps.Clone(d.deadlines)
+	c.completions = maps.Clone(d.completions)
+	c.cleared = maps.Clone(d.cleared)
+	c.configs = maps.Clone(d.configs)
+	c.activeConfig = maps.Clone(d.activeConfig)
+	c.rules = maps.Clone(d.rules)
+	c.final = slices.Clone(d.final)
+	c.rubrics = maps.Clone(d.rubrics)
+	c.marks = slices.Clone(d.marks)
+	c.markSessions = slices.Clone(d.markSessions)
+	c.feedback = maps.Clone(d.feedback)
+	c.ratings = maps.Clone(d.ratings)
+	c.flags = slices.Clone(d.flags)
+	c.topics = slices.Clone(d.topics)
+	c.materials = slices.Clone(d.materials)
+	return c
+}
+
+// cloneEach copies m, copying each value with clone.
+func cloneEach[K comparable, V any](m map[K]V, clone func(V) V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = clone(v)
+	}
+	return c
+}
+
+type students struct{ s *Store }
+
+func (r students) Get(ctx context.Context, id string) (repository.Student, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	st, ok := r.s.students[id]
+	if !ok {
+		return repository.Student{}, repository.ErrNotFound
+	}
+	return st, nil
+}
+
+func (r students) Credentials(ctx context.Context, email string) (repository.Student, string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, st := range r.s.students {
+		if st.Email == email && st.IsActive {
+			return st, r.s.passwords[st.ID], nil
+		}
+	}
+	return repository.Student{}, "", repository.ErrNotFound
+}
+
+func (r students) Promote(ctx context.Context, id string, level int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if st, ok := r.s.students[id]; ok && st.Level == level && level < repository.MaxLevel {
+		st.Level++
+		r.s.students[id] = st
+	}
+	return nil
+}
+
+type venues struct{ s *Store }
+
+func (r venues) Get(ctx context.Context, id string) (repository.Venue, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	v, ok := r.s.venues[id]
+	if !ok {
+		return repository.Venue{}, repository.ErrNotFound
+	}
+	return v, nil
+}
+
+func (r venues) BookedCount(ctx context.Context, venueID string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	booked := 0
+	for id, sess := range r.s.sessions {
+		if sess.VenueID == venueID {
+			booked += len(r.s.participants[id])
+		}
+	}
+	return booked, nil
+}
+
+func (r venues) ActiveByLevel(ctx context.Context, level int) ([]repository.Venue, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found []repository.Venue
+	for _, v := range r.s.venues {
+		if v.Level == level && v.IsActive {
+			found = append(found, v)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found, nil
+}
+
+type qrCodes struct{ s *Store }
+
+func (r qrCodes) FindByData(ctx context.Context, qrData, venueID string) (repository.QRCode, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, qr := range r.s.qrCodes {
+		if qr.QRData == qrData && qr.VenueID == venueID {
+			return qr, nil
+		}
+	}
+	return repository.QRCode{}, repository.ErrNotFound
+}
+
+func (r qrCodes) IncrementUsage(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if qr, ok := r.s.qrCodes[id]; ok {
+		qr.CurrentUsage++
+		r.s.qrCodes[id] = qr
+	}
+	return nil
+}
+
+type sessions struct{ s *Store }
+
+func (r sessions) Get(ctx context.Context, id string) (repository.Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	sess, ok := r.s.sessions[id]
+	if !ok {
+		return repository.Session{}, repository.ErrNotFound
+	}
+	return sess.Session, nil
+}
+
+func (r sessions) FindPendingByVenue(ctx context.Context, venueID string) (repository.Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found *session
+	for _, sess := range r.s.sessions {
+		if sess.VenueID != venueID || sess.Status != "pending" {
+			continue
+		}
+		if found == nil || sess.StartTime.Before(found.StartTime) {
+			found = sess
+		}
+	}
+	if found == nil {
+		return repository.Session{}, repository.ErrNotFound
+	}
+	return found.Session, nil
+}
+
+func (r sessions) FindOpenByQRGroup(ctx context.Context, venueID, qrGroupID string) (repository.Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found *session
+	for _, sess := range r.s.sessions {
+		if sess.VenueID != venueID || sess.QRGroupID != qrGroupID {
+			continue
+		}
+		if sess.Status != "pending" && sess.Status != "active" {
+			continue
+		}
+		if found == nil || sess.seq > found.seq {
+			found = sess
+		}
+	}
+	if found == nil {
+		return repository.Session{}, repository.ErrNotFound
+	}
+	return found.Session, nil
+}
+
+func (r sessions) Create(ctx context.Context, sess repository.Session) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, exists := r.s.sessions[sess.ID]; exists {
+		return repository.ErrDuplicate
+	}
+	now := time.Now()
+	sess.StartTime = now
+	sess.EndTime = now.Add(time.Hour)
+	sess.Level = r.s.venues[sess.VenueID].Level
+	r.s.seq++
+	r.s.sessions[sess.ID] = &session{Session: sess, seq: r.s.seq}
+	return nil
+}
+
+func (r sessions) Activate(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if sess, ok := r.s.sessions[id]; ok && sess.Status == "pending" {
+		sess.Status = "active"
+	}
+	return nil
+}
+
+func (r sessions) SurveysClosedBefore(ctx context.Context, t time.Time) ([]repository.Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found []repository.Session
+	for _, sess := range r.s.sessions {
+		if !sess.surveyEnd.IsZero() && sess.surveyEnd.Before(t) && !sess.Finalized {
+			found = append(found, sess.Session)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+	return found, nil
+}
+
+func (r sessions) PinConfig(ctx context.Context, id, configID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if sess, ok := r.s.sessions[id]; ok && sess.ConfigID == "" {
+		sess.ConfigID = configID
+	}
+	return nil
+}
+
+func (r sessions) SetTopic(ctx context.Context, id string, topic repository.Topic) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if sess, ok := r.s.sessions[id]; ok && sess.Topic == "" {
+		sess.Topic, sess.TopicID = topic.Text, topic.ID
+	}
+	return nil
+}
+
+func (r sessions) Reviewed(ctx context.Context) ([]repository.Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	flagged, pending := map[string]bool{}, map[string]bool{}
+	for _, f := range r.s.flags {
+		flagged[f.SessionID] = true
+		pending[f.SessionID] = pending[f.SessionID] || f.Status == repository.FlagPending
+	}
+	var found []repository.Session
+	for id := range flagged {
+		if sess, ok := r.s.sessions[id]; ok && !pending[id] && !sess.Finalized {
+			found = append(found, sess.Session)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+	return found, nil
+}
+
+func (r sessions) SetStatus(ctx context.Context, id, status string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if sess, ok := r.s.sessions[id]; ok {
+		sess.Status = status
+	}
+	return nil
+}
+
+func (r sessions) StartSurveyTimer(ctx context.Context, id string, d time.Duration) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if sess, ok := r.s.sessions[id]; ok {
+		sess.surveyEnd = time.Now().Add(d)
+	}
+	return nil
+}
+
+func (r sessions) SurveyEnd(ctx context.Context, id string) (time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	sess, ok := r.s.sessions[id]
+	if !ok || sess.surveyEnd.IsZero() {
+		return time.Time{}, repository.ErrNotFound
+	}
+	return sess.surveyEnd, nil
+}
+
+func (r sessions) MarkFinalized(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if sess, ok := r.s.sessions[id]; ok {
+		sess.Status = "completed"
+		sess.Finalized = true
+	}
+	return nil
+}
+
+type participants struct{ s *Store }
+
+func (r participants) IsParticipant(ctx context.Context, sessionID, studentID string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, id := range r.s.participants[sessionID] {
+		if id == studentID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r participants) Add(ctx context.Context, sessionID, studentID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, id := range r.s.participants[sessionID] {
+		if id == studentID {
+			return repository.ErrDuplicate
+		}
+	}
+	r.s.participants[sessionID] = append(r.s.participants[sessionID], studentID)
+	return nil
+}
+
+func (r participants) CountPendingBookings(ctx context.Context, studentID string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	count := 0
+	for sessionID, ids := range r.s.participants {
+		sess, ok := r.s.sessions[sessionID]
+		if !ok || sess.Status != "pending" {
+			continue
+		}
+		for _, id := range ids {
+			if id == studentID {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// pendingAt returns the IDs of the venue's pending sessions the student is
+// booked into. The caller holds the lock.
+func (r participants) pendingAt(studentID, venueID string) []string {
+	var booked []string
+	for sessionID, ids := range r.s.participants {
+		sess, ok := r.s.sessions[sessionID]
+		if ok && sess.VenueID == venueID && sess.Status == "pending" && slices.Contains(ids, studentID) {
+			booked = append(booked, sessionID)
+		}
+	}
+	return booked
+}
+
+func (r participants) HasPendingBooking(ctx context.Context, studentID, venueID string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return len(r.pendingAt(studentID, venueID)) > 0, nil
+}
+
+func (r participants) CancelPendingBooking(ctx context.Context, studentID, venueID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	booked := r.pendingAt(studentID, venueID)
+	if len(booked) == 0 {
+		return repository.ErrNotFound
+	}
+	for _, sessionID := range booked {
+		r.s.participants[sessionID] = slices.DeleteFunc(r.s.participants[sessionID], func(id string) bool { return id == studentID })
+	}
+	return nil
+}
+
+func (r participants) Overlapping(ctx context.Context, studentID string, sess repository.Session) (repository.Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found *session
+	for sessionID, ids := range r.s.participants {
+		other, ok := r.s.sessions[sessionID]
+		if !ok || sessionID == sess.ID || !slices.Contains(ids, studentID) {
+			continue
+		}
+		if other.Status != "pending" && other.Status != "active" {
+			continue
+		}
+		if !other.StartTime.Before(sess.EndTime) || !other.EndTime.After(sess.StartTime) {
+			continue
+		}
+		if found == nil || other.StartTime.Before(found.StartTime) {
+			found = other
+		}
+	}
+	if found == nil {
+		return repository.Session{}, repository.ErrNotFound
+	}
+	return found.Session, nil
+}
+
+func (r participants) List(ctx context.Context, sessionID string) ([]repository.Participant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []repository.Participant
+	for _, id := range r.s.participants[sessionID] {
+		st := r.s.students[id]
+		list = append(list, repository.Participant{StudentID: id, Name: st.Name, PhotoURL: st.PhotoURL})
+	}
+	return list, nil
+}
+
+func (r participants) CountPresent(ctx context.Context, sessionID string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	count := 0
+	for _, id := range r.s.participants[sessionID] {
+		if _, ok := r.s.phases[id][sessionID]; ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r participants) Present(ctx context.Context, sessionID string, since time.Time) ([]repository.Participant, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var list []repository.Participant
+	for _, id := range r.s.participants[sessionID] {
+		st, ok := r.s.students[id]
+		if !ok || !st.IsActive {
+			continue
+		}
+		for _, t := range r.s.phaseStarts[member{sessionID, id}] {
+			if t.After(since) {
+				list = append(list, repository.Participant{StudentID: id, Name: st.Name, PhotoURL: st.PhotoURL, Department: st.Department})
+				break
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (r participants) PrunePhases(ctx context.Context, sessionID string, t time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for key, starts := range r.s.phaseStarts {
+		if key.sessionID != sessionID {
+			continue
+		}
+		maps.DeleteFunc(starts, func(_ string, started time.Time) bool { return started.Before(t) })
+		if len(starts) == 0 {
+			delete(r.s.phaseStarts, key)
+			delete(r.s.phases[key.studentID], sessionID)
+		}
+	}
+	return nil
+}
+
+func (r participants) ClearPhases(ctx context.Context, studentID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.phases, studentID)
+	for key := range r.s.phaseStarts {
+		if key.studentID == studentID {
+			delete(r.s.phaseStarts, key)
+		}
+	}
+	return nil
+}
+
+func (r participants) StartPhase(ctx context.Context, sessionID, studentID, phase string) error {
+	r.s.SetPhaseStart(sessionID, studentID, phase, time.Now())
+	return nil
+}
+
+func (r participants) Phases(ctx context.Context, sessionID, studentID string) (map[string]time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	phases := map[string]time.Time{}
+	for phase, t := range r.s.phaseStarts[member{sessionID, studentID}] {
+		phases[phase] = t
+	}
+	return phases, nil
+}
+
+type surveys struct{ s *Store }
+
+func (r surveys) ActiveQuestions(ctx context.Context, level int) ([]repository.Question, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return append([]repository.Question(nil), r.s.questions[level]...), nil
+}
+
+func (r surveys) Template(ctx context.Context, level int) (map[string]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	draws := map[string]int{}
+	for bank, n := range r.s.templates[level] {
+		draws[bank] = n
+	}
+	return draws, nil
+}
+
+func (r surveys) SessionQuestions(ctx context.Context, sessionID string) ([]repository.Question, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return append([]repository.Question(nil), r.s.drawn[sessionID]...), nil
+}
+
+func (r surveys) SaveSessionQuestions(ctx context.Context, sessionID string, questions []repository.Question) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if len(r.s.drawn[sessionID]) == 0 {
+		r.s.drawn[sessionID] = append([]repository.Question(nil), questions...)
+	}
+	return nil
+}
+
+func (r surveys) ServedSets(ctx context.Context, studentID string) (map[string][]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	sets := map[string][]string{}
+	for key, questions := range r.s.served {
+		if key.studentID != studentID {
+			continue
+		}
+		for _, q := range questions {
+			sets[key.sessionID] = append(sets[key.sessionID], q.ID)
+		}
+	}
+	return sets, nil
+}
+
+func (r surveys) ServedQuestions(ctx context.Context, sessionID, studentID string) ([]repository.Question, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return append([]repository.Question(nil), r.s.served[member{sessionID, studentID}]...), nil
+}
+
+func (r surveys) ServeQuestions(ctx context.Context, sessionID, studentID string, questions []repository.Question) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := member{sessionID, studentID}
+	if len(r.s.served[key]) == 0 {
+		r.s.served[key] = append([]repository.Question(nil), questions...)
+	}
+	return nil
+}
+
+func (r surveys) DeleteResponses(ctx context.Context, sessionID, responderID, questionID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	kept := r.s.results[:0]
+	for _, res := range r.s.results {
+		if res.SessionID == sessionID && res.ResponderID == responderID && res.QuestionID == questionID {
+			continue
+		}
+		kept = append(kept, res)
+	}
+	r.s.results = kept
+	return nil
+}
+
+func (r surveys) SaveResult(ctx context.Context, res repository.SurveyResult) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.results {
+		if existing.SessionID == res.SessionID && existing.ResponderID == res.ResponderID &&
+			existing.QuestionID == res.QuestionID && existing.Rank == res.Rank {
+			return repository.ErrDuplicate
+		}
+	}
+	r.s.results = append(r.s.results, res)
+	return nil
+}
+
+func (r surveys) CountAnswered(ctx context.Context, sessionID, responderID string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	seen := map[string]bool{}
+	for _, res := range r.s.results {
+		if res.SessionID == sessionID && res.ResponderID == responderID {
+			seen[res.QuestionID] = true
+		}
+	}
+	return len(seen), nil
+}
+
+func (r surveys) MarkCompleted(ctx context.Context, sessionID, responderID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.completions[member{sessionID, responderID}] = time.Now()
+	return nil
+}
+
+func (r surveys) CountCompleted(ctx context.Context, sessionID string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	count := 0
+	for _, id := range r.s.participants[sessionID] {
+		if _, ok := r.s.completions[member{sessionID, id}]; ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r surveys) Results(ctx context.Context, sessionID string) ([]repository.SurveyResult, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var results []repository.SurveyResult
+	for _, res := range r.s.results {
+		if res.SessionID == sessionID && !res.Voided {
+			results = append(results, res)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.QuestionID != b.QuestionID {
+			return a.QuestionID < b.QuestionID
+		}
+		if a.ResponderID != b.ResponderID {
+			return a.ResponderID < b.ResponderID
+		}
+		return a.Rank < b.Rank
+	})
+	return results, nil
+}
+
+func (r surveys) ReplaceBias(ctx context.Context, sessionID string, entries []repository.Bias) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	kept := r.s.bias[:0]
+	for _, b := range r.s.bias {
+		if b.SessionID != sessionID {
+			kept = append(kept, b)
+		}
+	}
+	for _, b := range entries {
+		b.SessionID = sessionID
+		kept = append(kept, b)
+	}
+	r.s.bias = kept
+	return nil
+}
+
+func (r surveys) Bias(ctx context.Context, sessionID string) ([]repository.Bias, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var entries []repository.Bias
+	for _, b := range r.s.bias {
+		if b.SessionID == sessionID {
+			entries = append(entries, b)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ResponderID != entries[j].ResponderID {
+			return entries[i].ResponderID < entries[j].ResponderID
+		}
+		return entries[i].QuestionID < entries[j].QuestionID
+	})
+	return entries, nil
+}
+
+func (r surveys) ActiveConfig(ctx context.Context, level int) (repository.ScoringConfig, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c, ok := r.s.configs[r.s.activeConfig[level]]
+	if !ok {
+		return repository.ScoringConfig{}, repository.ErrNotFound
+	}
+	return c, nil
+}
+
+func (r surveys) Config(ctx context.Context, id string) (repository.ScoringConfig, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c, ok := r.s.configs[id]
+	if !ok {
+		return repository.ScoringConfig{}, repository.ErrNotFound
+	}
+	return c, nil
+}
+
+func (r surveys) SaveFlags(ctx context.Context, flags []repository.CollusionFlag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, f := range flags {
+		key := strings.Join(f.ResponderIDs, ",")
+		exists := false
+		for _, existing := range r.s.flags {
+			exists = exists || (existing.SessionID == f.SessionID && existing.Kind == f.Kind &&
+				strings.Join(existing.ResponderIDs, ",") == key)
+		}
+		if exists {
+			continue
+		}
+		r.s.seq++
+		f.ID = fmt.Sprintf("flag-%d", r.s.seq)
+		f.Status = repository.FlagPending
+		r.s.flags = append(r.s.flags, f)
+	}
+	return nil
+}
+
+func (r surveys) Flags(ctx context.Context, sessionID string) ([]repository.CollusionFlag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found []repository.CollusionFlag
+	for _, f := range r.s.flags {
+		if f.SessionID == sessionID {
+			found = append(found, f)
+		}
+	}
+	return found, nil
+}
+
+func (r surveys) StartQuestionTimer(ctx context.Context, sessionID, questionID string, d time.Duration) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.deadlines[questionKey{sessionID, questionID}] = time.Now().Add(d)
+	return nil
+}
+
+func (r surveys) QuestionDeadline(ctx context.Context, sessionID, questionID string) (time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	end, ok := r.s.deadlines[questionKey{sessionID, questionID}]
+	if !ok {
+		return time.Time{}, repository.ErrNotFound
+	}
+	return end, nil
+}
+
+func (r surveys) ChargeTimeout(ctx context.Context, sessionID, studentID, questionID string, points float64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := timeout{member{sessionID, studentID}, questionID}
+	if _, charged := r.s.timeouts[key]; charged {
+		return repository.ErrDuplicate
+	}
+	r.s.timeouts[key] = points
+	return nil
+}
+
+func (r surveys) ScoreSummaries(ctx context.Context, sessionID string) ([]repository.ScoreSummary, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	byStudent := map[string]*repository.ScoreSummary{}
+	order := r.s.participants[sessionID]
+	for _, id := range order {
+		byStudent[id] = &repository.ScoreSummary{StudentID: id}
+	}
+	for _, res := range r.s.results {
+		if res.SessionID != sessionID || r.s.cleared[sessionID] || res.Voided {
+			continue
+		}
+		sum, ok := byStudent[res.StudentID]
+		if !ok {
+			continue
+		}
+		sum.TotalScore += res.Score
+		if res.Rank == 1 {
+			sum.FirstPlaces++
+		}
+	}
+	for _, b := range r.s.bias {
+		sum, ok := byStudent[b.ResponderID]
+		if b.SessionID != sessionID || !ok {
+			continue
+		}
+		sum.BiasPenalty += b.PenaltyPoints
+		if b.IsBiased {
+			sum.BiasedQuestions++
+		}
+	}
+	for key, points := range r.s.timeouts {
+		if sum, ok := byStudent[key.studentID]; ok && key.sessionID == sessionID {
+			sum.TimeoutPenalty += points
+		}
+	}
+
+	summaries := make([]repository.ScoreSummary, 0, len(order))
+	for _, id := range order {
+		summaries = append(summaries, *byStudent[id])
+	}
+	return summaries, nil
+}
+
+func (r surveys) ClearCurrent(ctx context.Context, sessionID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.cleared[sessionID] = true
+	return nil
+}
+
+type results struct{ s *Store }
+
+func (r results) Rule(ctx context.Context, level int) (repository.QualificationRule, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	rule, ok := r.s.rules[level]
+	if !ok {
+		return repository.QualificationRule{}, repository.ErrNotFound
+	}
+	return rule, nil
+}
+
+func (r results) Save(ctx context.Context, results []repository.SessionResult) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, res := range results {
+		for _, existing := range r.s.final {
+			if existing.SessionID == res.SessionID && existing.StudentID == res.StudentID {
+				return repository.ErrDuplicate
+			}
+		}
+	}
+	r.s.final = append(r.s.final, results...)
+	return nil
+}
+
+func (r results) ForSession(ctx context.Context, sessionID string) ([]repository.SessionResult, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found []repository.SessionResult
+	for _, res := range r.s.final {
+		if res.SessionID == sessionID {
+			found = append(found, res)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Rank < found[j].Rank })
+	return found, nil
+}
+
+func (r results) Rubric(ctx context.Context, level int) (repository.Rubric, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	rubric, ok := r.s.rubrics[level]
+	if !ok {
+		return repository.Rubric{}, repository.ErrNotFound
+	}
+	return rubric, nil
+}
+
+func (r results) RubricScores(ctx context.Context, sessionID string) ([]repository.RubricScore, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var found []repository.RubricScore
+	for i, sc := range r.s.marks {
+		if r.s.markSessions[i] == sessionID {
+			found = append(found, sc)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].StudentID < found[j].StudentID })
+	return found, nil
+}
+
+func (r results) RubricFeedback(ctx context.Context, sessionID, studentID string) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.feedback[member{sessionID, studentID}], nil
+}
+
+type topics struct{ s *Store }
+
+// withUses returns t with Uses counted from the sessions. The caller holds
+// the lock.
+func (r topics) withUses(t repository.Topic) repository.Topic {
+	t.Uses = 0
+	for _, sess := range r.s.sessions {
+		if sess.TopicID == t.ID {
+			t.Uses++
+		}
+	}
+	return t
+}
+
+func (r topics) Active(ctx context.Context, level int) ([]repository.Topic, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var active []repository.Topic
+	for _, t := range r.s.topics {
+		if t.Level == level {
+			active = append(active, r.withUses(t))
+		}
+	}
+	return active, nil
+}
+
+func (r topics) Get(ctx context.Context, id string) (repository.Topic, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.topics {
+		if t.ID == id {
+			return r.withUses(t), nil
+		}
+	}
+	return repository.Topic{}, repository.ErrNotFound
+}
+
+func (r topics) Discussed(ctx context.Context, studentIDs []string, excludeSessionID string) (map[string]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	discussed := map[string]int{}
+	for id, sess := range r.s.sessions {
+		if id == excludeSessionID || sess.TopicID == "" {
+			continue
+		}
+		for _, st := range studentIDs {
+			if slices.Contains(r.s.participants[id], st) {
+				discussed[sess.TopicID]++
+			}
+		}
+	}
+	return discussed, nil
+}
+
+func (r topics) VenueUses(ctx context.Context, venueID, excludeSessionID string) (map[string]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	uses := map[string]int{}
+	for id, sess := range r.s.sessions {
+		if id != excludeSessionID && sess.TopicID != "" && sess.VenueID == venueID {
+			uses[sess.TopicID]++
+		}
+	}
+	return uses, nil
+}
+
+func (r topics) Materials(ctx context.Context, topicID string) ([]repository.PrepMaterial, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var materials []repository.PrepMaterial
+	for _, m := range r.s.materials {
+		if m.TopicID == topicID {
+			materials = append(materials, m)
+		}
+	}
+	slices.SortStableFunc(materials, func(a, b repository.PrepMaterial) int { return a.DisplayOrder - b.DisplayOrder })
+	return materials, nil
+}
+
+type feedback struct{ s *Store }
+
+func (r feedback) Submit(ctx context.Context, f repository.Feedback) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := member{f.SessionID, f.StudentID}
+	if _, exists := r.s.ratings[key]; exists {
+		return repository.ErrDuplicate
+	}
+	r.s.ratings[key] = f
+	return nil
+}
+
+func (r feedback) Get(ctx context.Context, sessionID, studentID string) (repository.Feedback, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.ratings[member{sessionID, studentID}]
+	if !ok {
+		return repository.Feedback{}, repository.ErrNotFound
+	}
+	return f, nil
+}
+
+var _ repository.Store = (*Store)(nil)
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gd/repository"
+)
+
+func TestInTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	q := repository.Question{ID: "q1", Text: "Clarity", Weight: 1}
+	seed := func() *Store {
+		s := New()
+		s.AddStudent(repository.Student{ID: "alice", Level: 1})
+		s.AddVenue(repository.Venue{ID: "venue1", Level: 1})
+		s.AddQRCode(repository.QRCode{ID: "qr1", VenueID: "venue1", MaxCapacity: 5})
+		s.AddSession(repository.Session{ID: "s1", VenueID: "venue1", Level: 1, Status: "pending"})
+		s.AddParticipant("s1", "bob")
+		s.SetPhaseStart("s1", "bob", "prep", time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+		s.SetRankingPoints(1, 3, 2, 1)
+		s.SetQuestions(1, q)
+		return s
+	}
+	s := seed()
+
+	failed := errors.New("failed")
+	err := s.InTx(ctx, func(tx repository.Store) error {
+		writes := []error{
+			tx.Participants().CancelPendingBooking(ctx, "bob", "venue1"),
+			tx.Participants().PrunePhases(ctx, "s1", time.Now()),
+			tx.Sessions().Create(ctx, repository.Session{ID: "s2", VenueID: "venue1", Status: "pending"}),
+			tx.Sessions().Activate(ctx, "s1"),
+			tx.Sessions().SetTopic(ctx, "s1", repository.Topic{ID: "t1", Text: "Topic"}),
+			tx.Participants().Add(ctx, "s1", "alice"),
+			tx.Participants().ClearPhases(ctx, "bob"),
+			tx.Participants().StartPhase(ctx, "s1", "alice", "prep"),
+			tx.QRCodes().IncrementUsage(ctx, "qr1"),
+			tx.Students().Promote(ctx, "alice", 1),
+			tx.Surveys().SaveSessionQuestions(ctx, "s1", []repository.Question{q}),
+			tx.Surveys().ServeQuestions(ctx, "s1", "alice", []repository.Question{q}),
+			tx.Surveys().SaveResult(ctx, repository.SurveyResult{SessionID: "s1", StudentID: "bob", ResponderID: "alice", QuestionID: "q1", Rank: 1}),
+			tx.Surveys().MarkCompleted(ctx, "s1", "alice"),
+			tx.Surveys().ReplaceBias(ctx, "s1", []repository.Bias{{SessionID: "s1", ResponderID: "alice", QuestionID: "q1"}}),
+			tx.Surveys().SaveFlags(ctx, []repository.CollusionFlag{{SessionID: "s1", Kind: repository.FlagIdenticalRankings, ResponderIDs: []string{"alice"}}}),
+			tx.Results().Save(ctx, []repository.SessionResult{{SessionID: "s1", StudentID: "bob", Rank: 1}}),
+			tx.Surveys().StartQuestionTimer(ctx, "s1", "q1", time.Minute),
+			tx.Surveys().ChargeTimeout(ctx, "s1", "bob", "q1", 0.5),
+			tx.Sessions().StartSurveyTimer(ctx, "s1", time.Minute),
+			tx.Sessions().SetStatus(ctx, "s1", "lobby"),
+			tx.Feedback().Submit(ctx, repository.Feedback{SessionID: "s1", StudentID: "bob", Rating: 5}),
+			tx.Sessions().MarkFinalized(ctx, "s1"),
+		}
+		if err := errors.Join(writes...); err != nil {
+			t.Fatal(err)
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("InTx = %v, want fn's error", err)
+	}
+	if want := seed().data; !reflect.DeepEqual(s.data, want) {
+		t.Errorf("data after a failed unit of work:\n%+v\nwant\n%+v", s.data, want)
+	}
+
+	if err := s.InTx(ctx, func(tx repository.Store) error {
+		return tx.Participants().Add(ctx, "s1", "alice")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Participants().IsParticipant(ctx, "s1", "alice"); !ok {
+		t.Error("a successful unit of work was not kept")
+	}
+}
@@ -0,0 +1,1158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// querier is the subset of *sql.DB and *sql.Tx used by the MySQL repositories.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type mysqlStore struct {
+	db *sql.DB
+	q  querier
+}
+
+// NewMySQLStore returns a Store backed by the given connection pool.
+func NewMySQLStore(db *sql.DB) Store {
+	return &mysqlStore{db: db, q: db}
+}
+
+func (s *mysqlStore) Students() StudentRepository         { return mysqlStudents{s.q} }
+func (s *mysqlStore) Venues() VenueRepository             { return mysqlVenues{s.q} }
+func (s *mysqlStore) QRCodes() QRCodeRepository           { return mysqlQRCodes{s.q} }
+func (s *mysqlStore) Sessions() SessionRepository         { return mysqlSessions{s.q} }
+func (s *mysqlStore) Participants() ParticipantRepository { return mysqlParticipants{s.q} }
+func (s *mysqlStore) Surveys() SurveyRepository           { return mysqlSurveys{s.q} }
+func (s *mysqlStore) Results() ResultRepository           { return mysqlResults{s.q} }
+func (s *mysqlStore) Topics() TopicRepository             { return mysqlTopics{s.q} }
+func (s *mysqlStore) Feedback() FeedbackRepository        { return mysqlFeedback{s.q} }
+
+func (s *mysqlStore) InTx(ctx context.Context, fn func(Store) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		// Already inside a transaction; join it.
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&mysqlStore{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type mysqlStudents struct{ q querier }
+
+func (r mysqlStudents) Get(ctx context.Context, id string) (Student, error) {
+	var st Student
+	err := r.q.QueryRowContext(ctx, `
+		SELECT id, email, full_name, COALESCE(photo_url, ''), department, current_gd_level, is_active
+		FROM student_users WHERE id = ?`, id,
+	).Scan(&st.ID, &st.Email, &st.Name, &st.PhotoURL, &st.Department, &st.Level, &st.IsActive)
+	return st, notFound(err)
+}
+
+func (r mysqlStudents) Credentials(ctx context.Context, email string) (Student, string, error) {
+	var st Student
+	var hash string
+	err := r.q.QueryRowContext(ctx, `
+		SELECT id, email, full_name, COALESCE(photo_url, ''), department, current_gd_level, is_active, password_hash
+		FROM student_users WHERE email = ? AND is_active = TRUE`, email,
+	).Scan(&st.ID, &st.Email, &st.Name, &st.PhotoURL, &st.Department, &st.Level, &st.IsActive, &hash)
+	return st, hash, notFound(err)
+}
+
+func (r mysqlStudents) Promote(ctx context.Context, id string, level int) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE student_users
+		SET current_gd_level = current_gd_level + 1
+		WHERE id = ? AND current_gd_level = ? AND current_gd_level < ?`,
+		id, level, MaxLevel)
+	return err
+}
+
+type mysqlVenues struct{ q querier }
+
+func (r mysqlVenues) Get(ctx context.Context, id string) (Venue, error) {
+	var v Venue
+	err := r.q.QueryRowContext(ctx, `
+		SELECT id, name, capacity, level, is_active, session_timing, table_details
+		FROM venues WHERE id = ?`, id,
+	).Scan(&v.ID, &v.Name, &v.Capacity, &v.Level, &v.IsActive, &v.SessionTiming, &v.TableDetails)
+	return v, notFound(err)
+}
+
+func (r mysqlVenues) BookedCount(ctx context.Context, venueID string) (int, error) {
+	var booked int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM session_participants sp
+		JOIN gd_sessions s ON sp.session_id = s.id
+		WHERE s.venue_id = ?`, venueID,
+	).Scan(&booked)
+	return booked, err
+}
+
+func (r mysqlVenues) ActiveByLevel(ctx context.Context, level int) ([]Venue, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, name, capacity, level, is_active, session_timing, table_details
+		FROM venues WHERE level = ? AND is_active = TRUE
+		ORDER BY name`, level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var venues []Venue
+	for rows.Next() {
+		var v Venue
+		if err := rows.Scan(&v.ID, &v.Name, &v.Capacity, &v.Level, &v.IsActive, &v.SessionTiming, &v.TableDetails); err != nil {
+			return nil, err
+		}
+		venues = append(venues, v)
+	}
+	return venues, rows.Err()
+}
+
+type mysqlQRCodes struct{ q querier }
+
+func (r mysqlQRCodes) FindByData(ctx context.Context, qrData, venueID string) (QRCode, error) {
+	var qr QRCode
+	var groupID sql.NullString
+	err := r.q.QueryRowContext(ctx, `
+		SELECT id, venue_id, qr_data, max_capacity, current_usage, is_active, qr_group_id, expires_at
+		FROM venue_qr_codes
+		WHERE qr_data = ? AND venue_id = ?`, qrData, venueID,
+	).Scan(&qr.ID, &qr.VenueID, &qr.QRData, &qr.MaxCapacity, &qr.CurrentUsage, &qr.IsActive, &groupID, &qr.ExpiresAt)
+	qr.QRGroupID = groupID.String
+	return qr, notFound(err)
+}
+
+func (r mysqlQRCodes) IncrementUsage(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE venue_qr_codes
+		SET current_usage = current_usage + 1
+		WHERE id = ?`, id)
+	return err
+}
+
+type mysqlSessions struct{ q querier }
+
+const sessionColumns = `id, COALESCE(venue_id, ''), level, status, COALESCE(qr_group_id, ''), start_time, end_time,
+	finalized_at IS NOT NULL, COALESCE(ranking_config_id, ''), COALESCE(topic, ''), COALESCE(topic_id, ''),
+	COALESCE(CAST(JSON_UNQUOTE(JSON_EXTRACT(agenda, '$.prep_time')) AS SIGNED), 1),
+	COALESCE(CAST(JSON_UNQUOTE(JSON_EXTRACT(agenda, '$.discussion')) AS SIGNED), 1),
+	COALESCE(CAST(JSON_UNQUOTE(JSON_EXTRACT(agenda, '$.survey')) AS SIGNED), 1)`
+
+// scanner is a *sql.Row or *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSession reads a row of sessionColumns.
+func scanSession(row scanner) (Session, error) {
+	var s Session
+	err := row.Scan(&s.ID, &s.VenueID, &s.Level, &s.Status, &s.QRGroupID, &s.StartTime, &s.EndTime, &s.Finalized, &s.ConfigID,
+		&s.Topic, &s.TopicID, &s.PrepMinutes, &s.DiscussionMinutes, &s.SurveyMinutes)
+	return s, notFound(err)
+}
+
+func (r mysqlSessions) Get(ctx context.Context, id string) (Session, error) {
+	return scanSession(r.q.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM gd_sessions WHERE id = ?`, id))
+}
+
+func (r mysqlSessions) FindPendingByVenue(ctx context.Context, venueID string) (Session, error) {
+	return scanSession(r.q.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+` FROM gd_sessions
+		WHERE venue_id = ? AND status = 'pending'
+		ORDER BY start_time ASC LIMIT 1`, venueID))
+}
+
+func (r mysqlSessions) FindOpenByQRGroup(ctx context.Context, venueID, qrGroupID string) (Session, error) {
+	return scanSession(r.q.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+` FROM gd_sessions
+		WHERE venue_id = ? AND qr_group_id = ? AND status IN ('pending', 'active')
+		ORDER BY created_at DESC LIMIT 1`, venueID, qrGroupID))
+}
+
+func (r mysqlSessions) Create(ctx context.Context, s Session) error {
+	var groupID interface{}
+	if s.QRGroupID != "" {
+		groupID = s.QRGroupID
+	}
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO gd_sessions
+		(id, venue_id, status, start_time, end_time, level, qr_group_id)
+		VALUES (?, ?, ?, NOW(), DATE_ADD(NOW(), INTERVAL 1 HOUR),
+		       (SELECT level FROM venues WHERE id = ?), ?)`,
+		s.ID, s.VenueID, s.Status, s.VenueID, groupID)
+	return err
+}
+
+func (r mysqlSessions) Activate(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE gd_sessions
+		SET status = 'active'
+		WHERE id = ? AND status = 'pending'`, id)
+	return err
+}
+
+func (r mysqlSessions) SurveysClosedBefore(ctx context.Context, t time.Time) ([]Session, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM gd_sessions
+		WHERE survey_end_time < ? AND finalized_at IS NULL`, t)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r mysqlSessions) Reviewed(ctx context.Context) ([]Session, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM gd_sessions
+		WHERE finalized_at IS NULL
+		  AND id IN (SELECT session_id FROM collusion_flags)
+		  AND id NOT IN (SELECT session_id FROM collusion_flags WHERE status = 'pending')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r mysqlSessions) PinConfig(ctx context.Context, id, configID string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE gd_sessions
+		SET ranking_config_id = ?
+		WHERE id = ? AND ranking_config_id IS NULL`, configID, id)
+	return err
+}
+
+func (r mysqlSessions) SetTopic(ctx context.Context, id string, topic Topic) error {
+	var topicID interface{}
+	if topic.ID != "" {
+		topicID = topic.ID
+	}
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE gd_sessions
+		SET topic = ?, topic_id = ?
+		WHERE id = ? AND COALESCE(topic, '') = ''`, topic.Text, topicID, id)
+	return err
+}
+
+func (r mysqlSessions) SetStatus(ctx context.Context, id, status string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE gd_sessions SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+func (r mysqlSessions) StartSurveyTimer(ctx context.Context, id string, d time.Duration) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE gd_sessions
+		SET survey_end_time = DATE_ADD(NOW(), INTERVAL ? SECOND)
+		WHERE id = ?`, int(d.Seconds()), id)
+	return err
+}
+
+func (r mysqlSessions) SurveyEnd(ctx context.Context, id string) (time.Time, error) {
+	var end sql.NullTime
+	err := r.q.QueryRowContext(ctx, `SELECT survey_end_time FROM gd_sessions WHERE id = ?`, id).Scan(&end)
+	if err == nil && !end.Valid {
+		err = sql.ErrNoRows
+	}
+	return end.Time, notFound(err)
+}
+
+func (r mysqlSessions) MarkFinalized(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE gd_sessions
+		SET status = 'completed', finalized_at = NOW()
+		WHERE id = ?`, id)
+	return err
+}
+
+type mysqlParticipants struct{ q querier }
+
+func (r mysqlParticipants) IsParticipant(ctx context.Context, sessionID, studentID string) (bool, error) {
+	var exists bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM session_participants
+			WHERE session_id = ? AND student_id = ? AND is_dummy = FALSE
+		)`, sessionID, studentID).Scan(&exists)
+	return exists, err
+}
+
+func (r mysqlParticipants) Add(ctx context.Context, sessionID, studentID string) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO session_participants
+		(id, session_id, student_id, is_dummy)
+		VALUES (?, ?, ?, FALSE)`,
+		uuid.New().String(), sessionID, studentID)
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r mysqlParticipants) CountPendingBookings(ctx context.Context, studentID string) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM session_participants sp
+		JOIN gd_sessions s ON sp.session_id = s.id
+		WHERE sp.student_id = ? AND s.status = 'pending'`, studentID).Scan(&count)
+	return count, err
+}
+
+func (r mysqlParticipants) HasPendingBooking(ctx context.Context, studentID, venueID string) (bool, error) {
+	var exists bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM session_participants sp
+			JOIN gd_sessions s ON sp.session_id = s.id
+			WHERE sp.student_id = ? AND s.venue_id = ? AND s.status = 'pending'
+		)`, studentID, venueID).Scan(&exists)
+	return exists, err
+}
+
+func (r mysqlParticipants) CancelPendingBooking(ctx context.Context, studentID, venueID string) error {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE sp FROM session_participants sp
+		JOIN gd_sessions s ON sp.session_id = s.id
+		WHERE sp.student_id = ? AND s.venue_id = ? AND s.status = 'pending'`,
+		studentID, venueID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r mysqlParticipants) Overlapping(ctx context.Context, studentID string, sess Session) (Session, error) {
+	return scanSession(r.q.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+` FROM gd_sessions
+		WHERE id IN (SELECT session_id FROM session_participants WHERE student_id = ? AND is_dummy = FALSE)
+		  AND id <> ? AND status IN ('pending', 'active')
+		  AND start_time < ? AND end_time > ?
+		ORDER BY start_time LIMIT 1`, studentID, sess.ID, sess.EndTime, sess.StartTime))
+}
+
+func (r mysqlParticipants) List(ctx context.Context, sessionID string) ([]Participant, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT su.id, su.full_name, COALESCE(su.photo_url, '') as photo_url
+		FROM student_users su
+		JOIN session_participants sp ON su.id = sp.student_id
+		WHERE sp.session_id = ? AND sp.is_dummy = FALSE`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []Participant
+	for rows.Next() {
+		var p Participant
+		if err := rows.Scan(&p.StudentID, &p.Name, &p.PhotoURL); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+func (r mysqlParticipants) CountPresent(ctx context.Context, sessionID string) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT sp.student_id)
+		FROM session_participants sp
+		JOIN session_phase_tracking spt ON sp.session_id = spt.session_id
+		                              AND sp.student_id = spt.student_id
+		WHERE sp.session_id = ? AND sp.is_dummy = FALSE`,
+		sessionID).Scan(&count)
+	return count, err
+}
+
+func (r mysqlParticipants) Present(ctx context.Context, sessionID string, since time.Time) ([]Participant, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT DISTINCT su.id, su.full_name, COALESCE(su.photo_url, ''), su.department
+		FROM session_participants sp
+		JOIN student_users su ON sp.student_id = su.id
+		JOIN session_phase_tracking spt ON sp.session_id = spt.session_id
+		                              AND sp.student_id = spt.student_id
+		WHERE sp.session_id = ?
+		  AND sp.is_dummy = FALSE
+		  AND su.is_active = TRUE
+		  AND spt.start_time > ?
+		ORDER BY su.full_name`, sessionID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []Participant
+	for rows.Next() {
+		var p Participant
+		if err := rows.Scan(&p.StudentID, &p.Name, &p.PhotoURL, &p.Department); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+func (r mysqlParticipants) PrunePhases(ctx context.Context, sessionID string, t time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM session_phase_tracking
+		WHERE session_id = ? AND start_time < ?`, sessionID, t)
+	return err
+}
+
+func (r mysqlParticipants) ClearPhases(ctx context.Context, studentID string) error {
+	_, err := r.q.ExecContext(ctx, `DELETE FROM session_phase_tracking WHERE student_id = ?`, studentID)
+	return err
+}
+
+func (r mysqlParticipants) StartPhase(ctx context.Context, sessionID, studentID, phase string) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO session_phase_tracking
+		(session_id, student_id, phase, start_time)
+		VALUES (?, ?, ?, NOW())`,
+		sessionID, studentID, phase)
+	return err
+}
+
+func (r mysqlParticipants) Phases(ctx context.Context, sessionID, studentID string) (map[string]time.Time, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT phase, start_time FROM session_phase_tracking
+		WHERE session_id = ? AND student_id = ?`, sessionID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	phases := map[string]time.Time{}
+	for rows.Next() {
+		var phase string
+		var started time.Time
+		if err := rows.Scan(&phase, &started); err != nil {
+			return nil, err
+		}
+		phases[phase] = started
+	}
+	return phases, rows.Err()
+}
+
+type mysqlSurveys struct{ q querier }
+
+func (r mysqlSurveys) ActiveQuestions(ctx context.Context, level int) ([]Question, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT q.id, q.question_text, q.weight, COALESCE(q.bank_id, '')
+		FROM survey_questions q
+		JOIN question_levels ql ON ql.question_id = q.id
+		WHERE ql.level = ? AND q.is_active = TRUE
+		ORDER BY ql.display_order, q.created_at, q.id`, level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []Question
+	for rows.Next() {
+		var q Question
+		if err := rows.Scan(&q.ID, &q.Text, &q.Weight, &q.BankID); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (r mysqlSurveys) Template(ctx context.Context, level int) (map[string]int, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT bank_id, draw_count FROM question_templates WHERE level = ?", level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	draws := map[string]int{}
+	for rows.Next() {
+		var bankID string
+		var count int
+		if err := rows.Scan(&bankID, &count); err != nil {
+			return nil, err
+		}
+		draws[bankID] = count
+	}
+	return draws, rows.Err()
+}
+
+func (r mysqlSurveys) SessionQuestions(ctx context.Context, sessionID string) ([]Question, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT question_id, question_text, weight, COALESCE(bank_id, '')
+		FROM session_questions
+		WHERE session_id = ?
+		ORDER BY position`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []Question
+	for rows.Next() {
+		var q Question
+		if err := rows.Scan(&q.ID, &q.Text, &q.Weight, &q.BankID); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (r mysqlSurveys) SaveSessionQuestions(ctx context.Context, sessionID string, questions []Question) error {
+	var drawn bool
+	err := r.q.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM session_questions WHERE session_id = ?)", sessionID).Scan(&drawn)
+	if err != nil || drawn {
+		return err
+	}
+	for i, q := range questions {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT IGNORE INTO session_questions (session_id, question_id, position, question_text, weight, bank_id)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))`,
+			sessionID, q.ID, i, q.Text, q.Weight, q.BankID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlSurveys) ServedSets(ctx context.Context, studentID string) (map[string][]string, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT session_id, question_id FROM served_questions
+		WHERE student_id = ?
+		ORDER BY session_id, position`, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := map[string][]string{}
+	for rows.Next() {
+		var sessionID, questionID string
+		if err := rows.Scan(&sessionID, &questionID); err != nil {
+			return nil, err
+		}
+		sets[sessionID] = append(sets[sessionID], questionID)
+	}
+	return sets, rows.Err()
+}
+
+func (r mysqlSurveys) ServedQuestions(ctx context.Context, sessionID, studentID string) ([]Question, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT question_id, question_text, weight
+		FROM served_questions
+		WHERE session_id = ? AND student_id = ?
+		ORDER BY position`, sessionID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []Question
+	for rows.Next() {
+		var q Question
+		if err := rows.Scan(&q.ID, &q.Text, &q.Weight); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (r mysqlSurveys) ServeQuestions(ctx context.Context, sessionID, studentID string, questions []Question) error {
+	var served bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM served_questions WHERE session_id = ? AND student_id = ?)`,
+		sessionID, studentID).Scan(&served)
+	if err != nil || served {
+		return err
+	}
+	for i, q := range questions {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT IGNORE INTO served_questions (session_id, student_id, question_id, position, question_text, weight)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			sessionID, studentID, q.ID, i, q.Text, q.Weight)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlSurveys) DeleteResponses(ctx context.Context, sessionID, responderID, questionID string) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM survey_results
+		WHERE session_id = ? AND responder_id = ? AND question_id = ?`,
+		sessionID, responderID, questionID)
+	return err
+}
+
+func (r mysqlSurveys) SaveResult(ctx context.Context, res SurveyResult) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO survey_results
+		(id, session_id, student_id, responder_id, question_id, ranks, score,
+		 weighted_score, penalty_points, is_biased, is_current_session, is_completed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, 0)`,
+		uuid.New().String(), res.SessionID, res.StudentID, res.ResponderID, res.QuestionID, res.Rank,
+		res.Score, res.WeightedScore, res.PenaltyPoints, res.IsBiased)
+	return err
+}
+
+func (r mysqlSurveys) CountAnswered(ctx context.Context, sessionID, responderID string) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT question_id)
+		FROM survey_results
+		WHERE session_id = ? AND responder_id = ?`,
+		sessionID, responderID).Scan(&count)
+	return count, err
+}
+
+func (r mysqlSurveys) MarkCompleted(ctx context.Context, sessionID, responderID string) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO survey_completion (session_id, student_id, completed_at)
+		VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE completed_at = NOW()`,
+		sessionID, responderID)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		UPDATE survey_results
+		SET is_completed = 1
+		WHERE session_id = ? AND responder_id = ?`,
+		sessionID, responderID)
+	return err
+}
+
+func (r mysqlSurveys) CountCompleted(ctx context.Context, sessionID string) (int, error) {
+	var count int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT sc.student_id)
+		FROM survey_completion sc
+		JOIN session_participants sp ON sc.session_id = sp.session_id AND sc.student_id = sp.student_id
+		WHERE sc.session_id = ? AND sp.is_dummy = FALSE`,
+		sessionID).Scan(&count)
+	return count, err
+}
+
+func (r mysqlSurveys) Results(ctx context.Context, sessionID string) ([]SurveyResult, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT session_id, student_id, responder_id, question_id, ranks, score,
+		       weighted_score, penalty_points, is_biased
+		FROM survey_results
+		WHERE session_id = ? AND voided = FALSE
+		ORDER BY question_id, responder_id, ranks`,
+		sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SurveyResult
+	for rows.Next() {
+		var res SurveyResult
+		if err := rows.Scan(&res.SessionID, &res.StudentID, &res.ResponderID, &res.QuestionID, &res.Rank,
+			&res.Score, &res.WeightedScore, &res.PenaltyPoints, &res.IsBiased); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+func (r mysqlSurveys) ReplaceBias(ctx context.Context, sessionID string, entries []Bias) error {
+	if _, err := r.q.ExecContext(ctx, `DELETE FROM survey_bias WHERE session_id = ?`, sessionID); err != nil {
+		return err
+	}
+	for _, b := range entries {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT INTO survey_bias
+			(session_id, responder_id, question_id, consensus_method, deviation, penalty_points, is_biased)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			sessionID, b.ResponderID, b.QuestionID, b.Method, b.Deviation, b.PenaltyPoints, b.IsBiased)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlSurveys) Bias(ctx context.Context, sessionID string) ([]Bias, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT session_id, responder_id, question_id, consensus_method, deviation, penalty_points, is_biased
+		FROM survey_bias
+		WHERE session_id = ?
+		ORDER BY responder_id, question_id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Bias
+	for rows.Next() {
+		var b Bias
+		if err := rows.Scan(&b.SessionID, &b.ResponderID, &b.QuestionID, &b.Method, &b.Deviation,
+			&b.PenaltyPoints, &b.IsBiased); err != nil {
+			return nil, err
+		}
+		entries = append(entries, b)
+	}
+	return entries, rows.Err()
+}
+
+// Configurations saved before points existed only have the top three.
+const configColumns = `id, level, version,
+	COALESCE(points, JSON_ARRAY(first_place_points, second_place_points, third_place_points)),
+	consensus_method, effective_from`
+
+func scanConfig(row *sql.Row) (ScoringConfig, error) {
+	var c ScoringConfig
+	var points []byte
+	if err := row.Scan(&c.ID, &c.Level, &c.Version, &points, &c.Method, &c.EffectiveFrom); err != nil {
+		return c, notFound(err)
+	}
+	return c, json.Unmarshal(points, &c.Points)
+}
+
+func (r mysqlSurveys) ActiveConfig(ctx context.Context, level int) (ScoringConfig, error) {
+	return scanConfig(r.q.QueryRowContext(ctx, `
+		SELECT `+configColumns+` FROM ranking_points_config
+		WHERE level = ? AND is_active = TRUE`, level))
+}
+
+func (r mysqlSurveys) Config(ctx context.Context, id string) (ScoringConfig, error) {
+	return scanConfig(r.q.QueryRowContext(ctx, `
+		SELECT `+configColumns+` FROM ranking_points_config
+		WHERE id = ?`, id))
+}
+
+func (r mysqlSurveys) SaveFlags(ctx context.Context, flags []CollusionFlag) error {
+	for _, f := range flags {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT IGNORE INTO collusion_flags (id, session_id, kind, responder_ids, detail, status)
+			VALUES (?, ?, ?, ?, ?, 'pending')`,
+			uuid.New().String(), f.SessionID, f.Kind, strings.Join(f.ResponderIDs, ","), f.Detail)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlSurveys) Flags(ctx context.Context, sessionID string) ([]CollusionFlag, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, session_id, kind, responder_ids, detail, status
+		FROM collusion_flags
+		WHERE session_id = ?
+		ORDER BY created_at, id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []CollusionFlag
+	for rows.Next() {
+		var f CollusionFlag
+		var responders string
+		if err := rows.Scan(&f.ID, &f.SessionID, &f.Kind, &responders, &f.Detail, &f.Status); err != nil {
+			return nil, err
+		}
+		f.ResponderIDs = strings.Split(responders, ",")
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+func (r mysqlSurveys) StartQuestionTimer(ctx context.Context, sessionID, questionID string, d time.Duration) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO question_timers (session_id, question_id, end_time)
+		VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))
+		ON DUPLICATE KEY UPDATE end_time = VALUES(end_time)`,
+		sessionID, questionID, int(d.Seconds()))
+	return err
+}
+
+func (r mysqlSurveys) QuestionDeadline(ctx context.Context, sessionID, questionID string) (time.Time, error) {
+	var end time.Time
+	err := r.q.QueryRowContext(ctx, `
+		SELECT end_time FROM question_timers
+		WHERE session_id = ? AND question_id = ?`, sessionID, questionID).Scan(&end)
+	return end, notFound(err)
+}
+
+func (r mysqlSurveys) ChargeTimeout(ctx context.Context, sessionID, studentID, questionID string, points float64) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO survey_penalties
+		(id, session_id, student_id, question_id, penalty_points)
+		VALUES (?, ?, ?, ?, ?)`,
+		uuid.New().String(), sessionID, studentID, questionID, points)
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r mysqlSurveys) ScoreSummaries(ctx context.Context, sessionID string) ([]ScoreSummary, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT sp.student_id,
+		       COALESCE(sr.total, 0) as total_score,
+		       COALESCE(b.penalty, 0) as bias_penalty,
+		       COALESCE(t.penalty, 0) as timeout_penalty,
+		       COALESCE(b.biased, 0) as biased_questions,
+		       COALESCE(sr.first_places, 0) as first_places
+		FROM session_participants sp
+		LEFT JOIN (
+			SELECT student_id, SUM(score) as total, COUNT(CASE WHEN ranks = 1 THEN 1 END) as first_places
+			FROM survey_results
+			WHERE session_id = ? AND is_current_session = 1 AND voided = FALSE
+			GROUP BY student_id
+		) sr ON sr.student_id = sp.student_id
+		LEFT JOIN (
+			SELECT responder_id, SUM(penalty_points) as penalty, SUM(is_biased) as biased
+			FROM survey_bias
+			WHERE session_id = ?
+			GROUP BY responder_id
+		) b ON b.responder_id = sp.student_id
+		LEFT JOIN (
+			SELECT student_id, SUM(penalty_points) as penalty
+			FROM survey_penalties
+			WHERE session_id = ?
+			GROUP BY student_id
+		) t ON t.student_id = sp.student_id
+		WHERE sp.session_id = ? AND sp.is_dummy = FALSE`, sessionID, sessionID, sessionID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []ScoreSummary
+	for rows.Next() {
+		var s ScoreSummary
+		if err := rows.Scan(&s.StudentID, &s.TotalScore, &s.BiasPenalty, &s.TimeoutPenalty, &s.BiasedQuestions, &s.FirstPlaces); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+func (r mysqlSurveys) ClearCurrent(ctx context.Context, sessionID string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE survey_results SET is_current_session = 0 WHERE session_id = ?`, sessionID)
+	return err
+}
+
+type mysqlResults struct{ q querier }
+
+func (r mysqlResults) Rule(ctx context.Context, level int) (QualificationRule, error) {
+	var rule QualificationRule
+	err := r.q.QueryRowContext(ctx, `
+		SELECT qualifying_places, penalty_threshold
+		FROM gd_rules WHERE level = ?`, level,
+	).Scan(&rule.Places, &rule.PenaltyThreshold)
+	return rule, notFound(err)
+}
+
+func (r mysqlResults) Save(ctx context.Context, results []SessionResult) error {
+	for _, res := range results {
+		var configID, moderatorScore interface{}
+		if res.ConfigID != "" {
+			configID = res.ConfigID
+		}
+		if res.Moderated {
+			moderatorScore = res.ModeratorScore
+		}
+		_, err := r.q.ExecContext(ctx, `
+			INSERT INTO session_results
+			(session_id, student_id, level, total_score, penalty_points, final_score, rank_position,
+			 first_places, biased_questions, qualified, peer_score, moderator_score, moderator_weight,
+			 config_id, config_version, consensus_method, finalized_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			res.SessionID, res.StudentID, res.Level, res.TotalScore, res.PenaltyPoints, res.FinalScore, res.Rank,
+			res.FirstPlaces, res.BiasedQuestions, res.Qualified, res.PeerScore, moderatorScore, res.ModeratorWeight,
+			configID, res.ConfigVersion, res.ConsensusMethod, res.FinalizedAt)
+		if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+			return ErrDuplicate
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlResults) ForSession(ctx context.Context, sessionID string) ([]SessionResult, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT session_id, student_id, level, total_score, penalty_points, final_score, rank_position,
+		       first_places, biased_questions, qualified, peer_score, moderator_score, moderator_weight,
+		       COALESCE(config_id, ''), config_version, consensus_method, finalized_at
+		FROM session_results
+		WHERE session_id = ?
+		ORDER BY rank_position`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SessionResult
+	for rows.Next() {
+		var res SessionResult
+		var moderatorScore sql.NullFloat64
+		if err := rows.Scan(&res.SessionID, &res.StudentID, &res.Level, &res.TotalScore, &res.PenaltyPoints,
+			&res.FinalScore, &res.Rank, &res.FirstPlaces, &res.BiasedQuestions, &res.Qualified, &res.PeerScore,
+			&moderatorScore, &res.ModeratorWeight, &res.ConfigID, &res.ConfigVersion, &res.ConsensusMethod,
+			&res.FinalizedAt); err != nil {
+			return nil, err
+		}
+		res.ModeratorScore, res.Moderated = moderatorScore.Float64, moderatorScore.Valid
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+func (r mysqlResults) Rubric(ctx context.Context, level int) (Rubric, error) {
+	rubric := Rubric{Level: level}
+	err := r.q.QueryRowContext(ctx,
+		"SELECT moderator_weight FROM rubrics WHERE level = ?", level,
+	).Scan(&rubric.ModeratorWeight)
+	return rubric, notFound(err)
+}
+
+func (r mysqlResults) RubricScores(ctx context.Context, sessionID string) ([]RubricScore, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT ms.student_id, c.id, c.name, c.weight, c.max_score, ms.score
+		FROM moderator_scores ms
+		JOIN rubric_criteria c ON c.id = ms.criterion_id
+		WHERE ms.session_id = ?
+		ORDER BY ms.student_id, c.display_order, c.id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []RubricScore
+	for rows.Next() {
+		var sc RubricScore
+		if err := rows.Scan(&sc.StudentID, &sc.CriterionID, &sc.Criterion, &sc.Weight, &sc.MaxScore, &sc.Score); err != nil {
+			return nil, err
+		}
+		scores = append(scores, sc)
+	}
+	return scores, rows.Err()
+}
+
+func (r mysqlResults) RubricFeedback(ctx context.Context, sessionID, studentID string) (string, error) {
+	var feedback string
+	err := r.q.QueryRowContext(ctx,
+		"SELECT feedback FROM moderator_feedback WHERE session_id = ? AND student_id = ?",
+		sessionID, studentID,
+	).Scan(&feedback)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return feedback, err
+}
+
+type mysqlTopics struct{ q querier }
+
+const topicColumns = `t.id, t.level, t.topic_text, t.prep_materials,
+	(SELECT COUNT(*) FROM gd_sessions s WHERE s.topic_id = t.id)`
+
+func scanTopic(row interface{ Scan(...interface{}) error }) (Topic, error) {
+	var t Topic
+	err := row.Scan(&t.ID, &t.Level, &t.Text, &t.PrepMaterials, &t.Uses)
+	return t, err
+}
+
+func (r mysqlTopics) Active(ctx context.Context, level int) ([]Topic, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+topicColumns+` FROM gd_topics t
+		WHERE t.level = ? AND t.is_active = TRUE
+		ORDER BY t.created_at, t.id`, level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var topics []Topic
+	for rows.Next() {
+		t, err := scanTopic(rows)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+func (r mysqlTopics) Get(ctx context.Context, id string) (Topic, error) {
+	t, err := scanTopic(r.q.QueryRowContext(ctx, `SELECT `+topicColumns+` FROM gd_topics t WHERE t.id = ?`, id))
+	return t, notFound(err)
+}
+
+func (r mysqlTopics) Discussed(ctx context.Context, studentIDs []string, excludeSessionID string) (map[string]int, error) {
+	discussed := map[string]int{}
+	if len(studentIDs) == 0 {
+		return discussed, nil
+	}
+	args := []interface{}{excludeSessionID}
+	for _, id := range studentIDs {
+		args = append(args, id)
+	}
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT s.topic_id, COUNT(DISTINCT sp.student_id)
+		FROM session_participants sp
+		JOIN gd_sessions s ON s.id = sp.session_id
+		WHERE s.topic_id IS NOT NULL AND s.id <> ?
+		  AND sp.student_id IN (?`+strings.Repeat(", ?", len(studentIDs)-1)+`)
+		GROUP BY s.topic_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var topicID string
+		var n int
+		if err := rows.Scan(&topicID, &n); err != nil {
+			return nil, err
+		}
+		discussed[topicID] = n
+	}
+	return discussed, rows.Err()
+}
+
+func (r mysqlTopics) VenueUses(ctx context.Context, venueID, excludeSessionID string) (map[string]int, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT topic_id, COUNT(*) FROM gd_sessions
+		WHERE venue_id = ? AND topic_id IS NOT NULL AND id <> ?
+		GROUP BY topic_id`, venueID, excludeSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uses := map[string]int{}
+	for rows.Next() {
+		var topicID string
+		var n int
+		if err := rows.Scan(&topicID, &n); err != nil {
+			return nil, err
+		}
+		uses[topicID] = n
+	}
+	return uses, rows.Err()
+}
+
+func (r mysqlTopics) Materials(ctx context.Context, topicID string) ([]PrepMaterial, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+prepMaterialColumns+`
+		FROM prep_materials
+		WHERE topic_id = ?
+		ORDER BY display_order, created_at, id`, topicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var materials []PrepMaterial
+	for rows.Next() {
+		m, err := scanPrepMaterial(rows)
+		if err != nil {
+			return nil, err
+		}
+		materials = append(materials, m)
+	}
+	return materials, rows.Err()
+}
+
+const prepMaterialColumns = `id, topic_id, kind, title, COALESCE(body, ''), COALESCE(url, ''), COALESCE(file_key, ''),
+	COALESCE(file_name, ''), COALESCE(content_type, ''), COALESCE(size_bytes, 0),
+	visible_from, COALESCE(visible_until, ''), display_order`
+
+func scanPrepMaterial(row scanner) (PrepMaterial, error) {
+	var m PrepMaterial
+	err := row.Scan(&m.ID, &m.TopicID, &m.Kind, &m.Title, &m.Body, &m.URL, &m.FileKey,
+		&m.FileName, &m.ContentType, &m.Size, &m.VisibleFrom, &m.VisibleUntil, &m.DisplayOrder)
+	return m, err
+}
+
+type mysqlFeedback struct{ q querier }
+
+func (r mysqlFeedback) Submit(ctx context.Context, f Feedback) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO session_feedback
+		(id, session_id, student_id, rating, comments)
+		VALUES (?, ?, ?, ?, ?)`,
+		uuid.New().String(), f.SessionID, f.StudentID, f.Rating, f.Comments)
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r mysqlFeedback) Get(ctx context.Context, sessionID, studentID string) (Feedback, error) {
+	f := Feedback{SessionID: sessionID, StudentID: studentID}
+	err := r.q.QueryRowContext(ctx, `
+		SELECT rating, COALESCE(comments, '') FROM session_feedback
+		WHERE session_id = ? AND student_id = ?`, sessionID, studentID).Scan(&f.Rating, &f.Comments)
+	return f, notFound(err)
+}
@@ -0,0 +1,1501 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type mysqlAdminStore struct {
+	db *sql.DB
+	q  querier
+}
+
+// NewMySQLAdminStore returns an AdminStore backed by the given connection
+// pool.
+func NewMySQLAdminStore(db *sql.DB) AdminStore {
+	return &mysqlAdminStore{db: db, q: db}
+}
+
+func (s *mysqlAdminStore) Admins() AdminRepository        { return mysqlAdmins{s.q} }
+func (s *mysqlAdminStore) Venues() AdminVenueRepository   { return mysqlAdminVenues{s.q} }
+func (s *mysqlAdminStore) QRCodes() AdminQRCodeRepository { return mysqlAdminQRCodes{s.q} }
+func (s *mysqlAdminStore) Reports() ReportRepository      { return mysqlReports{s.q} }
+func (s *mysqlAdminStore) Schedule() ScheduleRepository   { return mysqlSchedule{s.q} }
+func (s *mysqlAdminStore) Questions() AdminQuestionRepository {
+	return mysqlAdminQuestions{s.q}
+}
+func (s *mysqlAdminStore) Banks() QuestionBankRepository { return mysqlBanks{s.q} }
+func (s *mysqlAdminStore) Topics() AdminTopicRepository  { return mysqlAdminTopics{s.q} }
+func (s *mysqlAdminStore) Materials() AdminMaterialRepository {
+	return mysqlAdminMaterials{s.q}
+}
+func (s *mysqlAdminStore) RankingConfigs() RankingConfigRepository {
+	return mysqlRankingConfigs{s.q}
+}
+func (s *mysqlAdminStore) Rubrics() RubricRepository { return mysqlRubrics{s.q} }
+func (s *mysqlAdminStore) Flags() FlagRepository     { return mysqlFlags{s.q} }
+
+func (s *mysqlAdminStore) InTx(ctx context.Context, fn func(AdminStore) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		// Already inside a transaction; join it.
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&mysqlAdminStore{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type mysqlAdmins struct{ q querier }
+
+func (r mysqlAdmins) Credentials(ctx context.Context, email string) (string, string, error) {
+	var id, hash string
+	err := r.q.QueryRowContext(ctx,
+		"SELECT id, password_hash FROM admin_users WHERE email = ?", email).Scan(&id, &hash)
+	return id, hash, notFound(err)
+}
+
+type mysqlAdminVenues struct{ q querier }
+
+func (r mysqlAdminVenues) Active(ctx context.Context) ([]Venue, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, name, capacity, level, session_timing, table_details, availability
+		FROM venues WHERE is_active = TRUE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var venues []Venue
+	for rows.Next() {
+		v := Venue{IsActive: true}
+		if err := rows.Scan(&v.ID, &v.Name, &v.Capacity, &v.Level, &v.SessionTiming, &v.TableDetails, &v.Availability); err != nil {
+			return nil, err
+		}
+		venues = append(venues, v)
+	}
+	return venues, rows.Err()
+}
+
+func (r mysqlAdminVenues) Create(ctx context.Context, v Venue) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO venues
+		(id, name, capacity, level, qr_secret, is_active, created_by, session_timing, table_details, availability, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		v.ID, v.Name, v.Capacity, v.Level, v.QRSecret, v.IsActive, v.CreatedBy, v.SessionTiming, v.TableDetails,
+		v.Availability, time.Now())
+	return err
+}
+
+func (r mysqlAdminVenues) Update(ctx context.Context, v Venue) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE venues
+		SET name = ?, capacity = ?, level = ?, session_timing = ?, table_details = ?
+		WHERE id = ? AND is_active = TRUE`,
+		v.Name, v.Capacity, v.Level, v.SessionTiming, v.TableDetails, v.ID)
+	return err
+}
+
+func (r mysqlAdminVenues) Get(ctx context.Context, id string) (Venue, error) {
+	v := Venue{ID: id}
+	err := r.q.QueryRowContext(ctx,
+		"SELECT name, capacity, level, is_active, availability FROM venues WHERE id = ?", id).
+		Scan(&v.Name, &v.Capacity, &v.Level, &v.IsActive, &v.Availability)
+	return v, notFound(err)
+}
+
+func (r mysqlAdminVenues) Scheduled(ctx context.Context) ([]Venue, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, name, level, capacity, availability FROM venues
+		WHERE is_active = TRUE AND availability IS NOT NULL
+		ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var venues []Venue
+	for rows.Next() {
+		v := Venue{IsActive: true}
+		if err := rows.Scan(&v.ID, &v.Name, &v.Level, &v.Capacity, &v.Availability); err != nil {
+			return nil, err
+		}
+		venues = append(venues, v)
+	}
+	return venues, rows.Err()
+}
+
+func (r mysqlAdminVenues) SetAvailability(ctx context.Context, id string, availability []byte) error {
+	var exists bool
+	err := r.q.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM venues WHERE id = ? AND is_active = TRUE)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	_, err = r.q.ExecContext(ctx, "UPDATE venues SET availability = ? WHERE id = ?", availability, id)
+	return err
+}
+
+type mysqlAdminQRCodes struct{ q querier }
+
+const qrCodeColumns = `id, venue_id, qr_data, max_capacity, current_usage, is_active,
+	COALESCE(qr_group_id, ''), expires_at, created_at`
+
+func scanQRCode(row scanner) (QRCode, error) {
+	var c QRCode
+	err := row.Scan(&c.ID, &c.VenueID, &c.QRData, &c.MaxCapacity, &c.CurrentUsage, &c.IsActive,
+		&c.QRGroupID, &c.ExpiresAt, &c.CreatedAt)
+	return c, notFound(err)
+}
+
+func (r mysqlAdminQRCodes) Current(ctx context.Context, venueID string, full bool) (QRCode, error) {
+	seats := "current_usage < max_capacity"
+	if full {
+		seats = "current_usage >= max_capacity"
+	}
+	return scanQRCode(r.q.QueryRowContext(ctx, `
+		SELECT `+qrCodeColumns+` FROM venue_qr_codes
+		WHERE venue_id = ? AND is_active = TRUE AND expires_at > NOW() AND `+seats+`
+		ORDER BY created_at DESC LIMIT 1`, venueID))
+}
+
+func (r mysqlAdminQRCodes) Create(ctx context.Context, c QRCode, d time.Duration) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO venue_qr_codes
+		(id, venue_id, qr_data, expires_at, is_active, max_capacity, current_usage, qr_group_id)
+		VALUES (?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND), TRUE, ?, 0, ?)`,
+		c.ID, c.VenueID, c.QRData, int(d.Seconds()), c.MaxCapacity, c.QRGroupID)
+	return err
+}
+
+func (r mysqlAdminQRCodes) Active(ctx context.Context, venueID string) ([]QRCode, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+qrCodeColumns+` FROM venue_qr_codes
+		WHERE venue_id = ? AND is_active = TRUE AND expires_at > NOW()
+		ORDER BY created_at DESC`, venueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []QRCode
+	for rows.Next() {
+		c, err := scanQRCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, c)
+	}
+	return codes, rows.Err()
+}
+
+func (r mysqlAdminQRCodes) Deactivate(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, "UPDATE venue_qr_codes SET is_active = FALSE WHERE id = ?", id)
+	return err
+}
+
+// DeactivateExpired compares expires_at against NOW() rather than UTC, as
+// it is written with NOW().
+func (r mysqlAdminQRCodes) DeactivateExpired(ctx context.Context) error {
+	_, err := r.q.ExecContext(ctx,
+		"UPDATE venue_qr_codes SET is_active = FALSE WHERE expires_at < NOW() AND is_active = TRUE")
+	return err
+}
+
+type mysqlReports struct{ q querier }
+
+func (r mysqlReports) QualificationRates(ctx context.Context) (map[string]float64, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT su.department, 100 * AVG(res.qualified)
+		FROM session_results res
+		JOIN student_users su ON res.student_id = su.id
+		GROUP BY su.department`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := map[string]float64{}
+	for rows.Next() {
+		var department string
+		var rate float64
+		if err := rows.Scan(&department, &rate); err != nil {
+			return nil, err
+		}
+		rates[department] = rate
+	}
+	return rates, rows.Err()
+}
+
+// TopParticipants only counts finalized sessions; session_results is their
+// snapshot.
+func (r mysqlReports) TopParticipants(ctx context.Context, level, limit int) ([]Standing, error) {
+	query := `
+		SELECT res.student_id, su.full_name, su.current_gd_level, COUNT(res.session_id),
+		       SUM(res.final_score) AS total_score, AVG(res.final_score)
+		FROM session_results res
+		JOIN student_users su ON res.student_id = su.id
+		WHERE su.is_active = TRUE`
+	var args []interface{}
+	if level > 0 {
+		query += " AND su.current_gd_level = ?"
+		args = append(args, level)
+	}
+	query += `
+		GROUP BY res.student_id, su.full_name, su.current_gd_level
+		ORDER BY total_score DESC
+		LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var standings []Standing
+	for rows.Next() {
+		var s Standing
+		if err := rows.Scan(&s.StudentID, &s.Name, &s.Level, &s.Sessions, &s.TotalScore, &s.AverageScore); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+	return standings, rows.Err()
+}
+
+func (r mysqlReports) PendingBookings(ctx context.Context) ([]Booking, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT su.id, su.full_name, v.id, v.name, s.id, s.level, sp.joined_at
+		FROM session_participants sp
+		JOIN student_users su ON sp.student_id = su.id
+		JOIN gd_sessions s ON sp.session_id = s.id
+		JOIN venues v ON s.venue_id = v.id
+		WHERE s.status = 'pending' AND sp.is_dummy = FALSE
+		ORDER BY sp.joined_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []Booking
+	for rows.Next() {
+		var b Booking
+		if err := rows.Scan(&b.StudentID, &b.StudentName, &b.VenueID, &b.VenueName, &b.SessionID, &b.SessionLevel, &b.BookedAt); err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, rows.Err()
+}
+
+func (r mysqlReports) SessionFeedback(ctx context.Context, sessionID string) ([]FeedbackEntry, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT sf.id, sf.student_id, sf.rating, COALESCE(sf.comments, ''), sf.created_at,
+		       su.full_name, su.department, su.year
+		FROM session_feedback sf
+		JOIN student_users su ON sf.student_id = su.id
+		WHERE sf.session_id = ?
+		ORDER BY sf.created_at DESC`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []FeedbackEntry
+	for rows.Next() {
+		f := FeedbackEntry{Feedback: Feedback{SessionID: sessionID}}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&f.ID, &f.StudentID, &f.Rating, &f.Comments, &createdAt,
+			&f.Name, &f.Department, &f.Year); err != nil {
+			return nil, err
+		}
+		f.CreatedAt = createdAt.Time
+		entries = append(entries, f)
+	}
+	return entries, rows.Err()
+}
+
+type mysqlSchedule struct{ q querier }
+
+func (r mysqlSchedule) Holidays(ctx context.Context) ([]Holiday, error) {
+	return r.holidays(ctx, "SELECT DATE_FORMAT(date, '%Y-%m-%d'), name FROM holidays ORDER BY date")
+}
+
+func (r mysqlSchedule) HolidaysBetween(ctx context.Context, from, to time.Time) ([]Holiday, error) {
+	return r.holidays(ctx, `
+		SELECT DATE_FORMAT(date, '%Y-%m-%d'), name FROM holidays
+		WHERE date BETWEEN ? AND ?
+		ORDER BY date`, from.Format(time.DateOnly), to.Format(time.DateOnly))
+}
+
+func (r mysqlSchedule) holidays(ctx context.Context, query string, args ...any) ([]Holiday, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holidays []Holiday
+	for rows.Next() {
+		var h Holiday
+		if err := rows.Scan(&h.Date, &h.Name); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, h)
+	}
+	return holidays, rows.Err()
+}
+
+func (r mysqlSchedule) SaveHoliday(ctx context.Context, h Holiday) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO holidays (date, name) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE name = VALUES(name)`, h.Date, h.Name)
+	return err
+}
+
+func (r mysqlSchedule) DeleteHoliday(ctx context.Context, date string) error {
+	res, err := r.q.ExecContext(ctx, "DELETE FROM holidays WHERE date = ?", date)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r mysqlSchedule) Booked(ctx context.Context, venueID string, start, end time.Time) ([]Session, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, start_time, end_time FROM gd_sessions
+		WHERE venue_id = ? AND status <> 'cancelled' AND start_time < ? AND end_time > ?
+		FOR UPDATE`, venueID, end, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		s := Session{VenueID: venueID}
+		if err := rows.Scan(&s.ID, &s.StartTime, &s.EndTime); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r mysqlSchedule) Create(ctx context.Context, s NewSession) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO gd_sessions
+		(id, topic, venue_id, level, start_time, end_time, agenda, survey_weights, max_capacity, status)
+		VALUES (?, '', ?, ?, ?, ?, ?, ?, COALESCE(NULLIF(?, 0), DEFAULT(max_capacity)), 'pending')`,
+		s.ID, s.VenueID, s.Level, s.StartTime, s.EndTime, s.Agenda, s.SurveyWeights, s.MaxCapacity)
+	return err
+}
+
+func (r mysqlSchedule) Agenda(ctx context.Context, sessionID string) ([]byte, error) {
+	var agenda []byte
+	err := r.q.QueryRowContext(ctx, "SELECT agenda FROM gd_sessions WHERE id = ?", sessionID).Scan(&agenda)
+	return agenda, notFound(err)
+}
+
+func (r mysqlSchedule) SetAgenda(ctx context.Context, sessionID string, agenda []byte, minutes int) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE gd_sessions
+		SET agenda = ?, end_time = DATE_ADD(start_time, INTERVAL ? MINUTE)
+		WHERE id = ?`, string(agenda), minutes, sessionID)
+	return err
+}
+
+// duplicate turns a unique key violation into ErrDuplicate.
+func duplicate(err error) error {
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// placeholders returns n comma-separated placeholders.
+func placeholders(n int) string {
+	return "?" + strings.Repeat(", ?", n-1)
+}
+
+// splitLevels parses a GROUP_CONCAT of levels.
+func splitLevels(list string, sep string) []int {
+	levels := []int{}
+	for _, l := range strings.Split(list, sep) {
+		if level, err := strconv.Atoi(l); err == nil {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+type mysqlAdminQuestions struct{ q querier }
+
+func (r mysqlAdminQuestions) List(ctx context.Context, level int, archived bool) ([]ManagedQuestion, error) {
+	filter := "q.archived_at IS NULL"
+	if archived {
+		filter = "TRUE"
+	}
+	query := `
+		SELECT q.id, q.question_text, q.weight, q.is_active, COALESCE(q.bank_id, ''), q.archived_at,
+		       GROUP_CONCAT(ql.level ORDER BY ql.level)
+		FROM survey_questions q
+		LEFT JOIN question_levels ql ON q.id = ql.question_id
+		WHERE ` + filter + `
+		GROUP BY q.id
+		ORDER BY q.created_at DESC`
+	var args []interface{}
+	if level != 0 {
+		query = `
+		SELECT q.id, q.question_text, q.weight, q.is_active, COALESCE(q.bank_id, ''), q.archived_at,
+		       GROUP_CONCAT(ql.level ORDER BY ql.level)
+		FROM question_levels lo
+		JOIN survey_questions q ON q.id = lo.question_id
+		JOIN question_levels ql ON q.id = ql.question_id
+		WHERE lo.level = ? AND ` + filter + `
+		GROUP BY q.id, lo.display_order
+		ORDER BY lo.display_order, q.created_at, q.id`
+		args = append(args, level)
+	}
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []ManagedQuestion
+	for rows.Next() {
+		var q ManagedQuestion
+		var archivedAt sql.NullTime
+		var levels sql.NullString
+		if err := rows.Scan(&q.ID, &q.Text, &q.Weight, &q.IsActive, &q.BankID, &archivedAt, &levels); err != nil {
+			return nil, err
+		}
+		q.ArchivedAt = archivedAt.Time
+		if levels.Valid {
+			q.Levels = splitLevels(levels.String, ",")
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (r mysqlAdminQuestions) Export(ctx context.Context, archived bool) ([]ManagedQuestion, error) {
+	filter := "q.archived_at IS NULL"
+	if archived {
+		filter = "TRUE"
+	}
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT COALESCE(q.external_key, q.id), q.id, q.question_text, q.weight,
+		       COALESCE(GROUP_CONCAT(ql.level ORDER BY ql.level SEPARATOR ';'), ''),
+		       COALESCE(q.bank_id, ''), COALESCE(b.name, ''), q.is_active
+		FROM survey_questions q
+		LEFT JOIN question_levels ql ON ql.question_id = q.id
+		LEFT JOIN question_banks b ON b.id = q.bank_id
+		WHERE `+filter+`
+		GROUP BY q.id, b.name
+		ORDER BY q.created_at, q.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []ManagedQuestion
+	for rows.Next() {
+		var q ManagedQuestion
+		var levels string
+		if err := rows.Scan(&q.ExternalKey, &q.ID, &q.Text, &q.Weight, &levels, &q.BankID, &q.BankName, &q.IsActive); err != nil {
+			return nil, err
+		}
+		q.Levels = splitLevels(levels, ";")
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (r mysqlAdminQuestions) Create(ctx context.Context, q ManagedQuestion) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO survey_questions (id, question_text, weight, bank_id)
+		VALUES (?, ?, ?, NULLIF(?, ''))`,
+		q.ID, q.Text, q.Weight, q.BankID)
+	return err
+}
+
+func (r mysqlAdminQuestions) Update(ctx context.Context, id string, c QuestionChange) error {
+	var set []string
+	var args []interface{}
+	if c.Text != nil {
+		set = append(set, "question_text = ?")
+		args = append(args, *c.Text)
+	}
+	if c.Weight != nil {
+		set = append(set, "weight = ?")
+		args = append(args, *c.Weight)
+	}
+	if c.IsActive != nil {
+		set = append(set, "is_active = ?")
+		args = append(args, *c.IsActive)
+		if *c.IsActive {
+			set = append(set, "archived_at = NULL")
+		}
+	}
+	if c.BankID != nil {
+		set = append(set, "bank_id = NULLIF(?, '')")
+		args = append(args, *c.BankID)
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	_, err := r.q.ExecContext(ctx, "UPDATE survey_questions SET "+strings.Join(set, ", ")+" WHERE id = ?",
+		append(args, id)...)
+	return err
+}
+
+func (r mysqlAdminQuestions) SetLevels(ctx context.Context, id string, levels []int) error {
+	query := "DELETE FROM question_levels WHERE question_id = ?"
+	args := []interface{}{id}
+	if len(levels) > 0 {
+		query += " AND level NOT IN (" + placeholders(len(levels)) + ")"
+		for _, level := range levels {
+			args = append(args, level)
+		}
+	}
+	if _, err := r.q.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	for _, level := range levels {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT IGNORE INTO question_levels (question_id, level, display_order)
+			SELECT ?, ?, COALESCE(MAX(display_order) + 1, 0) FROM question_levels WHERE level = ?`,
+			id, level, level)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// questionUsageQuery counts, per question, the sessions it was part of and
+// the responses it received. Sessions from before served questions were
+// recorded are counted from their results.
+const questionUsageQuery = `
+	SELECT q.id, q.question_text, q.archived_at,
+	       COALESCE(used.sessions, 0) AS sessions, COALESCE(answered.responses, 0) AS responses,
+	       used.last_used_at
+	FROM survey_questions q
+	LEFT JOIN (
+	    SELECT question_id, COUNT(DISTINCT session_id) AS sessions, MAX(at) AS last_used_at
+	    FROM (
+	        SELECT question_id, session_id, NULL AS at FROM session_questions
+	        UNION ALL SELECT question_id, session_id, served_at FROM served_questions
+	        UNION ALL SELECT question_id, session_id, created_at FROM survey_results
+	    ) uses
+	    GROUP BY question_id
+	) used ON used.question_id = q.id
+	LEFT JOIN (
+	    SELECT question_id, COUNT(DISTINCT session_id, responder_id) AS responses
+	    FROM survey_results
+	    GROUP BY question_id
+	) answered ON answered.question_id = q.id`
+
+func scanQuestionUsage(row scanner) (QuestionUsage, error) {
+	var u QuestionUsage
+	var archivedAt, lastUsed sql.NullTime
+	err := row.Scan(&u.ID, &u.Text, &archivedAt, &u.Sessions, &u.Responses, &lastUsed)
+	u.ArchivedAt, u.LastUsedAt = archivedAt.Time, lastUsed.Time
+	return u, notFound(err)
+}
+
+func (r mysqlAdminQuestions) Usage(ctx context.Context, id string) (QuestionUsage, error) {
+	return scanQuestionUsage(r.q.QueryRowContext(ctx, questionUsageQuery+" WHERE q.id = ? FOR UPDATE OF q", id))
+}
+
+func (r mysqlAdminQuestions) UsageList(ctx context.Context) ([]QuestionUsage, error) {
+	rows, err := r.q.QueryContext(ctx, questionUsageQuery+" ORDER BY sessions DESC, responses DESC, q.question_text")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []QuestionUsage
+	for rows.Next() {
+		u, err := scanQuestionUsage(rows)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+func (r mysqlAdminQuestions) Archive(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE survey_questions SET is_active = FALSE, archived_at = COALESCE(archived_at, NOW())
+		WHERE id = ?`, id)
+	return err
+}
+
+func (r mysqlAdminQuestions) Delete(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM survey_questions WHERE id = ?", id)
+	return err
+}
+
+func (r mysqlAdminQuestions) LevelIDs(ctx context.Context, level int) ([]string, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT question_id FROM question_levels WHERE level = ? FOR UPDATE", level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r mysqlAdminQuestions) SetOrder(ctx context.Context, level int, ids []string) error {
+	for i, id := range ids {
+		_, err := r.q.ExecContext(ctx,
+			"UPDATE question_levels SET display_order = ? WHERE question_id = ? AND level = ?", i, id, level)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlAdminQuestions) ImportedID(ctx context.Context, key string) (string, error) {
+	return importedID(ctx, r.q, "survey_questions", key)
+}
+
+func (r mysqlAdminQuestions) SaveImported(ctx context.Context, q QuestionImport) (string, error) {
+	var err error
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+		_, err = r.q.ExecContext(ctx, `
+			INSERT INTO survey_questions (id, external_key, question_text, weight, bank_id, is_active)
+			VALUES (?, ?, ?, ?, NULLIF(?, ''), COALESCE(?, TRUE))`,
+			q.ID, q.ExternalKey, q.Text, q.Weight, q.BankID, q.IsActive)
+	} else {
+		_, err = r.q.ExecContext(ctx, `
+			UPDATE survey_questions
+			SET external_key = ?, question_text = ?, weight = ?, bank_id = NULLIF(?, ''),
+			    is_active = COALESCE(?, is_active), archived_at = IF(COALESCE(?, FALSE), NULL, archived_at)
+			WHERE id = ?`,
+			q.ExternalKey, q.Text, q.Weight, q.BankID, q.IsActive, q.IsActive, q.ID)
+	}
+	return q.ID, duplicate(err)
+}
+
+// importedID returns the id of table's row with the external key, or, for a
+// row exported before it had one, the id key, locking it. It is empty when
+// there is none.
+func importedID(ctx context.Context, q querier, table, key string) (string, error) {
+	var id string
+	err := q.QueryRowContext(ctx,
+		"SELECT id FROM "+table+" WHERE external_key = ? OR (external_key IS NULL AND id = ?) FOR UPDATE", key, key).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+type mysqlBanks struct{ q querier }
+
+func (r mysqlBanks) List(ctx context.Context) ([]QuestionBank, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT b.id, b.name, COALESCE(b.description, ''), COUNT(q.id)
+		FROM question_banks b
+		LEFT JOIN survey_questions q ON q.bank_id = b.id AND q.is_active = TRUE
+		GROUP BY b.id
+		ORDER BY b.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var banks []QuestionBank
+	for rows.Next() {
+		var b QuestionBank
+		if err := rows.Scan(&b.ID, &b.Name, &b.Description, &b.Questions); err != nil {
+			return nil, err
+		}
+		banks = append(banks, b)
+	}
+	return banks, rows.Err()
+}
+
+func (r mysqlBanks) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := r.q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM question_banks WHERE id = ?)", id).Scan(&exists)
+	return exists, err
+}
+
+func (r mysqlBanks) IDByName(ctx context.Context, name string) (string, error) {
+	var id string
+	err := r.q.QueryRowContext(ctx, "SELECT id FROM question_banks WHERE name = ?", name).Scan(&id)
+	return id, notFound(err)
+}
+
+func (r mysqlBanks) Create(ctx context.Context, b QuestionBank) error {
+	_, err := r.q.ExecContext(ctx,
+		"INSERT INTO question_banks (id, name, description) VALUES (?, ?, ?)", b.ID, b.Name, b.Description)
+	return duplicate(err)
+}
+
+func (r mysqlBanks) Update(ctx context.Context, b QuestionBank) error {
+	res, err := r.q.ExecContext(ctx,
+		"UPDATE question_banks SET name = ?, description = ? WHERE id = ?", b.Name, b.Description, b.ID)
+	if err != nil {
+		return duplicate(err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	// Nothing changed, either because the bank is missing or because it
+	// already had this name and description.
+	exists, err := r.Exists(ctx, b.ID)
+	if err == nil && !exists {
+		err = ErrNotFound
+	}
+	return err
+}
+
+func (r mysqlBanks) Delete(ctx context.Context, id string) error {
+	res, err := r.q.ExecContext(ctx, "DELETE FROM question_banks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	_, err = r.q.ExecContext(ctx, "UPDATE survey_questions SET bank_id = NULL WHERE bank_id = ?", id)
+	return err
+}
+
+func (r mysqlBanks) TemplateLevels(ctx context.Context, id string) ([]int, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT level FROM question_templates WHERE bank_id = ? ORDER BY level", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []int
+	for rows.Next() {
+		var level int
+		if err := rows.Scan(&level); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, rows.Err()
+}
+
+func (r mysqlBanks) Template(ctx context.Context, level int) ([]BankDraw, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT t.bank_id, t.draw_count
+		FROM question_templates t
+		JOIN question_banks b ON b.id = t.bank_id
+		WHERE t.level = ?
+		ORDER BY b.name`, level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var draws []BankDraw
+	for rows.Next() {
+		var d BankDraw
+		if err := rows.Scan(&d.BankID, &d.Count); err != nil {
+			return nil, err
+		}
+		draws = append(draws, d)
+	}
+	return draws, rows.Err()
+}
+
+func (r mysqlBanks) Available(ctx context.Context, bankID string, level int) (int, error) {
+	var available int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM survey_questions q
+		JOIN question_levels ql ON ql.question_id = q.id
+		WHERE q.bank_id = ? AND ql.level = ? AND q.is_active = TRUE`, bankID, level).Scan(&available)
+	return available, err
+}
+
+func (r mysqlBanks) SetTemplate(ctx context.Context, level int, draws []BankDraw) error {
+	if _, err := r.q.ExecContext(ctx, "DELETE FROM question_templates WHERE level = ?", level); err != nil {
+		return err
+	}
+	for _, d := range draws {
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO question_templates (level, bank_id, draw_count) VALUES (?, ?, ?)", level, d.BankID, d.Count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type mysqlAdminTopics struct{ q querier }
+
+func (r mysqlAdminTopics) List(ctx context.Context, f TopicFilter) ([]ManagedTopic, int, error) {
+	where := []string{"TRUE"}
+	var args []interface{}
+	if f.Level != 0 {
+		where = append(where, "t.level = ?")
+		args = append(args, f.Level)
+	}
+	if !f.Inactive {
+		where = append(where, "t.is_active = TRUE")
+	}
+	if f.Category != "" {
+		where = append(where, "t.category = ?")
+		args = append(args, f.Category)
+	}
+	if f.Difficulty != "" {
+		where = append(where, "t.difficulty = ?")
+		args = append(args, f.Difficulty)
+	}
+	if f.Tag != "" {
+		where = append(where, "EXISTS(SELECT 1 FROM topic_tags tt WHERE tt.topic_id = t.id AND tt.tag = ?)")
+		args = append(args, f.Tag)
+	}
+	order := "t.level, t.created_at DESC, t.id"
+	var orderArgs []interface{}
+	if f.Query != "" {
+		where = append(where, "MATCH(t.topic_text) AGAINST(? IN NATURAL LANGUAGE MODE)")
+		args = append(args, f.Query)
+		order = "MATCH(t.topic_text) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, " + order
+		orderArgs = append(orderArgs, f.Query)
+	}
+	limit := ""
+	var limitArgs []interface{}
+	if f.Limit > 0 {
+		limit = " LIMIT ? OFFSET ?"
+		limitArgs = []interface{}{f.Limit, f.Offset}
+	}
+
+	filter := " WHERE " + strings.Join(where, " AND ")
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM gd_topics t"+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT t.id, t.level, t.topic_text, t.prep_materials, t.is_active,
+		       COALESCE(t.category, ''), COALESCE(t.difficulty, ''), COALESCE(t.source, ''),
+		       COALESCE(u.uses, 0), fb.rating
+		FROM gd_topics t
+		LEFT JOIN (
+			SELECT topic_id, COUNT(*) AS uses FROM gd_sessions
+			WHERE topic_id IS NOT NULL GROUP BY topic_id
+		) u ON u.topic_id = t.id
+		LEFT JOIN (
+			SELECT s.topic_id, AVG(f.rating) AS rating
+			FROM session_feedback f
+			JOIN gd_sessions s ON s.id = f.session_id
+			WHERE s.topic_id IS NOT NULL
+			GROUP BY s.topic_id
+		) fb ON fb.topic_id = t.id`+filter+`
+		ORDER BY `+order+limit,
+		append(append(append([]interface{}{}, args...), orderArgs...), limitArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var topics []ManagedTopic
+	for rows.Next() {
+		t := ManagedTopic{Tags: []string{}}
+		var rating sql.NullFloat64
+		err := rows.Scan(&t.ID, &t.Level, &t.Text, &t.PrepMaterials, &t.IsActive,
+			&t.Category, &t.Difficulty, &t.Source, &t.Uses, &rating)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.AverageRating, t.Rated = rating.Float64, rating.Valid
+		topics = append(topics, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	return topics, total, r.addTags(ctx, topics)
+}
+
+// addTags fills in the tags of topics.
+func (r mysqlAdminTopics) addTags(ctx context.Context, topics []ManagedTopic) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	ids := make([]interface{}, 0, len(topics))
+	byID := map[string]int{}
+	for i, t := range topics {
+		ids = append(ids, t.ID)
+		byID[t.ID] = i
+	}
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT topic_id, tag FROM topic_tags
+		WHERE topic_id IN (`+placeholders(len(ids))+`)
+		ORDER BY tag`, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var topicID, tag string
+		if err := rows.Scan(&topicID, &tag); err != nil {
+			return err
+		}
+		topics[byID[topicID]].Tags = append(topics[byID[topicID]].Tags, tag)
+	}
+	return rows.Err()
+}
+
+func (r mysqlAdminTopics) Export(ctx context.Context) ([]ManagedTopic, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT COALESCE(t.external_key, t.id), t.id, t.level, t.topic_text, COALESCE(t.category, ''),
+		       COALESCE(GROUP_CONCAT(tt.tag ORDER BY tt.tag SEPARATOR ';'), ''),
+		       COALESCE(t.difficulty, ''), COALESCE(t.source, ''), t.is_active
+		FROM gd_topics t
+		LEFT JOIN topic_tags tt ON tt.topic_id = t.id
+		GROUP BY t.id
+		ORDER BY t.level, t.created_at, t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var topics []ManagedTopic
+	for rows.Next() {
+		var t ManagedTopic
+		var tags string
+		if err := rows.Scan(&t.ExternalKey, &t.ID, &t.Level, &t.Text, &t.Category, &tags, &t.Difficulty, &t.Source, &t.IsActive); err != nil {
+			return nil, err
+		}
+		t.Tags = []string{}
+		for _, tag := range strings.Split(tags, ";") {
+			if tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+func (r mysqlAdminTopics) AtLevel(ctx context.Context, level int, excludeID string) ([]Topic, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, topic_text FROM gd_topics WHERE level = ? AND id <> ?", level, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var topics []Topic
+	for rows.Next() {
+		t := Topic{Level: level}
+		if err := rows.Scan(&t.ID, &t.Text); err != nil {
+			return nil, err
+		}
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+func (r mysqlAdminTopics) Create(ctx context.Context, t ManagedTopic) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO gd_topics (id, level, topic_text, prep_materials, category, difficulty, source, is_active)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?)`,
+		t.ID, t.Level, t.Text, t.PrepMaterials, t.Category, t.Difficulty, t.Source, t.IsActive)
+	return duplicate(err)
+}
+
+func (r mysqlAdminTopics) Update(ctx context.Context, t ManagedTopic) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE gd_topics
+		SET level = ?, topic_text = ?, prep_materials = ?, is_active = ?,
+		    category = NULLIF(?, ''), difficulty = NULLIF(?, ''), source = NULLIF(?, '')
+		WHERE id = ?`,
+		t.Level, t.Text, t.PrepMaterials, t.IsActive, t.Category, t.Difficulty, t.Source, t.ID)
+	return duplicate(err)
+}
+
+func (r mysqlAdminTopics) SetTags(ctx context.Context, id string, tags []string) error {
+	if _, err := r.q.ExecContext(ctx, "DELETE FROM topic_tags WHERE topic_id = ?", id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := r.q.ExecContext(ctx, "INSERT INTO topic_tags (topic_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlAdminTopics) Deactivate(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, "UPDATE gd_topics SET is_active = FALSE WHERE id = ?", id)
+	return err
+}
+
+func (r mysqlAdminTopics) ImportedID(ctx context.Context, key string) (string, error) {
+	return importedID(ctx, r.q, "gd_topics", key)
+}
+
+func (r mysqlAdminTopics) SaveImported(ctx context.Context, t TopicImport) (string, error) {
+	var err error
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+		_, err = r.q.ExecContext(ctx, `
+			INSERT INTO gd_topics (id, external_key, level, topic_text, category, difficulty, source, is_active)
+			VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), COALESCE(?, TRUE))`,
+			t.ID, t.ExternalKey, t.Level, t.Text, t.Category, t.Difficulty, t.Source, t.IsActive)
+	} else {
+		_, err = r.q.ExecContext(ctx, `
+			UPDATE gd_topics
+			SET external_key = ?, level = ?, topic_text = ?, category = NULLIF(?, ''),
+			    difficulty = NULLIF(?, ''), source = NULLIF(?, ''), is_active = COALESCE(?, is_active)
+			WHERE id = ?`,
+			t.ExternalKey, t.Level, t.Text, t.Category, t.Difficulty, t.Source, t.IsActive, t.ID)
+	}
+	return t.ID, duplicate(err)
+}
+
+type mysqlAdminMaterials struct{ q querier }
+
+func (r mysqlAdminMaterials) List(ctx context.Context, topicID string) ([]PrepMaterial, error) {
+	return mysqlTopics{r.q}.Materials(ctx, topicID)
+}
+
+func (r mysqlAdminMaterials) Get(ctx context.Context, id string) (PrepMaterial, error) {
+	m, err := scanPrepMaterial(r.q.QueryRowContext(ctx,
+		"SELECT "+prepMaterialColumns+" FROM prep_materials WHERE id = ?", id))
+	return m, notFound(err)
+}
+
+func (r mysqlAdminMaterials) Append(ctx context.Context, m PrepMaterial) (int, error) {
+	var topicID string
+	err := r.q.QueryRowContext(ctx, "SELECT id FROM gd_topics WHERE id = ? FOR UPDATE", m.TopicID).Scan(&topicID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+
+	err = r.q.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(display_order) + 1, 0) FROM prep_materials WHERE topic_id = ?", m.TopicID).Scan(&m.DisplayOrder)
+	if err != nil {
+		return 0, err
+	}
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO prep_materials
+		(id, topic_id, kind, title, body, url, file_key, file_name, content_type, size_bytes,
+		 visible_from, visible_until, display_order)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0),
+		        ?, NULLIF(?, ''), ?)`,
+		m.ID, m.TopicID, m.Kind, m.Title, m.Body, m.URL, m.FileKey, m.FileName, m.ContentType, m.Size,
+		m.VisibleFrom, m.VisibleUntil, m.DisplayOrder)
+	return m.DisplayOrder, err
+}
+
+func (r mysqlAdminMaterials) Update(ctx context.Context, m PrepMaterial) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE prep_materials
+		SET title = ?, body = NULLIF(?, ''), url = NULLIF(?, ''),
+		    visible_from = ?, visible_until = NULLIF(?, ''), display_order = ?
+		WHERE id = ?`,
+		m.Title, m.Body, m.URL, m.VisibleFrom, m.VisibleUntil, m.DisplayOrder, m.ID)
+	return err
+}
+
+func (r mysqlAdminMaterials) Delete(ctx context.Context, id string) (string, error) {
+	var fileKey sql.NullString
+	err := r.q.QueryRowContext(ctx, "SELECT file_key FROM prep_materials WHERE id = ?", id).Scan(&fileKey)
+	if err != nil {
+		return "", notFound(err)
+	}
+	_, err = r.q.ExecContext(ctx, "DELETE FROM prep_materials WHERE id = ?", id)
+	return fileKey.String, err
+}
+
+type mysqlRankingConfigs struct{ q querier }
+
+func scanRankingConfig(row scanner) (RankingConfig, error) {
+	var c RankingConfig
+	var points []byte
+	err := row.Scan(&c.ID, &c.Level, &c.Version, &points, &c.Method, &c.EffectiveFrom, &c.IsActive)
+	if err != nil {
+		return c, err
+	}
+	return c, json.Unmarshal(points, &c.Points)
+}
+
+func (r mysqlRankingConfigs) List(ctx context.Context, level int) ([]RankingConfig, error) {
+	query := "SELECT " + configColumns + ", is_active FROM ranking_points_config ORDER BY level, version DESC"
+	var args []interface{}
+	if level != 0 {
+		query = "SELECT " + configColumns + ", is_active FROM ranking_points_config WHERE level = ? ORDER BY version DESC"
+		args = append(args, level)
+	}
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []RankingConfig
+	for rows.Next() {
+		c, err := scanRankingConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	return configs, rows.Err()
+}
+
+func (r mysqlRankingConfigs) Get(ctx context.Context, id string) (RankingConfig, error) {
+	c, err := scanRankingConfig(r.q.QueryRowContext(ctx,
+		"SELECT "+configColumns+", is_active FROM ranking_points_config WHERE id = ?", id))
+	return c, notFound(err)
+}
+
+func (r mysqlRankingConfigs) NextVersion(ctx context.Context, level int) (int, error) {
+	var version int
+	err := r.q.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version), 0) + 1 FROM ranking_points_config WHERE level = ? FOR UPDATE",
+		level,
+	).Scan(&version)
+	return version, err
+}
+
+func (r mysqlRankingConfigs) Create(ctx context.Context, c RankingConfig, createdBy string) error {
+	points, err := json.Marshal(c.Points)
+	if err != nil {
+		return err
+	}
+	// The place columns still back configurations read by older code.
+	places := make([]float64, 3)
+	copy(places, c.Points)
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO ranking_points_config
+		(id, points, first_place_points, second_place_points, third_place_points, level, consensus_method,
+		 version, effective_from, is_active, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE, ?)`,
+		c.ID, points, places[0], places[1], places[2], c.Level,
+		c.Method, c.Version, c.EffectiveFrom, createdBy)
+	return err
+}
+
+func (r mysqlRankingConfigs) SetActive(ctx context.Context, id string, active bool) error {
+	var level int
+	err := r.q.QueryRowContext(ctx, "SELECT level FROM ranking_points_config WHERE id = ? FOR UPDATE", id).Scan(&level)
+	if err != nil {
+		return notFound(err)
+	}
+	if !active {
+		_, err = r.q.ExecContext(ctx, "UPDATE ranking_points_config SET is_active = FALSE, updated_at = NOW() WHERE id = ?", id)
+		return err
+	}
+
+	// The others are switched off first so the unique_active_level index
+	// holds.
+	_, err = r.q.ExecContext(ctx,
+		"UPDATE ranking_points_config SET is_active = FALSE, updated_at = NOW() WHERE level = ? AND id <> ? AND is_active = TRUE",
+		level, id,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx,
+		"UPDATE ranking_points_config SET is_active = TRUE, activated_at = COALESCE(activated_at, NOW()), updated_at = NOW() WHERE id = ?",
+		id,
+	)
+	return err
+}
+
+func (r mysqlRankingConfigs) Due(ctx context.Context, now time.Time) ([]RankingConfig, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, level FROM ranking_points_config
+		WHERE activated_at IS NULL AND effective_from <= ?
+		ORDER BY level, version`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []RankingConfig
+	for rows.Next() {
+		var c RankingConfig
+		if err := rows.Scan(&c.ID, &c.Level); err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	return configs, rows.Err()
+}
+
+func (r mysqlRankingConfigs) Used(ctx context.Context, id string) (bool, error) {
+	var used bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM gd_sessions WHERE ranking_config_id = ?)
+		    OR EXISTS(SELECT 1 FROM session_results WHERE config_id = ?)`,
+		id, id,
+	).Scan(&used)
+	return used, err
+}
+
+func (r mysqlRankingConfigs) Delete(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM ranking_points_config WHERE id = ?", id)
+	return err
+}
+
+type mysqlRubrics struct{ q querier }
+
+func (r mysqlRubrics) Get(ctx context.Context, level int) (Rubric, error) {
+	rubric, err := mysqlResults{r.q}.Rubric(ctx, level)
+	if err == ErrNotFound {
+		return Rubric{Level: level}, nil
+	}
+	return rubric, err
+}
+
+func (r mysqlRubrics) Criteria(ctx context.Context, level int) ([]RubricCriterion, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, name, weight, max_score FROM rubric_criteria
+		WHERE level = ? AND is_active = TRUE
+		ORDER BY display_order, id`, level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var criteria []RubricCriterion
+	for rows.Next() {
+		var c RubricCriterion
+		if err := rows.Scan(&c.ID, &c.Name, &c.Weight, &c.MaxScore); err != nil {
+			return nil, err
+		}
+		criteria = append(criteria, c)
+	}
+	return criteria, rows.Err()
+}
+
+func (r mysqlRubrics) CriterionIDs(ctx context.Context, level int) ([]string, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id FROM rubric_criteria WHERE level = ?", level)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r mysqlRubrics) Save(ctx context.Context, rubric Rubric) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO rubrics (level, moderator_weight) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE moderator_weight = VALUES(moderator_weight)`,
+		rubric.Level, rubric.ModeratorWeight)
+	return err
+}
+
+func (r mysqlRubrics) Retire(ctx context.Context, level int) error {
+	_, err := r.q.ExecContext(ctx, "UPDATE rubric_criteria SET is_active = FALSE WHERE level = ?", level)
+	return err
+}
+
+func (r mysqlRubrics) AddCriterion(ctx context.Context, level, order int, c RubricCriterion) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO rubric_criteria (id, level, name, weight, max_score, display_order)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		c.ID, level, c.Name, c.Weight, c.MaxScore, order)
+	return err
+}
+
+func (r mysqlRubrics) UpdateCriterion(ctx context.Context, order int, c RubricCriterion) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE rubric_criteria
+		SET name = ?, weight = ?, max_score = ?, display_order = ?, is_active = TRUE
+		WHERE id = ?`,
+		c.Name, c.Weight, c.MaxScore, order, c.ID)
+	return err
+}
+
+func (r mysqlRubrics) MarkingSession(ctx context.Context, sessionID string) (int, bool, error) {
+	var level int
+	var finalized bool
+	err := r.q.QueryRowContext(ctx,
+		"SELECT level, finalized_at IS NOT NULL FROM gd_sessions WHERE id = ? FOR UPDATE",
+		sessionID,
+	).Scan(&level, &finalized)
+	return level, finalized, notFound(err)
+}
+
+func (r mysqlRubrics) Participant(ctx context.Context, sessionID, studentID string) (bool, error) {
+	var isParticipant bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM session_participants
+		              WHERE session_id = ? AND student_id = ? AND is_dummy = FALSE)`,
+		sessionID, studentID,
+	).Scan(&isParticipant)
+	return isParticipant, err
+}
+
+func (r mysqlRubrics) SaveMark(ctx context.Context, sessionID, studentID, criterionID string, score float64, moderatorID string) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO moderator_scores (session_id, student_id, criterion_id, score, moderator_id)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE score = VALUES(score), moderator_id = VALUES(moderator_id)`,
+		sessionID, studentID, criterionID, score, moderatorID)
+	return err
+}
+
+func (r mysqlRubrics) SaveFeedback(ctx context.Context, sessionID, studentID, feedback, moderatorID string) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO moderator_feedback (session_id, student_id, feedback, moderator_id)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE feedback = VALUES(feedback), moderator_id = VALUES(moderator_id)`,
+		sessionID, studentID, feedback, moderatorID)
+	return err
+}
+
+func (r mysqlRubrics) Reviews(ctx context.Context, sessionID string) ([]ModeratorReview, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT su.id, su.full_name, ms.criterion_id, ms.score, COALESCE(mf.feedback, '')
+		FROM session_participants sp
+		JOIN student_users su ON su.id = sp.student_id
+		LEFT JOIN moderator_scores ms ON ms.session_id = sp.session_id AND ms.student_id = sp.student_id
+		LEFT JOIN moderator_feedback mf ON mf.session_id = sp.session_id AND mf.student_id = sp.student_id
+		WHERE sp.session_id = ? AND sp.is_dummy = FALSE
+		ORDER BY su.full_name, su.id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []ModeratorReview
+	for rows.Next() {
+		var studentID, name, feedback string
+		var criterionID sql.NullString
+		var score sql.NullFloat64
+		if err := rows.Scan(&studentID, &name, &criterionID, &score, &feedback); err != nil {
+			return nil, err
+		}
+		if n := len(reviews); n == 0 || reviews[n-1].StudentID != studentID {
+			reviews = append(reviews, ModeratorReview{StudentID: studentID, Name: name, Scores: map[string]float64{}, Feedback: feedback})
+		}
+		if criterionID.Valid {
+			reviews[len(reviews)-1].Scores[criterionID.String] = score.Float64
+		}
+	}
+	return reviews, rows.Err()
+}
+
+type mysqlFlags struct{ q querier }
+
+func (r mysqlFlags) Queue(ctx context.Context, status, sessionID string) ([]QueuedFlag, error) {
+	query := `
+		SELECT f.id, f.session_id, s.level, f.kind, f.responder_ids, COALESCE(f.detail, ''), f.status,
+		       COALESCE(f.review_note, ''), f.created_at, f.reviewed_at
+		FROM collusion_flags f
+		JOIN gd_sessions s ON s.id = f.session_id
+		WHERE f.status = ?`
+	args := []interface{}{status}
+	if sessionID != "" {
+		query += " AND f.session_id = ?"
+		args = append(args, sessionID)
+	}
+	rows, err := r.q.QueryContext(ctx, query+" ORDER BY f.created_at, f.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []QueuedFlag
+	for rows.Next() {
+		var f QueuedFlag
+		var responders string
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&f.ID, &f.SessionID, &f.Level, &f.Kind, &responders, &f.Detail, &f.Status,
+			&f.ReviewNote, &f.CreatedAt, &reviewedAt); err != nil {
+			return nil, err
+		}
+		f.ResponderIDs = strings.Split(responders, ",")
+		f.ReviewedAt = reviewedAt.Time
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+func (r mysqlFlags) Lock(ctx context.Context, id string) (CollusionFlag, error) {
+	f := CollusionFlag{ID: id}
+	var responders string
+	err := r.q.QueryRowContext(ctx,
+		"SELECT session_id, kind, responder_ids, COALESCE(detail, ''), status FROM collusion_flags WHERE id = ? FOR UPDATE", id,
+	).Scan(&f.SessionID, &f.Kind, &responders, &f.Detail, &f.Status)
+	if err != nil {
+		return f, notFound(err)
+	}
+	f.ResponderIDs = strings.Split(responders, ",")
+	return f, nil
+}
+
+func (r mysqlFlags) Void(ctx context.Context, sessionID string, responderIDs, questionIDs []string) error {
+	query := "UPDATE survey_results SET voided = TRUE WHERE session_id = ? AND responder_id IN (" +
+		placeholders(len(responderIDs)) + ")"
+	args := []interface{}{sessionID}
+	for _, id := range responderIDs {
+		args = append(args, id)
+	}
+	if len(questionIDs) > 0 {
+		query += " AND question_id IN (" + placeholders(len(questionIDs)) + ")"
+		for _, id := range questionIDs {
+			args = append(args, id)
+		}
+	}
+	_, err := r.q.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r mysqlFlags) Review(ctx context.Context, id, status, reviewerID, note string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE collusion_flags
+		SET status = ?, reviewed_by = ?, reviewed_at = NOW(), review_note = ?
+		WHERE id = ?`,
+		status, reviewerID, note, id)
+	return err
+}
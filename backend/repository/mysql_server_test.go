@@ -0,0 +1,76 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"gd/database"
+	"gd/repository"
+)
+
+// openServer connects to the MySQL server named by GD_TEST_DB_URL the way
+// main does, creating the schema, and skips the test when it is unset. Point
+// it at a scratch database: tests add rows and remove only their own.
+func openServer(t *testing.T) repository.Store {
+	t.Helper()
+	dsn := os.Getenv("GD_TEST_DB_URL")
+	if dsn == "" {
+		t.Skip("GD_TEST_DB_URL is not set")
+	}
+	if err := database.Initialize(dsn, database.PoolConfig{MaxOpenConns: 4, MaxIdleConns: 2}); err != nil {
+		t.Fatal(err)
+	}
+	db := database.GetDB()
+	t.Cleanup(func() { db.Close() })
+	return repository.NewMySQLStore(db)
+}
+
+func TestMySQLSessionLookups(t *testing.T) {
+	store := openServer(t)
+	ctx := context.Background()
+	db := database.GetDB()
+
+	venueID, groupID := uuid.NewString(), uuid.NewString()
+	_, err := db.Exec(`
+		INSERT INTO venues (id, name, capacity, level, qr_secret, session_timing, table_details)
+		VALUES (?, 'Test hall', 10, 2, 'secret', '10:00-11:00', 'T1')`, venueID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM gd_sessions WHERE venue_id = ?", venueID)
+		db.Exec("DELETE FROM venues WHERE id = ?", venueID)
+	})
+
+	before := time.Now().Add(-time.Minute)
+	created := repository.Session{ID: uuid.NewString(), VenueID: venueID, Status: "pending", QRGroupID: groupID}
+	if err := store.Sessions().Create(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+
+	lookups := map[string]func() (repository.Session, error){
+		"Get":                func() (repository.Session, error) { return store.Sessions().Get(ctx, created.ID) },
+		"FindPendingByVenue": func() (repository.Session, error) { return store.Sessions().FindPendingByVenue(ctx, venueID) },
+		"FindOpenByQRGroup":  func() (repository.Session, error) { return store.Sessions().FindOpenByQRGroup(ctx, venueID, groupID) },
+	}
+	for name, lookup := range lookups {
+		s, err := lookup()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if s.ID != created.ID || s.Level != 2 || s.QRGroupID != groupID {
+			t.Errorf("%s = %+v, want the created level 2 session", name, s)
+		}
+		if s.PrepMinutes != 1 || s.DiscussionMinutes != 1 || s.SurveyMinutes != 1 {
+			t.Errorf("%s: agenda %d/%d/%d, want the one-minute defaults", name, s.PrepMinutes, s.DiscussionMinutes, s.SurveyMinutes)
+		}
+		if s.StartTime.Before(before) || s.EndTime.Sub(s.StartTime) != time.Hour || s.StartTime.Location() != time.UTC {
+			t.Errorf("%s: session runs %v to %v, want an hour from now in UTC", name, s.StartTime, s.EndTime)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+// countingScanner records how many destinations a scan was given.
+type countingScanner struct{ n int }
+
+func (c *countingScanner) Scan(dest ...any) error {
+	c.n = len(dest)
+	return nil
+}
+
+// columnCount counts the comma-separated expressions of a select list,
+// ignoring commas within parentheses and quotes.
+func columnCount(list string) int {
+	n, depth, quoted := 1, 0, false
+	for _, r := range list {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			n++
+		}
+	}
+	return n
+}
+
+func TestScanSessionMatchesColumns(t *testing.T) {
+	if got := columnCount("a, COALESCE(b, ''), c"); got != 3 {
+		t.Fatalf("columnCount = %d, want 3", got)
+	}
+	var c countingScanner
+	if _, err := scanSession(&c); err != nil {
+		t.Fatal(err)
+	}
+	if want := columnCount(strings.TrimSpace(sessionColumns)); c.n != want {
+		t.Errorf("scanSession scans %d destinations, sessionColumns has %d columns", c.n, want)
+	}
+}
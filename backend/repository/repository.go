@@ -0,0 +1,526 @@
+// Package repository defines the data access layer used by the controllers.
+//
+// Each aggregate (sessions, participants, surveys, results, venues, QR codes
+// and students) is exposed through a small interface so handlers can be exercised
+// against the in-memory implementation in gd/repository/memory instead of a
+// live MySQL instance.
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// MaxLevel is the last GD level; qualifying there does not promote.
+const MaxLevel = 3
+
+var (
+	// ErrNotFound is returned when a lookup matches no rows.
+	ErrNotFound = errors.New("repository: not found")
+	// ErrDuplicate is returned when an insert violates a unique key.
+	ErrDuplicate = errors.New("repository: duplicate entry")
+)
+
+type Student struct {
+	ID         string
+	Email      string
+	Name       string
+	PhotoURL   string
+	Department string
+	Level      int
+	IsActive   bool
+}
+
+type Venue struct {
+	ID            string
+	Name          string
+	Capacity      int
+	Level         int
+	IsActive      bool
+	SessionTiming string
+	TableDetails  string
+	// QRSecret and CreatedBy are recorded when an admin creates the venue.
+	QRSecret  string
+	CreatedBy string
+	// Availability is the raw availability JSON sessions are generated
+	// from, or nil. Only the admin repositories read and write it.
+	Availability []byte
+}
+
+type QRCode struct {
+	ID           string
+	VenueID      string
+	QRData       string
+	MaxCapacity  int
+	CurrentUsage int
+	IsActive     bool
+	QRGroupID    string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+type Session struct {
+	ID        string
+	VenueID   string
+	Level     int
+	Status    string
+	QRGroupID string
+	StartTime time.Time
+	EndTime   time.Time
+	// Finalized is set once the session's results have been snapshotted into
+	// session_results; they no longer change after that.
+	Finalized bool
+	// ConfigID is the ranking points version pinned to the session when it
+	// was first scored, or empty before that.
+	ConfigID string
+	// Topic is the text every participant discusses, chosen once for the
+	// session; TopicID is the gd_topics row it came from. Both are empty
+	// until the topic is chosen.
+	Topic   string
+	TopicID string
+	// PrepMinutes, DiscussionMinutes and SurveyMinutes are the agenda's
+	// phase lengths.
+	PrepMinutes       int
+	DiscussionMinutes int
+	SurveyMinutes     int
+}
+
+// Prep material kinds.
+const (
+	MaterialLink            = "link"
+	MaterialKeyPoint        = "key_point"
+	MaterialArgumentFor     = "argument_for"
+	MaterialArgumentAgainst = "argument_against"
+	MaterialAttachment      = "attachment"
+)
+
+// MaterialKinds lists the prep material kinds.
+var MaterialKinds = []string{MaterialLink, MaterialKeyPoint, MaterialArgumentFor, MaterialArgumentAgainst, MaterialAttachment}
+
+// MaterialPhases are the points of a student's session, in order, at which
+// prep materials can be revealed or hidden: once booked, after checking in
+// for the prep phase, in the discussion and in the survey.
+var MaterialPhases = []string{"booked", "prep", "discussion", "survey"}
+
+// PrepMaterial is one item of a topic's preparation: a reading link, a key
+// point, an argument for or against, or an attached file.
+type PrepMaterial struct {
+	ID      string
+	TopicID string
+	Kind    string
+	Title   string
+	Body    string
+	URL     string
+	// FileKey locates an attachment in the storage backend.
+	FileKey     string
+	FileName    string
+	ContentType string
+	Size        int64
+	// VisibleFrom is the phase the material is revealed in. VisibleUntil is
+	// the last phase it is shown in, or empty to keep showing it.
+	VisibleFrom  string
+	VisibleUntil string
+	DisplayOrder int
+}
+
+// VisibleIn reports whether the material is shown to a student in phase.
+func (m PrepMaterial) VisibleIn(phase string) bool {
+	at := slices.Index(MaterialPhases, phase)
+	if at < slices.Index(MaterialPhases, m.VisibleFrom) {
+		return false
+	}
+	return m.VisibleUntil == "" || at <= slices.Index(MaterialPhases, m.VisibleUntil)
+}
+
+// Topic is a discussion topic from the level's pool.
+type Topic struct {
+	ID    string
+	Level int
+	Text  string
+	// PrepMaterials is the raw prep_materials JSON, or nil.
+	PrepMaterials []byte
+	// Uses counts the sessions that have discussed the topic.
+	Uses int
+}
+
+// Participant is a non-dummy member of a session together with the profile
+// fields shown on the results screen.
+type Participant struct {
+	StudentID  string
+	Name       string
+	PhotoURL   string
+	Department string
+}
+
+type Question struct {
+	ID     string
+	Text   string
+	Weight float64
+	// BankID is the question bank, by competency, the question belongs to,
+	// or empty if it has none.
+	BankID string
+}
+
+// SurveyResult is one ranking given by a responder to a student for a question.
+type SurveyResult struct {
+	SessionID     string
+	StudentID     string
+	ResponderID   string
+	QuestionID    string
+	Rank          int
+	Score         float64
+	WeightedScore float64
+	PenaltyPoints float64
+	IsBiased      bool
+	// Voided responses were struck out by an admin reviewing a collusion
+	// flag. They still count as answered but score nothing.
+	Voided bool
+}
+
+// Kinds of CollusionFlag.
+const (
+	// FlagFirstPlaceRing marks responders who ranked each other first in a
+	// cycle that no other responder supported.
+	FlagFirstPlaceRing = "first_place_ring"
+	// FlagIdenticalRankings marks a responder who gave the same ranking on
+	// every question.
+	FlagIdenticalRankings = "identical_rankings"
+)
+
+// Review states of a CollusionFlag.
+const (
+	FlagPending   = "pending"
+	FlagVoided    = "voided"
+	FlagDismissed = "dismissed"
+)
+
+// CollusionFlag marks responders whose rankings look coordinated. A session
+// with pending flags is not finalized until an admin voids the responses
+// or dismisses the flag.
+type CollusionFlag struct {
+	ID        string
+	SessionID string
+	Kind      string
+	// ResponderIDs lists the responders involved; a ring is listed in the
+	// order each ranked the next first.
+	ResponderIDs []string
+	Detail       string
+	Status       string
+}
+
+// Bias is the penalty one responder earned for how far their ranking of a
+// question strayed from the consensus. It is kept apart from the scores the
+// ranked students received.
+type Bias struct {
+	SessionID   string
+	ResponderID string
+	QuestionID  string
+	// Method is the consensus method the ranking was compared against.
+	Method string
+	// Deviation sums the places each ranked student was off the consensus.
+	Deviation     int
+	PenaltyPoints float64
+	IsBiased      bool
+}
+
+// ScoreSummary aggregates the current-session results received by a student
+// together with the penalties the student earned: bias penalties as a
+// responder and timeout penalties for questions left unanswered. Every
+// participant has one, whether or not anyone ranked them.
+type ScoreSummary struct {
+	StudentID       string
+	TotalScore      float64
+	BiasPenalty     float64
+	TimeoutPenalty  float64
+	BiasedQuestions int
+	FirstPlaces     int
+}
+
+// ScoringConfig is one immutable version of a level's ranking points
+// configuration. ID is empty for the built-in defaults.
+type ScoringConfig struct {
+	ID      string
+	Level   int
+	Version int
+	// Points awards each rank, best first; its length is the ranking depth.
+	Points []float64
+	// Method is the consensus method; see gd/scoring.
+	Method        string
+	EffectiveFrom time.Time
+}
+
+// QualificationRule decides who moves on from a level: students ranked in
+// the first Places whose penalties stay below PenaltyThreshold. A zero
+// threshold disables the penalty check.
+type QualificationRule struct {
+	Places           int
+	PenaltyThreshold float64
+}
+
+// SessionResult is one student's final standing in a finalized session. It
+// is written once and never recomputed.
+type SessionResult struct {
+	SessionID       string
+	StudentID       string
+	Level           int
+	TotalScore      float64
+	PenaltyPoints   float64
+	FinalScore      float64
+	Rank            int
+	FirstPlaces     int
+	BiasedQuestions int
+	Qualified       bool
+	// PeerScore is TotalScore less PenaltyPoints. FinalScore equals it
+	// unless moderator marks were blended in, in which case FinalScore is a
+	// percentage; see Rubric.
+	PeerScore float64
+	// ModeratorScore is the student's rubric mark as a percentage; it is
+	// only meaningful when Moderated is set.
+	ModeratorScore float64
+	Moderated      bool
+	// ModeratorWeight is the share of FinalScore taken from moderator
+	// marks, or 0 when none were blended in.
+	ModeratorWeight float64
+	// ConfigID and ConfigVersion record the ranking points configuration the
+	// scores were computed with; both are empty when the defaults applied.
+	ConfigID        string
+	ConfigVersion   int
+	ConsensusMethod string
+	FinalizedAt     time.Time
+}
+
+// Rubric is a level's moderator rubric. ModeratorWeight, between 0 and 1,
+// is the share of a moderated session's final score taken from moderator
+// marks; the rest comes from the peer ranking.
+type Rubric struct {
+	Level           int
+	ModeratorWeight float64
+}
+
+// RubricScore is a moderator's mark for one participant on one rubric
+// criterion, with the criterion's weight and scale.
+type RubricScore struct {
+	StudentID   string
+	CriterionID string
+	Criterion   string
+	Weight      float64
+	MaxScore    float64
+	Score       float64
+}
+
+// Feedback is a student's rating of a session they took part in.
+type Feedback struct {
+	SessionID string
+	StudentID string
+	Rating    int
+	Comments  string
+}
+
+type StudentRepository interface {
+	Get(ctx context.Context, id string) (Student, error)
+	// Credentials returns the active student with the email and their
+	// password hash, or ErrNotFound.
+	Credentials(ctx context.Context, email string) (Student, string, error)
+	// Promote moves the student from level to the next one. It does nothing
+	// when the student is no longer at level or level is the last.
+	Promote(ctx context.Context, id string, level int) error
+}
+
+type VenueRepository interface {
+	Get(ctx context.Context, id string) (Venue, error)
+	// BookedCount returns the number of participants across all sessions at the venue.
+	BookedCount(ctx context.Context, venueID string) (int, error)
+	// ActiveByLevel returns the level's active venues by name.
+	ActiveByLevel(ctx context.Context, level int) ([]Venue, error)
+}
+
+type QRCodeRepository interface {
+	// FindByData looks up a QR code by its payload and venue, active or not.
+	FindByData(ctx context.Context, qrData, venueID string) (QRCode, error)
+	IncrementUsage(ctx context.Context, id string) error
+}
+
+type SessionRepository interface {
+	Get(ctx context.Context, id string) (Session, error)
+	// FindPendingByVenue returns the earliest pending session at the venue.
+	FindPendingByVenue(ctx context.Context, venueID string) (Session, error)
+	// FindOpenByQRGroup returns the newest pending or active session for a QR group.
+	FindOpenByQRGroup(ctx context.Context, venueID, qrGroupID string) (Session, error)
+	// Create inserts a session lasting one hour from now, taking its level from the venue.
+	Create(ctx context.Context, s Session) error
+	// Activate moves a pending session to active.
+	Activate(ctx context.Context, id string) error
+	// SurveysClosedBefore returns sessions whose survey window ended before t
+	// and which have not been finalized.
+	SurveysClosedBefore(ctx context.Context, t time.Time) ([]Session, error)
+	// MarkFinalized completes the session and records that its results are final.
+	MarkFinalized(ctx context.Context, id string) error
+	// PinConfig records the ranking points version scoring the session. It
+	// does nothing if the session already has one.
+	PinConfig(ctx context.Context, id, configID string) error
+	// Reviewed returns unfinalized sessions that had collusion flags, all of
+	// which have now been reviewed.
+	Reviewed(ctx context.Context) ([]Session, error)
+	// SetTopic records the session's topic. It does nothing if the session
+	// already has one.
+	SetTopic(ctx context.Context, id string, topic Topic) error
+	SetStatus(ctx context.Context, id, status string) error
+	// StartSurveyTimer closes the session's survey window d from now.
+	StartSurveyTimer(ctx context.Context, id string, d time.Duration) error
+	// SurveyEnd returns when the session's survey window closes, or
+	// ErrNotFound if the session has no survey timer.
+	SurveyEnd(ctx context.Context, id string) (time.Time, error)
+}
+
+type TopicRepository interface {
+	// Active returns the level's active topics with their Uses.
+	Active(ctx context.Context, level int) ([]Topic, error)
+	// Get returns a topic by ID, active or not, or ErrNotFound.
+	Get(ctx context.Context, id string) (Topic, error)
+	// Discussed counts, per topic ID, how many of the students have
+	// discussed it in a session other than excludeSessionID.
+	Discussed(ctx context.Context, studentIDs []string, excludeSessionID string) (map[string]int, error)
+	// VenueUses counts, per topic ID, the venue's sessions other than
+	// excludeSessionID that discussed it.
+	VenueUses(ctx context.Context, venueID, excludeSessionID string) (map[string]int, error)
+	// Materials returns the topic's prep materials in display order.
+	Materials(ctx context.Context, topicID string) ([]PrepMaterial, error)
+}
+
+type ParticipantRepository interface {
+	IsParticipant(ctx context.Context, sessionID, studentID string) (bool, error)
+	// Add inserts the student into the session and returns ErrDuplicate if already present.
+	Add(ctx context.Context, sessionID, studentID string) error
+	// CountPendingBookings counts the student's participations in pending sessions.
+	CountPendingBookings(ctx context.Context, studentID string) (int, error)
+	// HasPendingBooking reports whether the student is booked into a pending
+	// session at the venue.
+	HasPendingBooking(ctx context.Context, studentID, venueID string) (bool, error)
+	// CancelPendingBooking removes the student from the venue's pending
+	// sessions, or returns ErrNotFound if they were booked into none.
+	CancelPendingBooking(ctx context.Context, studentID, venueID string) error
+	// Overlapping returns another pending or active session of the student
+	// whose times overlap sess, or ErrNotFound.
+	Overlapping(ctx context.Context, studentID string, sess Session) (Session, error)
+	List(ctx context.Context, sessionID string) ([]Participant, error)
+	// CountPresent counts the participants who scanned in and are still tracked.
+	CountPresent(ctx context.Context, sessionID string) (int, error)
+	// Present returns the active participants who started a phase of the
+	// session after since, by name.
+	Present(ctx context.Context, sessionID string, since time.Time) ([]Participant, error)
+	// PrunePhases removes the session's phase tracking started before t,
+	// left behind by students who dropped out.
+	PrunePhases(ctx context.Context, sessionID string, t time.Time) error
+	// ClearPhases removes all phase tracking rows for the student.
+	ClearPhases(ctx context.Context, studentID string) error
+	StartPhase(ctx context.Context, sessionID, studentID, phase string) error
+	// Phases returns when the student started each tracked phase of the
+	// session.
+	Phases(ctx context.Context, sessionID, studentID string) (map[string]time.Time, error)
+}
+
+type SurveyRepository interface {
+	// ActiveQuestions returns the active questions mapped to a level in
+	// question_levels, in the level's display order.
+	ActiveQuestions(ctx context.Context, level int) ([]Question, error)
+	// Template returns how many questions a level's sessions draw from each
+	// question bank, by bank ID. A level without a template asks all of its
+	// active questions.
+	Template(ctx context.Context, level int) (map[string]int, error)
+	// SessionQuestions returns the questions drawn for a session, or none if
+	// they have not been drawn yet.
+	SessionQuestions(ctx context.Context, sessionID string) ([]Question, error)
+	// SaveSessionQuestions records the questions drawn for a session, text
+	// and weight included. A session already drawn keeps its set.
+	SaveSessionQuestions(ctx context.Context, sessionID string, questions []Question) error
+	// ServedSets returns the IDs of the questions a student was served, by
+	// session.
+	ServedSets(ctx context.Context, studentID string) (map[string][]string, error)
+	// ServedQuestions returns the questions a student was served for a
+	// session, in the order they were shown, or none if nothing was served.
+	ServedQuestions(ctx context.Context, sessionID, studentID string) ([]Question, error)
+	// ServeQuestions records the questions shown to a student for a session,
+	// text and weight included. A student already served keeps their set.
+	ServeQuestions(ctx context.Context, sessionID, studentID string, questions []Question) error
+	DeleteResponses(ctx context.Context, sessionID, responderID, questionID string) error
+	SaveResult(ctx context.Context, res SurveyResult) error
+	CountAnswered(ctx context.Context, sessionID, responderID string) (int, error)
+	// MarkCompleted records survey completion and flags the responder's results as completed.
+	MarkCompleted(ctx context.Context, sessionID, responderID string) error
+	// CountCompleted counts the session's participants who completed the survey.
+	CountCompleted(ctx context.Context, sessionID string) (int, error)
+	// Results returns every result of the session that has not been voided,
+	// ordered by question, responder and rank.
+	Results(ctx context.Context, sessionID string) ([]SurveyResult, error)
+	// ReplaceBias swaps the session's bias penalties for entries.
+	ReplaceBias(ctx context.Context, sessionID string, entries []Bias) error
+	Bias(ctx context.Context, sessionID string) ([]Bias, error)
+	// ActiveConfig returns the active scoring configuration for a level, or ErrNotFound.
+	ActiveConfig(ctx context.Context, level int) (ScoringConfig, error)
+	// Config returns a scoring configuration version by ID, active or not.
+	Config(ctx context.Context, id string) (ScoringConfig, error)
+	ScoreSummaries(ctx context.Context, sessionID string) ([]ScoreSummary, error)
+	// ClearCurrent takes the session's results out of the current-session set
+	// once they have been finalized.
+	ClearCurrent(ctx context.Context, sessionID string) error
+	// SaveFlags records new collusion flags as pending. A flag matching an
+	// existing one by kind and responders is skipped, so a reviewed flag is
+	// not raised again.
+	SaveFlags(ctx context.Context, flags []CollusionFlag) error
+	// Flags returns the session's collusion flags, reviewed or not.
+	Flags(ctx context.Context, sessionID string) ([]CollusionFlag, error)
+	// StartQuestionTimer gives the session d from now to answer a question,
+	// restarting the timer if it was already running.
+	StartQuestionTimer(ctx context.Context, sessionID, questionID string, d time.Duration) error
+	// QuestionDeadline returns when the question's timer runs out, or
+	// ErrNotFound if it was never started.
+	QuestionDeadline(ctx context.Context, sessionID, questionID string) (time.Time, error)
+	// ChargeTimeout penalizes a student for letting a question time out. It
+	// returns ErrDuplicate if the student was already charged for it.
+	ChargeTimeout(ctx context.Context, sessionID, studentID, questionID string, points float64) error
+}
+
+type ResultRepository interface {
+	// Rule returns the qualification rule for a level, or ErrNotFound.
+	Rule(ctx context.Context, level int) (QualificationRule, error)
+	// Save writes a session's final results, returning ErrDuplicate if the
+	// session already has them.
+	Save(ctx context.Context, results []SessionResult) error
+	// ForSession returns a session's final results by rank; it is empty until
+	// the session is finalized.
+	ForSession(ctx context.Context, sessionID string) ([]SessionResult, error)
+	// Rubric returns a level's moderator rubric, or ErrNotFound.
+	Rubric(ctx context.Context, level int) (Rubric, error)
+	// RubricScores returns the moderator marks given in a session, ordered
+	// by student and criterion.
+	RubricScores(ctx context.Context, sessionID string) ([]RubricScore, error)
+	// RubricFeedback returns the moderator's written feedback for a student
+	// in a session, or "" when there is none.
+	RubricFeedback(ctx context.Context, sessionID, studentID string) (string, error)
+}
+
+type FeedbackRepository interface {
+	// Submit records a student's feedback, returning ErrDuplicate if they
+	// already rated the session.
+	Submit(ctx context.Context, f Feedback) error
+	// Get returns a student's feedback on a session, or ErrNotFound.
+	Get(ctx context.Context, sessionID, studentID string) (Feedback, error)
+}
+
+// Store groups the repositories and runs units of work atomically.
+type Store interface {
+	Students() StudentRepository
+	Venues() VenueRepository
+	QRCodes() QRCodeRepository
+	Sessions() SessionRepository
+	Participants() ParticipantRepository
+	Surveys() SurveyRepository
+	Results() ResultRepository
+	Topics() TopicRepository
+	Feedback() FeedbackRepository
+	// InTx runs fn against a Store bound to a single transaction, committing
+	// when fn returns nil and rolling back otherwise.
+	InTx(ctx context.Context, fn func(Store) error) error
+}
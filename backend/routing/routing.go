@@ -0,0 +1,64 @@
+// Package routing holds the glue between the /api/v1 route tables and the
+// handlers, which predate path parameters, plus the shim that keeps the
+// unversioned paths working while clients migrate.
+package routing
+
+import (
+	"net/http"
+	"strings"
+
+	"gd/apierror"
+	"gd/metrics"
+)
+
+// PathParams copies the wildcards of the matched pattern into the query
+// string, so a handler reading ?session_id= also serves
+// /sessions/{session_id}. Path values win over query values.
+func PathParams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names := wildcards(r.Pattern)
+		if len(names) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		q := r.URL.Query()
+		for _, name := range names {
+			q.Set(name, r.PathValue(name))
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.RawQuery = q.Encode()
+		next.ServeHTTP(w, r2)
+	})
+}
+
+func wildcards(pattern string) []string {
+	var names []string
+	for _, seg := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			name := strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "}")
+			names = append(names, strings.TrimSuffix(name, "..."))
+		}
+	}
+	return names
+}
+
+// Legacy marks every response from next as deprecated in favour of
+// /api/v1 and counts the call, so we can tell when the app has migrated.
+func Legacy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</api/v1/>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+		// The inner mux records the pattern it matched on r.
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.LegacyRequests.Inc(route)
+	})
+}
+
+// NotFound answers paths no router claims with the JSON error envelope.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, apierror.New(http.StatusNotFound, "No route for "+r.URL.Path))
+}
@@ -0,0 +1,36 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gd/metrics"
+)
+
+func TestPathParamsOverrideQuery(t *testing.T) {
+	var got string
+	mux := http.NewServeMux()
+	mux.Handle("GET /sessions/{session_id}", PathParams(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("session_id") + "," + r.URL.Query().Get("level")
+	})))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sessions/s1?session_id=old&level=2", nil))
+	if got != "s1,2" {
+		t.Errorf("query = %q, want s1,2", got)
+	}
+}
+
+func TestLegacyMarksAndCounts(t *testing.T) {
+	inner := http.NewServeMux()
+	inner.HandleFunc("/admin/venues", func(w http.ResponseWriter, r *http.Request) {})
+	before := metrics.LegacyRequests.Value("/admin/venues")
+
+	rec := httptest.NewRecorder()
+	Legacy(inner).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/venues", nil))
+	if rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Link") == "" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	if got := metrics.LegacyRequests.Value("/admin/venues") - before; got != 1 {
+		t.Errorf("legacy count rose by %v, want 1", got)
+	}
+}
@@ -0,0 +1,83 @@
+// Package scheduler runs periodic maintenance jobs alongside the HTTP server
+// and stops them cleanly on shutdown.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic work. It should honour ctx cancellation.
+type Job func(ctx context.Context) error
+
+type entry struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+type Scheduler struct {
+	entries []entry
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers job to run once per interval after Start. Jobs must be
+// registered before Start is called.
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	s.entries = append(s.entries, entry{name: name, interval: interval, job: job})
+}
+
+// Start launches one goroutine per job. The first run happens after one
+// interval has elapsed. Each run is bounded by the job's interval so a stuck
+// query cannot pile up behind itself.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.loop(ctx, e)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, e entry) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(ctx, e)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, e entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("job panicked", "job", e.name, "panic", r)
+		}
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	if err := e.job(runCtx); err != nil && ctx.Err() == nil {
+		slog.Error("job failed", "job", e.name, "error", err)
+	}
+}
+
+// Stop cancels all jobs and waits for in-flight runs to return.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsUntilStopped(t *testing.T) {
+	var runs atomic.Int32
+	s := New()
+	s.Every("count", 5*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	s.Every("failing", 5*time.Millisecond, func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	s.Every("panicking", 5*time.Millisecond, func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	s.Start(context.Background())
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("job ran %d times in 1s", runs.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.Stop()
+
+	after := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != after {
+		t.Errorf("job kept running after Stop")
+	}
+}
+
+func TestStopWaitsForInFlightRun(t *testing.T) {
+	started := make(chan struct{}, 1)
+	var finished atomic.Bool
+	s := New()
+	s.Every("slow", 50*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		finished.Store(true)
+		return ctx.Err()
+	})
+
+	s.Start(context.Background())
+	<-started
+	s.Stop()
+	if !finished.Load() {
+		t.Error("Stop returned before the running job observed cancellation")
+	}
+}
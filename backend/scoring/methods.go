@@ -0,0 +1,126 @@
+package scoring
+
+import "sort"
+
+// Borda awards each student n-1 points for a first place on a ballot, n-2
+// for second and so on, where n is the number of students on any ballot.
+// Students left off a ballot get nothing from it.
+type Borda struct{}
+
+func (Borda) Name() string { return "borda" }
+
+func (Borda) Order(ballots []Ballot) []string {
+	ids := candidates(ballots)
+	points := make(map[string]int, len(ids))
+	for _, b := range ballots {
+		for i, id := range b.Ranking {
+			points[id] += len(ids) - 1 - i
+		}
+	}
+	return sortBy(ids, func(a, b string) (bool, bool) {
+		return points[a] > points[b], points[a] != points[b]
+	})
+}
+
+// Median orders students by their median place across all ballots, the
+// usual approximation of the Kemeny-Young ranking. Equal medians fall back
+// to the mean place.
+type Median struct{}
+
+func (Median) Name() string { return "median" }
+
+func (Median) Order(ballots []Ballot) []string {
+	ids := candidates(ballots)
+	median := make(map[string]float64, len(ids))
+	mean := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		places := make([]int, len(ballots))
+		sum := 0
+		for i, b := range ballots {
+			places[i] = rankOn(b, id)
+			sum += places[i]
+		}
+		sort.Ints(places)
+		n := len(places)
+		median[id] = float64(places[(n-1)/2]+places[n/2]) / 2
+		mean[id] = float64(sum) / float64(n)
+	}
+	return sortBy(ids, func(a, b string) (bool, bool) {
+		if median[a] != median[b] {
+			return median[a] < median[b], true
+		}
+		return mean[a] < mean[b], mean[a] != mean[b]
+	})
+}
+
+// Schulze is the beatpath method: a student ranks above another when the
+// strongest chain of pairwise majorities from the first to the second beats
+// the strongest chain back. It is a Condorcet method, so a student preferred
+// to every other by a majority always comes first.
+type Schulze struct{}
+
+func (Schulze) Name() string { return "schulze" }
+
+func (Schulze) Order(ballots []Ballot) []string {
+	ids := candidates(ballots)
+	n := len(ids)
+	index := make(map[string]int, n)
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	// d[i][j] counts the ballots placing i above j.
+	d := make([][]int, n)
+	for i := range d {
+		d[i] = make([]int, n)
+	}
+	for _, b := range ballots {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if i != j && rankOn(b, ids[i]) < rankOn(b, ids[j]) {
+					d[i][j]++
+				}
+			}
+		}
+	}
+
+	// p[i][j] is the strength of the widest path from i to j.
+	p := make([][]int, n)
+	for i := range p {
+		p[i] = make([]int, n)
+		for j := range p[i] {
+			if i != j && d[i][j] > d[j][i] {
+				p[i][j] = d[i][j]
+			}
+		}
+	}
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			if i == k {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				if j == i || j == k {
+					continue
+				}
+				p[i][j] = max(p[i][j], min(p[i][k], p[k][j]))
+			}
+		}
+	}
+
+	wins := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if p[i][j] > p[j][i] {
+				wins[ids[i]]++
+			}
+		}
+	}
+	return sortBy(ids, func(a, b string) (bool, bool) {
+		if wins[a] != wins[b] {
+			return wins[a] > wins[b], true
+		}
+		ia, ib := index[a], index[b]
+		return p[ia][ib] > p[ib][ia], p[ia][ib] != p[ib][ia]
+	})
+}
@@ -0,0 +1,107 @@
+// Package scoring turns the peer rankings collected by the survey into a
+// consensus order for a question. Each consensus method is a Strategy; the
+// method used for a level is chosen in its ranking points configuration.
+//
+// Every strategy is order-independent: shuffling the ballots never changes
+// the result, and ties are broken by student ID.
+package scoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Default is the method used for levels that have not chosen one.
+const Default = "borda"
+
+// Ballot is one responder's ranking for a question, best first. Ballots may
+// be partial; students a responder left out count as ranked below everyone
+// they placed.
+type Ballot struct {
+	Responder string
+	Ranking   []string
+}
+
+// Strategy computes a consensus order from a set of ballots.
+type Strategy interface {
+	// Name is the identifier stored in ranking_points_config.consensus_method.
+	Name() string
+	// Order returns every student named on any ballot, best first.
+	Order(ballots []Ballot) []string
+}
+
+var strategies = map[string]Strategy{}
+
+func register(s Strategy) { strategies[s.Name()] = s }
+
+func init() {
+	register(Borda{})
+	register(Median{})
+	register(Schulze{})
+}
+
+// Lookup returns the strategy called name.
+func Lookup(name string) (Strategy, error) {
+	if s, ok := strategies[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown consensus method %q (want one of %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names lists the registered methods in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Positions maps each student in order to their 1-based consensus rank.
+func Positions(order []string) map[string]int {
+	pos := make(map[string]int, len(order))
+	for i, id := range order {
+		pos[id] = i + 1
+	}
+	return pos
+}
+
+// candidates returns every student named on a ballot, sorted by ID.
+func candidates(ballots []Ballot) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, b := range ballots {
+		for _, id := range b.Ranking {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// rankOn is the 1-based place of id on b, or one past the end when b leaves
+// id out.
+func rankOn(b Ballot, id string) int {
+	for i, ranked := range b.Ranking {
+		if ranked == id {
+			return i + 1
+		}
+	}
+	return len(b.Ranking) + 1
+}
+
+// sortBy orders ids by less, falling back to ID so the result is total.
+func sortBy(ids []string, less func(a, b string) (bool, bool)) []string {
+	sort.SliceStable(ids, func(i, j int) bool {
+		if lt, decided := less(ids[i], ids[j]); decided {
+			return lt
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
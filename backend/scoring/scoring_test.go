@@ -0,0 +1,83 @@
+package scoring
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func ballots(spec ...string) []Ballot {
+	var out []Ballot
+	for i, s := range spec {
+		count, ranking, _ := strings.Cut(s, "x")
+		n := 0
+		for _, c := range count {
+			n = n*10 + int(c-'0')
+		}
+		for j := 0; j < n; j++ {
+			out = append(out, Ballot{Responder: string(rune('a'+i)) + count, Ranking: strings.Split(ranking, "")})
+		}
+	}
+	return out
+}
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		method  string
+		ballots []Ballot
+		want    string
+	}{
+		// A is the plurality winner but most ballots put it last.
+		{"borda", ballots("3xABC", "2xBCA", "2xCBA"), "BAC"},
+		{"median", ballots("3xABC", "2xBCA", "2xCBA"), "BCA"},
+		// The worked example from Schulze's paper.
+		{"schulze", ballots("5xACBED", "5xADECB", "8xBEDAC", "3xCABED", "7xCAEBD", "2xCBADE", "7xDCEBA", "8xEBADC"), "EACBD"},
+		// Partial ballots: D is never ranked by the first group.
+		{"borda", ballots("2xAB", "1xDAB"), "ABD"},
+		{"schulze", ballots("2xAB", "1xDAB"), "ABD"},
+		// A perfect tie falls back to student ID.
+		{"borda", ballots("1xAB", "1xBA"), "AB"},
+		{"median", ballots("1xBA", "1xAB"), "AB"},
+		{"schulze", ballots("1xBA", "1xAB"), "AB"},
+	}
+	for _, tt := range tests {
+		s, err := Lookup(tt.method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(s.Order(tt.ballots), ""); got != tt.want {
+			t.Errorf("%s(%d ballots) = %s, want %s", tt.method, len(tt.ballots), got, tt.want)
+		}
+	}
+}
+
+func TestOrderIndependent(t *testing.T) {
+	base := ballots("3xABCD", "2xBDCA", "2xCBAD", "1xDC", "1xACB")
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range Names() {
+		s, _ := Lookup(name)
+		want := strings.Join(s.Order(base), "")
+		for i := 0; i < 20; i++ {
+			shuffled := append([]Ballot(nil), base...)
+			rng.Shuffle(len(shuffled), func(a, b int) { shuffled[a], shuffled[b] = shuffled[b], shuffled[a] })
+			if got := strings.Join(s.Order(shuffled), ""); got != want {
+				t.Fatalf("%s: shuffled ballots gave %s, want %s", name, got, want)
+			}
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if got := strings.Join(Names(), ","); got != "borda,median,schulze" {
+		t.Errorf("Names() = %s", got)
+	}
+	if _, err := Lookup(Default); err != nil {
+		t.Errorf("default method: %v", err)
+	}
+	if _, err := Lookup("plurality"); err == nil {
+		t.Error("unknown method accepted")
+	}
+	if pos := Positions([]string{"x", "y"}); pos["x"] != 1 || pos["y"] != 2 {
+		t.Errorf("Positions = %v", pos)
+	}
+}
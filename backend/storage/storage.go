@@ -0,0 +1,146 @@
+// Package storage keeps uploaded files, such as the attachments of a topic's
+// prep materials, outside the database. Handlers depend on Backend so a
+// deployment can keep files on local disk or plug in another store.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound is returned when no file is stored under a key.
+var ErrNotFound = errors.New("storage: file not found")
+
+// Backend stores files under slash-separated keys chosen by the caller.
+type Backend interface {
+	// Put stores the contents of r under key, replacing any existing file.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the file stored under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key. Deleting a missing file is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Serve writes the file stored under key as the response, for the browser
+// to show inline under name. It returns Open's error without writing
+// anything, so the caller can still report it; once the response has
+// started, a failed copy can only be logged.
+func Serve(w http.ResponseWriter, r *http.Request, b Backend, key, name, contentType string) error {
+	f, err := b.Open(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, f); err != nil {
+		slog.WarnContext(r.Context(), "sending stored file failed", "key", key, "error", err)
+	}
+	return nil
+}
+
+// Dir stores files below a local directory, creating it on first write.
+type Dir string
+
+func (d Dir) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(string(d), filepath.FromSlash(key)), nil
+}
+
+func (d Dir) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write beside the target and rename so readers never see a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (d Dir) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d Dir) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Memory keeps files in memory, for tests.
+type Memory struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{files: map[string][]byte{}}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[key] = data
+	return nil
+}
+
+func (m *Memory) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, key)
+	return nil
+}
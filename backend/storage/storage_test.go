@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testBackend(t *testing.T, b Backend) {
+	t.Helper()
+	ctx := context.Background()
+	if err := b.Put(ctx, "prep/t1/a.pdf", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(ctx, "prep/t1/a.pdf", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+	f, err := b.Open(ctx, "prep/t1/a.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "second" {
+		t.Errorf("read %q, want the replacement", data)
+	}
+
+	if err := b.Delete(ctx, "prep/t1/a.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete(ctx, "prep/t1/a.pdf"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+	if _, err := b.Open(ctx, "prep/t1/a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("open after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	d := Dir(t.TempDir())
+	testBackend(t, d)
+	for _, key := range []string{"../escape", "/etc/passwd", ""} {
+		if err := d.Put(context.Background(), key, strings.NewReader("x")); err == nil {
+			t.Errorf("Put(%q) accepted a key outside the directory", key)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, NewMemory())
+}
+
+func TestServe(t *testing.T) {
+	m := NewMemory()
+	m.Put(context.Background(), "k", strings.NewReader("%PDF-1.4"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	if err := Serve(w, r, m, "k", "brief notes.pdf", "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `inline; filename="brief notes.pdf"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if w.Header().Get("Content-Type") != "application/pdf" || w.Body.String() != "%PDF-1.4" {
+		t.Errorf("served %q as %q", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	if err := Serve(w, r, m, "missing", "x", "text/plain"); !errors.Is(err, ErrNotFound) || w.Header().Get("Content-Type") != "" {
+		t.Errorf("missing file: err %v, headers %v", err, w.Header())
+	}
+}
@@ -1,13 +1,13 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
+	"gd/apierror"
 	"gd/student/utils"
-	"gd/database"
+	"gd/repository"
 	"golang.org/x/crypto/bcrypt"
-	"log"
+	"log/slog"
 )
 
 type StudentLoginRequest struct {
@@ -15,57 +15,39 @@ type StudentLoginRequest struct {
 	Password string `json:"password"`
 }
 
-func StudentLogin(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) StudentLogin(w http.ResponseWriter, r *http.Request) {
     var req StudentLoginRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid request format", err))
         return
     }
 
-    log.Printf("Login attempt for: %s", req.Email) // Debug log
+    slog.DebugContext(r.Context(), "student login attempt", "email", req.Email)
     
-    var student struct {
-        ID           string
-        PasswordHash string
-        Level        int
-    }
-    
-    err := database.GetDB().QueryRow(
-        `SELECT id, password_hash, current_gd_level 
-        FROM student_users 
-        WHERE email = ? AND is_active = TRUE`,
-        req.Email,
-    ).Scan(&student.ID, &student.PasswordHash, &student.Level)
-
+    student, passwordHash, err := h.store.Students().Credentials(r.Context(), req.Email)
     if err != nil {
-        log.Printf("Database error for %s: %v", req.Email, err) // Debug log
-        if err == sql.ErrNoRows {
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
+        if err == repository.ErrNotFound {
+            slog.WarnContext(r.Context(), "student login for unknown or inactive account", "email", req.Email)
+            apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Invalid credentials"))
         } else {
+            slog.ErrorContext(r.Context(), "student login lookup failed", "email", req.Email, "error", err)
             w.WriteHeader(http.StatusInternalServerError)
         }
         return
     }
 
-    log.Printf("Found student: %s", student.ID) // Debug log
-    
     // Compare password
-    err = bcrypt.CompareHashAndPassword([]byte(student.PasswordHash), []byte(req.Password))
+    err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password))
     if err != nil {
-        log.Printf("Password mismatch for %s", req.Email) // Debug log
-        w.WriteHeader(http.StatusUnauthorized)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
+        slog.WarnContext(r.Context(), "student login password mismatch", "student_id", student.ID)
+        apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Invalid credentials"))
         return
     }
-log.Printf("Found student: %s, level: %d", student.ID, student.Level)
 	// Generate JWT token
 	token, err := jwt.GenerateStudentToken(student.ID, student.Level)
 	if err != nil {
-		log.Printf("Token generation error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+		slog.ErrorContext(r.Context(), "generating student token failed", "student_id", student.ID, "error", err)
+		apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Internal server error"))
 		return
 	}
 
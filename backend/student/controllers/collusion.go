@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+
+	"gd/repository"
+)
+
+// minIdenticalQuestions is the shortest survey on which giving every
+// question the same ranking is flagged. With fewer questions it is common
+// and says little.
+const minIdenticalQuestions = 3
+
+// detectCollusion looks for coordinated voting in a session's results:
+//
+//   - first-place rings, where responders rank each other first in a cycle
+//     and nobody outside the cycle ranks any of them first, on at least half
+//     of the questions;
+//   - responders who give the same ranking, at least two deep, on every
+//     question of a survey with minIdenticalQuestions or more.
+func detectCollusion(sessionID string, results []repository.SurveyResult) []repository.CollusionFlag {
+	// Results arrive ordered by question, responder and rank.
+	rankings := map[string]map[string][]string{}
+	var questions []string
+	for _, res := range results {
+		if rankings[res.QuestionID] == nil {
+			rankings[res.QuestionID] = map[string][]string{}
+			questions = append(questions, res.QuestionID)
+		}
+		rankings[res.QuestionID][res.ResponderID] = append(rankings[res.QuestionID][res.ResponderID], res.StudentID)
+	}
+
+	var flags []repository.CollusionFlag
+	rings := map[string]int{}
+	for _, q := range questions {
+		for _, ring := range unsupportedRings(rankings[q]) {
+			rings[strings.Join(ring, ",")]++
+		}
+	}
+	for key, count := range rings {
+		if count*2 < len(questions) {
+			continue
+		}
+		flags = append(flags, repository.CollusionFlag{
+			SessionID:    sessionID,
+			Kind:         repository.FlagFirstPlaceRing,
+			ResponderIDs: strings.Split(key, ","),
+			Detail: fmt.Sprintf("ranked each other first on %d of %d questions with no other responder ranking them first",
+				count, len(questions)),
+		})
+	}
+
+	if len(questions) >= minIdenticalQuestions {
+		for responder, first := range rankings[questions[0]] {
+			same := len(first) >= 2
+			for _, q := range questions[1:] {
+				same = same && slices.Equal(rankings[q][responder], first)
+			}
+			if same {
+				flags = append(flags, repository.CollusionFlag{
+					SessionID:    sessionID,
+					Kind:         repository.FlagIdenticalRankings,
+					ResponderIDs: []string{responder},
+					Detail:       fmt.Sprintf("gave the same ranking on all %d questions", len(questions)),
+				})
+			}
+		}
+	}
+
+	sort.Slice(flags, func(i, j int) bool {
+		if flags[i].Kind != flags[j].Kind {
+			return flags[i].Kind < flags[j].Kind
+		}
+		return strings.Join(flags[i].ResponderIDs, ",") < strings.Join(flags[j].ResponderIDs, ",")
+	})
+	return flags
+}
+
+// unsupportedRings finds the cycles in one question's first places: each
+// responder points at the student they ranked first. A cycle is returned,
+// starting from its smallest ID, when no responder outside it ranked one
+// of its members first.
+func unsupportedRings(ballots map[string][]string) [][]string {
+	first := make(map[string]string, len(ballots))
+	var responders []string
+	for responder, ranking := range ballots {
+		if len(ranking) > 0 {
+			first[responder] = ranking[0]
+			responders = append(responders, responder)
+		}
+	}
+	sort.Strings(responders)
+
+	var rings [][]string
+	done := map[string]bool{}
+	for _, start := range responders {
+		var path []string
+		at := map[string]int{}
+		for id := start; !done[id]; {
+			next, ok := first[id]
+			if !ok {
+				break
+			}
+			at[id] = len(path)
+			path = append(path, id)
+			done[id] = true
+			if i, seen := at[next]; seen {
+				if ring := path[i:]; len(ring) >= 2 && !supported(ring, first) {
+					rings = append(rings, rotateToSmallest(ring))
+				}
+				break
+			}
+			id = next
+		}
+	}
+	return rings
+}
+
+// supported reports whether a responder outside ring ranked a member first.
+func supported(ring []string, first map[string]string) bool {
+	for responder, pick := range first {
+		if !slices.Contains(ring, responder) && slices.Contains(ring, pick) {
+			return true
+		}
+	}
+	return false
+}
+
+func rotateToSmallest(ring []string) []string {
+	i := slices.Index(ring, slices.Min(ring))
+	return append(slices.Clone(ring[i:]), ring[:i]...)
+}
+
+// holdForReview raises collusion flags for the session's responses and
+// reports whether any still await an admin, in which case the session
+// must not be finalized yet.
+func holdForReview(ctx context.Context, s repository.Store, sessionID string) (bool, error) {
+	results, err := s.Surveys().Results(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if err := s.Surveys().SaveFlags(ctx, detectCollusion(sessionID, results)); err != nil {
+		return false, err
+	}
+	pending, err := pendingFlags(ctx, s, sessionID)
+	if pending > 0 {
+		slog.InfoContext(ctx, "session held for collusion review", "session_id", sessionID, "flags", pending)
+	}
+	return pending > 0, err
+}
+
+// pendingFlags counts the session's collusion flags awaiting review.
+func pendingFlags(ctx context.Context, s repository.Store, sessionID string) (int, error) {
+	flags, err := s.Surveys().Flags(ctx, sessionID)
+	n := 0
+	for _, f := range flags {
+		if f.Status == repository.FlagPending {
+			n++
+		}
+	}
+	return n, err
+}
+
+// voidedResponder reports whether a voided collusion flag of the session
+// names the responder.
+func voidedResponder(ctx context.Context, s repository.Store, sessionID, responderID string) (bool, error) {
+	flags, err := s.Surveys().Flags(ctx, sessionID)
+	for _, f := range flags {
+		if f.Status == repository.FlagVoided && slices.Contains(f.ResponderIDs, responderID) {
+			return true, err
+		}
+	}
+	return false, err
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+
+	"gd/repository"
+	"gd/scoring"
+)
+
+// applyConsensusPenalties scores every responder's answers against the
+// consensus for each question and replaces the session's bias entries. It
+// uses the full response set, so the outcome does not depend on who
+// submitted first.
+func applyConsensusPenalties(ctx context.Context, s repository.Store, sessionID string, strategy scoring.Strategy) error {
+	results, err := s.Surveys().Results(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	// Results arrive ordered by question, responder and rank.
+	type answer struct {
+		ballot   scoring.Ballot
+		rankings map[int]string
+	}
+	answers := map[string][]*answer{}
+	var questions []string
+	for _, res := range results {
+		as := answers[res.QuestionID]
+		if as == nil {
+			questions = append(questions, res.QuestionID)
+		}
+		if len(as) == 0 || as[len(as)-1].ballot.Responder != res.ResponderID {
+			as = append(as, &answer{ballot: scoring.Ballot{Responder: res.ResponderID}, rankings: map[int]string{}})
+			answers[res.QuestionID] = as
+		}
+		a := as[len(as)-1]
+		a.ballot.Ranking = append(a.ballot.Ranking, res.StudentID)
+		a.rankings[res.Rank] = res.StudentID
+	}
+
+	var entries []repository.Bias
+	for _, questionID := range questions {
+		ballots := make([]scoring.Ballot, len(answers[questionID]))
+		for i, a := range answers[questionID] {
+			ballots[i] = a.ballot
+		}
+		consensus := scoring.Positions(strategy.Order(ballots))
+		for _, a := range answers[questionID] {
+			penalty, biased := calculateRankingPenalty(a.rankings, consensus)
+			entries = append(entries, repository.Bias{
+				SessionID:     sessionID,
+				ResponderID:   a.ballot.Responder,
+				QuestionID:    questionID,
+				Method:        strategy.Name(),
+				Deviation:     rankDeviation(a.rankings, consensus),
+				PenaltyPoints: penalty,
+				IsBiased:      biased,
+			})
+		}
+	}
+	if err := s.Surveys().ReplaceBias(ctx, sessionID, entries); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "applied consensus penalties", "method", strategy.Name(), "questions", len(questions))
+	return nil
+}
+
+// rankDeviation sums how many places each ranked student is from their
+// consensus place.
+func rankDeviation(rankings map[int]string, consensus map[string]int) int {
+	total := 0
+	for rank, studentID := range rankings {
+		if place, ok := consensus[studentID]; ok {
+			total += abs(rank - place)
+		}
+	}
+	return total
+}
+
+// surveyComplete reports whether every participant who is present has
+// completed the survey. When presence is no longer tracked, every
+// participant counts.
+func surveyComplete(ctx context.Context, s repository.Store, sessionID string) (bool, error) {
+	expected, err := s.Participants().CountPresent(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if expected == 0 {
+		participants, err := s.Participants().List(ctx, sessionID)
+		if err != nil {
+			return false, err
+		}
+		expected = len(participants)
+	}
+	completed, err := s.Surveys().CountCompleted(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return completed >= expected, nil
+}
@@ -1,9 +1,9 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"gd/database"
+	"gd/apierror"
+	"gd/repository"
 	"net/http"
 )
 
@@ -13,46 +13,27 @@ type FeedbackRequest struct {
 	Comments  string `json:"comments"`
 }
 
-func SubmitFeedback(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) SubmitFeedback(w http.ResponseWriter, r *http.Request) {
 	studentID := r.Context().Value("studentID").(string)
 	
 	var req FeedbackRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+	if err := apierror.Decode(r, &req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	// Check if student already submitted feedback for this session
-	var exists bool
-	err := database.GetDB().QueryRow(`
-		SELECT EXISTS(
-			SELECT 1 FROM session_feedback 
-			WHERE session_id = ? AND student_id = ?
-		)`, req.SessionID, studentID).Scan(&exists)
-	
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-		return
-	}
-
-	if exists {
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Feedback already submitted for this session"})
+	err := h.store.Feedback().Submit(r.Context(), repository.Feedback{
+		SessionID: req.SessionID,
+		StudentID: studentID,
+		Rating:    req.Rating,
+		Comments:  req.Comments,
+	})
+	if err == repository.ErrDuplicate {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, "Feedback already submitted for this session"))
 		return
 	}
-
-	// Insert feedback
-	_, err = database.GetDB().Exec(`
-		INSERT INTO session_feedback 
-		(id, session_id, student_id, rating, comments)
-		VALUES (UUID(), ?, ?, ?, ?)`,
-		req.SessionID, studentID, req.Rating, req.Comments)
-	
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to submit feedback"})
+		apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to submit feedback", err))
 		return
 	}
 
@@ -60,32 +41,25 @@ func SubmitFeedback(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
-func GetFeedback(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetFeedback(w http.ResponseWriter, r *http.Request) {
     studentID := r.Context().Value("studentID").(string)
     sessionID := r.URL.Query().Get("session_id")
 
-    var feedback struct {
-        Rating   int    `json:"rating"`
-        Comments string `json:"comments"`
-    }
-
-    err := database.GetDB().QueryRow(`
-        SELECT rating, comments FROM session_feedback
-        WHERE session_id = ? AND student_id = ?`,
-        sessionID, studentID).Scan(&feedback.Rating, &feedback.Comments)
-
+    feedback, err := h.store.Feedback().Get(r.Context(), sessionID, studentID)
     if err != nil {
-        if err == sql.ErrNoRows {
+        if err == repository.ErrNotFound {
             // Return empty response with 200 status when no feedback exists
             w.Header().Set("Content-Type", "application/json")
             json.NewEncoder(w).Encode(map[string]interface{}{})
             return
         }
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
 
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(feedback)
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "rating":   feedback.Rating,
+        "comments": feedback.Comments,
+    })
 }
\ No newline at end of file
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gd/repository"
+)
+
+// defaultRule applies to levels without a gd_rules row.
+var defaultRule = repository.QualificationRule{Places: 3}
+
+// finalizeSession is the single step that closes a session's scoring: it
+// computes the bias penalties from the full response set, snapshots every
+// participant's final score, rank and qualification into session_results,
+// takes the survey results out of the current-session set and promotes the
+// students who qualified. A session is finalized at most once, and not
+// while collusion flags on its responses await review.
+func finalizeSession(ctx context.Context, s repository.Store, sessionID string) error {
+	return s.InTx(ctx, func(tx repository.Store) error {
+		session, err := tx.Sessions().Get(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.Finalized {
+			return nil
+		}
+		if held, err := holdForReview(ctx, tx, session.ID); err != nil || held {
+			return err
+		}
+		config, err := scoringConfig(ctx, tx, session)
+		if err != nil {
+			return err
+		}
+		if err := applyConsensusPenalties(ctx, tx, session.ID, consensusStrategy(ctx, config)); err != nil {
+			return err
+		}
+		results, err := computeSessionResults(ctx, tx, session, config)
+		if err != nil {
+			return err
+		}
+		if err := tx.Results().Save(ctx, results); err != nil {
+			return err
+		}
+		if err := tx.Surveys().ClearCurrent(ctx, session.ID); err != nil {
+			return err
+		}
+		for _, res := range results {
+			if !res.Qualified {
+				continue
+			}
+			if err := tx.Students().Promote(ctx, res.StudentID, session.Level); err != nil {
+				return err
+			}
+		}
+		if err := tx.Sessions().MarkFinalized(ctx, session.ID); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "finalized session", "session_id", session.ID, "participants", len(results))
+		return nil
+	})
+}
+
+// computeSessionResults ranks the session's participants from the current
+// survey results and stamps them with the configuration that scored them.
+func computeSessionResults(ctx context.Context, s repository.Store, session repository.Session, config repository.ScoringConfig) ([]repository.SessionResult, error) {
+	members, err := s.Participants().List(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.Surveys().ScoreSummaries(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.Results().Rule(ctx, session.Level)
+	if err == repository.ErrNotFound {
+		rule = defaultRule
+	} else if err != nil {
+		return nil, err
+	}
+
+	mod, err := sessionModeration(ctx, s, session)
+	if err != nil {
+		return nil, err
+	}
+	results := rankSessionResults(members, summaries, mod, rule)
+	method := consensusStrategy(ctx, config).Name()
+	now := time.Now()
+	for i := range results {
+		results[i].SessionID = session.ID
+		results[i].Level = session.Level
+		results[i].ConfigID = config.ID
+		results[i].ConfigVersion = config.Version
+		results[i].ConsensusMethod = method
+		results[i].FinalizedAt = now
+	}
+	return results, nil
+}
+
+// rankSessionResults orders participants by final score, breaking ties on
+// first-place votes. The final score is the peer score (received score
+// minus bias and timeout penalties), blended with moderator marks when the
+// session was moderated. Students tied on both share a rank. Participants
+// without any results are ranked with zero scores.
+func rankSessionResults(members []repository.Participant, summaries []repository.ScoreSummary, mod moderation, rule repository.QualificationRule) []repository.SessionResult {
+	byStudent := make(map[string]repository.ScoreSummary, len(summaries))
+	for _, sum := range summaries {
+		byStudent[sum.StudentID] = sum
+	}
+
+	results := make([]repository.SessionResult, 0, len(members))
+	for _, m := range members {
+		sum := byStudent[m.StudentID]
+		penalty := sum.BiasPenalty + sum.TimeoutPenalty
+		results = append(results, repository.SessionResult{
+			StudentID:       m.StudentID,
+			TotalScore:      sum.TotalScore,
+			PenaltyPoints:   penalty,
+			PeerScore:       sum.TotalScore - penalty,
+			FinalScore:      sum.TotalScore - penalty,
+			FirstPlaces:     sum.FirstPlaces,
+			BiasedQuestions: sum.BiasedQuestions,
+		})
+	}
+	if mod.blended() {
+		blendModeratorMarks(results, mod)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].FinalScore != results[j].FinalScore {
+			return results[i].FinalScore > results[j].FinalScore
+		}
+		return results[i].FirstPlaces > results[j].FirstPlaces
+	})
+
+	for i := range results {
+		res := &results[i]
+		res.Rank = i + 1
+		if i > 0 && res.FinalScore == results[i-1].FinalScore && res.FirstPlaces == results[i-1].FirstPlaces {
+			res.Rank = results[i-1].Rank
+		}
+		res.Qualified = res.Rank <= rule.Places &&
+			(rule.PenaltyThreshold == 0 || res.PenaltyPoints < rule.PenaltyThreshold)
+	}
+	return results
+}
+
+// FinalizeClosedSessions finalizes sessions whose survey window has closed
+// without every participant submitting, and sessions held for collusion
+// review once every flag has been reviewed. It is run by the scheduler. A
+// session that fails is skipped until the next run.
+func (h *Handlers) FinalizeClosedSessions(ctx context.Context) error {
+	sessions, err := h.store.Sessions().SurveysClosedBefore(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	reviewed, err := h.store.Sessions().Reviewed(ctx)
+	if err != nil {
+		return err
+	}
+	// One session failing must not hold up the rest.
+	var errs []error
+	for _, session := range append(sessions, reviewed...) {
+		if err := finalizeSession(ctx, h.store, session.ID); err != nil {
+			slog.ErrorContext(ctx, "finalizing session failed", "session_id", session.ID, "error", err)
+			errs = append(errs, fmt.Errorf("session %s: %w", session.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,405 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gd/repository"
+)
+
+func TestFinalizeClosedSessions(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	ctx := context.Background()
+	submit(t, h, "alice", map[string]map[int]string{
+		"q-clarity": {1: "bob", 2: "carol", 3: "dave"},
+		"q-lead":    {1: "bob", 2: "carol", 3: "dave"},
+	})
+
+	var out map[string]interface{}
+	apply := func() int {
+		return serve(t, h.ApplySurveyPenalties, studentRequest(t, "POST", "/student/survey/penalties?session_id=sess1", "alice", nil), &out)
+	}
+	if code := apply(); code != http.StatusConflict {
+		t.Errorf("penalties while survey open = %d, want 409", code)
+	}
+
+	s.SetSurveyEnd("sess1", time.Now().Add(time.Hour))
+	if err := h.FinalizeClosedSessions(ctx); err != nil || s.Finalized("sess1") {
+		t.Fatalf("finalized an open survey: %v", err)
+	}
+
+	// A session that can't be finalized, listed first, doesn't hold up the rest.
+	s.AddSession(repository.Session{ID: "broken", Level: 1, Status: "active", ConfigID: "missing"})
+	s.SetSurveyEnd("broken", time.Now().Add(-time.Minute))
+	s.SetSurveyEnd("sess1", time.Now().Add(-time.Minute))
+	if err := h.FinalizeClosedSessions(ctx); err == nil || !strings.Contains(err.Error(), "session broken") {
+		t.Errorf("err = %v, want the broken session's", err)
+	}
+	if !s.Finalized("sess1") {
+		t.Fatal("closed survey not finalized")
+	}
+	if bias, _ := s.Surveys().Bias(ctx, "sess1"); len(bias) != 2 {
+		t.Errorf("bias entries = %+v, want one per question for alice", bias)
+	}
+	if code := apply(); code != http.StatusOK {
+		t.Errorf("penalties after finalization = %d, want 200", code)
+	}
+}
+
+func TestSessionResultsKeepUnrankedResponderBias(t *testing.T) {
+	s, _ := newStore(t)
+	ctx := context.Background()
+	session := repository.Session{ID: "sess2", Level: 1, Status: "active"}
+	s.AddSession(session)
+	s.AddParticipant("sess2", "alice")
+	s.AddParticipant("sess2", "bob")
+	// bob ranked alice but nobody ranked bob.
+	s.Surveys().SaveResult(ctx, repository.SurveyResult{SessionID: "sess2", StudentID: "alice", ResponderID: "bob", QuestionID: "q1", Rank: 1, Score: 4})
+	s.Surveys().ReplaceBias(ctx, "sess2", []repository.Bias{{SessionID: "sess2", ResponderID: "bob", QuestionID: "q1", PenaltyPoints: 1.5, IsBiased: true}})
+
+	results, err := computeSessionResults(ctx, s, session, defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	penalties := map[string]float64{}
+	for _, res := range results {
+		penalties[res.StudentID] = res.PenaltyPoints
+	}
+	if len(results) != 2 || penalties["bob"] != 1.5 || penalties["alice"] != 0 {
+		t.Errorf("results = %+v, want bob's bias penalty kept", results)
+	}
+}
+
+func TestFinalizeSessionSnapshotsResults(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	ctx := context.Background()
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		s.AddStudent(repository.Student{ID: id, Name: id, Level: 1})
+	}
+	s.SetRule(1, repository.QualificationRule{Places: 2, PenaltyThreshold: 3})
+	s.AddScoringConfig(repository.ScoringConfig{ID: "cfg1", Level: 1, Version: 3, Points: []float64{4, 3, 2}, Method: "borda"})
+	s.AddTimeoutPenalty("sess1", "carol", "q1", 0.5)
+
+	all := func(first, second, third string) map[string]map[int]string {
+		return map[string]map[int]string{
+			"q-clarity": {1: first, 2: second, 3: third},
+			"q-lead":    {1: first, 2: second, 3: third},
+		}
+	}
+	submit(t, h, "alice", all("bob", "carol", "dave"))
+	submit(t, h, "bob", all("carol", "dave", "alice"))
+	submit(t, h, "carol", all("bob", "alice", "dave"))
+	submit(t, h, "dave", all("bob", "carol", "alice"))
+	if !s.Finalized("sess1") {
+		t.Fatal("session not finalized by the last submission")
+	}
+
+	results, err := s.Results().ForSession(ctx, "sess1")
+	if err != nil || len(results) != 4 {
+		t.Fatalf("snapshot = %+v, %v", results, err)
+	}
+	// Received scores are bob 36, carol 30, dave 21 and alice 21. bob pays 3
+	// in bias penalties, which misses the threshold; carol pays 2 plus 0.5
+	// for a timeout.
+	want := []struct {
+		id        string
+		final     float64
+		qualified bool
+	}{
+		{"bob", 33, false}, {"carol", 27.5, true}, {"dave", 21, false}, {"alice", 20, false},
+	}
+	for i, w := range want {
+		res := results[i]
+		if res.StudentID != w.id || res.FinalScore != w.final || res.Rank != i+1 || res.Qualified != w.qualified {
+			t.Errorf("rank %d = %+v, want %s with %v", i+1, res, w.id, w.final)
+		}
+		if res.ConfigID != "cfg1" || res.ConfigVersion != 3 || res.ConsensusMethod != "borda" {
+			t.Errorf("%s scored with %s v%d (%s)", res.StudentID, res.ConfigID, res.ConfigVersion, res.ConsensusMethod)
+		}
+	}
+
+	// The current-session results are retired and late answers refused.
+	summaries, _ := s.Surveys().ScoreSummaries(ctx, "sess1")
+	for _, sum := range summaries {
+		if sum.TotalScore != 0 || sum.FirstPlaces != 0 {
+			t.Errorf("results still current after finalization: %+v", summaries)
+			break
+		}
+	}
+	var errBody map[string]interface{}
+	code := serve(t, h.SubmitSurvey, studentRequest(t, "POST", "/student/survey", "alice",
+		map[string]interface{}{"session_id": "sess1", "responses": all("bob", "carol", "dave")}), &errBody)
+	if code != http.StatusConflict {
+		t.Errorf("submission after finalization = %d, want 409", code)
+	}
+
+	var out struct {
+		Finalized bool                     `json:"finalized"`
+		Results   []map[string]interface{} `json:"results"`
+	}
+	if code := serve(t, h.GetResults, studentRequest(t, "GET", "/student/results?session_id=sess1", "alice", nil), &out); code != http.StatusOK {
+		t.Fatalf("results status = %d", code)
+	}
+	if !out.Finalized || out.Results[0]["student_id"] != "bob" || out.Results[1]["qualified"] != true {
+		t.Errorf("results = %+v, want the snapshot", out)
+	}
+}
+
+func TestFinalizeSessionPromotesQualified(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	ctx := context.Background()
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		s.AddStudent(repository.Student{ID: id, Name: id, Level: 1})
+	}
+	s.SetRule(1, repository.QualificationRule{Places: 2})
+
+	both := func(first, second, third string) map[string]map[int]string {
+		return map[string]map[int]string{
+			"q-clarity": {1: first, 2: second, 3: third},
+			"q-lead":    {1: first, 2: second, 3: third},
+		}
+	}
+	submit(t, h, "alice", both("bob", "carol", "dave"))
+	submit(t, h, "carol", both("bob", "dave", "alice"))
+	submit(t, h, "dave", both("bob", "carol", "alice"))
+	submit(t, h, "bob", both("carol", "alice", "dave"))
+
+	for id, level := range map[string]int{"bob": 2, "carol": 2, "alice": 1, "dave": 1} {
+		if st, _ := s.Students().Get(ctx, id); st.Level != level {
+			t.Errorf("%s at level %d, want %d", id, st.Level, level)
+		}
+	}
+
+	// Finalizing again changes nothing.
+	if err := finalizeSession(ctx, s, "sess1"); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := s.Students().Get(ctx, "bob"); st.Level != 2 {
+		t.Errorf("bob promoted twice to level %d", st.Level)
+	}
+}
+
+func TestRankSessionResults(t *testing.T) {
+	members := []repository.Participant{{StudentID: "a"}, {StudentID: "b"}, {StudentID: "c"}, {StudentID: "d"}}
+	summaries := []repository.ScoreSummary{
+		{StudentID: "a", TotalScore: 10, FirstPlaces: 1},
+		{StudentID: "b", TotalScore: 12, BiasPenalty: 1, TimeoutPenalty: 1, FirstPlaces: 1},
+		{StudentID: "c", TotalScore: 12, BiasPenalty: 2.5},
+	}
+	results := rankSessionResults(members, summaries, moderation{}, repository.QualificationRule{Places: 2, PenaltyThreshold: 2})
+
+	want := []struct {
+		id        string
+		rank      int
+		qualified bool
+	}{
+		// a and b tie on score and first places; b is over the threshold.
+		{"a", 1, true}, {"b", 1, false}, {"c", 3, false}, {"d", 4, false},
+	}
+	for i, w := range want {
+		if r := results[i]; r.StudentID != w.id || r.Rank != w.rank || r.Qualified != w.qualified {
+			t.Errorf("results[%d] = %+v, want %s at %d (qualified %v)", i, r, w.id, w.rank, w.qualified)
+		}
+	}
+}
+
+func TestRankSessionResultsBlendsModeratorMarks(t *testing.T) {
+	members := []repository.Participant{{StudentID: "a"}, {StudentID: "b"}, {StudentID: "c"}}
+	summaries := []repository.ScoreSummary{
+		{StudentID: "a", TotalScore: 20},
+		{StudentID: "b", TotalScore: 16},
+		{StudentID: "c", TotalScore: 10},
+	}
+	mark := func(student, criterion string, weight, maxScore, score float64) repository.RubricScore {
+		return repository.RubricScore{StudentID: student, CriterionID: criterion, Weight: weight, MaxScore: maxScore, Score: score}
+	}
+	marks := moderatorMarks([]repository.RubricScore{
+		mark("a", "lead", 2, 10, 4), mark("a", "team", 1, 5, 2),
+		mark("b", "lead", 2, 10, 10), mark("b", "team", 1, 5, 5),
+	})
+
+	// Peer scores scale to a 100, b 80 and c 50. The moderators put b on
+	// 100 and a on 40; nobody marked c, who keeps their peer score.
+	results := rankSessionResults(members, summaries, moderation{Weight: 0.5, Marks: marks}, repository.QualificationRule{Places: 1})
+	want := []struct {
+		id        string
+		final     float64
+		moderated bool
+	}{{"b", 90, true}, {"a", 70, true}, {"c", 50, false}}
+	for i, w := range want {
+		res := results[i]
+		if res.StudentID != w.id || res.FinalScore != w.final || res.Moderated != w.moderated || res.ModeratorWeight != 0.5 {
+			t.Errorf("rank %d = %+v, want %s with %v", i+1, res, w.id, w.final)
+		}
+	}
+	if results[1].PeerScore != 20 || math.Abs(results[1].ModeratorScore-40) > 1e-9 {
+		t.Errorf("a's parts = %v peer, %v moderator", results[1].PeerScore, results[1].ModeratorScore)
+	}
+	if !results[0].Qualified || results[1].Qualified {
+		t.Error("qualification should follow the blended rank")
+	}
+
+	// Without marks, or with a zero weight, the peer ranking stands.
+	for _, mod := range []moderation{{Weight: 0.5}, {Marks: marks}} {
+		if res := rankSessionResults(members, summaries, mod, repository.QualificationRule{}); res[0].StudentID != "a" || res[0].FinalScore != 20 {
+			t.Errorf("unmoderated ranking = %+v", res)
+		}
+	}
+}
+
+func TestGetResultsShowsOwnRubric(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	s.SetRubric(repository.Rubric{Level: 1, ModeratorWeight: 0.25})
+	s.AddRubricScore("sess1", repository.RubricScore{StudentID: "alice", CriterionID: "lead", Criterion: "Leadership", Weight: 1, MaxScore: 10, Score: 8})
+	s.AddRubricScore("sess1", repository.RubricScore{StudentID: "bob", CriterionID: "lead", Criterion: "Leadership", Weight: 1, MaxScore: 10, Score: 3})
+	s.SetRubricFeedback("sess1", "alice", "Summarised the group well")
+
+	for responder, ranking := range map[string][2]string{
+		"alice": {"bob", "carol"}, "carol": {"bob", "dave"}, "dave": {"bob", "carol"}, "bob": {"carol", "alice"},
+	} {
+		submit(t, h, responder, map[string]map[int]string{
+			"q-clarity": {1: ranking[0], 2: ranking[1]},
+			"q-lead":    {1: ranking[0], 2: ranking[1]},
+		})
+	}
+
+	var out struct {
+		Results  []map[string]interface{} `json:"results"`
+		Rubric   []map[string]interface{} `json:"rubric"`
+		Feedback string                   `json:"feedback"`
+	}
+	if code := serve(t, h.GetResults, studentRequest(t, "GET", "/student/results?session_id=sess1", "alice", nil), &out); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if len(out.Rubric) != 1 || out.Rubric[0]["criterion"] != "Leadership" || out.Rubric[0]["score"] != 8.0 {
+		t.Errorf("rubric = %+v, want alice's own mark only", out.Rubric)
+	}
+	if out.Feedback != "Summarised the group well" {
+		t.Errorf("feedback = %q", out.Feedback)
+	}
+	for _, res := range out.Results {
+		if res["moderator_weight"] != 0.25 {
+			t.Errorf("%s has moderator_weight %v", res["student_id"], res["moderator_weight"])
+		}
+		_, marked := res["moderator_score"]
+		if wantMarked := res["student_id"] == "alice" || res["student_id"] == "bob"; marked != wantMarked {
+			t.Errorf("%s moderator_score present = %v", res["student_id"], marked)
+		}
+	}
+}
+
+func TestDetectCollusion(t *testing.T) {
+	// ballot builds results for one question from responder:ranking pairs.
+	ballot := func(question string, ballots ...string) []repository.SurveyResult {
+		var out []repository.SurveyResult
+		for _, b := range ballots {
+			responder, ranking, _ := strings.Cut(b, ":")
+			for i, id := range strings.Split(ranking, ",") {
+				out = append(out, repository.SurveyResult{QuestionID: question, ResponderID: responder, StudentID: id, Rank: i + 1})
+			}
+		}
+		return out
+	}
+	kinds := func(flags []repository.CollusionFlag) string {
+		var got []string
+		for _, f := range flags {
+			got = append(got, f.Kind+"["+strings.Join(f.ResponderIDs, ",")+"]")
+		}
+		return strings.Join(got, " ")
+	}
+
+	tests := []struct {
+		name    string
+		results []repository.SurveyResult
+		want    string
+	}{
+		{"pair nobody else backs", append(
+			ballot("q1", "a:b,c", "b:a,c", "c:d,a", "d:c,b"),
+			ballot("q2", "a:b,c", "b:a,d", "c:a,d", "d:a,b")...),
+			"first_place_ring[a,b] first_place_ring[c,d]"},
+		{"pair the others agree with", ballot("q1", "a:b,c", "b:a,c", "c:a,b", "d:b,a"), ""},
+		{"three-way ring", ballot("q1", "a:b", "b:c", "c:a", "d:e", "e:a"), ""},
+		{"three-way ring unsupported", ballot("q1", "a:b", "b:c", "c:a", "d:e", "e:d"), "first_place_ring[a,b,c] first_place_ring[d,e]"},
+		{"same ranking on two questions", append(ballot("q1", "a:b,c"), ballot("q2", "a:b,c")...), ""},
+		{"same ranking on three questions", append(append(
+			ballot("q1", "a:b,c", "b:c,a"),
+			ballot("q2", "a:b,c", "b:a,c")...),
+			ballot("q3", "a:b,c", "b:c,a")...),
+			"identical_rankings[a]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kinds(detectCollusion("sess1", tt.results)); got != tt.want {
+				t.Errorf("flags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinalizeHeldForCollusionReview(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	ctx := context.Background()
+
+	both := func(first, second string) map[string]map[int]string {
+		return map[string]map[int]string{"q-clarity": {1: first, 2: second}, "q-lead": {1: first, 2: second}}
+	}
+	// alice and bob trade first places, as do carol and dave.
+	submit(t, h, "alice", both("bob", "carol"))
+	submit(t, h, "bob", both("alice", "dave"))
+	submit(t, h, "carol", both("dave", "alice"))
+	submit(t, h, "dave", both("carol", "bob"))
+	if s.Finalized("sess1") {
+		t.Fatal("flagged session was finalized")
+	}
+	flags, _ := s.Surveys().Flags(ctx, "sess1")
+	if len(flags) != 2 {
+		t.Fatalf("flags = %+v, want the two pairs", flags)
+	}
+
+	var out map[string]interface{}
+	if code := serve(t, h.GetResults, studentRequest(t, "GET", "/student/results?session_id=sess1", "alice", nil), &out); code != http.StatusOK || out["under_review"] != true {
+		t.Errorf("results = %d %v, want under_review", code, out)
+	}
+	code := serve(t, h.SubmitSurvey, studentRequest(t, "POST", "/student/survey", "alice",
+		map[string]interface{}{"session_id": "sess1", "responses": both("carol", "dave")}), &out)
+	if code != http.StatusConflict {
+		t.Errorf("resubmission under review = %d, want 409", code)
+	}
+
+	// Voiding one pair leaves the session held until the other is reviewed.
+	s.ReviewFlag(flags[0].ID, repository.FlagVoided)
+	if err := h.FinalizeClosedSessions(ctx); err != nil || s.Finalized("sess1") {
+		t.Fatalf("finalized with a flag pending: %v", err)
+	}
+	s.ReviewFlag(flags[1].ID, repository.FlagDismissed)
+
+	// Reviewed flags no longer freeze responses, except those voided.
+	code = serve(t, h.SubmitSurvey, studentRequest(t, "POST", "/student/survey", "alice",
+		map[string]interface{}{"session_id": "sess1", "responses": both("bob", "carol")}), &out)
+	if code != http.StatusConflict {
+		t.Errorf("resubmission of voided responses = %d %v, want 409", code, out)
+	}
+	submit(t, h, "carol", both("dave", "alice"))
+	if err := h.FinalizeClosedSessions(ctx); err != nil || !s.Finalized("sess1") {
+		t.Fatalf("reviewed session not finalized: %v", err)
+	}
+
+	// Only carol's and dave's votes count.
+	results, _ := s.Results().ForSession(ctx, "sess1")
+	total := map[string]float64{}
+	for _, res := range results {
+		total[res.StudentID] = res.TotalScore
+	}
+	if total["alice"] != 9 || total["bob"] != 9 || total["carol"] != 12 || total["dave"] != 12 {
+		t.Errorf("totals = %v, want alice's and bob's votes voided", total)
+	}
+}
@@ -1,124 +1,106 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
-	"gd/database"
+	"gd/apierror"
+	"gd/repository"
 )
 
-func GetQuestionsForStudent(w http.ResponseWriter, r *http.Request) {
-    levelStr := r.URL.Query().Get("level")
+// GetQuestionsForStudent returns the survey questions in this student's
+// order. With a session ID the questions are the ones served to the student
+// for that session, recorded on first request; without one they are a
+// preview of the level's current questions.
+func (h *Handlers) GetQuestionsForStudent(w http.ResponseWriter, r *http.Request) {
+    ctx := r.Context()
     studentID := r.Context().Value("studentID").(string)
-    
-    log.Printf("GetQuestionsForStudent called with level: %s, studentID: %s", levelStr, studentID)
-    
-    level, err := strconv.Atoi(levelStr)
-    if err != nil {
-        log.Printf("Invalid level parameter: %s", levelStr)
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level"})
-        return
-    }
+    sessionID := r.URL.Query().Get("session_id")
 
-    log.Printf("Querying questions for level: %d", level)
-    
-    // FIXED: Enhanced query with better error handling
-    rows, err := database.GetDB().Query(`
-        SELECT id, question_text, weight 
-        FROM survey_questions 
-        WHERE level = ? AND is_active = 1
-        ORDER BY created_at`, level)
-    
+    var questions []repository.Question
+    var config repository.ScoringConfig
+    var err error
+    if sessionID != "" {
+        err = h.store.InTx(ctx, func(tx repository.Store) error {
+            session, err := tx.Sessions().Get(ctx, sessionID)
+            if err == repository.ErrNotFound {
+                return apierror.New(http.StatusNotFound, "Session not found")
+            }
+            if err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            }
+            if ok, err := isParticipant(ctx, tx, session.ID, studentID); err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            } else if !ok {
+                return apierror.New(http.StatusForbidden, "Not a participant of the session")
+            }
+            if questions, err = servedQuestions(ctx, tx, session, studentID); err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            }
+            // Tell the client how many students to rank per question
+            if config, err = scoringConfig(ctx, tx, session); err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            }
+            return nil
+        })
+    } else {
+        levelStr := r.URL.Query().Get("level")
+        level, convErr := strconv.Atoi(levelStr)
+        if convErr != nil {
+            slog.WarnContext(ctx, "invalid question level", "level", levelStr)
+            apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Invalid level"))
+            return
+        }
+        questions, config, err = h.previewQuestions(ctx, level, studentID)
+    }
     if err != nil {
-        log.Printf("Database error: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(ctx, "loading questions failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, err)
         return
     }
-    defer rows.Close()
 
-    var questions []map[string]interface{}
-    var questionCount int
-    
-    for rows.Next() {
-        var question struct {
-            ID     string
-            Text   string
-            Weight float64
-        }
-        if err := rows.Scan(&question.ID, &question.Text, &question.Weight); err != nil {
-            log.Printf("Error scanning row: %v", err)
-            continue
-        }
-        questions = append(questions, map[string]interface{}{
-            "id":     question.ID,
-            "text":   question.Text,
-            "weight": question.Weight,
+    slog.DebugContext(ctx, "loaded questions", "session_id", sessionID, "count", len(questions))
+
+    out := make([]map[string]interface{}, 0, len(questions))
+    for _, q := range questions {
+        out = append(out, map[string]interface{}{
+            "id":            q.ID,
+            "text":          q.Text,
+            "weight":        q.Weight,
+            "ranking_depth": len(config.Points),
         })
-        questionCount++
-        
-        log.Printf("Found question: ID=%s, Text=%s, Weight=%.1f", 
-            question.ID, question.Text, question.Weight)
     }
 
-    // Check for any errors during iteration
-    if err := rows.Err(); err != nil {
-        log.Printf("Row iteration error: %v", err)
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(out); err != nil {
+        slog.ErrorContext(ctx, "encoding questions failed", "error", err)
     }
+}
 
-    log.Printf("Total questions found for level %d: %d", level, questionCount)
-
-    // If no questions found, use defaults
-    if len(questions) == 0 {
-        log.Printf("No questions found for level %d, using fallback questions", level)
-        
-        // Debug: Check what's actually in the database
-        debugRows, debugErr := database.GetDB().Query(`
-            SELECT id, question_text, weight, level, is_active 
-            FROM survey_questions 
-            ORDER BY created_at`)
-        
-        if debugErr == nil {
-            defer debugRows.Close()
-            var debugCount int
-            for debugRows.Next() {
-                var id, text string
-                var weight float64
-                var dbLevel int
-                var isActive bool
-                if err := debugRows.Scan(&id, &text, &weight, &dbLevel, &isActive); err == nil {
-                    log.Printf("DB Question: ID=%s, Text=%s, Level=%d, Active=%t", 
-                        id, text, dbLevel, isActive)
-                    debugCount++
-                }
-            }
-            log.Printf("Total questions in database: %d", debugCount)
-        }
-        
-        questions = []map[string]interface{}{
-            {"id": "q1", "text": "Clarity of arguments", "weight": 1.0},
-            {"id": "q2", "text": "Contribution to discussion", "weight": 1.0},
-            {"id": "q3", "text": "Teamwork and collaboration", "weight": 1.0},
-        }
+// previewQuestions loads the level's questions, shuffled for the student,
+// and its active ranking points without recording anything.
+func (h *Handlers) previewQuestions(ctx context.Context, level int, studentID string) ([]repository.Question, repository.ScoringConfig, error) {
+    questions, err := levelQuestions(ctx, h.store, level)
+    if err != nil {
+        return nil, repository.ScoringConfig{}, apierror.Wrap(http.StatusInternalServerError, "Database error", err)
     }
-
-    // Create a consistent but user-specific shuffle seed
-    sessionID := r.URL.Query().Get("session_id")
-    shuffleSeed := studentID
-    if sessionID != "" {
-        shuffleSeed += sessionID
+    config, err := levelConfig(ctx, h.store, level)
+    if err != nil {
+        return nil, config, apierror.Wrap(http.StatusInternalServerError, "Database error", err)
     }
+    return shuffleQuestionsWithSeed(questions, studentID), config, nil
+}
 
-    log.Printf("Shuffling %d questions with seed: %s", len(questions), shuffleSeed)
-
-    // Shuffle questions using a consistent seed for this user
-    shuffledQuestions := shuffleQuestionsWithSeed(questions, shuffleSeed)
-
-    w.Header().Set("Content-Type", "application/json")
-    if err := json.NewEncoder(w).Encode(shuffledQuestions); err != nil {
-        log.Printf("Error encoding response: %v", err)
+// isParticipant reports whether studentID is a participant of the session.
+func isParticipant(ctx context.Context, s repository.Store, sessionID, studentID string) (bool, error) {
+    members, err := s.Participants().List(ctx, sessionID)
+    for _, m := range members {
+        if m.StudentID == studentID {
+            return true, err
+        }
     }
-}
\ No newline at end of file
+    return false, err
+}
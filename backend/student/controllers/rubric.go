@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+
+	"gd/repository"
+)
+
+// moderation is the moderator rubric as it applies to one session: Marks
+// maps each student a moderator scored to a percentage, and Weight is the
+// share of the final score those marks take.
+type moderation struct {
+	Weight float64
+	Marks  map[string]float64
+}
+
+// blended reports whether moderator marks change the session's scores.
+func (m moderation) blended() bool {
+	return m.Weight > 0 && len(m.Marks) > 0
+}
+
+// sessionModeration loads the level's rubric weight and the marks given in
+// the session. Levels without a rubric are not moderated.
+func sessionModeration(ctx context.Context, s repository.Store, session repository.Session) (moderation, error) {
+	rubric, err := s.Results().Rubric(ctx, session.Level)
+	if err == repository.ErrNotFound {
+		return moderation{}, nil
+	}
+	if err != nil {
+		return moderation{}, err
+	}
+	scores, err := s.Results().RubricScores(ctx, session.ID)
+	if err != nil {
+		return moderation{}, err
+	}
+	return moderation{Weight: rubric.ModeratorWeight, Marks: moderatorMarks(scores)}, nil
+}
+
+// moderatorMarks turns per-criterion scores into one percentage per
+// student: each score is taken against its criterion's maximum and the
+// criteria are averaged by weight. Criteria a moderator skipped for a
+// student do not count against them.
+func moderatorMarks(scores []repository.RubricScore) map[string]float64 {
+	weighted := map[string]float64{}
+	weights := map[string]float64{}
+	for _, sc := range scores {
+		if sc.MaxScore <= 0 || sc.Weight <= 0 {
+			continue
+		}
+		weighted[sc.StudentID] += sc.Weight * sc.Score / sc.MaxScore
+		weights[sc.StudentID] += sc.Weight
+	}
+	marks := make(map[string]float64, len(weights))
+	for id, w := range weights {
+		marks[id] = weighted[id] / w * 100
+	}
+	return marks
+}
+
+// blendModeratorMarks replaces each FinalScore with a percentage combining
+// the peer score and the moderator mark. Peer scores are scaled so the
+// session's best is 100 and negative ones count as 0. A student no
+// moderator scored keeps their scaled peer score, so an oversight does not
+// cost them.
+func blendModeratorMarks(results []repository.SessionResult, mod moderation) {
+	top := 0.0
+	for _, res := range results {
+		top = max(top, res.PeerScore)
+	}
+	for i := range results {
+		res := &results[i]
+		peer := 0.0
+		if top > 0 {
+			peer = max(res.PeerScore, 0) / top * 100
+		}
+		mark, ok := mod.Marks[res.StudentID]
+		if !ok {
+			mark = peer
+		}
+		res.ModeratorScore, res.Moderated = mark, ok
+		res.ModeratorWeight = mod.Weight
+		res.FinalScore = (1-mod.Weight)*peer + mod.Weight*mark
+	}
+}
+
+// ownRubric returns the marks and feedback moderators gave studentID in the
+// session.
+func (h *Handlers) ownRubric(ctx context.Context, sessionID, studentID string) ([]map[string]interface{}, string, error) {
+	scores, err := h.store.Results().RubricScores(ctx, sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	rubric := []map[string]interface{}{}
+	for _, sc := range scores {
+		if sc.StudentID == studentID {
+			rubric = append(rubric, map[string]interface{}{
+				"criterion": sc.Criterion,
+				"score":     sc.Score,
+				"max_score": sc.MaxScore,
+			})
+		}
+	}
+	feedback, err := h.store.Results().RubricFeedback(ctx, sessionID, studentID)
+	return rubric, feedback, err
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+
+	"gd/repository"
+	"gd/scoring"
+)
+
+// defaultConfig scores levels that have never had a ranking points
+// configuration.
+var defaultConfig = repository.ScoringConfig{Points: []float64{4, 3, 2}, Method: scoring.Default}
+
+// levelConfig returns the level's active configuration, or the defaults.
+func levelConfig(ctx context.Context, s repository.Store, level int) (repository.ScoringConfig, error) {
+	c, err := s.Surveys().ActiveConfig(ctx, level)
+	if err == repository.ErrNotFound {
+		c = defaultConfig
+		c.Level = level
+		return c, nil
+	}
+	return withDefaults(c), err
+}
+
+// scoringConfig returns the configuration that scores a session: the version
+// pinned to it, or else the level's active version, which is pinned so that
+// later changes never re-score the session.
+func scoringConfig(ctx context.Context, s repository.Store, session repository.Session) (repository.ScoringConfig, error) {
+	if session.ConfigID != "" {
+		c, err := s.Surveys().Config(ctx, session.ConfigID)
+		return withDefaults(c), err
+	}
+	c, err := levelConfig(ctx, s, session.Level)
+	if err != nil || c.ID == "" {
+		return c, err
+	}
+	if err := s.Sessions().PinConfig(ctx, session.ID, c.ID); err != nil {
+		return c, err
+	}
+	// Another request may have pinned a different version first.
+	if pinned, err := s.Sessions().Get(ctx, session.ID); err == nil && pinned.ConfigID != c.ID {
+		c, err = s.Surveys().Config(ctx, pinned.ConfigID)
+		return withDefaults(c), err
+	}
+	return c, nil
+}
+
+// withDefaults fills in what a stored configuration leaves empty.
+func withDefaults(c repository.ScoringConfig) repository.ScoringConfig {
+	if len(c.Points) == 0 {
+		c.Points = defaultConfig.Points
+	}
+	if c.Method == "" {
+		c.Method = defaultConfig.Method
+	}
+	return c
+}
+
+// consensusStrategy returns the strategy a configuration names, falling back
+// to scoring.Default for a method this build does not know.
+func consensusStrategy(ctx context.Context, c repository.ScoringConfig) scoring.Strategy {
+	strategy, err := scoring.Lookup(c.Method)
+	if err != nil {
+		slog.WarnContext(ctx, "falling back to default consensus method", "level", c.Level, "error", err)
+		strategy, _ = scoring.Lookup(scoring.Default)
+	}
+	return strategy
+}
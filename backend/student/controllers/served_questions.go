@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gd/repository"
+)
+
+// fallbackQuestions are asked at levels with no active questions.
+var fallbackQuestions = []repository.Question{
+	{ID: "q1", Text: "Clarity of arguments", Weight: 1},
+	{ID: "q2", Text: "Contribution to discussion", Weight: 1},
+	{ID: "q3", Text: "Teamwork and collaboration", Weight: 1},
+}
+
+// levelQuestions returns the level's active questions, or the fallback
+// questions when it has none.
+func levelQuestions(ctx context.Context, s repository.Store, level int) ([]repository.Question, error) {
+	questions, err := s.Surveys().ActiveQuestions(ctx, level)
+	if err == nil && len(questions) == 0 {
+		questions = fallbackQuestions
+	}
+	return questions, err
+}
+
+// maxDraws bounds how many draws sessionQuestions tries while looking for a
+// set none of the participants has been asked before.
+const maxDraws = 10
+
+// servedQuestions returns the questions studentID answers in the session.
+// The first call takes the session's questions, in an order seeded by the
+// student and session, and records them; later calls return that record,
+// so editing survey_questions mid-session changes neither the set nor its
+// text and weights.
+func servedQuestions(ctx context.Context, s repository.Store, session repository.Session, studentID string) ([]repository.Question, error) {
+	served, err := s.Surveys().ServedQuestions(ctx, session.ID, studentID)
+	if err != nil || len(served) > 0 {
+		return served, err
+	}
+	questions, err := sessionQuestions(ctx, s, session)
+	if err != nil {
+		return nil, err
+	}
+	questions = shuffleQuestionsWithSeed(questions, studentID+session.ID)
+	if err := s.Surveys().ServeQuestions(ctx, session.ID, studentID, questions); err != nil {
+		return nil, err
+	}
+	// A concurrent request may have recorded its draw first.
+	return s.Surveys().ServedQuestions(ctx, session.ID, studentID)
+}
+
+// sessionQuestions returns the questions every participant of the session
+// is asked. They are drawn once, when the first participant is served, as
+// the level's template says. The draw is seeded by the session; if a
+// participant was already asked exactly that set in an earlier session, the
+// next seed is tried, up to maxDraws.
+func sessionQuestions(ctx context.Context, s repository.Store, session repository.Session) ([]repository.Question, error) {
+	drawn, err := s.Surveys().SessionQuestions(ctx, session.ID)
+	if err != nil || len(drawn) > 0 {
+		return drawn, err
+	}
+	questions, err := levelQuestions(ctx, s, session.Level)
+	if err != nil {
+		return nil, err
+	}
+	template, err := s.Surveys().Template(ctx, session.Level)
+	if err != nil {
+		return nil, err
+	}
+	// A template whose banks have no questions at the level asks them all
+	// rather than none.
+	if len(template) > 0 && len(drawQuestions(questions, template, session.ID)) > 0 {
+		asked, err := askedSets(ctx, s, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		seed := session.ID
+		for i := 1; ; i++ {
+			drawn = drawQuestions(questions, template, seed)
+			if !asked[setKey(drawn)] || i == maxDraws {
+				break
+			}
+			seed = fmt.Sprintf("%s#%d", session.ID, i)
+		}
+		questions = drawn
+	}
+	if err := s.Surveys().SaveSessionQuestions(ctx, session.ID, questions); err != nil {
+		return nil, err
+	}
+	return s.Surveys().SessionQuestions(ctx, session.ID)
+}
+
+// drawQuestions takes template[bank] questions from each bank, chosen by
+// seed, and returns them in the level's order. Questions outside the
+// template's banks are not asked.
+func drawQuestions(questions []repository.Question, template map[string]int, seed string) []repository.Question {
+	banks := map[string][]repository.Question{}
+	for _, q := range questions {
+		banks[q.BankID] = append(banks[q.BankID], q)
+	}
+	picked := map[string]bool{}
+	for bank, n := range template {
+		for _, q := range shuffleQuestionsWithSeed(banks[bank], seed+bank)[:min(n, len(banks[bank]))] {
+			picked[q.ID] = true
+		}
+	}
+	var drawn []repository.Question
+	for _, q := range questions {
+		if picked[q.ID] {
+			drawn = append(drawn, q)
+		}
+	}
+	return drawn
+}
+
+// askedSets returns the question sets the session's participants were
+// asked in earlier sessions, keyed by setKey.
+func askedSets(ctx context.Context, s repository.Store, sessionID string) (map[string]bool, error) {
+	members, err := s.Participants().List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	asked := map[string]bool{}
+	for _, m := range members {
+		sets, err := s.Surveys().ServedSets(ctx, m.StudentID)
+		if err != nil {
+			return nil, err
+		}
+		for id, set := range sets {
+			if id != sessionID {
+				asked[strings.Join(sorted(set), ",")] = true
+			}
+		}
+	}
+	return asked, nil
+}
+
+// setKey identifies a set of questions regardless of order.
+func setKey(questions []repository.Question) string {
+	ids := make([]string, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+	return strings.Join(sorted(ids), ",")
+}
+
+func sorted(ids []string) []string {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return ids
+}
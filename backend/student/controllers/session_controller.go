@@ -2,16 +2,18 @@ package controllers
 
 import (
 	// "bytes"
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
-	"gd/database"
-	"sort"
-	"log"
+	"gd/apierror"
+	"gd/logging"
+	"gd/metrics"
+	"gd/repository"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,6 +34,45 @@ type BookingRequest struct {
     StudentID  string `json:"student_id"`
 }
 
+func (b BookingRequest) Validate() error {
+    var p apierror.Problems
+    p.Required("venue_id", b.VenueID)
+    return p.Err()
+}
+
+// SurveySubmission carries a student's rankings keyed by question ID, then
+// by rank. Only the questions served to the student for the session count.
+type SurveySubmission struct {
+    SessionID string                   `json:"session_id"`
+    Responses map[string]map[int]string `json:"responses"`
+    IsPartial bool                     `json:"is_partial"`
+    IsFinal   bool                     `json:"is_final"`
+}
+
+func (s SurveySubmission) Validate() error {
+    var p apierror.Problems
+    p.Required("session_id", s.SessionID)
+    for question, rankings := range s.Responses {
+        field := "responses." + question
+        p.Check(question != "", field, "question IDs must not be empty")
+        // Walk ranks in order so a repeat is reported at its lower place.
+        ranks := make([]int, 0, len(rankings))
+        for rank := range rankings {
+            ranks = append(ranks, rank)
+        }
+        sort.Ints(ranks)
+        seen := make(map[string]bool, len(ranks))
+        for _, rank := range ranks {
+            rankedID := rankings[rank]
+            p.Check(rank >= 1, fmt.Sprintf("%s.%d", field, rank), "ranks start at 1")
+            p.Required(fmt.Sprintf("%s.%d", field, rank), rankedID)
+            p.Check(!seen[rankedID], fmt.Sprintf("%s.%d", field, rank), "ranks a student already placed on this question")
+            seen[rankedID] = true
+        }
+    }
+    return p.Err()
+}
+
 type SurveyResponse struct {
 	Question int               `json:"question"`
 	Rankings map[int]string    `json:"rankings"` 
@@ -40,149 +81,134 @@ type SurveyResponse struct {
 
 
 
-func GetSessionDetails(w http.ResponseWriter, r *http.Request) {
+// GetSessionRules returns the phase lengths of a session, in minutes, as
+// its timer runs them.
+func (h *Handlers) GetSessionRules(w http.ResponseWriter, r *http.Request) {
+    sessionID := r.URL.Query().Get("session_id")
+    if sessionID == "" {
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "session_id is required"))
+        return
+    }
+
+    session, err := h.store.Sessions().Get(r.Context(), sessionID)
+    if err == repository.ErrNotFound {
+        apierror.Write(w, r, apierror.New(http.StatusNotFound, "Session not found"))
+        return
+    }
+    if err != nil {
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "prep_time":       session.PrepMinutes,
+        "discussion_time": session.DiscussionMinutes,
+        "survey_time":     session.SurveyMinutes,
+    })
+}
+
+func (h *Handlers) GetSessionDetails(w http.ResponseWriter, r *http.Request) {
      w.Header().Set("Content-Type", "application/json")
     sessionID := r.URL.Query().Get("session_id")
     if sessionID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "session_id is required"})
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "session_id is required"))
         return
     }
 
     studentID := r.Context().Value("studentID").(string)
-    log.Printf("Fetching session %s for student %s", sessionID, studentID)
+    slog.DebugContext(r.Context(), "fetching session details", "session_id", sessionID)
     
     // First verify the student is part of this session
-    var isParticipant bool
-    err := database.GetDB().QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM session_participants 
-            WHERE session_id = ? AND student_id = ? AND is_dummy = FALSE
-        )`, sessionID, studentID).Scan(&isParticipant)
-    
+    isParticipant, err := h.store.Participants().IsParticipant(r.Context(), sessionID, studentID)
     if err != nil {
-        log.Printf("Database error checking participant: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(r.Context(), "checking session participant failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
 
     if !isParticipant {
-        log.Printf("Student %s not authorized for session %s", studentID, sessionID)
-        w.WriteHeader(http.StatusForbidden)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Not authorized to view this session"})
+        slog.WarnContext(r.Context(), "student is not a participant of session", "session_id", sessionID)
+        apierror.Write(w, r, apierror.New(http.StatusForbidden, "Not authorized to view this session"))
         return
     }
 
-    // Get session details with proper error handling
-    var (
-        id         string
-        venue      string
-        topic      sql.NullString
-        agendaJSON []byte
-        startTimeStr string
-    )
-
-    err = database.GetDB().QueryRow(`
-        SELECT s.id, v.name, s.topic, s.agenda, s.start_time
-        FROM gd_sessions s
-        JOIN venues v ON s.venue_id = v.id
-        WHERE s.id = ?`, sessionID).Scan(
-        &id, &venue, &topic, &agendaJSON, &startTimeStr,
-    )
-
+    session, err := h.store.Sessions().Get(r.Context(), sessionID)
+    var venue repository.Venue
+    if err == nil {
+        venue, err = h.store.Venues().Get(r.Context(), session.VenueID)
+    }
     if err != nil {
-        log.Printf("Database error fetching session: %v", err)
-        if err == sql.ErrNoRows {
-            w.WriteHeader(http.StatusNotFound)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Session not found"})
+        slog.ErrorContext(r.Context(), "fetching session failed", "session_id", sessionID, "error", err)
+        if err == repository.ErrNotFound {
+            apierror.Write(w, r, apierror.New(http.StatusNotFound, "Session not found"))
         } else {
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+            apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         }
         return
     }
 
-    // Parse start_time from string
-    startTime, err := time.Parse("2006-01-02 15:04:05", startTimeStr)
-    if err != nil {
-        log.Printf("Error parsing start_time: %v", err)
-        startTime = time.Now() // Fallback to current time if parsing fails
+    // The topic is chosen when a participant first asks for it
+    topic := session.Topic
+    if topic == "" {
+        err = h.store.InTx(r.Context(), func(tx repository.Store) error {
+            chosen, err := sessionTopic(r.Context(), tx, session)
+            topic = chosen.Text
+            return err
+        })
+        if err != nil {
+            slog.ErrorContext(r.Context(), "choosing session topic failed", "session_id", sessionID, "error", err)
+            apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+            return
+        }
     }
 
-    // Parse agenda with defaults
-    var agenda struct {
-        PrepTime   int `json:"prep_time"`
-        Discussion int `json:"discussion"`
-        Survey     int `json:"survey"`
-    }
-    
-    // Set default values
-    agenda.PrepTime = 1
-    agenda.Discussion = 1
-    agenda.Survey = 1
-    
-    if len(agendaJSON) > 0 {
-        if err := json.Unmarshal(agendaJSON, &agenda); err != nil {
-            log.Printf("Error parsing agenda JSON: %v", err)
-            // Use defaults if parsing fails
-        } else {
-            // Ensure values are in minutes (not seconds)
-            if agenda.Discussion > 5 { // If somehow seconds got stored
-                agenda.Discussion = agenda.Discussion / 5
-            }
-        }
+    // Ensure values are in minutes (not seconds)
+    discussion := session.DiscussionMinutes
+    if discussion > 5 { // If somehow seconds got stored
+        discussion = discussion / 5
     }
 
     response := map[string]interface{}{
-        "id":            id,
-        "venue":         venue,
-        "topic":        topic.String,
-        "prep_time":    agenda.PrepTime,
-        "discussion_time": agenda.Discussion,
-        "survey_time":   agenda.Survey,
-        "start_time":   startTime,
+        "id":            session.ID,
+        "venue":         venue.Name,
+        "topic":        topic,
+        "prep_time":    session.PrepMinutes,
+        "discussion_time": discussion,
+        "survey_time":   session.SurveyMinutes,
+        "start_time":   session.StartTime,
     }
 
-    log.Printf("Successfully fetched session %s", sessionID)
+    slog.DebugContext(r.Context(), "fetched session details", "session_id", sessionID)
     w.Header().Set("Content-Type", "application/json")
     if err := json.NewEncoder(w).Encode(response); err != nil {
-        log.Printf("Error encoding session response: %v", err)
+        slog.ErrorContext(r.Context(), "encoding session response failed", "error", err)
     }
 }
 ////////////////
 
 
-func JoinSession(w http.ResponseWriter, r *http.Request) {
-    log.Println("JoinSession endpoint hit")
-    var qrCapacity struct {
-        ID           string
-        MaxCapacity  int
-        CurrentUsage int
-        IsActive     bool
-        QRGroupID    string
-    }
+func (h *Handlers) JoinSession(w http.ResponseWriter, r *http.Request) {
+    ctx := r.Context()
 
     var request struct {
         QRData string `json:"qr_data"`
     }
     
     if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-        log.Printf("JoinSession decode error: %v", err)
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+        slog.WarnContext(ctx, "invalid join request", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Invalid request format"))
         return
     }
 
     if request.QRData == "" {
-        log.Println("Empty QR data received")
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "QR data is required"})
+        slog.WarnContext(ctx, "join request without QR data")
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "QR data is required"))
         return
     }
 
     studentID := r.Context().Value("studentID").(string)
-    log.Printf("JoinSession request for student %s", studentID)
+    slog.DebugContext(ctx, "join requested")
     
     // Parse QR data
     var qrPayload struct {
@@ -190,186 +216,120 @@ func JoinSession(w http.ResponseWriter, r *http.Request) {
         Expiry  string `json:"expiry"`
     }
     if err := json.Unmarshal([]byte(request.QRData), &qrPayload); err != nil {
-        log.Printf("QR data parsing error: %v", err)
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid QR code format"})
+        slog.WarnContext(ctx, "QR data is not valid JSON", "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Invalid QR code format"))
         return
     }
 
-    log.Printf("QR payload parsed - VenueID: %s, Expiry: %s", qrPayload.VenueID, qrPayload.Expiry)
+    ctx = logging.With(ctx, slog.String("venue_id", qrPayload.VenueID))
+    slog.DebugContext(ctx, "parsed QR payload", "expiry", qrPayload.Expiry)
 
     // Verify QR code against database and get QR details
-    err := database.GetDB().QueryRow(`
-        SELECT id, max_capacity, current_usage, is_active, qr_group_id
-        FROM venue_qr_codes 
-        WHERE qr_data = ? AND venue_id = ?`,
-        request.QRData, qrPayload.VenueID).Scan(&qrCapacity.ID, &qrCapacity.MaxCapacity, 
-        &qrCapacity.CurrentUsage, &qrCapacity.IsActive, &qrCapacity.QRGroupID)
-
+    qrCode, err := h.store.QRCodes().FindByData(ctx, request.QRData, qrPayload.VenueID)
     if err != nil {
-        if err == sql.ErrNoRows {
-            log.Printf("No active QR code found for venue %s", qrPayload.VenueID)
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired QR code"})
+        if err == repository.ErrNotFound {
+            slog.WarnContext(ctx, "QR code rejected", "reason", "invalid")
+            metrics.QRRejections.Inc("invalid")
+            apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Invalid or expired QR code").WithCode(apierror.CodeQRInvalid))
         } else {
-            log.Printf("QR capacity check error: %v", err)
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "QR code validation failed"})
+            apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "QR code validation failed", err))
         }
         return
     }
 
-    if !qrCapacity.IsActive {
-        log.Printf("QR code is not active")
-        w.WriteHeader(http.StatusUnauthorized)
-        json.NewEncoder(w).Encode(map[string]string{"error": "QR code is no longer active"})
+    if !qrCode.IsActive {
+        slog.WarnContext(ctx, "QR code rejected", "reason", "inactive", "qr_id", qrCode.ID)
+        metrics.QRRejections.Inc("inactive")
+        apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "QR code is no longer active").WithCode(apierror.CodeQRInactive))
         return
     }
 
-    if qrCapacity.CurrentUsage >= qrCapacity.MaxCapacity {
-        log.Printf("QR code is full: %d/%d", qrCapacity.CurrentUsage, qrCapacity.MaxCapacity)
-        w.WriteHeader(http.StatusForbidden)
-        json.NewEncoder(w).Encode(map[string]string{"error": "This QR code has reached its capacity limit"})
+    if qrCode.CurrentUsage >= qrCode.MaxCapacity {
+        slog.WarnContext(ctx, "QR code rejected", "reason", "full", "qr_id", qrCode.ID,
+            "usage", qrCode.CurrentUsage, "capacity", qrCode.MaxCapacity)
+        metrics.QRRejections.Inc("full")
+        apierror.Write(w, r, apierror.New(http.StatusForbidden, "This QR code has reached its capacity limit").WithCode(apierror.CodeQRFull))
         return
     }
 
     // Increment QR usage
-    _, err = database.GetDB().Exec(`
-        UPDATE venue_qr_codes 
-        SET current_usage = current_usage + 1 
-        WHERE id = ?`,
-        qrCapacity.ID)
-
-    if err != nil {
-        log.Printf("Failed to update QR usage: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to join session"})
+    if err := h.store.QRCodes().IncrementUsage(ctx, qrCode.ID); err != nil {
+        slog.ErrorContext(ctx, "incrementing QR usage failed", "qr_id", qrCode.ID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to join session"))
         return
     }
 
     // Find or create session for this specific QR group
     var sessionID string
-    tx, err := database.GetDB().Begin()
-    if err != nil {
-        log.Printf("Failed to begin transaction: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
-    defer tx.Rollback()
-
-    // First clear any old phase tracking for this student
-    _, err = tx.Exec(`
-        DELETE FROM session_phase_tracking 
-        WHERE student_id = ?`, 
-        studentID)
-    if err != nil {
-        log.Printf("Failed to clear old phase tracking: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to join session"})
-        return
-    }
-
-    // First check if there's an active session for this QR group
-    err = tx.QueryRow(`
-        SELECT id FROM gd_sessions 
-        WHERE venue_id = ? AND qr_group_id = ? AND status IN ('pending', 'active')
-        ORDER BY created_at DESC LIMIT 1`,
-        qrPayload.VenueID, qrCapacity.QRGroupID).Scan(&sessionID)
+    err = h.store.InTx(ctx, func(tx repository.Store) error {
+        // First clear any old phase tracking for this student
+        if err := tx.Participants().ClearPhases(ctx, studentID); err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Failed to join session", err)
+        }
 
-    if err != nil && err != sql.ErrNoRows {
-        log.Printf("Database error finding venue session: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
+        // First check if there's an active session for this QR group
+        session, err := tx.Sessions().FindOpenByQRGroup(ctx, qrPayload.VenueID, qrCode.QRGroupID)
+        switch {
+        case err == repository.ErrNotFound:
+            // If no session exists, create one with the QR group ID
+            sessionID = uuid.New().String()
+            err = tx.Sessions().Create(ctx, repository.Session{
+                ID:        sessionID,
+                VenueID:   qrPayload.VenueID,
+                Status:    "active",
+                QRGroupID: qrCode.QRGroupID,
+            })
+            if err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Failed to create session", err)
+            }
+            slog.InfoContext(ctx, "created session for QR group", "session_id", sessionID, "qr_group_id", qrCode.QRGroupID)
+            // Read back the times the session was given
+            session, err = tx.Sessions().Get(ctx, sessionID)
+            if err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            }
+        case err != nil:
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        default:
+            sessionID = session.ID
+        }
 
-    // If no session exists, create one with the QR group ID
-    if err == sql.ErrNoRows {
-        sessionID = uuid.New().String()
-        _, err = tx.Exec(`
-            INSERT INTO gd_sessions 
-            (id, venue_id, status, start_time, end_time, level, qr_group_id) 
-            VALUES (?, ?, 'active', NOW(), DATE_ADD(NOW(), INTERVAL 1 HOUR), 
-                   (SELECT level FROM venues WHERE id = ?), ?)`,
-            sessionID, qrPayload.VenueID, qrPayload.VenueID, qrCapacity.QRGroupID)
+        // Check if student is already in this session
+        isParticipant, err := tx.Participants().IsParticipant(ctx, sessionID, studentID)
         if err != nil {
-            log.Printf("Failed to create session: %v", err)
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create session"})
-            return
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
         }
-        log.Printf("Created new session %s for venue %s with QR group %s", sessionID, qrPayload.VenueID, qrCapacity.QRGroupID)
-    }
 
-    // Check if student is already in this session
-    var isParticipant bool
-    err = tx.QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM session_participants 
-            WHERE session_id = ? AND student_id = ? AND is_dummy = FALSE
-        )`, sessionID, studentID).Scan(&isParticipant)
-
-    if err != nil {
-        log.Printf("Database error checking participation: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
-
-    if !isParticipant {
-        // Add student to session
-        _, err = tx.Exec(`
-            INSERT INTO session_participants 
-            (id, session_id, student_id, is_dummy) 
-            VALUES (UUID(), ?, ?, FALSE)`,
-            sessionID, studentID)
-        
-        if err != nil {
-            log.Printf("Failed to add participant: %v", err)
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Failed to join session"})
-            return
+        if !isParticipant {
+            if err := checkOverlap(ctx, tx, studentID, session); err != nil {
+                return err
+            }
+            // Add student to session
+            if err := tx.Participants().Add(ctx, sessionID, studentID); err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Failed to join session", err)
+            }
+            slog.DebugContext(ctx, "added session participant", "session_id", sessionID)
         }
-        log.Printf("Added student %s to session %s as participant", studentID, sessionID)
-    }
 
-    // Add phase tracking (marks QR code scanned)
-    _, err = tx.Exec(`
-        INSERT INTO session_phase_tracking 
-        (session_id, student_id, phase, start_time)
-        VALUES (?, ?, 'prep', NOW())`,
-        sessionID, studentID)
-    
-    if err != nil {
-        log.Printf("Failed to update phase tracking: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update session phase"})
-        return
-    }
-    log.Printf("Updated phase tracking for student %s in session %s", studentID, sessionID)
+        // Add phase tracking (marks QR code scanned)
+        if err := tx.Participants().StartPhase(ctx, sessionID, studentID, "prep"); err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Failed to update session phase", err)
+        }
+        slog.DebugContext(ctx, "started prep phase", "session_id", sessionID)
 
-    // Update session status to active if not already
-    _, err = tx.Exec(`
-        UPDATE gd_sessions 
-        SET status = 'active' 
-        WHERE id = ? AND status = 'pending'`,
-        sessionID)
+        // Update session status to active if not already
+        if err := tx.Sessions().Activate(ctx, sessionID); err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Failed to activate session", err)
+        }
+        return nil
+    })
     if err != nil {
-        log.Printf("Failed to activate session: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to activate session"})
+        apierror.Write(w, r, err)
         return
     }
 
-    if err := tx.Commit(); err != nil {
-        log.Printf("Failed to commit transaction: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to join session"})
-        return
-    }
-
-    log.Printf("Successfully joined session %s for student %s", sessionID, studentID)
+    slog.InfoContext(ctx, "student joined session", "session_id", sessionID)
+    metrics.SessionJoins.Inc()
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]string{
         "status":     "joined",
@@ -377,202 +337,156 @@ func JoinSession(w http.ResponseWriter, r *http.Request) {
     })
 }
 
-func SubmitSurvey(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) SubmitSurvey(w http.ResponseWriter, r *http.Request) {
+    ctx := r.Context()
     studentID := r.Context().Value("studentID").(string)
-    log.Printf("SubmitSurvey started for student %s", studentID)
-    
-    var req struct {
-        SessionID string                   `json:"session_id"`
-        Responses map[int]map[int]string   `json:"responses"`
-        IsPartial bool                     `json:"is_partial"`
-        IsFinal   bool                     `json:"is_final"`
-    }
     
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("Survey decode error: %v", err)
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+    var req SurveySubmission
+    if err := apierror.Decode(r, &req); err != nil {
+        slog.WarnContext(ctx, "invalid survey submission", "error", err)
+        apierror.Write(w, r, err)
         return
     }
+    ctx = logging.With(ctx, slog.String("session_id", req.SessionID))
 
-    tx, err := database.GetDB().Begin()
-    if err != nil {
-        log.Printf("Failed to begin transaction: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
-    defer tx.Rollback()
+    var answeredQuestionsCount, totalQuestions int
+    err := h.store.InTx(ctx, func(tx repository.Store) error {
+        // Get session level
+        session, err := tx.Sessions().Get(ctx, req.SessionID)
+        if err == repository.ErrNotFound {
+            return apierror.New(http.StatusNotFound, "Session not found")
+        }
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        }
+        if session.Finalized {
+            return apierror.New(http.StatusConflict, "Session results are already final")
+        }
+        // While responses are under collusion review they are frozen.
+        if pending, err := pendingFlags(ctx, tx, session.ID); err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        } else if pending > 0 {
+            return apierror.New(http.StatusConflict, "Session responses are under review")
+        }
+        // Responses voided on review can't be replaced.
+        if voided, err := voidedResponder(ctx, tx, session.ID, studentID); err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        } else if voided {
+            return apierror.New(http.StatusConflict, "Your responses to this session were voided on review")
+        }
+        sessionLevel := session.Level
 
-    // Get session level
-    var sessionLevel int
-    err = tx.QueryRow("SELECT level FROM gd_sessions WHERE id = ?", req.SessionID).Scan(&sessionLevel)
-    if err != nil {
-        log.Printf("Error getting session level: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
+        // Only participants answer, and they rank the other participants.
+        members, err := tx.Participants().List(ctx, session.ID)
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        }
+        inSession := make(map[string]bool, len(members))
+        for _, m := range members {
+            inSession[m.StudentID] = true
+        }
+        if !inSession[studentID] {
+            return apierror.New(http.StatusForbidden, "Not a participant of the session")
+        }
 
-    // Get questions
-    rows, err := tx.Query(`
-        SELECT id, weight 
-        FROM survey_questions 
-        WHERE level = ? AND is_active = TRUE 
-        ORDER BY display_order`,
-        sessionLevel)
-    if err != nil {
-        log.Printf("Error getting questions: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
-    defer rows.Close()
+        // Answers are checked against the questions this student was served
+        questions, err := servedQuestions(ctx, tx, session, studentID)
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        }
+        questionMappings := make(map[string]repository.Question, len(questions))
+        for _, q := range questions {
+            questionMappings[q.ID] = q
+        }
+        totalQuestions = len(questionMappings)
 
-    questionMappings := make(map[int]struct {
-        ID     string
-        Weight float64
-    })
-    
-    index := 1
-    for rows.Next() {
-        var questionID string
-        var weight float64
-        if err := rows.Scan(&questionID, &weight); err != nil {
-            continue
+        // Responders rank as many students as the session's points go deep
+        config, err := scoringConfig(ctx, tx, session)
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        }
+        points := config.Points
+        var problems apierror.Problems
+        for questionID, rankings := range req.Responses {
+            _, served := questionMappings[questionID]
+            problems.Check(served, "responses."+questionID, "was not served to you in this session")
+            for rank, rankedID := range rankings {
+                field := fmt.Sprintf("responses.%s.%d", questionID, rank)
+                problems.Check(rank <= len(points), field,
+                    fmt.Sprintf("level %d ranks at most %d students", sessionLevel, len(points)))
+                problems.Check(rankedID != studentID, field, "cannot rank yourself")
+                problems.Check(rankedID == studentID || inSession[rankedID], field, "is not a participant of the session")
+            }
+        }
+        if err := problems.Err(); err != nil {
+            return err
         }
-        questionMappings[index] = struct {
-            ID     string
-            Weight float64
-        }{ID: questionID, Weight: weight}
-        index++
-    }
 
-    totalQuestions := len(questionMappings)
+        // Process each question response
+        for questionID, rankings := range req.Responses {
+            questionMapping := questionMappings[questionID]
 
-    // Calculate consensus rankings for this session
-    consensus, err := calculateConsensusRankings(req.SessionID)
-    if err != nil {
-        log.Printf("Error calculating consensus: %v", err)
-    }
+            // Clear previous responses
+            if err := tx.Surveys().DeleteResponses(ctx, req.SessionID, studentID, questionMapping.ID); err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            }
 
-    // Process each question response
-    for questionNumber, rankings := range req.Responses {
-        questionMapping, exists := questionMappings[questionNumber]
-        if !exists {
-            continue
+            // Save rankings; bias penalties wait until every participant has answered
+            for rank, rankedStudentID := range rankings {
+                finalScore := points[rank-1] * questionMapping.Weight
+
+                err := tx.Surveys().SaveResult(ctx, repository.SurveyResult{
+                    SessionID:     req.SessionID,
+                    StudentID:     rankedStudentID,
+                    ResponderID:   studentID,
+                    QuestionID:    questionMapping.ID,
+                    Rank:          rank,
+                    Score:         finalScore,
+                    WeightedScore: finalScore,
+                })
+                if err != nil {
+                    return apierror.Wrap(http.StatusInternalServerError, "Failed to save survey response", err)
+                }
+            }
         }
 
-        // Clear previous responses
-        _, err = tx.Exec(`
-            DELETE FROM survey_results 
-            WHERE session_id = ? AND responder_id = ? AND question_id = ?`,
-            req.SessionID, studentID, questionMapping.ID)
+        // Check if ALL questions have been answered by counting responses
+        answeredQuestionsCount, err = tx.Surveys().CountAnswered(ctx, req.SessionID, studentID)
         if err != nil {
-            log.Printf("Error clearing previous responses: %v", err)
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-            return
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
         }
 
-        // Calculate penalty for this question
-        penaltyPoints, isBiased := calculateRankingPenalty(rankings, consensus)
-        
-        // Save rankings with penalty
-        for rank, rankedStudentID := range rankings {
-            basePoints, err := getRankingPoints(sessionLevel, rank)
-            if err != nil {
-                basePoints = 5 - float64(rank)
+        slog.DebugContext(ctx, "survey progress", "answered", answeredQuestionsCount, "total", totalQuestions)
+
+        // Only mark as completed if ALL questions are answered
+        if answeredQuestionsCount >= totalQuestions {
+            // Mark survey as completed and flag this student's results
+            if err := tx.Surveys().MarkCompleted(ctx, req.SessionID, studentID); err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Failed to mark survey completion", err)
             }
             
-            finalScore := basePoints * questionMapping.Weight
-            
-            // Apply penalty to the score
-            penalizedScore := finalScore - penaltyPoints
-            if penalizedScore < 0 {
-                penalizedScore = 0
-            }
+            slog.InfoContext(ctx, "survey completed")
 
-            _, err = tx.Exec(`
-                INSERT INTO survey_results 
-                (id, session_id, student_id, responder_id, question_id, ranks, score, 
-                 weighted_score, penalty_points, is_biased, is_current_session, is_completed)
-                VALUES (UUID(), ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, 0)`,
-                req.SessionID, rankedStudentID, studentID, questionMapping.ID, rank, 
-                finalScore, penalizedScore, penaltyPoints, isBiased)
-            
+            // The last present participant to finish finalizes the session.
+            done, err := surveyComplete(ctx, tx, req.SessionID)
             if err != nil {
-                tx.Rollback()
-                log.Printf("Error saving survey response: %v", err)
-                w.WriteHeader(http.StatusInternalServerError)
-                json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save survey response"})
-                return
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            }
+            if done {
+                if err := finalizeSession(ctx, tx, req.SessionID); err != nil {
+                    return apierror.Wrap(http.StatusInternalServerError, "Failed to finalize session", err)
+                }
             }
         }
-    }
-
-
-    // Check if ALL questions have been answered by counting responses
-    var answeredQuestionsCount int
-    err = tx.QueryRow(`
-        SELECT COUNT(DISTINCT question_id) 
-        FROM survey_results 
-        WHERE session_id = ? AND responder_id = ?`,
-        req.SessionID, studentID).Scan(&answeredQuestionsCount)
-    
+        return nil
+    })
     if err != nil {
-        log.Printf("Error counting answered questions: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
-
-    log.Printf("Student %s has answered %d out of %d questions", studentID, answeredQuestionsCount, totalQuestions)
-
-    // Only mark as completed if ALL questions are answered
-    if answeredQuestionsCount >= totalQuestions {
-        log.Printf("All questions completed for student %s in session %s", studentID, req.SessionID)
-        
-        // Mark survey as completed in survey_completion table
-        _, err = tx.Exec(`
-            INSERT INTO survey_completion (session_id, student_id, completed_at)
-            VALUES (?, ?, NOW())
-            ON DUPLICATE KEY UPDATE completed_at = NOW()`,
-            req.SessionID, studentID)
-        
-        if err != nil {
-            log.Printf("Error marking survey completion: %v", err)
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Failed to mark survey completion"})
-            return
-        }
-        
-        // Update all survey_results records for this student to mark as completed
-        _, err = tx.Exec(`
-            UPDATE survey_results 
-            SET is_completed = 1 
-            WHERE session_id = ? AND responder_id = ?`,
-            req.SessionID, studentID)
-        if err != nil {
-            log.Printf("Error updating survey_results completion status: %v", err)
-            // Don't fail the whole request for this
-        }
-        
-        log.Printf("Survey marked as completed for student %s in session %s", studentID, req.SessionID)
-    } else {
-        log.Printf("Survey not yet completed for student %s (%d/%d questions)", 
-            studentID, answeredQuestionsCount, totalQuestions)
-    }
-
-    if err := tx.Commit(); err != nil {
-        log.Printf("Error committing transaction: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save survey"})
+        apierror.Write(w, r, err)
         return
     }
 
-    log.Printf("Successfully processed survey submission for student %s in session %s", 
-        studentID, req.SessionID)
+    slog.InfoContext(ctx, "survey submission accepted", "completed", answeredQuestionsCount >= totalQuestions)
+    metrics.SurveySubmissions.Inc()
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]interface{}{
         "status": "success",
@@ -582,17 +496,20 @@ func SubmitSurvey(w http.ResponseWriter, r *http.Request) {
     })
 }
 
-func UpdateSessionStatus(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) UpdateSessionStatus(w http.ResponseWriter, r *http.Request) {
     var req struct {
         SessionID string `json:"sessionId"`
         Status    string `json:"status"`
     }
     
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid request format", err))
         return
     }
+    // The body field predates the /api/v1 path parameter and is spelled differently.
+    if id := r.PathValue("session_id"); id != "" {
+        req.SessionID = id
+    }
 
     // Validate status
     validStatuses := map[string]bool{
@@ -602,90 +519,19 @@ func UpdateSessionStatus(w http.ResponseWriter, r *http.Request) {
         "completed": true,
     }
     if !validStatuses[req.Status] {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid status"})
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Invalid status"))
         return
     }
 
-    _, err := database.GetDB().Exec(`
-        UPDATE gd_sessions 
-        SET status = ?
-        WHERE id = ?`,
-        req.Status, req.SessionID)
-    
-    if err != nil {
-        log.Printf("Failed to update session status: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update session status"})
+    if err := h.store.Sessions().SetStatus(r.Context(), req.SessionID, req.Status); err != nil {
+        slog.ErrorContext(r.Context(), "updating session status failed", "session_id", req.SessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Failed to update session status"))
         return
     }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
 }
-func calculatePenalty(sessionID string, studentID string) (float64, error) {
-    var totalPenalty float64
-    err := database.GetDB().QueryRow(`
-        SELECT COALESCE(SUM(penalty_points), 0) 
-        FROM survey_penalties 
-        WHERE session_id = ? AND student_id = ?`,
-        sessionID, studentID).Scan(&totalPenalty)
-    
-    if err != nil {
-        return 0, fmt.Errorf("error calculating penalties: %v", err)
-    }
-    return totalPenalty, nil
-}
-// calculateConsensusRankings calculates the consensus rankings for a session
-func calculateConsensusRankings(sessionID string) (map[string]int, error) {
-    consensus := make(map[string]int)
-    
-    // Get all completed surveys for this session
-    rows, err := database.GetDB().Query(`
-        SELECT student_id, ranks, COUNT(*) as vote_count
-        FROM survey_results 
-        WHERE session_id = ? AND is_completed = 1
-        GROUP BY student_id, ranks
-        ORDER BY student_id, vote_count DESC`,
-        sessionID)
-    
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-
-    currentStudent := ""
-    maxVotes := 0
-    currentRank := 0
-    
-    for rows.Next() {
-        var studentID string
-        var rank, voteCount int
-        
-        if err := rows.Scan(&studentID, &rank, &voteCount); err != nil {
-            continue
-        }
-        
-        if studentID != currentStudent {
-            if currentStudent != "" {
-                consensus[currentStudent] = currentRank
-            }
-            currentStudent = studentID
-            maxVotes = voteCount
-            currentRank = rank
-        } else if voteCount > maxVotes {
-            maxVotes = voteCount
-            currentRank = rank
-        }
-    }
-    
-    if currentStudent != "" {
-        consensus[currentStudent] = currentRank
-    }
-    
-    return consensus, nil
-}
-
 // calculateRankingPenalty calculates penalty points for biased rankings
 func calculateRankingPenalty(userRankings map[int]string, consensus map[string]int) (float64, bool) {
     if len(consensus) == 0 {
@@ -730,373 +576,247 @@ func abs(x int) int {
     }
     return x
 }
-func GetResults(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetResults(w http.ResponseWriter, r *http.Request) {
+    ctx := r.Context()
     sessionID := r.URL.Query().Get("session_id")
     studentID := r.Context().Value("studentID").(string)
 
     // Verify student is part of this session
-    var isParticipant bool
-    err := database.GetDB().QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM session_participants 
-            WHERE session_id = ? AND student_id = ? AND is_dummy = FALSE
-        )`, sessionID, studentID).Scan(&isParticipant)
-    
+    isParticipant, err := h.store.Participants().IsParticipant(ctx, sessionID, studentID)
     if err != nil {
-        log.Printf("Database error checking participant: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(ctx, "checking session participant failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
 
     if !isParticipant {
-        log.Printf("Student %s not authorized for session %s", studentID, sessionID)
-        w.WriteHeader(http.StatusForbidden)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Not authorized to view these results"})
+        slog.WarnContext(ctx, "student is not a participant of session", "session_id", sessionID)
+        apierror.Write(w, r, apierror.New(http.StatusForbidden, "Not authorized to view these results"))
         return
     }
 
     // Get all participants in this session (including the current student) with photo_url
-    participants := make(map[string]struct {
-        Name      string
-        PhotoURL  string
-    })
-    
-    rows, err := database.GetDB().Query(`
-        SELECT su.id, su.full_name, COALESCE(su.photo_url, '') as photo_url
-        FROM student_users su
-        JOIN session_participants sp ON su.id = sp.student_id
-        WHERE sp.session_id = ? AND sp.is_dummy = FALSE`, 
-        sessionID)
-    
+    members, err := h.store.Participants().List(ctx, sessionID)
     if err != nil {
-        log.Printf("Error getting participants: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(ctx, "listing session participants failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
-    defer rows.Close()
-    
-    for rows.Next() {
-        var id, name, photoURL string
-        if err := rows.Scan(&id, &name, &photoURL); err != nil {
-            continue
-        }
-        
-        // Use default avatar if no photo URL
-        if photoURL == "" {
-            photoURL = "https://ui-avatars.com/api/?name=" + url.QueryEscape(name) + "&background=random&color=fff"
-        }
-        
-        participants[id] = struct {
-            Name      string
-            PhotoURL  string
-        }{
-            Name:     name,
-            PhotoURL: photoURL,
-        }
-    }
 
-    // Get all survey responses for this session with penalties
-    type SurveyResult struct {
-        StudentID   string  // The student being ranked
-        Score       float64 // The score they received
-        Rank        int     // The rank they received
-    }
-    
-    // Create a map to store scores for each student
-    studentScores := make(map[string]*struct {
-        TotalScore      float64
-        FirstPlaces     int
-        Penalty         float64
-        BiasedQuestions int
-    })
-    
-    // Initialize all participants with zero scores
-    for id := range participants {
-        studentScores[id] = &struct {
-            TotalScore      float64
-            FirstPlaces     int
-            Penalty         float64
-            BiasedQuestions int
-        }{}
-    }
-
-    // Get scores and penalties for each student
-    rows, err = database.GetDB().Query(`
-        SELECT student_id, 
-               SUM(score) as total_score,
-               SUM(penalty_points) as total_penalty,
-               COUNT(CASE WHEN is_biased THEN 1 END) as biased_questions
-        FROM survey_results 
-        WHERE session_id = ? AND is_current_session = 1
-        GROUP BY student_id`, sessionID)
-    
+    // Finalized sessions are served from their snapshot; until then the
+    // standings are provisional and computed from the current results.
+    results, err := h.store.Results().ForSession(ctx, sessionID)
     if err != nil {
-        log.Printf("Error getting survey responses: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(ctx, "loading session results failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
-    defer rows.Close()
-    
-    for rows.Next() {
-        var studentID string
-        var totalScore, totalPenalty float64
-        var biasedQuestions int
-        
-        if err := rows.Scan(&studentID, &totalScore, &totalPenalty, &biasedQuestions); err != nil {
-            log.Printf("Error scanning survey results: %v", err)
-            continue
-        }
-        
-        if studentData, exists := studentScores[studentID]; exists {
-            studentData.TotalScore = totalScore
-            studentData.Penalty = totalPenalty
-            studentData.BiasedQuestions = biasedQuestions
+    finalized := len(results) > 0
+    if !finalized {
+        summaries, err := h.store.Surveys().ScoreSummaries(ctx, sessionID)
+        if err != nil {
+            slog.ErrorContext(ctx, "loading score summaries failed", "session_id", sessionID, "error", err)
+            apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+            return
         }
-    }
-
-    // Get first place counts separately
-    rows, err = database.GetDB().Query(`
-        SELECT student_id, COUNT(*) as first_places
-        FROM survey_results 
-        WHERE session_id = ? AND ranks = 1 AND is_current_session = 1
-        GROUP BY student_id`, sessionID)
-    
-    if err != nil {
-        log.Printf("Error getting first places: %v", err)
-    } else {
-        defer rows.Close()
-        for rows.Next() {
-            var studentID string
-            var firstPlaces int
-            if err := rows.Scan(&studentID, &firstPlaces); err != nil {
-                continue
-            }
-            if studentData, exists := studentScores[studentID]; exists {
-                studentData.FirstPlaces = firstPlaces
+        var mod moderation
+        if session, err := h.store.Sessions().Get(ctx, sessionID); err == nil {
+            if mod, err = sessionModeration(ctx, h.store, session); err != nil {
+                slog.ErrorContext(ctx, "loading moderator marks failed", "session_id", sessionID, "error", err)
+                apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+                return
             }
         }
+        results = rankSessionResults(members, summaries, mod, repository.QualificationRule{})
     }
 
-    // Prepare results for sorting
-    type StudentResult struct {
-        ID              string
-        Name            string
-        PhotoURL        string
-        TotalScore      float64
-        Penalty         float64
-        FinalScore      float64
-        FirstPlaces     int
-        BiasedQuestions int
+    response := map[string]interface{}{
+        "results":    formatResults(members, results, finalized),
+        "session_id": sessionID,
+        "finalized":  finalized,
     }
-    
-    var sortedResults []StudentResult
-    for id, data := range studentScores {
-        finalScore := data.TotalScore - data.Penalty
-        participant := participants[id]
-        sortedResults = append(sortedResults, StudentResult{
-            ID:              id,
-            Name:            participant.Name,
-            PhotoURL:        participant.PhotoURL,
-            TotalScore:      data.TotalScore,
-            Penalty:         data.Penalty,
-            FinalScore:      finalScore,
-            FirstPlaces:     data.FirstPlaces,
-            BiasedQuestions: data.BiasedQuestions,
-        })
+    if !finalized {
+        pending, err := pendingFlags(ctx, h.store, sessionID)
+        if err != nil {
+            slog.ErrorContext(ctx, "loading collusion flags failed", "session_id", sessionID, "error", err)
+            apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+            return
+        }
+        response["under_review"] = pending > 0
     }
-
-    // Sort results by final score (descending)
-    sort.Slice(sortedResults, func(i, j int) bool {
-        if sortedResults[i].FinalScore != sortedResults[j].FinalScore {
-            return sortedResults[i].FinalScore > sortedResults[j].FinalScore
+    // Students see their own rubric marks once the results are final.
+    if finalized {
+        rubric, feedback, err := h.ownRubric(ctx, sessionID, studentID)
+        if err != nil {
+            slog.ErrorContext(ctx, "loading rubric marks failed", "session_id", sessionID, "error", err)
+            apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+            return
+        }
+        if len(rubric) > 0 || feedback != "" {
+            response["rubric"] = rubric
+            response["feedback"] = feedback
         }
-        return sortedResults[i].FirstPlaces > sortedResults[j].FirstPlaces
-    })
-
-    // Prepare response
-    var response []map[string]interface{}
-    for _, r := range sortedResults {
-        response = append(response, map[string]interface{}{
-            "student_id":       r.ID,
-            "name":             r.Name,
-            "photo_url":        r.PhotoURL,
-            "total_score":      fmt.Sprintf("%.2f", r.TotalScore),
-            "penalty_points":   fmt.Sprintf("%.2f", r.Penalty),
-            "final_score":      fmt.Sprintf("%.2f", r.FinalScore),
-            "first_places":     r.FirstPlaces,
-            "biased_questions": r.BiasedQuestions,
-        })
     }
 
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "results":    response,
-        "session_id": sessionID,
-    })
+    json.NewEncoder(w).Encode(response)
 }
 
-
-
-func BookVenue(w http.ResponseWriter, r *http.Request) {
-    studentID := r.Context().Value("studentID").(string)
-    
-    var req BookingRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
-        return
+// formatResults formats ranked results for the results screen. Qualification
+// is only reported once the results are final.
+func formatResults(members []repository.Participant, results []repository.SessionResult, finalized bool) []map[string]interface{} {
+    profiles := make(map[string]repository.Participant, len(members))
+    for _, m := range members {
+        profiles[m.StudentID] = m
     }
-    req.StudentID = studentID
-
-
-    
 
+    var response []map[string]interface{}
+    for _, res := range results {
+        m := profiles[res.StudentID]
+        // Use default avatar if no photo URL
+        photoURL := m.PhotoURL
+        if photoURL == "" {
+            photoURL = "https://ui-avatars.com/api/?name=" + url.QueryEscape(m.Name) + "&background=random&color=fff"
+        }
 
-    // Start transaction
-    tx, err := database.GetDB().Begin()
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
+        entry := map[string]interface{}{
+            "student_id":       res.StudentID,
+            "name":             m.Name,
+            "photo_url":        photoURL,
+            "rank":             res.Rank,
+            "total_score":      fmt.Sprintf("%.2f", res.TotalScore),
+            "penalty_points":   fmt.Sprintf("%.2f", res.PenaltyPoints),
+            "final_score":      fmt.Sprintf("%.2f", res.FinalScore),
+            "first_places":     res.FirstPlaces,
+            "biased_questions": res.BiasedQuestions,
+        }
+        if res.ModeratorWeight > 0 {
+            entry["peer_score"] = fmt.Sprintf("%.2f", res.PeerScore)
+            entry["moderator_weight"] = res.ModeratorWeight
+            if res.Moderated {
+                entry["moderator_score"] = fmt.Sprintf("%.2f", res.ModeratorScore)
+            }
+        }
+        if finalized {
+            entry["qualified"] = res.Qualified
+        }
+        response = append(response, entry)
     }
-    defer tx.Rollback() 
-
+    return response
+}
 
-	var studentLevel int
-    err = tx.QueryRow("SELECT current_gd_level FROM student_users WHERE id = ?", studentID).Scan(&studentLevel)
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to verify student level"})
-        return
-    }
 
-    // Get venue level
-    var venueLevel int
-    err = tx.QueryRow("SELECT level FROM venues WHERE id = ?", req.VenueID).Scan(&venueLevel)
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to verify venue level"})
-        return
+// checkOverlap refuses to put a student in a session that overlaps another
+// session they are in, since they can't be in two at once.
+func checkOverlap(ctx context.Context, tx repository.Store, studentID string, session repository.Session) error {
+    other, err := tx.Participants().Overlapping(ctx, studentID, session)
+    switch {
+    case err == nil:
+        return apierror.New(http.StatusConflict,
+            fmt.Sprintf("This session overlaps another session you are in, from %s to %s",
+                other.StartTime.Format("Jan 2 15:04"), other.EndTime.Format("15:04 MST"))).WithCode(apierror.CodeScheduleConflict)
+    case err != repository.ErrNotFound:
+        return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
     }
+    return nil
+}
 
-    // Check if student is trying to book a venue of their level
-    if studentLevel != venueLevel {
-        w.WriteHeader(http.StatusForbidden)
-        json.NewEncoder(w).Encode(map[string]string{
-            "error": fmt.Sprintf("You can only book venues for your current level (Level %d)", studentLevel),
-        })
+func (h *Handlers) BookVenue(w http.ResponseWriter, r *http.Request) {
+    ctx := r.Context()
+    studentID := r.Context().Value("studentID").(string)
+    
+    var req BookingRequest
+    if err := apierror.Decode(r, &req); err != nil {
+        apierror.Write(w, r, err)
         return
     }
+    req.StudentID = studentID
 
+    var sessionID string
+    var capacity, booked int
+    err := h.store.InTx(ctx, func(tx repository.Store) error {
+        student, err := tx.Students().Get(ctx, studentID)
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Failed to verify student level", err)
+        }
 
+        // Get venue level
+        venue, err := tx.Venues().Get(ctx, req.VenueID)
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Failed to verify venue level", err)
+        }
 
+        // Check if student is trying to book a venue of their level
+        if student.Level != venue.Level {
+            return apierror.Wrap(http.StatusForbidden,
+                fmt.Sprintf("You can only book venues for your current level (Level %d)", student.Level), nil)
+        }
 
-	 var activeBookingCount int
-    err = tx.QueryRow(`
-        SELECT COUNT(*) 
-        FROM session_participants sp
-        JOIN gd_sessions s ON sp.session_id = s.id
-        WHERE sp.student_id = ? AND s.status = 'pending'`, 
-        studentID).Scan(&activeBookingCount)
+        activeBookingCount, err := tx.Participants().CountPendingBookings(ctx, studentID)
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        }
 
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
+        if activeBookingCount > 0 {
+            return apierror.Wrap(http.StatusForbidden,
+                "You already have an active booking. Complete or cancel it before booking another venue", nil)
+        }
 
-    if activeBookingCount > 0 {
-        w.WriteHeader(http.StatusForbidden)
-        json.NewEncoder(w).Encode(map[string]string{
-            "error": "You already have an active booking. Complete or cancel it before booking another venue",
-        })
-        return
-    }
+        // Check if venue is active and get capacity
+        if !venue.IsActive {
+            return apierror.New(http.StatusNotFound, "Venue not found")
+        }
+        capacity = venue.Capacity
+        booked, err = tx.Venues().BookedCount(ctx, req.VenueID)
+        if err != nil {
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        }
 
+        // Check capacity
+        if booked >= capacity {
+            return apierror.New(http.StatusConflict, "Venue is full")
+        }
 
-    // Check if venue exists and get capacity
-    var capacity, booked int
-    err = tx.QueryRow(`
-        SELECT v.capacity, 
-               (SELECT COUNT(*) FROM session_participants sp 
-                JOIN gd_sessions s ON sp.session_id = s.id 
-                WHERE s.venue_id = v.id) as booked
-        FROM venues v 
-        WHERE v.id = ? AND v.is_active = TRUE`, req.VenueID).Scan(&capacity, &booked)
-    
-    if err != nil {
-        if err == sql.ErrNoRows {
-            w.WriteHeader(http.StatusNotFound)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Venue not found"})
-        } else {
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        // Create a new session if needed
+        session, err := tx.Sessions().FindPendingByVenue(ctx, req.VenueID)
+        switch {
+        case err == repository.ErrNotFound:
+            sessionID = uuid.New().String()
+            err = tx.Sessions().Create(ctx, repository.Session{
+                ID:      sessionID,
+                VenueID: req.VenueID,
+                Status:  "pending",
+            })
+            if err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Failed to create session", err)
+            }
+            // Read back the times the session was given
+            session, err = tx.Sessions().Get(ctx, sessionID)
+            if err != nil {
+                return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+            }
+        case err != nil:
+            return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+        default:
+            sessionID = session.ID
         }
-        return
-    }
 
-    // Check capacity
-    if booked >= capacity {
-        w.WriteHeader(http.StatusConflict)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Venue is full"})
-        return
-    }
+        if err := checkOverlap(ctx, tx, studentID, session); err != nil {
+            return err
+        }
 
-    // Create a new session if needed
-    var sessionID string
-    err = tx.QueryRow(`
-        SELECT id FROM gd_sessions 
-        WHERE venue_id = ? AND status = 'pending' 
-        ORDER BY start_time ASC LIMIT 1`, req.VenueID).Scan(&sessionID)
-
-    if err == sql.ErrNoRows {
-        // Create new session
-        sessionID = uuid.New().String()
-        _, err = tx.Exec(`
-            INSERT INTO gd_sessions 
-            (id, venue_id, status, start_time, end_time, level) 
-            VALUES (?, ?, 'pending', NOW(), DATE_ADD(NOW(), INTERVAL 1 HOUR), 
-                   (SELECT level FROM venues WHERE id = ?))`,
-            sessionID, req.VenueID, req.VenueID)
+        // Add student to session
+        err = tx.Participants().Add(ctx, sessionID, req.StudentID)
+        if err == repository.ErrDuplicate {
+            return apierror.New(http.StatusConflict, "You have already booked this venue")
+        }
         if err != nil {
-            log.Printf("Failed to create session: %v", err)
-            w.WriteHeader(http.StatusInternalServerError)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create session"})
-            return
+            return apierror.Wrap(http.StatusInternalServerError, "Booking failed", err)
         }
-    } else if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
-        return
-    }
-
-    // Add student to session
-    _, err = tx.Exec(`
-        INSERT INTO session_participants 
-        (id, session_id, student_id, is_dummy) 
-        VALUES (UUID(), ?, ?, FALSE)`,
-        sessionID, req.StudentID)
-    
-   if err != nil {
-    if strings.Contains(err.Error(), "Duplicate entry") {
-        w.WriteHeader(http.StatusConflict)
-        json.NewEncoder(w).Encode(map[string]string{"error": "You have already booked this venue"})
-        return
-    }
-    log.Printf("Failed to add participant: %v", err)
-    w.WriteHeader(http.StatusInternalServerError)
-    json.NewEncoder(w).Encode(map[string]string{"error": "Booking failed"})
-    return
-}
-
-    // Commit transaction
-    if err := tx.Commit(); err != nil {
-        log.Printf("Failed to commit transaction: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Booking failed"})
+        return nil
+    })
+    if err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
@@ -1110,57 +830,39 @@ func BookVenue(w http.ResponseWriter, r *http.Request) {
     })
 }
 
-func GetAvailableSessions(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetAvailableSessions(w http.ResponseWriter, r *http.Request) {
     levelStr := r.URL.Query().Get("level")
     level, err := strconv.Atoi(levelStr)
     if err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusBadRequest, "Invalid level", err))
         return
     }
 
-    rows, err := database.GetDB().Query(`
-        SELECT v.id, v.name, v.capacity, v.session_timing, v.table_details, v.level,
-               (SELECT COUNT(*) FROM session_participants sp 
-                JOIN gd_sessions s ON sp.session_id = s.id 
-                WHERE s.venue_id = v.id) as booked
-        FROM venues v 
-        WHERE v.level = ? AND v.is_active = TRUE`, level)
-    
+    active, err := h.store.Venues().ActiveByLevel(r.Context(), level)
     if err != nil {
-        log.Printf("Database error: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(r.Context(), "listing venues failed", "level", level, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
-    defer rows.Close()
 
     var venues []map[string]interface{}
-    for rows.Next() {
-        var venue struct {
-            ID           string
-            Name         string
-            Capacity     int
-            SessionTiming string
-            TableDetails  string
-			Level        int
-            Booked       int
-        }
-        if err := rows.Scan(&venue.ID, &venue.Name, &venue.Capacity, 
-                          &venue.SessionTiming, &venue.TableDetails,&venue.Level, &venue.Booked); err != nil {
-            log.Printf("Error scanning venue row: %v", err)
-            continue
+    for _, venue := range active {
+        booked, err := h.store.Venues().BookedCount(r.Context(), venue.ID)
+        if err != nil {
+            slog.ErrorContext(r.Context(), "counting venue bookings failed", "venue_id", venue.ID, "error", err)
+            apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
+            return
         }
 
         venues = append(venues, map[string]interface{}{
             "id":            venue.ID,
             "venue_name":    venue.Name,
             "capacity":     venue.Capacity,
-            "booked":       venue.Booked,
-            "remaining":    venue.Capacity - venue.Booked,
+            "booked":       booked,
+            "remaining":    venue.Capacity - booked,
             "session_timing": venue.SessionTiming,
             "table_details":  venue.TableDetails,
-			"level":        venue.Level,
+            "level":        venue.Level,
         })
     }
 
@@ -1168,21 +870,13 @@ func GetAvailableSessions(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(venues)
 }
 
-func CheckBooking(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CheckBooking(w http.ResponseWriter, r *http.Request) {
     studentID := r.Context().Value("studentID").(string)
     venueID := r.URL.Query().Get("venue_id")
 
-    var isBooked bool
-    err := database.GetDB().QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM session_participants sp
-            JOIN gd_sessions s ON sp.session_id = s.id
-            WHERE sp.student_id = ? AND s.venue_id = ? AND s.status = 'pending'
-        )`, studentID, venueID).Scan(&isBooked)
-
+    isBooked, err := h.store.Participants().HasPendingBooking(r.Context(), studentID, venueID)
     if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
         return
     }
 
@@ -1190,121 +884,74 @@ func CheckBooking(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(map[string]bool{"is_booked": isBooked})
 }
 
-func CancelBooking(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CancelBooking(w http.ResponseWriter, r *http.Request) {
     studentID := r.Context().Value("studentID").(string)
     
     var req struct {
         VenueID string `json:"venue_id"`
     }
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+    if err := apierror.Decode(r, &req); err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
-    result, err := database.GetDB().Exec(`
-        DELETE sp FROM session_participants sp
-        JOIN gd_sessions s ON sp.session_id = s.id
-        WHERE sp.student_id = ? AND s.venue_id = ? AND s.status = 'pending'`,
-        studentID, req.VenueID)
-
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+    err := h.store.Participants().CancelPendingBooking(r.Context(), studentID, req.VenueID)
+    if err == repository.ErrNotFound {
+        apierror.Write(w, r, apierror.New(http.StatusNotFound, "No active booking found"))
         return
     }
-
-    rowsAffected, _ := result.RowsAffected()
-    if rowsAffected == 0 {
-        w.WriteHeader(http.StatusNotFound)
-        json.NewEncoder(w).Encode(map[string]string{"error": "No active booking found"})
+    if err != nil {
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
         return
     }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
 }
-func GetSessionParticipants(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetSessionParticipants(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json")
     
     sessionID := r.URL.Query().Get("session_id")
     if sessionID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]interface{}{
-            "error": "session_id is required",
-            "data": []interface{}{},
-        })
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "session_id is required"))
         return
     }
 
     studentID := r.Context().Value("studentID").(string)
+    now := time.Now()
     
     // First, clean up any stale phase tracking (users who left unexpectedly)
-    _, err := database.GetDB().Exec(`
-        DELETE FROM session_phase_tracking 
-        WHERE session_id = ? 
-        AND start_time < DATE_SUB(NOW(), INTERVAL 1 HOUR)`, 
-        sessionID)
-    if err != nil {
-        log.Printf("Error cleaning up stale phase tracking: %v", err)
+    if err := h.store.Participants().PrunePhases(r.Context(), sessionID, now.Add(-time.Hour)); err != nil {
+        slog.WarnContext(r.Context(), "cleaning up stale phase tracking failed", "session_id", sessionID, "error", err)
     }
 
     // Get current active participants who:
     // 1. Are booked for this session
     // 2. Have scanned QR (have phase tracking)
     // 3. Have active phase tracking within last 5 minutes
-    rows, err := database.GetDB().Query(`
-        SELECT DISTINCT su.id, su.full_name, su.department, 
-               COALESCE(su.photo_url, '') as profileImage 
-        FROM session_participants sp
-        JOIN student_users su ON sp.student_id = su.id
-        JOIN session_phase_tracking spt ON sp.session_id = spt.session_id 
-                                      AND sp.student_id = spt.student_id
-        WHERE sp.session_id = ? 
-          AND sp.is_dummy = FALSE
-          AND su.is_active = TRUE
-          AND spt.start_time > DATE_SUB(NOW(), INTERVAL 5 MINUTE)
-        ORDER BY su.full_name`, 
-        sessionID)
-
+    present, err := h.store.Participants().Present(r.Context(), sessionID, now.Add(-5*time.Minute))
     if err != nil {
-        log.Printf("Database error fetching participants: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]interface{}{
-            "error": "Database error",
-            "data": []interface{}{},
-        })
+        slog.ErrorContext(r.Context(), "listing present participants failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
-    defer rows.Close()
 
     var participants []map[string]interface{}
-    for rows.Next() {
-        var participant struct {
-            ID           string
-            FullName     string
-            Department   string
-            ProfileImage string // Changed from sql.NullString to string
-        }
-        if err := rows.Scan(&participant.ID, &participant.FullName, &participant.Department, &participant.ProfileImage); err != nil {
-            log.Printf("Error scanning participant: %v", err)
-            continue
-        }
-
+    for _, participant := range present {
         // Skip the current student
-        if participant.ID == studentID {
+        if participant.StudentID == studentID {
             continue
         }
 
         // Use default image if profile image is not available
-        imageURL := participant.ProfileImage
+        imageURL := participant.PhotoURL
         if imageURL == "" {
-            imageURL = "https://ui-avatars.com/api/?name=" + url.QueryEscape(participant.FullName) + "&background=random"
+            imageURL = "https://ui-avatars.com/api/?name=" + url.QueryEscape(participant.Name) + "&background=random"
         }
 
         participants = append(participants, map[string]interface{}{
-            "id":           participant.ID,
-            "name":         participant.FullName,
+            "id":           participant.StudentID,
+            "name":         participant.Name,
             "email":        "", 
             "department":   participant.Department,
             "profileImage": imageURL,
@@ -1317,51 +964,32 @@ func GetSessionParticipants(w http.ResponseWriter, r *http.Request) {
 }
 
 
-func CheckSurveyCompletion(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CheckSurveyCompletion(w http.ResponseWriter, r *http.Request) {
     sessionID := r.URL.Query().Get("session_id")
     if sessionID == "" {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "session_id is required"})
+        apierror.Write(w, r, apierror.New(http.StatusBadRequest, "session_id is required"))
         return
     }
 
-    // Get total participants who have both booked AND scanned QR (have phase tracking)
-    var totalParticipants int
-    err := database.GetDB().QueryRow(`
-        SELECT COUNT(DISTINCT sp.student_id)
-        FROM session_participants sp
-        JOIN session_phase_tracking spt ON sp.session_id = spt.session_id 
-                                      AND sp.student_id = spt.student_id
-        WHERE sp.session_id = ? 
-          AND sp.is_dummy = FALSE
-          AND spt.start_time > DATE_SUB(NOW(), INTERVAL 1 HOUR)`,
-        sessionID).Scan(&totalParticipants)
-    
+    // Participants who have both booked AND scanned QR (have phase tracking)
+    present, err := h.store.Participants().Present(r.Context(), sessionID, time.Now().Add(-time.Hour))
     if err != nil {
-        log.Printf("Error getting QR-scanned participants: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(r.Context(), "counting present participants failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
+    totalParticipants := len(present)
 
     // Get count of participants who have completed ALL questions
-    var completedCount int
-    err = database.GetDB().QueryRow(`
-        SELECT COUNT(DISTINCT sc.student_id)
-        FROM survey_completion sc
-        JOIN session_participants sp ON sc.session_id = sp.session_id AND sc.student_id = sp.student_id
-        WHERE sc.session_id = ?`,
-        sessionID).Scan(&completedCount)
-    
+    completedCount, err := h.store.Surveys().CountCompleted(r.Context(), sessionID)
     if err != nil {
-        log.Printf("Error getting completed count: %v", err)
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+        slog.ErrorContext(r.Context(), "counting completed surveys failed", "session_id", sessionID, "error", err)
+        apierror.Write(w, r, apierror.New(http.StatusInternalServerError, "Database error"))
         return
     }
 
-    log.Printf("Completion check - Session: %s, QR-Scanned: %d, Completed: %d", 
-        sessionID, totalParticipants, completedCount)
+    slog.DebugContext(r.Context(), "survey completion check", "session_id", sessionID,
+        "present", totalParticipants, "completed", completedCount)
     
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1371,28 +999,20 @@ func CheckSurveyCompletion(w http.ResponseWriter, r *http.Request) {
     })
 }
 
-func MarkSurveyCompleted(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) MarkSurveyCompleted(w http.ResponseWriter, r *http.Request) {
     var req struct {
         SessionID string `json:"session_id"`
     }
     
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+    if err := apierror.Decode(r, &req); err != nil {
+        apierror.Write(w, r, err)
         return
     }
 
     studentID := r.Context().Value("studentID").(string)
 
-    _, err := database.GetDB().Exec(`
-        INSERT INTO survey_completion (session_id, student_id)
-        VALUES (?, ?)
-        ON DUPLICATE KEY UPDATE completed_at = NOW()`,
-        req.SessionID, studentID)
-    
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Failed to mark survey completion"})
+    if err := h.store.Surveys().MarkCompleted(r.Context(), req.SessionID, studentID); err != nil {
+        apierror.Write(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to mark survey completion", err))
         return
     }
 
@@ -1402,7 +1022,6 @@ func MarkSurveyCompleted(w http.ResponseWriter, r *http.Request) {
 
 
 
-
 // package controllers
 
 // import (
@@ -1993,7 +1612,7 @@ func MarkSurveyCompleted(w http.ResponseWriter, r *http.Request) {
 //     })
 // }
 
-// func UpdateSessionStatus(w http.ResponseWriter, r *http.Request) {
+// func (h *Handlers) UpdateSessionStatus(w http.ResponseWriter, r *http.Request) {
 //     var req struct {
 //         SessionID string `json:"sessionId"`
 //         Status    string `json:"status"`
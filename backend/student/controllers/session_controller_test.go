@@ -0,0 +1,897 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gd/apierror"
+	"gd/metrics"
+	"gd/repository"
+	"gd/repository/memory"
+	"gd/storage"
+)
+
+// newStore seeds an in-memory store and returns it with handlers over it.
+func newStore(t *testing.T) (*memory.Store, *Handlers) {
+	t.Helper()
+	s := memory.New()
+
+	s.AddVenue(repository.Venue{ID: "venue1", Name: "Table 1-A", Capacity: 3, Level: 1, IsActive: true})
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		s.AddStudent(repository.Student{ID: id, Name: id, Level: 1, IsActive: true})
+	}
+	return s, NewHandlers(s, nil)
+}
+
+func studentRequest(t *testing.T, method, target, studentID string, body interface{}) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	return r.WithContext(context.WithValue(r.Context(), "studentID", studentID))
+}
+
+func serve(t *testing.T, h http.HandlerFunc, r *http.Request, out interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h(w, r)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("decoding %q: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestBookVenue(t *testing.T) {
+	s, h := newStore(t)
+
+	var booked map[string]interface{}
+	code := serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "alice",
+		BookingRequest{VenueID: "venue1"}), &booked)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%v)", code, booked)
+	}
+	if booked["booked_seats"].(float64) != 1 || booked["remaining_seats"].(float64) != 2 {
+		t.Errorf("seats = %v/%v, want 1/2", booked["booked_seats"], booked["remaining_seats"])
+	}
+	sessionID := booked["session_id"].(string)
+
+	// A second student lands in the same pending session.
+	code = serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "bob",
+		BookingRequest{VenueID: "venue1"}), &booked)
+	if code != http.StatusOK || booked["session_id"] != sessionID {
+		t.Fatalf("second booking = %d %v, want session %s", code, booked, sessionID)
+	}
+
+	// A student can hold only one pending booking.
+	var errBody map[string]string
+	code = serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "alice",
+		BookingRequest{VenueID: "venue1"}), &errBody)
+	if code != http.StatusForbidden {
+		t.Errorf("repeat booking status = %d, want 403 (%v)", code, errBody)
+	}
+
+	s.AddStudent(repository.Student{ID: "eve", Name: "eve", Level: 2, IsActive: true})
+	code = serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "eve",
+		BookingRequest{VenueID: "venue1"}), &errBody)
+	if code != http.StatusForbidden {
+		t.Errorf("level mismatch status = %d, want 403 (%v)", code, errBody)
+	}
+
+	serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "carol",
+		BookingRequest{VenueID: "venue1"}), nil)
+	code = serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "dave",
+		BookingRequest{VenueID: "venue1"}), &errBody)
+	if code != http.StatusConflict {
+		t.Errorf("full venue status = %d, want 409 (%v)", code, errBody)
+	}
+}
+
+func TestBookVenueRejectsOverlap(t *testing.T) {
+	s, h := newStore(t)
+	now := time.Now()
+	s.AddVenue(repository.Venue{ID: "venue2", Name: "Table 1-B", Capacity: 3, Level: 1, IsActive: true})
+	s.AddSession(repository.Session{ID: "current", VenueID: "venue3", Level: 1, Status: "active",
+		StartTime: now.Add(-10 * time.Minute), EndTime: now.Add(50 * time.Minute)})
+	s.AddParticipant("current", "alice")
+	s.AddSession(repository.Session{ID: "soon", VenueID: "venue2", Level: 1, Status: "pending",
+		StartTime: now.Add(30 * time.Minute), EndTime: now.Add(90 * time.Minute)})
+	s.AddSession(repository.Session{ID: "later", VenueID: "venue1", Level: 1, Status: "pending",
+		StartTime: now.Add(3 * time.Hour), EndTime: now.Add(4 * time.Hour)})
+
+	var errBody map[string]string
+	code := serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "alice",
+		BookingRequest{VenueID: "venue2"}), &errBody)
+	if code != http.StatusConflict || errBody["code"] != apierror.CodeScheduleConflict {
+		t.Errorf("overlapping booking = %d %v, want 409 %s", code, errBody, apierror.CodeScheduleConflict)
+	}
+
+	var booked map[string]interface{}
+	code = serve(t, h.BookVenue, studentRequest(t, "POST", "/student/sessions/book", "alice",
+		BookingRequest{VenueID: "venue1"}), &booked)
+	if code != http.StatusOK || booked["session_id"] != "later" {
+		t.Errorf("later booking = %d %v, want session later", code, booked)
+	}
+}
+
+func TestJoinSessionRejectsOverlap(t *testing.T) {
+	s, h := newStore(t)
+	qrData := `{"venue_id":"venue1","expiry":"2099-01-01T00:00:00Z","salt":"abc"}`
+	s.AddQRCode(repository.QRCode{
+		ID: "qr1", VenueID: "venue1", QRData: qrData, MaxCapacity: 5,
+		IsActive: true, QRGroupID: "group1", ExpiresAt: time.Now().Add(time.Hour),
+	})
+	s.AddSession(repository.Session{ID: "elsewhere", VenueID: "venue2", Level: 1, Status: "active",
+		StartTime: time.Now().Add(-10 * time.Minute), EndTime: time.Now().Add(50 * time.Minute)})
+	s.AddParticipant("elsewhere", "alice")
+	s.SetPhaseStart("elsewhere", "alice", "discussion", time.Now())
+
+	var out map[string]string
+	code := serve(t, h.JoinSession, studentRequest(t, "POST", "/student/sessions/join", "alice",
+		map[string]string{"qr_data": qrData}), &out)
+	if code != http.StatusConflict || out["code"] != apierror.CodeScheduleConflict {
+		t.Errorf("overlapping join = %d %v, want 409 %s", code, out, apierror.CodeScheduleConflict)
+	}
+	// The join is one unit of work: the session it opened and the phases it
+	// cleared are rolled back with it.
+	if sess, err := s.Sessions().FindOpenByQRGroup(context.Background(), "venue1", "group1"); err != repository.ErrNotFound {
+		t.Errorf("rejected join left session %+v (%v)", sess, err)
+	}
+	if got := s.Phase("elsewhere", "alice"); got != "discussion" {
+		t.Errorf("phase elsewhere = %q after rejected join, want discussion", got)
+	}
+	code = serve(t, h.JoinSession, studentRequest(t, "POST", "/student/sessions/join", "bob",
+		map[string]string{"qr_data": qrData}), &out)
+	if code != http.StatusOK {
+		t.Errorf("join = %d %v, want 200", code, out)
+	}
+}
+
+func TestJoinSession(t *testing.T) {
+	s, h := newStore(t)
+	qrData := `{"venue_id":"venue1","expiry":"2099-01-01T00:00:00Z","salt":"abc"}`
+	s.AddQRCode(repository.QRCode{
+		ID: "qr1", VenueID: "venue1", QRData: qrData, MaxCapacity: 2,
+		IsActive: true, QRGroupID: "group1", ExpiresAt: time.Now().Add(time.Hour),
+	})
+	joinsBefore := metrics.SessionJoins.Value()
+	fullBefore := metrics.QRRejections.Value("full")
+
+	join := func(studentID string) (int, map[string]string) {
+		var out map[string]string
+		code := serve(t, h.JoinSession, studentRequest(t, "POST", "/student/sessions/join", studentID,
+			map[string]string{"qr_data": qrData}), &out)
+		return code, out
+	}
+
+	code, first := join("alice")
+	if code != http.StatusOK || first["status"] != "joined" {
+		t.Fatalf("join = %d %v", code, first)
+	}
+	session, err := s.Sessions().Get(context.Background(), first["session_id"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Status != "active" || session.QRGroupID != "group1" || session.Level != 1 {
+		t.Errorf("session = %+v, want active level 1 session for group1", session)
+	}
+	if got := s.Phase(session.ID, "alice"); got != "prep" {
+		t.Errorf("phase = %q, want prep", got)
+	}
+
+	code, second := join("bob")
+	if code != http.StatusOK || second["session_id"] != session.ID {
+		t.Fatalf("second join = %d %v, want session %s", code, second, session.ID)
+	}
+	if got := s.QRCode("qr1").CurrentUsage; got != 2 {
+		t.Errorf("usage = %d, want 2", got)
+	}
+
+	if code, out := join("carol"); code != http.StatusForbidden {
+		t.Errorf("join over capacity = %d %v, want 403", code, out)
+	}
+	if joins, full := metrics.SessionJoins.Value()-joinsBefore, metrics.QRRejections.Value("full")-fullBefore; joins != 2 || full != 1 {
+		t.Errorf("metrics: joins +%v, full rejections +%v, want +2 and +1", joins, full)
+	}
+
+	var out map[string]string
+	code = serve(t, h.JoinSession, studentRequest(t, "POST", "/student/sessions/join", "carol",
+		map[string]string{"qr_data": `{"venue_id":"venue1","salt":"forged"}`}), &out)
+	if code != http.StatusUnauthorized {
+		t.Errorf("unknown QR = %d %v, want 401", code, out)
+	}
+}
+
+func seedSurvey(s *memory.Store) {
+	s.AddSession(repository.Session{ID: "sess1", VenueID: "venue1", Level: 1, Status: "active"})
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		s.AddParticipant("sess1", id)
+		s.Participants().StartPhase(context.Background(), "sess1", id, "survey")
+	}
+	s.SetQuestions(1,
+		repository.Question{ID: "q-clarity", Text: "Clarity", Weight: 1},
+		repository.Question{ID: "q-lead", Text: "Leadership", Weight: 2},
+	)
+	s.SetRankingPoints(1, 4, 3, 2)
+}
+
+func submit(t *testing.T, h *Handlers, responder string, responses map[string]map[int]string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	code := serve(t, h.SubmitSurvey, studentRequest(t, "POST", "/student/survey", responder,
+		map[string]interface{}{"session_id": "sess1", "responses": responses}), &out)
+	if code != http.StatusOK {
+		t.Fatalf("submit by %s = %d %v", responder, code, out)
+	}
+	return out
+}
+
+func TestSubmitSurveyScoresAndCompletes(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+
+	out := submit(t, h, "alice", map[string]map[int]string{
+		"q-clarity": {1: "bob", 2: "carol", 3: "dave"},
+	})
+	if out["completed"] != false || out["questions_answered"].(float64) != 1 {
+		t.Errorf("partial submission = %v, want 1 of 2 answered", out)
+	}
+	if s.IsCompleted("sess1", "alice") {
+		t.Error("survey marked complete after one of two questions")
+	}
+
+	out = submit(t, h, "alice", map[string]map[int]string{
+		"q-clarity": {1: "bob", 2: "carol", 3: "dave"},
+		"q-lead":    {1: "carol", 2: "bob", 3: "dave"},
+	})
+	if out["completed"] != true {
+		t.Errorf("full submission = %v, want completed", out)
+	}
+	if !s.IsCompleted("sess1", "alice") {
+		t.Error("survey not marked complete")
+	}
+
+	// Resubmitting replaces rather than duplicates earlier answers.
+	results := s.SurveyResults()
+	if len(results) != 6 {
+		t.Fatalf("stored %d results, want 6", len(results))
+	}
+
+	want := map[string]float64{
+		"q-clarity/bob": 4, "q-clarity/carol": 3, "q-clarity/dave": 2,
+		"q-lead/carol": 8, "q-lead/bob": 6, "q-lead/dave": 4,
+	}
+	for _, res := range results {
+		key := res.QuestionID + "/" + res.StudentID
+		if res.Score != want[key] {
+			t.Errorf("score for %s = %v, want %v", key, res.Score, want[key])
+		}
+		if res.PenaltyPoints != 0 || res.IsBiased {
+			t.Errorf("first responder penalised for %s: %+v", key, res)
+		}
+	}
+}
+
+func TestSubmitSurveyPenalisesDisagreementOnceAllAreIn(t *testing.T) {
+	all := func(first, second, third string) map[string]map[int]string {
+		return map[string]map[int]string{
+			"q-clarity": {1: first, 2: second, 3: third},
+			"q-lead":    {1: first, 2: second, 3: third},
+		}
+	}
+	penalties := func(s *memory.Store, responder string) map[string]float64 {
+		t.Helper()
+		bias, err := s.Surveys().Bias(context.Background(), "sess1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]float64{}
+		for _, b := range bias {
+			if b.ResponderID == responder {
+				got[b.QuestionID] = b.PenaltyPoints
+				if b.Method != "borda" {
+					t.Errorf("bias computed with %q, want borda", b.Method)
+				}
+			}
+		}
+		return got
+	}
+
+	// Borda over the four ballots: bob 9, carol 7, alice 4, dave 4, so the
+	// consensus is bob, carol, alice, dave with the tie broken by ID.
+	s, h := newStore(t)
+	seedSurvey(s)
+	submit(t, h, "alice", all("bob", "carol", "dave"))
+	submit(t, h, "bob", all("carol", "dave", "alice"))
+	submit(t, h, "carol", all("bob", "alice", "dave"))
+	if bias, _ := s.Surveys().Bias(context.Background(), "sess1"); len(bias) != 0 || s.Finalized("sess1") {
+		t.Fatalf("penalties applied before every participant answered: %+v", bias)
+	}
+	submit(t, h, "dave", all("bob", "carol", "alice"))
+	if !s.Finalized("sess1") {
+		t.Error("session not finalized by the last submission")
+	}
+	// Peer scores are left as awarded; the penalty belongs to the responder.
+	for _, res := range s.SurveyResults() {
+		if res.PenaltyPoints != 0 || res.WeightedScore != res.Score {
+			t.Errorf("ranked student charged for the responder's bias: %+v", res)
+		}
+	}
+
+	// bob puts carol one place high and dave two: 0.5 + 1.
+	if got := penalties(s, "bob"); got["q-clarity"] != 1.5 || got["q-lead"] != 1.5 {
+		t.Errorf("bob's penalties = %v, want 1.5 per question", got)
+	}
+	if got := penalties(s, "alice"); got["q-clarity"] != 0.5 {
+		t.Errorf("alice's penalties = %v, want 0.5 per question", got)
+	}
+
+	// Submission order does not matter.
+	s, h = newStore(t)
+	seedSurvey(s)
+	submit(t, h, "dave", all("bob", "carol", "alice"))
+	submit(t, h, "carol", all("bob", "alice", "dave"))
+	submit(t, h, "bob", all("carol", "dave", "alice"))
+	submit(t, h, "alice", all("bob", "carol", "dave"))
+	if got := penalties(s, "bob"); got["q-clarity"] != 1.5 || got["q-lead"] != 1.5 {
+		t.Errorf("bob's penalties in reverse order = %v, want 1.5 per question", got)
+	}
+}
+
+func TestConsensusStrategyPerLevel(t *testing.T) {
+	s, _ := newStore(t)
+	ctx := context.Background()
+	s.SetConsensusMethod(2, "schulze")
+	s.SetConsensusMethod(3, "plurality")
+
+	for level, want := range map[int]string{1: "borda", 2: "schulze", 3: "borda"} {
+		config, err := levelConfig(ctx, s, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strategy := consensusStrategy(ctx, config); strategy.Name() != want {
+			t.Errorf("level %d strategy = %s, want %s", level, strategy.Name(), want)
+		}
+	}
+}
+
+func TestCalculateRankingPenalty(t *testing.T) {
+	consensus := map[string]int{"a": 1, "b": 2, "c": 3, "d": 5}
+	tests := []struct {
+		name     string
+		rankings map[int]string
+		penalty  float64
+		biased   bool
+	}{
+		{"matches consensus", map[int]string{1: "a", 2: "b", 3: "c"}, 0, false},
+		{"one place off", map[int]string{1: "b", 2: "a", 3: "c"}, 1, true},
+		{"far off", map[int]string{1: "d"}, 2, true},
+		{"unranked student", map[int]string{1: "x"}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			penalty, biased := calculateRankingPenalty(tt.rankings, consensus)
+			if penalty != tt.penalty || biased != tt.biased {
+				t.Errorf("got (%v, %v), want (%v, %v)", penalty, biased, tt.penalty, tt.biased)
+			}
+		})
+	}
+
+	if penalty, biased := calculateRankingPenalty(map[int]string{1: "a"}, nil); penalty != 0 || biased {
+		t.Errorf("empty consensus gave (%v, %v), want no penalty", penalty, biased)
+	}
+}
+
+func TestScoringConfigPinsVersion(t *testing.T) {
+	s, _ := newStore(t)
+	ctx := context.Background()
+	s.AddSession(repository.Session{ID: "sess2", Level: 2, Status: "active"})
+
+	// Without a configuration the defaults apply and nothing is pinned.
+	config, err := scoringConfig(ctx, s, repository.Session{ID: "sess2", Level: 2})
+	if err != nil || config.ID != "" || len(config.Points) != 3 || config.Points[0] != 4 {
+		t.Fatalf("default config = %+v, %v", config, err)
+	}
+
+	s.SetRankingPoints(2, 10, 5, 1)
+	session, _ := s.Sessions().Get(ctx, "sess2")
+	first, err := scoringConfig(ctx, s, session)
+	if err != nil || first.Version != 1 || first.Points[1] != 5 {
+		t.Fatalf("first config = %+v, %v", first, err)
+	}
+
+	// A new version mid-session does not re-score it.
+	s.SetRankingPoints(2, 20, 10, 5)
+	session, _ = s.Sessions().Get(ctx, "sess2")
+	if session.ConfigID != first.ID {
+		t.Fatalf("pinned %q, want %q", session.ConfigID, first.ID)
+	}
+	if again, _ := scoringConfig(ctx, s, session); again.ID != first.ID || again.Points[0] != 10 {
+		t.Errorf("config after new version = %+v, want version 1", again)
+	}
+	if active, _ := levelConfig(ctx, s, 2); active.Version != 2 || active.Points[0] != 20 {
+		t.Errorf("active config = %+v, want version 2", active)
+	}
+}
+
+func TestSubmitSurveyRankingDepth(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	for _, id := range []string{"erin", "frank"} {
+		s.AddParticipant("sess1", id)
+	}
+
+	// With five points configured, students rank their top five and a
+	// sixth place is refused.
+	s.SetRankingPoints(1, 10, 7, 5, 3, 1)
+	s.AddParticipant("sess1", "gina")
+	var errBody map[string]interface{}
+	deep := map[string]map[int]string{"q-clarity": {1: "bob", 2: "carol", 3: "dave", 4: "erin", 5: "frank", 6: "gina"}}
+	code := serve(t, h.SubmitSurvey, studentRequest(t, "POST", "/student/survey", "alice",
+		map[string]interface{}{"session_id": "sess1", "responses": deep}), &errBody)
+	if code < 400 || code >= 500 {
+		t.Fatalf("sixth place at depth 5 = %d, want a validation error", code)
+	}
+	if len(s.SurveyResults()) != 0 {
+		t.Fatal("rejected submission was stored")
+	}
+
+	submit(t, h, "alice", map[string]map[int]string{"q-clarity": {1: "bob", 2: "carol", 3: "dave", 4: "erin", 5: "frank"}})
+	want := map[string]float64{"bob": 10, "carol": 7, "dave": 5, "erin": 3, "frank": 1}
+	for _, res := range s.SurveyResults() {
+		if res.Score != want[res.StudentID] {
+			t.Errorf("%s at rank %d scored %v, want %v", res.StudentID, res.Rank, res.Score, want[res.StudentID])
+		}
+	}
+	if len(s.SurveyResults()) != 5 {
+		t.Errorf("stored %d rankings, want 5", len(s.SurveyResults()))
+	}
+}
+
+func TestGetResultsRanksByFinalScore(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+
+	submit(t, h, "alice", map[string]map[int]string{"q-clarity": {1: "bob", 2: "carol", 3: "dave"}, "q-lead": {1: "bob", 2: "carol", 3: "dave"}})
+	submit(t, h, "dave", map[string]map[int]string{"q-clarity": {1: "bob", 2: "carol"}, "q-lead": {1: "bob", 2: "carol"}})
+
+	var out struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	code := serve(t, h.GetResults, studentRequest(t, "GET", "/student/results?session_id=sess1", "alice", nil), &out)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+
+	var order []string
+	for _, r := range out.Results {
+		order = append(order, r["student_id"].(string))
+	}
+	want := []string{"bob", "carol", "dave", "alice"}
+	if len(order) != len(want) {
+		t.Fatalf("results for %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	if out.Results[0]["final_score"] != "24.00" || out.Results[0]["first_places"].(float64) != 4 {
+		t.Errorf("winner = %v, want final 24.00 with 4 first places", out.Results[0])
+	}
+
+	var errBody map[string]string
+	s.AddStudent(repository.Student{ID: "outsider", Level: 1})
+	if code := serve(t, h.GetResults, studentRequest(t, "GET", "/student/results?session_id=sess1", "outsider", nil), &errBody); code != http.StatusForbidden {
+		t.Errorf("outsider status = %d, want 403", code)
+	}
+}
+
+func TestSubmitSurveyRejectsInvalidRankings(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	s.AddSession(repository.Session{ID: "other", Level: 1, Status: "active"})
+	s.AddParticipant("other", "zoe")
+
+	tests := []struct {
+		name      string
+		responder string
+		rankings  map[int]string
+		wantCode  int
+		wantField string
+	}{
+		{"self", "alice", map[int]string{1: "bob", 2: "alice"}, http.StatusBadRequest, "responses.q-clarity.2"},
+		{"repeat", "alice", map[int]string{1: "bob", 2: "carol", 3: "bob"}, http.StatusBadRequest, "responses.q-clarity.3"},
+		{"outsider", "alice", map[int]string{1: "zoe"}, http.StatusBadRequest, "responses.q-clarity.1"},
+		{"not a participant", "zoe", map[int]string{1: "bob"}, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out struct {
+				Fields []struct {
+					Field string `json:"field"`
+				} `json:"fields"`
+			}
+			code := serve(t, h.SubmitSurvey, studentRequest(t, "POST", "/student/survey", tt.responder,
+				map[string]interface{}{"session_id": "sess1", "responses": map[string]map[int]string{"q-clarity": tt.rankings}}), &out)
+			if code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", code, tt.wantCode)
+			}
+			if tt.wantField != "" && (len(out.Fields) != 1 || out.Fields[0].Field != tt.wantField) {
+				t.Errorf("fields = %+v, want %s", out.Fields, tt.wantField)
+			}
+		})
+	}
+	if len(s.SurveyResults()) != 0 {
+		t.Error("rejected submissions were stored")
+	}
+}
+
+func TestSubmitSurveyByServedQuestion(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+
+	var served []map[string]interface{}
+	if code := serve(t, h.GetQuestionsForStudent, studentRequest(t, "GET", "/student/questions?session_id=sess1", "alice", nil), &served); code != http.StatusOK {
+		t.Fatalf("questions = %d %v", code, served)
+	}
+	if len(served) != 2 {
+		t.Fatalf("served %d questions, want 2", len(served))
+	}
+
+	// Editing the bank mid-session changes neither the set nor its weights.
+	s.SetQuestions(1,
+		repository.Question{ID: "q-clarity", Text: "Clarity", Weight: 1},
+		repository.Question{ID: "q-lead", Text: "Leadership", Weight: 5},
+		repository.Question{ID: "q-new", Text: "Listening", Weight: 1},
+	)
+	var again []map[string]interface{}
+	serve(t, h.GetQuestionsForStudent, studentRequest(t, "GET", "/student/questions?session_id=sess1", "alice", nil), &again)
+	if !reflect.DeepEqual(again, served) {
+		t.Errorf("questions after edit = %v, want %v", again, served)
+	}
+
+	var errBody struct {
+		Fields []struct {
+			Field string `json:"field"`
+		} `json:"fields"`
+	}
+	code := serve(t, h.SubmitSurvey, studentRequest(t, "POST", "/student/survey", "alice",
+		map[string]interface{}{"session_id": "sess1", "responses": map[string]map[int]string{"q-new": {1: "bob"}}}), &errBody)
+	if code != http.StatusBadRequest || len(errBody.Fields) != 1 || errBody.Fields[0].Field != "responses.q-new" {
+		t.Fatalf("unserved question = %d %+v, want 400 on responses.q-new", code, errBody)
+	}
+
+	out := submit(t, h, "alice", map[string]map[int]string{"q-lead": {1: "bob"}})
+	if out["total_questions"].(float64) != 2 {
+		t.Errorf("total_questions = %v, want 2", out["total_questions"])
+	}
+	for _, res := range s.SurveyResults() {
+		if res.QuestionID != "q-lead" || res.Score != 8 {
+			t.Errorf("result %+v, want q-lead scored 4 x weight 2", res)
+		}
+	}
+
+	// Non-participants are not served.
+	if code := serve(t, h.GetQuestionsForStudent, studentRequest(t, "GET", "/student/questions?session_id=sess1", "zoe", nil), nil); code != http.StatusForbidden {
+		t.Errorf("outsider questions = %d, want 403", code)
+	}
+}
+
+func TestSessionQuestionsDrawFromBanks(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	ctx := context.Background()
+	bank := []repository.Question{
+		{ID: "c1", Text: "Clarity 1", Weight: 1, BankID: "clarity"},
+		{ID: "c2", Text: "Clarity 2", Weight: 1, BankID: "clarity"},
+		{ID: "c3", Text: "Clarity 3", Weight: 1, BankID: "clarity"},
+		{ID: "l1", Text: "Leadership 1", Weight: 1, BankID: "leadership"},
+		{ID: "l2", Text: "Leadership 2", Weight: 1, BankID: "leadership"},
+		{ID: "x1", Text: "Unbanked", Weight: 1},
+	}
+	s.SetQuestions(1, bank...)
+	template := map[string]int{"clarity": 1, "leadership": 1}
+	s.SetTemplate(1, template)
+
+	// alice was asked exactly the set sess1 would draw first.
+	first := drawQuestions(bank, template, "sess1")
+	s.Surveys().ServeQuestions(ctx, "earlier", "alice", first)
+
+	ids := func(student string) []string {
+		var out []map[string]interface{}
+		if code := serve(t, h.GetQuestionsForStudent, studentRequest(t, "GET", "/student/questions?session_id=sess1", student, nil), &out); code != http.StatusOK {
+			t.Fatalf("questions for %s = %d %v", student, code, out)
+		}
+		var ids []string
+		for _, q := range out {
+			ids = append(ids, q["id"].(string))
+		}
+		return sorted(ids)
+	}
+	alice, bob := ids("alice"), ids("bob")
+	if !reflect.DeepEqual(alice, bob) {
+		t.Errorf("alice got %v, bob got %v, want the same set", alice, bob)
+	}
+	if len(alice) != 2 || alice[0][0] != 'c' || alice[1][0] != 'l' {
+		t.Errorf("drew %v, want one clarity and one leadership question", alice)
+	}
+	if setKey(first) == strings.Join(alice, ",") {
+		t.Errorf("drew %v again, which alice was already asked", alice)
+	}
+}
+
+func TestSessionTopicChosenOnce(t *testing.T) {
+	s, h := newStore(t)
+	seedSurvey(s)
+	for _, id := range []string{"t1", "t2", "t3"} {
+		s.AddTopic(repository.Topic{ID: id, Level: 1, Text: "Topic " + id})
+	}
+	// alice discussed t1 and bob t2; t3 is new to the whole group.
+	s.AddSession(repository.Session{ID: "earlier1", VenueID: "venue1", Level: 1, Topic: "Topic t1", TopicID: "t1"})
+	s.AddParticipant("earlier1", "alice")
+	s.AddSession(repository.Session{ID: "earlier2", VenueID: "venue1", Level: 1, Topic: "Topic t2", TopicID: "t2"})
+	s.AddParticipant("earlier2", "bob")
+
+	topic := func(student string) (int, string) {
+		var out map[string]interface{}
+		code := serve(t, h.GetTopicForLevel, studentRequest(t, "GET", "/student/topic?session_id=sess1", student, nil), &out)
+		text, _ := out["topic_text"].(string)
+		return code, text
+	}
+	if code, text := topic("alice"); code != http.StatusOK || text != "Topic t3" {
+		t.Errorf("alice got %d %q, want Topic t3", code, text)
+	}
+	s.AddTopic(repository.Topic{ID: "t4", Level: 1, Text: "Topic t4"})
+	if code, text := topic("carol"); code != http.StatusOK || text != "Topic t3" {
+		t.Errorf("carol got %d %q, want the session's Topic t3", code, text)
+	}
+	if code, _ := topic("eve"); code != http.StatusForbidden {
+		t.Errorf("non-participant got %d, want 403", code)
+	}
+	if sess, _ := s.Sessions().Get(context.Background(), "sess1"); sess.TopicID != "t3" {
+		t.Errorf("session topic = %q, want t3", sess.TopicID)
+	}
+}
+
+func TestChooseTopicBalancesUsage(t *testing.T) {
+	s, _ := newStore(t)
+	for _, id := range []string{"t1", "t2"} {
+		s.AddTopic(repository.Topic{ID: id, Level: 1, Text: "Topic " + id})
+	}
+	for i := range 3 {
+		id := fmt.Sprintf("past%d", i)
+		s.AddSession(repository.Session{ID: id, VenueID: "elsewhere", Level: 1, Topic: "Topic t1", TopicID: "t1"})
+	}
+	s.AddSession(repository.Session{ID: "here", VenueID: "venue1", Level: 1, Topic: "Topic t2", TopicID: "t2"})
+
+	ctx := context.Background()
+	got, err := chooseTopic(ctx, s, repository.Session{ID: "next", VenueID: "venue2", Level: 1}, nil, "next")
+	if err != nil || got.ID != "t2" {
+		t.Errorf("chose %q (%v), want the less used t2", got.ID, err)
+	}
+	// At venue1 t2 was already discussed, which outweighs overall usage.
+	got, err = chooseTopic(ctx, s, repository.Session{ID: "next", VenueID: "venue1", Level: 1}, nil, "next")
+	if err != nil || got.ID != "t1" {
+		t.Errorf("chose %q (%v) at venue1, want t1", got.ID, err)
+	}
+	got, err = chooseTopic(ctx, s, repository.Session{Level: 2}, nil, "next")
+	if err != nil || got.Text != defaultTopic {
+		t.Errorf("chose %q (%v) at a level without topics, want the default", got.Text, err)
+	}
+}
+
+func TestPrepMaterialsFollowSessionPhase(t *testing.T) {
+	s, h := newStore(t)
+	files := storage.NewMemory()
+	h = NewHandlers(s, files)
+
+	s.AddTopic(repository.Topic{ID: "t1", Level: 1, Text: "AI in hiring"})
+	s.AddSession(repository.Session{ID: "sess2", VenueID: "venue1", Level: 1, Status: "active",
+		Topic: "AI in hiring", TopicID: "t1", PrepMinutes: 5, DiscussionMinutes: 10})
+	s.AddParticipant("sess2", "alice")
+	s.AddMaterial(repository.PrepMaterial{ID: "m-link", TopicID: "t1", Kind: "link", URL: "https://example.org", VisibleFrom: "booked"})
+	s.AddMaterial(repository.PrepMaterial{ID: "m-point", TopicID: "t1", Kind: "key_point", Body: "Bias", VisibleFrom: "prep", VisibleUntil: "prep", DisplayOrder: 1})
+	s.AddMaterial(repository.PrepMaterial{ID: "m-pdf", TopicID: "t1", Kind: "attachment", FileKey: "prep-materials/t1/m-pdf",
+		FileName: "brief.pdf", ContentType: "application/pdf", VisibleFrom: "discussion", DisplayOrder: 2})
+	files.Put(context.Background(), "prep-materials/t1/m-pdf", strings.NewReader("%PDF-1.4"))
+
+	visible := func() []string {
+		var out struct {
+			Materials []map[string]interface{} `json:"materials"`
+		}
+		if code := serve(t, h.GetTopicForLevel, studentRequest(t, "GET", "/student/topic?session_id=sess2", "alice", nil), &out); code != http.StatusOK {
+			t.Fatalf("topic = %d", code)
+		}
+		var ids []string
+		for _, m := range out.Materials {
+			ids = append(ids, m["id"].(string))
+		}
+		return ids
+	}
+	download := func() int {
+		w := httptest.NewRecorder()
+		h.GetPrepMaterialFile(w, studentRequest(t, "GET", "/student/materials?session_id=sess2&material_id=m-pdf", "alice", nil))
+		return w.Code
+	}
+
+	if got := visible(); !reflect.DeepEqual(got, []string{"m-link"}) {
+		t.Errorf("before check-in saw %v, want only the link", got)
+	}
+	s.SetPhaseStart("sess2", "alice", "prep", time.Now().Add(-2*time.Minute))
+	if got := visible(); !reflect.DeepEqual(got, []string{"m-link", "m-point"}) {
+		t.Errorf("during prep saw %v, want the link and key point", got)
+	}
+	if code := download(); code != http.StatusNotFound {
+		t.Errorf("download during prep = %d, want 404", code)
+	}
+	s.SetPhaseStart("sess2", "alice", "prep", time.Now().Add(-6*time.Minute))
+	if got := visible(); !reflect.DeepEqual(got, []string{"m-link", "m-pdf"}) {
+		t.Errorf("during discussion saw %v, want the link and attachment", got)
+	}
+	if code := download(); code != http.StatusOK {
+		t.Errorf("download during discussion = %d, want 200", code)
+	}
+}
+
+func TestGetSessionDetails(t *testing.T) {
+	s, h := newStore(t)
+	start := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	s.AddSession(repository.Session{ID: "sess1", VenueID: "venue1", Level: 1, Status: "active", StartTime: start,
+		Topic: "Remote work", TopicID: "t1", PrepMinutes: 2, DiscussionMinutes: 10, SurveyMinutes: 3})
+	s.AddParticipant("sess1", "alice")
+
+	var details struct {
+		Venue      string    `json:"venue"`
+		Topic      string    `json:"topic"`
+		Prep       int       `json:"prep_time"`
+		Discussion int       `json:"discussion_time"`
+		Survey     int       `json:"survey_time"`
+		StartTime  time.Time `json:"start_time"`
+	}
+	code := serve(t, h.GetSessionDetails, studentRequest(t, "GET", "/student/session?session_id=sess1", "alice", nil), &details)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if !details.StartTime.Equal(start) {
+		t.Errorf("start_time = %v, want the stored %v", details.StartTime, start)
+	}
+	if details.Venue != "Table 1-A" || details.Topic != "Remote work" || details.Prep != 2 || details.Discussion != 2 || details.Survey != 3 {
+		t.Errorf("details = %+v, want the stored venue, topic and agenda in minutes", details)
+	}
+
+	if code := serve(t, h.GetSessionDetails, studentRequest(t, "GET", "/student/session?session_id=sess1", "bob", nil), nil); code != http.StatusForbidden {
+		t.Errorf("non-participant status = %d, want 403", code)
+	}
+}
+
+func TestCancelBooking(t *testing.T) {
+	s, h := newStore(t)
+	s.AddSession(repository.Session{ID: "sess1", VenueID: "venue1", Level: 1, Status: "pending"})
+	s.AddParticipant("sess1", "alice")
+
+	var booked map[string]bool
+	serve(t, h.CheckBooking, studentRequest(t, "GET", "/student/check-booking?venue_id=venue1", "alice", nil), &booked)
+	if !booked["is_booked"] {
+		t.Fatal("is_booked = false before cancelling")
+	}
+
+	cancel := func() int {
+		return serve(t, h.CancelBooking, studentRequest(t, "POST", "/student/cancel-booking", "alice",
+			map[string]string{"venue_id": "venue1"}), nil)
+	}
+	if code := cancel(); code != http.StatusOK {
+		t.Fatalf("cancel status = %d, want 200", code)
+	}
+	serve(t, h.CheckBooking, studentRequest(t, "GET", "/student/check-booking?venue_id=venue1", "alice", nil), &booked)
+	if booked["is_booked"] {
+		t.Error("is_booked = true after cancelling")
+	}
+	if code := cancel(); code != http.StatusNotFound {
+		t.Errorf("second cancel status = %d, want 404", code)
+	}
+}
+
+func TestGetSessionParticipantsListsPresentOthers(t *testing.T) {
+	s, h := newStore(t)
+	now := time.Now()
+	s.AddStudent(repository.Student{ID: "bob", Name: "Bob", Department: "CSE", PhotoURL: "bob.png", IsActive: true})
+	s.AddStudent(repository.Student{ID: "carol", Name: "Carol", Department: "ECE", IsActive: true})
+	s.AddSession(repository.Session{ID: "sess1", VenueID: "venue1", Level: 1, Status: "active"})
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		s.AddParticipant("sess1", id)
+	}
+	s.SetPhaseStart("sess1", "alice", "prep", now)
+	s.SetPhaseStart("sess1", "bob", "prep", now.Add(-time.Minute))
+	s.SetPhaseStart("sess1", "carol", "prep", now.Add(-2*time.Minute))
+	s.SetPhaseStart("sess1", "dave", "prep", now.Add(-2*time.Hour))
+
+	var out struct {
+		Data []map[string]string `json:"data"`
+	}
+	if code := serve(t, h.GetSessionParticipants, studentRequest(t, "GET", "/student/session/participants?session_id=sess1", "alice", nil), &out); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	want := []map[string]string{
+		{"id": "bob", "name": "Bob", "email": "", "department": "CSE", "profileImage": "bob.png"},
+		{"id": "carol", "name": "Carol", "email": "", "department": "ECE", "profileImage": "https://ui-avatars.com/api/?name=Carol&background=random"},
+	}
+	if !reflect.DeepEqual(out.Data, want) {
+		t.Errorf("participants = %v, want %v", out.Data, want)
+	}
+	if phase := s.Phase("sess1", "dave"); phase != "" {
+		t.Errorf("stale phase %q was kept", phase)
+	}
+}
+
+func TestQuestionTimerAndPenalty(t *testing.T) {
+	s, h := newStore(t)
+	s.AddSession(repository.Session{ID: "sess1", VenueID: "venue1", Level: 1, Status: "active"})
+	s.AddParticipant("sess1", "alice")
+	questionID := "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b"
+	body := map[string]string{"session_id": "sess1", "question_id": questionID, "student_id": "alice"}
+
+	var timer map[string]interface{}
+	serve(t, h.CheckQuestionTimeout, studentRequest(t, "GET", "/student/survey/question-timeout?session_id=sess1&question_id="+questionID, "alice", nil), &timer)
+	if timer["remaining_seconds"].(float64) != 30 || timer["is_timed_out"].(bool) {
+		t.Errorf("timer before start = %v, want the default 30 seconds", timer)
+	}
+	if code := serve(t, h.StartQuestionTimer, studentRequest(t, "POST", "/student/survey/start-question-timer", "alice", body), nil); code != http.StatusOK {
+		t.Fatalf("start timer status = %d, want 200", code)
+	}
+	serve(t, h.CheckQuestionTimeout, studentRequest(t, "GET", "/student/survey/question-timeout?session_id=sess1&question_id="+questionID, "alice", nil), &timer)
+	if left := timer["remaining_seconds"].(float64); left <= 25 || left > 30 {
+		t.Errorf("remaining_seconds = %v, want just under 30", left)
+	}
+
+	for _, want := range []string{"penalty_applied", "penalty_already_applied"} {
+		var out map[string]string
+		serve(t, h.ApplyQuestionPenalty, studentRequest(t, "POST", "/student/survey/apply-penalty", "alice", body), &out)
+		if out["status"] != want {
+			t.Errorf("status = %q, want %q", out["status"], want)
+		}
+	}
+	summaries, err := s.Surveys().ScoreSummaries(context.Background(), "sess1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].TimeoutPenalty != 0.5 {
+		t.Errorf("summaries = %+v, want one 0.5 timeout penalty", summaries)
+	}
+}
+
+func TestSubmitFeedbackOnce(t *testing.T) {
+	_, h := newStore(t)
+	body := FeedbackRequest{SessionID: "sess1", Rating: 4, Comments: "Lively"}
+
+	if code := serve(t, h.SubmitFeedback, studentRequest(t, "POST", "/student/feedback", "alice", body), nil); code != http.StatusOK {
+		t.Fatalf("submit status = %d, want 200", code)
+	}
+	if code := serve(t, h.SubmitFeedback, studentRequest(t, "POST", "/student/feedback", "alice", body), nil); code != http.StatusConflict {
+		t.Errorf("second submit status = %d, want 409", code)
+	}
+
+	var got map[string]interface{}
+	serve(t, h.GetFeedback, studentRequest(t, "GET", "/student/feedback?session_id=sess1", "alice", nil), &got)
+	if got["rating"] != 4.0 || got["comments"] != "Lively" {
+		t.Errorf("feedback = %v, want the submitted rating and comments", got)
+	}
+	var none map[string]interface{}
+	serve(t, h.GetFeedback, studentRequest(t, "GET", "/student/feedback?session_id=sess1", "bob", nil), &none)
+	if len(none) != 0 {
+		t.Errorf("feedback of a student who gave none = %v, want {}", none)
+	}
+}
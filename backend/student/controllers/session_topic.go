@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"gd/repository"
+)
+
+// defaultTopic is discussed at levels with no active topics.
+const defaultTopic = "Discuss the impact of technology on modern education"
+
+// sessionTopic returns the topic every participant of the session
+// discusses. The first call chooses it for the members who have joined and
+// records it on the session; later calls return that record, so deactivating
+// or editing the topic mid-session changes nothing.
+func sessionTopic(ctx context.Context, s repository.Store, session repository.Session) (repository.Topic, error) {
+	if session.Topic == "" {
+		members, err := s.Participants().List(ctx, session.ID)
+		if err != nil {
+			return repository.Topic{}, err
+		}
+		studentIDs := make([]string, len(members))
+		for i, m := range members {
+			studentIDs[i] = m.StudentID
+		}
+		topic, err := chooseTopic(ctx, s, session, studentIDs, session.ID)
+		if err != nil {
+			return repository.Topic{}, err
+		}
+		if err := s.Sessions().SetTopic(ctx, session.ID, topic); err != nil {
+			return repository.Topic{}, err
+		}
+		// A concurrent request may have chosen first.
+		if session, err = s.Sessions().Get(ctx, session.ID); err != nil {
+			return repository.Topic{}, err
+		}
+	}
+
+	topic := repository.Topic{Level: session.Level}
+	if session.TopicID != "" {
+		var err error
+		topic, err = s.Topics().Get(ctx, session.TopicID)
+		if err != nil && err != repository.ErrNotFound {
+			return repository.Topic{}, err
+		}
+	}
+	topic.Text = session.Topic
+	return topic, nil
+}
+
+// chooseTopic picks one of the session level's active topics for the
+// students. It prefers, in order, topics fewer of the students have
+// discussed, topics used less at the session's venue and topics used less
+// overall; seed breaks the remaining ties.
+func chooseTopic(ctx context.Context, s repository.Store, session repository.Session, studentIDs []string, seed string) (repository.Topic, error) {
+	topics, err := s.Topics().Active(ctx, session.Level)
+	if err != nil || len(topics) == 0 {
+		return repository.Topic{Level: session.Level, Text: defaultTopic}, err
+	}
+	discussed, err := s.Topics().Discussed(ctx, studentIDs, session.ID)
+	if err != nil {
+		return repository.Topic{}, err
+	}
+	venueUses := map[string]int{}
+	if session.VenueID != "" {
+		if venueUses, err = s.Topics().VenueUses(ctx, session.VenueID, session.ID); err != nil {
+			return repository.Topic{}, err
+		}
+	}
+
+	topics = shuffleQuestionsWithSeed(topics, seed)
+	slices.SortStableFunc(topics, func(a, b repository.Topic) int {
+		return cmp.Or(
+			cmp.Compare(discussed[a.ID], discussed[b.ID]),
+			cmp.Compare(venueUses[a.ID], venueUses[b.ID]),
+			cmp.Compare(a.Uses, b.Uses),
+		)
+	})
+	return topics[0], nil
+}
+
+// materialPhase returns where the student is in the session, which decides
+// the prep materials they see. Until they check in they have only booked;
+// the prep phase then runs for the agenda's prep minutes and the discussion
+// for its discussion minutes, and the survey follows, or starts early if
+// the student opens it.
+func materialPhase(ctx context.Context, s repository.Store, session repository.Session, studentID string, now time.Time) (string, error) {
+	started, err := s.Participants().Phases(ctx, session.ID, studentID)
+	if err != nil {
+		return "", err
+	}
+	prep, checkedIn := started["prep"]
+	if _, ok := started["survey"]; ok {
+		return "survey", nil
+	}
+	switch {
+	case !checkedIn:
+		return "booked", nil
+	case now.Before(prep.Add(time.Duration(session.PrepMinutes) * time.Minute)):
+		return "prep", nil
+	case now.Before(prep.Add(time.Duration(session.PrepMinutes+session.DiscussionMinutes) * time.Minute)):
+		return "discussion", nil
+	}
+	return "survey", nil
+}
+
+// visibleMaterials returns the topic's prep materials shown in phase.
+func visibleMaterials(ctx context.Context, s repository.Store, topicID, phase string) ([]repository.PrepMaterial, error) {
+	if topicID == "" {
+		return nil, nil
+	}
+	materials, err := s.Topics().Materials(ctx, topicID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(materials, func(m repository.PrepMaterial) bool { return !m.VisibleIn(phase) }), nil
+}
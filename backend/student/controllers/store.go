@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"gd/repository"
+	"gd/storage"
+)
+
+// Handlers serves the student endpoints, which reach their data through a
+// repository.Store. main builds one over MySQL; tests build one over the
+// in-memory store.
+type Handlers struct {
+	store repository.Store
+	// files holds prep material attachments.
+	files storage.Backend
+}
+
+func NewHandlers(store repository.Store, files storage.Backend) *Handlers {
+	return &Handlers{store: store, files: files}
+}
@@ -1,72 +1,167 @@
-// Create a new controller file for student topic access
-// C:\xampp\htdocs\GDAPPC\backend\student\controllers\topic_controller.go
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
-	"gd/database"
+	"gd/apierror"
+	"gd/repository"
+	"gd/storage"
 )
 
-func GetTopicForLevel(w http.ResponseWriter, r *http.Request) {
-	levelStr := r.URL.Query().Get("level")
-	if levelStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Level is required"})
-		return
-	}
-
-	level, err := strconv.Atoi(levelStr)
-	if err != nil || level < 1 || level > 3 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level"})
-		return
-	}
+// GetTopicForLevel returns the topic to prepare for with the prep materials
+// the student may see now. With a session ID it is the session's topic,
+// chosen once for all of its participants; without one it is the topic the
+// student would be given at the level now, which is not recorded, with the
+// materials shown on booking.
+func (h *Handlers) GetTopicForLevel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	studentID := r.Context().Value("studentID").(string)
+	sessionID := r.URL.Query().Get("session_id")
 
-	var topicText string
-	var prepMaterialsJSON []byte
-	
-	err = database.GetDB().QueryRow(`
-		SELECT topic_text, prep_materials 
-		FROM gd_topics 
-		WHERE level = ? AND is_active = TRUE 
-		ORDER BY RAND() 
-		LIMIT 1`,
-		level,
-	).Scan(&topicText, &prepMaterialsJSON)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// Return a default topic if none found
-			defaultTopic := map[string]interface{}{
-				"topic_text": "Discuss the impact of technology on modern education",
-				"prep_materials": map[string]string{},
+	var topic repository.Topic
+	var materials []repository.PrepMaterial
+	var err error
+	if sessionID != "" {
+		err = h.store.InTx(ctx, func(tx repository.Store) error {
+			session, err := tx.Sessions().Get(ctx, sessionID)
+			if err == repository.ErrNotFound {
+				return apierror.New(http.StatusNotFound, "Session not found")
+			}
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+			}
+			if ok, err := isParticipant(ctx, tx, session.ID, studentID); err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+			} else if !ok {
+				return apierror.New(http.StatusForbidden, "Not a participant of the session")
+			}
+			if topic, err = sessionTopic(ctx, tx, session); err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Failed to fetch topic", err)
 			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(defaultTopic)
+			phase, err := materialPhase(ctx, tx, session, studentID, time.Now())
+			if err == nil {
+				materials, err = visibleMaterials(ctx, tx, topic.ID, phase)
+			}
+			if err != nil {
+				return apierror.Wrap(http.StatusInternalServerError, "Failed to fetch prep materials", err)
+			}
+			return nil
+		})
+	} else {
+		levelStr := r.URL.Query().Get("level")
+		if levelStr == "" {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Level is required"))
 			return
 		}
-		log.Printf("Error fetching topic: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch topic"})
+		level, convErr := strconv.Atoi(levelStr)
+		if convErr != nil || level < 1 || level > 3 {
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, "Invalid level"))
+			return
+		}
+		topic, err = chooseTopic(ctx, h.store, repository.Session{Level: level}, []string{studentID}, studentID)
+		if err == nil {
+			materials, err = visibleMaterials(ctx, h.store, topic.ID, "booked")
+		}
+		if err != nil {
+			err = apierror.Wrap(http.StatusInternalServerError, "Failed to fetch topic", err)
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "fetching topic failed", "session_id", sessionID, "error", err)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"topic_text": topicText,
+	prepMaterials := map[string]interface{}{}
+	if len(topic.PrepMaterials) > 0 {
+		if err := json.Unmarshal(topic.PrepMaterials, &prepMaterials); err != nil {
+			slog.WarnContext(ctx, "parsing prep materials failed", "topic_id", topic.ID, "error", err)
+		}
 	}
-	
-	if len(prepMaterialsJSON) > 0 {
-		var prepMaterials map[string]interface{}
-		if err := json.Unmarshal(prepMaterialsJSON, &prepMaterials); err == nil {
-			response["prep_materials"] = prepMaterials
+
+	items := make([]map[string]interface{}, 0, len(materials))
+	for _, m := range materials {
+		item := map[string]interface{}{"id": m.ID, "kind": m.Kind, "title": m.Title}
+		if m.Body != "" {
+			item["body"] = m.Body
+		}
+		if m.URL != "" {
+			item["url"] = m.URL
 		}
+		if m.FileKey != "" {
+			item["file_name"] = m.FileName
+			item["content_type"] = m.ContentType
+			item["size"] = m.Size
+			if sessionID != "" {
+				item["file_url"] = "/api/v1/student/sessions/" + sessionID + "/materials/" + m.ID
+			}
+		}
+		items = append(items, item)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"topic_text":     topic.Text,
+		"prep_materials": prepMaterials,
+		"materials":      items,
+	})
+}
+
+// GetPrepMaterialFile serves an attachment of the session's topic to a
+// participant, while the material is visible to them.
+func (h *Handlers) GetPrepMaterialFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	studentID := r.Context().Value("studentID").(string)
+	sessionID := r.URL.Query().Get("session_id")
+	materialID := r.URL.Query().Get("material_id")
+
+	var material repository.PrepMaterial
+	err := h.store.InTx(ctx, func(tx repository.Store) error {
+		session, err := tx.Sessions().Get(ctx, sessionID)
+		if err == repository.ErrNotFound {
+			return apierror.New(http.StatusNotFound, "Session not found")
+		}
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		if ok, err := isParticipant(ctx, tx, session.ID, studentID); err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		} else if !ok {
+			return apierror.New(http.StatusForbidden, "Not a participant of the session")
+		}
+		topic, err := sessionTopic(ctx, tx, session)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Failed to fetch topic", err)
+		}
+		phase, err := materialPhase(ctx, tx, session, studentID, time.Now())
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		materials, err := visibleMaterials(ctx, tx, topic.ID, phase)
+		if err != nil {
+			return apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+		}
+		for _, m := range materials {
+			if m.ID == materialID && m.FileKey != "" {
+				material = m
+				return nil
+			}
+		}
+		return apierror.New(http.StatusNotFound, "Attachment not found")
+	})
+	if err == nil {
+		err = storage.Serve(w, r, h.files, material.FileKey, material.FileName, material.ContentType)
+		if errors.Is(err, storage.ErrNotFound) {
+			err = apierror.New(http.StatusNotFound, "Attachment not found")
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "serving prep material failed", "session_id", sessionID, "material_id", materialID, "error", err)
+		apierror.Write(w, r, err)
+	}
+}
@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
+	"gd/apierror"
+	"gd/logging"
 	"gd/student/utils"
-	"log"
+	"log/slog"
 
 	// "log"
 	"net/http"
@@ -13,43 +14,39 @@ import (
 // student/middleware/auth.go
 func StudentOnly(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        log.Println("StudentOnly middleware - incoming request:", r.URL.Path)
+        slog.DebugContext(r.Context(), "authenticating student request", "path", r.URL.Path)
         
         authHeader := r.Header.Get("Authorization")
         if authHeader == "" {
-            log.Println("No authorization header found")
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Authorization header required"})
+            slog.WarnContext(r.Context(), "student request without authorization header")
+            apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Authorization header required"))
             return
         }
 
         tokenString := strings.TrimPrefix(authHeader, "Bearer ")
         if tokenString == authHeader {
-            log.Println("Invalid token format - Bearer prefix missing")
-            w.WriteHeader(http.StatusUnauthorized)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Bearer token required"})
+            slog.WarnContext(r.Context(), "student token missing Bearer prefix")
+            apierror.Write(w, r, apierror.New(http.StatusUnauthorized, "Bearer token required"))
             return
         }
 
-        log.Println("Verifying token...")
         claims, err := jwt.VerifyStudentToken(tokenString)
         if err != nil {
-            log.Printf("Token verification failed: %v", err)
-            w.WriteHeader(http.StatusForbidden)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Invalid token", "details": err.Error()})
+            slog.WarnContext(r.Context(), "student token verification failed", "error", err)
+            apierror.Write(w, r, apierror.New(http.StatusForbidden, "Invalid token"))
             return
         }
 
         if claims.Role != "student" {
-            log.Printf("Invalid role: %s", claims.Role)
-            w.WriteHeader(http.StatusForbidden)
-            json.NewEncoder(w).Encode(map[string]string{"error": "Insufficient privileges"})
+            slog.WarnContext(r.Context(), "student token has wrong role", "role", claims.Role)
+            apierror.Write(w, r, apierror.New(http.StatusForbidden, "Insufficient privileges"))
             return
         }
 
-        log.Printf("Token valid for student %s, level %d", claims.UserID, claims.Level)
         ctx := context.WithValue(r.Context(), "studentID", claims.UserID)
         ctx = context.WithValue(ctx, "studentLevel", claims.Level)
+        ctx = logging.With(ctx, slog.String("student_id", claims.UserID))
+        slog.DebugContext(ctx, "student token valid", "level", claims.Level)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
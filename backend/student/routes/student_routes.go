@@ -2,69 +2,80 @@ package routes
 
 import (
 	"gd/student/controllers"
-   s "gd/admin/controllers"
 	"gd/student/middleware"
 	"net/http"
 )
 
-func SetupStudentRoutes() *http.ServeMux {
+// Router is the part of *http.ServeMux the route tables use, so tests can
+// record what gets registered.
+type Router interface {
+    Handle(pattern string, handler http.Handler)
+}
+
+func SetupStudentRoutes(h *controllers.Handlers) *http.ServeMux {
     router := http.NewServeMux()
+    RegisterStudentRoutes(router, h)
+    return router
+}
+
+// RegisterStudentRoutes adds every student endpoint to router, serving them
+// from h. Each one must be described in gd/openapi.
+func RegisterStudentRoutes(router Router, h *controllers.Handlers) {
     
     // Auth
-    router.Handle("/student/login", http.HandlerFunc(controllers.StudentLogin))
+    router.Handle("/student/login", http.HandlerFunc(h.StudentLogin))
     
     // Session Management
     router.Handle("/student/sessions", middleware.StudentOnly(
-        http.HandlerFunc(controllers.GetAvailableSessions)))
+        http.HandlerFunc(h.GetAvailableSessions)))
     router.Handle("/student/sessions/book", middleware.StudentOnly(
-        http.HandlerFunc(controllers.BookVenue)))  // Add this line
+        http.HandlerFunc(h.BookVenue)))  // Add this line
     router.Handle("/student/sessions/join", middleware.StudentOnly(
-        http.HandlerFunc(controllers.JoinSession)))
+        http.HandlerFunc(h.JoinSession)))
     router.Handle("/student/session", middleware.StudentOnly(
-        http.HandlerFunc(controllers.GetSessionDetails)))
+        http.HandlerFunc(h.GetSessionDetails)))
     router.Handle("/student/topic", 
-    middleware.StudentOnly(http.HandlerFunc(controllers.GetTopicForLevel)))
+    middleware.StudentOnly(http.HandlerFunc(h.GetTopicForLevel)))
     // Survey System
     router.Handle("/student/survey", middleware.StudentOnly(
-        http.HandlerFunc(controllers.SubmitSurvey)))
+        http.HandlerFunc(h.SubmitSurvey)))
     
     // Results
      router.Handle("/student/results", middleware.StudentOnly(
-        http.HandlerFunc(controllers.GetResults)))
+        http.HandlerFunc(h.GetResults)))
     router.Handle("/student/survey/start-question", middleware.StudentOnly(
-    http.HandlerFunc(controllers.StartQuestionTimer)))
+    http.HandlerFunc(h.StartQuestionTimer)))
      router.Handle("/student/survey/check-timeout", middleware.StudentOnly(
-    http.HandlerFunc(controllers.CheckQuestionTimeout)))
+    http.HandlerFunc(h.CheckQuestionTimeout)))
      router.Handle("/student/survey/apply-penalty", middleware.StudentOnly(
-    http.HandlerFunc(controllers.ApplyQuestionPenalty)))
+    http.HandlerFunc(h.ApplyQuestionPenalty)))
     router.Handle("/student/survey/start", middleware.StudentOnly(
-    http.HandlerFunc(controllers.StartSurveyTimer)))
+    http.HandlerFunc(h.StartSurveyTimer)))
     router.Handle("/student/survey/timeout", middleware.StudentOnly(
-    http.HandlerFunc(controllers.CheckSurveyTimeout)))
+    http.HandlerFunc(h.CheckSurveyTimeout)))
     router.Handle("/student/survey/penalties", middleware.StudentOnly(
-    http.HandlerFunc(controllers.ApplySurveyPenalties)))
+    http.HandlerFunc(h.ApplySurveyPenalties)))
     router.Handle("/student/session/check", middleware.StudentOnly(
-    http.HandlerFunc(controllers.CheckBooking)))
+    http.HandlerFunc(h.CheckBooking)))
     router.Handle("/student/session/cancel", middleware.StudentOnly(
-    http.HandlerFunc(controllers.CancelBooking)))
+    http.HandlerFunc(h.CancelBooking)))
     router.Handle("/student/session/participants", middleware.StudentOnly(
-    http.HandlerFunc(controllers.GetSessionParticipants)))
+    http.HandlerFunc(h.GetSessionParticipants)))
     router.Handle("/student/survey/completion", middleware.StudentOnly(
-    http.HandlerFunc(controllers.CheckSurveyCompletion)))
+    http.HandlerFunc(h.CheckSurveyCompletion)))
     router.Handle("/student/survey/mark-completed", middleware.StudentOnly(
-    http.HandlerFunc(controllers.MarkSurveyCompleted)))
+    http.HandlerFunc(h.MarkSurveyCompleted)))
     router.Handle("/student/feedback", middleware.StudentOnly(
-    http.HandlerFunc(controllers.SubmitFeedback)))
+    http.HandlerFunc(h.SubmitFeedback)))
     router.Handle("/student/session/rules", middleware.StudentOnly(
-        http.HandlerFunc(s.GetSessionRules)))
+        http.HandlerFunc(h.GetSessionRules)))
 
 
 
     router.Handle("/student/feedback/get", middleware.StudentOnly(
-    http.HandlerFunc(controllers.GetFeedback)))
+    http.HandlerFunc(h.GetFeedback)))
      router.Handle("/student/questions", middleware.StudentOnly(
-        http.HandlerFunc(controllers.GetQuestionsForStudent)))
+        http.HandlerFunc(h.GetQuestionsForStudent)))
     router.Handle("/student/session/status", middleware.StudentOnly(
-    http.HandlerFunc(controllers.UpdateSessionStatus)))
-    return router
+    http.HandlerFunc(h.UpdateSessionStatus)))
 }
\ No newline at end of file
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"net/http"
+
+	"gd/routing"
+	"gd/student/controllers"
+	"gd/student/middleware"
+)
+
+// RegisterStudentV1 adds the /api/v1/student resources to router. Everything
+// about one session hangs off /sessions/{session_id}. Each route must be
+// described in gd/openapi. Every handler is served from h.
+func RegisterStudentV1(router Router, h *controllers.Handlers) {
+	public := func(h http.HandlerFunc) http.Handler { return routing.PathParams(h) }
+	student := func(h http.HandlerFunc) http.Handler { return middleware.StudentOnly(routing.PathParams(h)) }
+
+	router.Handle("POST /api/v1/student/login", public(h.StudentLogin))
+
+	router.Handle("GET /api/v1/student/venues", student(h.GetAvailableSessions))
+	router.Handle("POST /api/v1/student/bookings", student(h.BookVenue))
+	router.Handle("GET /api/v1/student/bookings/{venue_id}", student(h.CheckBooking))
+	router.Handle("DELETE /api/v1/student/bookings/{venue_id}", student(h.CancelBooking))
+	router.Handle("POST /api/v1/student/check-ins", student(h.JoinSession))
+	router.Handle("GET /api/v1/student/topic", student(h.GetTopicForLevel))
+
+	const session = "/api/v1/student/sessions/{session_id}"
+	router.Handle("GET "+session, student(h.GetSessionDetails))
+	router.Handle("GET "+session+"/rules", student(h.GetSessionRules))
+	router.Handle("GET "+session+"/participants", student(h.GetSessionParticipants))
+	router.Handle("PUT "+session+"/status", student(h.UpdateSessionStatus))
+	router.Handle("GET "+session+"/topic", student(h.GetTopicForLevel))
+	router.Handle("GET "+session+"/materials/{material_id}", student(h.GetPrepMaterialFile))
+	router.Handle("GET "+session+"/questions", student(h.GetQuestionsForStudent))
+	router.Handle("GET "+session+"/results", student(h.GetResults))
+	router.Handle("GET "+session+"/feedback", student(h.GetFeedback))
+	router.Handle("POST "+session+"/feedback", student(h.SubmitFeedback))
+
+	router.Handle("POST "+session+"/survey/responses", student(h.SubmitSurvey))
+	router.Handle("GET "+session+"/survey/completion", student(h.CheckSurveyCompletion))
+	router.Handle("PUT "+session+"/survey/completion", student(h.MarkSurveyCompleted))
+	router.Handle("GET "+session+"/survey/timer", student(h.CheckSurveyTimeout))
+	router.Handle("POST "+session+"/survey/timer", student(h.StartSurveyTimer))
+	router.Handle("POST "+session+"/survey/penalties", student(h.ApplySurveyPenalties))
+	router.Handle("GET "+session+"/survey/questions/{question_id}/timer", student(h.CheckQuestionTimeout))
+	router.Handle("POST "+session+"/survey/questions/{question_id}/timer", student(h.StartQuestionTimer))
+	router.Handle("POST "+session+"/survey/questions/{question_id}/penalties", student(h.ApplyQuestionPenalty))
+}
@@ -1,15 +1,18 @@
 package jwt
 
 import (
-	// "log"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var secret = []byte(os.Getenv("JWT_SECRET_STUDENT"))
+var secret []byte
+
+// SetSecret installs the key used to sign and verify student tokens.
+func SetSecret(key string) {
+	secret = []byte(key)
+}
 
 type StudentClaims struct {
 	UserID string `json:"user_id"`
@@ -38,7 +41,7 @@ func GenerateStudentToken(id string, level int) (string, error) {
         return "", err
     }
     
-    log.Printf("Generated token for user %s (level %d)", id, level)
+    slog.Debug("generated student token", "student_id", id, "level", level)
     return tokenString, nil
 }
 
@@ -47,41 +50,23 @@ func VerifyStudentToken(tokenString string) (*StudentClaims, error) {
         return nil, jwt.ErrInvalidKey
     }
 
-    // Add debug log
-    log.Printf("Verifying token: %s", tokenString)
-
     token, err := jwt.ParseWithClaims(tokenString, &StudentClaims{}, func(t *jwt.Token) (interface{}, error) {
         // Verify the signing method
         if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-            log.Printf("Unexpected signing method: %v", t.Header["alg"])
+            slog.Warn("student token has unexpected signing method", "alg", t.Header["alg"])
             return nil, jwt.ErrSignatureInvalid
         }
         return secret, nil
     })
     
     if err != nil {
-        log.Printf("Token verification error: %v", err)
         return nil, err
     }
     
     if claims, ok := token.Claims.(*StudentClaims); ok && token.Valid {
-        log.Printf("Token valid for user: %s, role: %s", claims.UserID, claims.Role)
         return claims, nil
     }
     
-    log.Println("Invalid token claims")
     return nil, jwt.ErrInvalidKey
 }
 
-
-func init() {
-    secretStr := os.Getenv("JWT_SECRET_STUDENT")
-    if secretStr == "" {
-        secretStr = "password123" // Fallback for development
-        log.Println("WARNING: Using default JWT secret - configure JWT_SECRET_STUDENT for production")
-    }
-    secret = []byte(secretStr)
-    log.Printf("JWT secret initialized (length: %d)", len(secret))
-}
-
-
@@ -58,6 +58,7 @@ export default function TopicManager({ navigation }) {
 
       if (editingTopic) {
         await api.put('/admin/topics', {
+          ...editingTopic,
           ...formData,
           id: editingTopic.id,
           level: parseInt(formData.level)
@@ -85,7 +86,7 @@ export default function TopicManager({ navigation }) {
       fetchTopics();
     } catch (error) {
       console.error('Failed to save topic:', error);
-      Alert.alert('Error', 'Failed to save topic');
+      Alert.alert('Error', error.response?.data?.error || 'Failed to save topic');
     }
   };
 
@@ -0,0 +1,1373 @@
+// Code generated by go run ./openapi/cmd/tsclient; DO NOT EDIT.
+// GD API 1.0.0
+
+export interface AdminLoginRequest {
+  email: string;
+  password: string;
+}
+
+export interface AdminToken {
+  token: string;
+  token_type: 'Bearer';
+}
+
+export interface ApiError {
+  code: 'bad_request' | 'validation_failed' | 'unauthorized' | 'forbidden' | 'not_found' | 'method_not_allowed' | 'conflict' | 'internal_error' | 'qr_invalid' | 'qr_inactive' | 'qr_full' | 'similar_topic' | 'schedule_conflict';
+  /** Human-readable message. */
+  error: string;
+  fields?: FieldError[];
+  request_id?: string;
+}
+
+export interface AvailabilityWindow {
+  end: string;
+  start: string;
+  weekdays: number[];
+}
+
+export interface AvailableVenue {
+  booked: number;
+  capacity: number;
+  id: string;
+  level: number;
+  remaining: number;
+  session_timing: string;
+  table_details: string;
+  venue_name: string;
+}
+
+export interface BankDraw {
+  bank_id: string;
+  count: number;
+}
+
+export interface Booking {
+  booked_seats: number;
+  remaining_seats: number;
+  session_id: string;
+  status: string;
+  venue_id: string;
+}
+
+export interface BookingCheck {
+  is_booked: boolean;
+}
+
+export interface BookingInfo {
+  booked_at: string;
+  session_id: string;
+  session_level: number;
+  student_id: string;
+  student_name: string;
+  venue_id: string;
+  venue_name: string;
+}
+
+export interface BookingRequest {
+  student_id?: string;
+  venue_id: string;
+}
+
+export interface CheckIn {
+  qr_data: string;
+}
+
+export interface CheckedIn {
+  session_id: string;
+  status: string;
+}
+
+export interface CollusionFlag {
+  created_at: string;
+  detail: string;
+  id: string;
+  kind: string;
+  level: number;
+  responder_ids: string[];
+  review_note: string;
+  reviewed_at?: string | null;
+  session_id: string;
+  status: string;
+}
+
+export interface CollusionReviewInput {
+  action: string;
+  id?: string;
+  note?: string;
+  question_ids?: string[];
+  responder_ids?: string[];
+}
+
+export interface CreatedSessions {
+  sessions: Array<{
+    end_time: string;
+    id: string;
+    start_time: string;
+    venue_id: string;
+  }>;
+  status: string;
+}
+
+export interface Feedback {
+  comments?: string;
+  rating?: number;
+}
+
+export interface FeedbackInput {
+  comments?: string;
+  rating: number;
+  session_id?: string;
+}
+
+export interface FeedbackRequest {
+  comments?: string;
+  rating: number;
+  session_id: string;
+}
+
+export interface FieldError {
+  field: string;
+  message: string;
+}
+
+export interface GeneratedSession {
+  end_time: string;
+  id: string;
+  level: number;
+  start_time: string;
+  venue_id: string;
+}
+
+export interface GeneratedSessions {
+  closures: VenueClosure[];
+  dry_run: boolean;
+  sessions: GeneratedSession[];
+  skipped: SkippedSlot[];
+}
+
+export interface Holiday {
+  date: string;
+  name: string;
+}
+
+export interface ImportReport {
+  created: number;
+  dry_run: boolean;
+  rows: ImportRow[];
+  updated: number;
+}
+
+export interface ImportRow {
+  action: string;
+  errors: FieldError[];
+  external_key: string;
+  row: number;
+}
+
+export interface Message {
+  message: string;
+}
+
+export interface ModeratorReview {
+  feedback: string;
+  name: string;
+  scores: Record<string, number>;
+  student_id: string;
+}
+
+export interface PrepMaterial {
+  body?: string;
+  content_type?: string;
+  display_order: number;
+  file_name?: string;
+  id: string;
+  kind: string;
+  size?: number;
+  title: string;
+  topic_id: string;
+  url?: string;
+  visible_from: string;
+  visible_until?: string;
+}
+
+export interface PrepMaterialInput {
+  body?: string;
+  content_type?: string;
+  display_order?: number;
+  file_name?: string;
+  id?: string;
+  kind: string;
+  size?: number;
+  title?: string;
+  topic_id?: string;
+  url?: string;
+  visible_from?: string;
+  visible_until?: string;
+}
+
+export interface Question {
+  /** Set, and otherwise null, once a used question was deleted. */
+  archived_at?: string;
+  /** Empty when the question is in no bank. */
+  bank_id: string;
+  id: string;
+  is_active: boolean;
+  levels: number[];
+  text: string;
+  weight: number;
+}
+
+export interface QuestionBank {
+  description: string;
+  id: string;
+  name: string;
+  questions: number;
+}
+
+export interface QuestionBankInput {
+  description?: string;
+  id?: string;
+  name: string;
+  questions?: number;
+}
+
+export interface QuestionOrder {
+  level: number;
+  question_ids: string[];
+}
+
+export interface QuestionOrderInput {
+  level?: number;
+  question_ids: string[];
+}
+
+export interface QuestionRemoved {
+  /** A question students were asked is archived rather than deleted. */
+  status: 'deleted' | 'archived';
+}
+
+export interface QuestionRequest {
+  bank_id?: string;
+  levels: number[];
+  text: string;
+  weight: number;
+}
+
+export interface QuestionRow {
+  bank: string;
+  external_key: string;
+  is_active?: boolean | null;
+  levels: number[];
+  text: string;
+  weight: number;
+}
+
+export interface QuestionTemplate {
+  draws: BankDraw[];
+  level: number;
+}
+
+export interface QuestionTemplateInput {
+  draws: BankDraw[];
+  level?: number;
+}
+
+export interface QuestionUpdate {
+  /** Empty to take the question out of its bank. */
+  bank_id?: string;
+  /** true also restores an archived question. */
+  is_active?: boolean;
+  levels?: number[];
+  text?: string;
+  weight?: number;
+}
+
+export interface QuestionUsage {
+  archived_at?: string | null;
+  id: string;
+  last_used_at?: string | null;
+  responses: number;
+  sessions: number;
+  text: string;
+}
+
+export interface RankingPointsConfig {
+  consensus_method: 'borda' | 'median' | 'schulze';
+  effective_from: string;
+  first_place_points: number;
+  id: string;
+  is_active: boolean;
+  level: number;
+  points: number[];
+  second_place_points: number;
+  third_place_points: number;
+  version: number;
+}
+
+export interface RankingPointsConfigActive {
+  is_active: boolean;
+  status: string;
+}
+
+export interface RankingPointsConfigInput {
+  consensus_method?: 'borda' | 'median' | 'schulze';
+  effective_from?: string;
+  first_place_points?: number;
+  id?: string;
+  is_active?: boolean;
+  level?: number;
+  points?: number[];
+  second_place_points?: number;
+  third_place_points?: number;
+  version?: number;
+}
+
+export interface RankingPointsConfigSaved {
+  config: RankingPointsConfig;
+  status: string;
+}
+
+export interface Rubric {
+  criteria: RubricCriterion[];
+  level: number;
+  moderator_weight: number;
+}
+
+export interface RubricCriterion {
+  id: string;
+  max_score: number;
+  name: string;
+  weight: number;
+}
+
+export interface RubricInput {
+  criteria?: RubricCriterion[];
+  level?: number;
+  moderator_weight?: number;
+}
+
+export interface RubricScoreInput {
+  feedback?: string;
+  scores?: Record<string, number>;
+  session_id?: string;
+  student_id?: string;
+}
+
+export interface SessionDetails {
+  discussion_time: number;
+  id: string;
+  prep_time: number;
+  start_time: string;
+  survey_time: number;
+  topic: string;
+  venue: string;
+}
+
+export interface SessionFeedbacks {
+  count: number;
+  feedbacks: Array<{
+    comments: string;
+    created_at: string;
+    id: string;
+    rating: number;
+    student: {
+      department: string;
+      name: string;
+      year: number;
+    };
+  }>;
+}
+
+export interface SessionGenerationInput {
+  agenda?: Record<string, unknown>;
+  from: string;
+  survey_weights?: Record<string, number>;
+  to: string;
+  venue_ids?: string[];
+}
+
+export interface SessionParticipants {
+  data: Array<{
+    department: string;
+    email: string;
+    id: string;
+    name: string;
+    profileImage: string;
+  }>;
+}
+
+export interface SessionRequest {
+  agenda?: Record<string, unknown>;
+  end_time: string;
+  level: number;
+  start_time: string;
+  survey_weights?: Record<string, number>;
+  venue_id: string;
+}
+
+export interface SessionResult {
+  biased_questions: number;
+  /** Decimal with two places. */
+  final_score: string;
+  first_places: number;
+  /** Rubric mark as a percentage, for students a moderator marked. */
+  moderator_score?: string;
+  /** Share of final_score taken from moderator marks. */
+  moderator_weight?: number;
+  name: string;
+  /** Total less penalties; present when moderator marks are blended in, final_score then being a percentage. */
+  peer_score?: string;
+  /** Decimal with two places. */
+  penalty_points: string;
+  photo_url: string;
+  /** Only present once the results are final. */
+  qualified?: boolean;
+  rank: number;
+  student_id: string;
+  /** Decimal with two places. */
+  total_score: string;
+}
+
+export interface SessionResults {
+  /** The moderator's feedback for the requesting student. */
+  feedback?: string;
+  /** Whether the results are final; until then they are provisional. */
+  finalized: boolean;
+  results: SessionResult[];
+  /** The requesting student's moderator marks, once the results are final. */
+  rubric?: Array<{
+    criterion: string;
+    max_score: number;
+    score: number;
+  }>;
+  session_id: string;
+  /** Until final: whether responses are held for collusion review. */
+  under_review?: boolean;
+}
+
+export interface SessionRules {
+  discussion_time: number;
+  prep_time: number;
+  survey_time: number;
+}
+
+export interface SessionRulesRequest {
+  discussion_time?: number;
+  prep_time?: number;
+  session_id: string;
+  survey_time?: number;
+}
+
+export interface SessionRulesUpdate {
+  discussion_time?: number;
+  prep_time?: number;
+  session_id?: string;
+  survey_time?: number;
+}
+
+export interface SessionSlot {
+  end_time: string;
+  id: string;
+  level: number;
+  start_time: string;
+  status: string;
+  venue: string;
+}
+
+export interface SkippedSlot {
+  end_time: string;
+  reason: string;
+  start_time: string;
+  venue_id: string;
+}
+
+export interface Status {
+  status: string;
+}
+
+export interface StudentLoginRequest {
+  email: string;
+  password: string;
+}
+
+export interface StudentProgress {
+  attempts: number;
+  current_level: number;
+  department: string;
+  id: string;
+  name: string;
+  qualified: boolean;
+}
+
+export interface StudentToken {
+  level: number;
+  token: string;
+  user_id: string;
+}
+
+export interface SurveyCompletion {
+  all_completed: boolean;
+  completed: number;
+  total: number;
+}
+
+export interface SurveyProgress {
+  completed: boolean;
+  questions_answered: number;
+  status: string;
+  total_questions: number;
+}
+
+export interface SurveyQuestion {
+  id: string;
+  /** How many students to rank. */
+  ranking_depth: number;
+  text: string;
+  weight: number;
+}
+
+export interface SurveyResponses {
+  is_final?: boolean;
+  is_partial?: boolean;
+  responses: Record<string, Record<string, string>>;
+  session_id?: string;
+}
+
+export interface SurveySubmission {
+  is_final?: boolean;
+  is_partial?: boolean;
+  responses: Record<string, Record<string, string>>;
+  session_id: string;
+}
+
+export interface Timeout {
+  is_timed_out: boolean;
+  remaining_seconds: number;
+}
+
+export interface TopParticipants {
+  level: number;
+  top_participants: Array<{
+    avg_score: number;
+    id: string;
+    level: number;
+    name: string;
+    session_count: number;
+    total_score: number;
+  }>;
+}
+
+export interface Topic {
+  average_rating?: number | null;
+  category: string;
+  difficulty: string;
+  id: string;
+  is_active: boolean;
+  level: number;
+  prep_materials: Record<string, unknown>;
+  source: string;
+  tags: string[];
+  times_used: number;
+  topic_text: string;
+}
+
+export interface TopicForLevel {
+  /** The prep materials visible to the student at this point of the session. */
+  materials: VisibleMaterial[];
+  /** The topic's free-form notes; superseded by materials. */
+  prep_materials: Record<string, unknown>;
+  topic_text: string;
+}
+
+export interface TopicInput {
+  average_rating?: number | null;
+  category?: string;
+  difficulty?: string;
+  id?: string;
+  is_active?: boolean;
+  level: number;
+  prep_materials?: Record<string, unknown>;
+  source?: string;
+  tags?: string[];
+  times_used?: number;
+  topic_text: string;
+}
+
+export interface TopicRow {
+  category: string;
+  difficulty: string;
+  external_key: string;
+  is_active?: boolean | null;
+  level: number;
+  source: string;
+  tags: string[];
+  topic_text: string;
+}
+
+export interface TopicUpdate {
+  average_rating?: number | null;
+  category?: string;
+  difficulty?: string;
+  id: string;
+  is_active?: boolean;
+  level: number;
+  prep_materials?: Record<string, unknown>;
+  source?: string;
+  tags?: string[];
+  times_used?: number;
+  topic_text: string;
+}
+
+export interface Venue {
+  availability?: VenueAvailability;
+  capacity: number;
+  created_by: string;
+  id: string;
+  is_active: boolean;
+  level: number;
+  name: string;
+  qr_secret: string;
+  session_timing: string;
+  table_details: string;
+}
+
+export interface VenueAvailability {
+  blackout_dates: string[];
+  slot_minutes: number;
+  timezone: string;
+  windows: AvailabilityWindow[];
+}
+
+export interface VenueAvailabilityInput {
+  blackout_dates?: string[];
+  slot_minutes: number;
+  timezone?: string;
+  windows: AvailabilityWindow[];
+}
+
+export interface VenueClosure {
+  date: string;
+  reason: string;
+  venue_id: string;
+}
+
+export interface VenueInput {
+  availability?: VenueAvailability;
+  capacity: number;
+  created_by?: string;
+  id?: string;
+  is_active?: boolean;
+  level: number;
+  name: string;
+  qr_secret?: string;
+  session_timing?: string;
+  table_details?: string;
+}
+
+export interface VenueQR {
+  current_usage: number;
+  expires_at: string;
+  /** Minutes until expiry. */
+  expires_in: number;
+  is_full?: boolean;
+  is_new: boolean;
+  max_capacity: number;
+  qr_group_id?: string;
+  qr_id: string;
+  /** Encrypted payload students scan. */
+  qr_string: string;
+  remaining_slots: number;
+  success: boolean;
+}
+
+export interface VenueQRSummary {
+  created_at: string;
+  current_usage: number;
+  expires_at: string;
+  id: string;
+  is_active: boolean;
+  is_full: boolean;
+  max_capacity: number;
+  qr_data_short: string;
+  qr_group_id: string;
+  remaining: number;
+}
+
+export interface VenueUpdate {
+  capacity: number;
+  /** Defaults to the id in the path. */
+  id?: string;
+  level: number;
+  name: string;
+  session_timing?: string;
+  table_details?: string;
+}
+
+export interface VisibleMaterial {
+  body?: string;
+  content_type?: string;
+  file_name?: string;
+  /** Where a participant downloads the attachment; only given with session_id. */
+  file_url?: string;
+  id: string;
+  kind: 'link' | 'key_point' | 'argument_for' | 'argument_against' | 'attachment';
+  size?: number;
+  title: string;
+  url?: string;
+}
+
+export interface HttpClient {
+  request<T>(config: {
+    method: string;
+    url: string;
+    params?: Record<string, unknown>;
+    data?: unknown;
+    responseType?: 'blob';
+  }): Promise<{ data: T }>;
+}
+
+export class GdClient {
+  constructor(private readonly http: HttpClient) {}
+
+  /** Qualification rate per department */
+  getQualificationRates(): Promise<Record<string, number>> {
+    return this.http
+      .request<Record<string, number>>({ method: 'GET', url: '/api/v1/admin/analytics/qualifications' })
+      .then(r => r.data);
+  }
+
+  /** Bookings for pending sessions */
+  listBookings(): Promise<BookingInfo[]> {
+    return this.http
+      .request<BookingInfo[]>({ method: 'GET', url: '/api/v1/admin/bookings' })
+      .then(r => r.data);
+  }
+
+  /** Survey responses flagged as possibly coordinated */
+  listCollusionFlags(query: { status?: 'pending' | 'voided' | 'dismissed'; session_id?: string } = {}): Promise<CollusionFlag[]> {
+    return this.http
+      .request<CollusionFlag[]>({ method: 'GET', url: '/api/v1/admin/collusion-flags', params: query })
+      .then(r => r.data);
+  }
+
+  /** Void the flagged responses or dismiss the flag */
+  reviewCollusionFlag(id: string, body: CollusionReviewInput): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'PUT', url: `/api/v1/admin/collusion-flags/${encodeURIComponent(id)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Dates no sessions are generated on, by date */
+  listHolidays(): Promise<Holiday[]> {
+    return this.http
+      .request<Holiday[]>({ method: 'GET', url: '/api/v1/admin/holidays' })
+      .then(r => r.data);
+  }
+
+  /** Remove a holiday */
+  deleteHoliday(date: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'DELETE', url: `/api/v1/admin/holidays/${encodeURIComponent(date)}` })
+      .then(r => r.data);
+  }
+
+  /** Add or rename the holiday on a date, written YYYY-MM-DD */
+  saveHoliday(date: string, body: {
+    name: string;
+  }): Promise<Holiday> {
+    return this.http
+      .request<Holiday>({ method: 'PUT', url: `/api/v1/admin/holidays/${encodeURIComponent(date)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Top 20 participants by total score */
+  getLeaderboard(query: { level?: number } = {}): Promise<TopParticipants> {
+    return this.http
+      .request<TopParticipants>({ method: 'GET', url: '/api/v1/admin/leaderboard', params: query })
+      .then(r => r.data);
+  }
+
+  /** Exchange admin credentials for a token */
+  adminLogin(body: AdminLoginRequest): Promise<AdminToken> {
+    return this.http
+      .request<AdminToken>({ method: 'POST', url: '/api/v1/admin/login', data: body })
+      .then(r => r.data);
+  }
+
+  /** Delete a material and any attached file */
+  deletePrepMaterial(id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'DELETE', url: `/api/v1/admin/prep-materials/${encodeURIComponent(id)}` })
+      .then(r => r.data);
+  }
+
+  /** Change a material's content, visibility and position */
+  updatePrepMaterial(id: string, body: PrepMaterialInput): Promise<PrepMaterial> {
+    return this.http
+      .request<PrepMaterial>({ method: 'PUT', url: `/api/v1/admin/prep-materials/${encodeURIComponent(id)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** An attachment's file */
+  downloadPrepMaterial(id: string): Promise<Blob> {
+    return this.http
+      .request<Blob>({ method: 'GET', url: `/api/v1/admin/prep-materials/${encodeURIComponent(id)}/file`, responseType: 'blob' })
+      .then(r => r.data);
+  }
+
+  /** Deactivate a QR code */
+  deactivateQRGroup(qr_id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'DELETE', url: `/api/v1/admin/qr-groups/${encodeURIComponent(qr_id)}` })
+      .then(r => r.data);
+  }
+
+  /** Question banks by name, with their active question counts */
+  listQuestionBanks(): Promise<QuestionBank[]> {
+    return this.http
+      .request<QuestionBank[]>({ method: 'GET', url: '/api/v1/admin/question-banks' })
+      .then(r => r.data);
+  }
+
+  /** Create a question bank for a competency */
+  createQuestionBank(body: QuestionBankInput): Promise<QuestionBank> {
+    return this.http
+      .request<QuestionBank>({ method: 'POST', url: '/api/v1/admin/question-banks', data: body })
+      .then(r => r.data);
+  }
+
+  /** Delete a question bank; its questions are kept without one */
+  deleteQuestionBank(id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'DELETE', url: `/api/v1/admin/question-banks/${encodeURIComponent(id)}` })
+      .then(r => r.data);
+  }
+
+  /** Rename a question bank */
+  updateQuestionBank(id: string, body: QuestionBankInput): Promise<QuestionBank> {
+    return this.http
+      .request<QuestionBank>({ method: 'PUT', url: `/api/v1/admin/question-banks/${encodeURIComponent(id)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Set the order a level's questions are served in */
+  reorderQuestions(level: string, body: QuestionOrderInput): Promise<QuestionOrder> {
+    return this.http
+      .request<QuestionOrder>({ method: 'PUT', url: `/api/v1/admin/question-order/${encodeURIComponent(level)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** How many questions the level's sessions draw from each bank */
+  getQuestionTemplate(level: string): Promise<QuestionTemplate> {
+    return this.http
+      .request<QuestionTemplate>({ method: 'GET', url: `/api/v1/admin/question-templates/${encodeURIComponent(level)}` })
+      .then(r => r.data);
+  }
+
+  /** Replace the level's question template */
+  updateQuestionTemplate(level: string, body: QuestionTemplateInput): Promise<QuestionTemplate> {
+    return this.http
+      .request<QuestionTemplate>({ method: 'PUT', url: `/api/v1/admin/question-templates/${encodeURIComponent(level)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Every question, archived ones included, with the sessions and responses it has */
+  listQuestionUsage(): Promise<QuestionUsage[]> {
+    return this.http
+      .request<QuestionUsage[]>({ method: 'GET', url: '/api/v1/admin/question-usage' })
+      .then(r => r.data);
+  }
+
+  /** List survey questions */
+  listQuestions(query: { level?: number; archived?: boolean } = {}): Promise<Question[]> {
+    return this.http
+      .request<Question[]>({ method: 'GET', url: '/api/v1/admin/questions', params: query })
+      .then(r => r.data);
+  }
+
+  /** Create a survey question */
+  createQuestion(body: QuestionRequest): Promise<{
+    id: string;
+    status: string;
+  }> {
+    return this.http
+      .request<{
+    id: string;
+    status: string;
+  }>({ method: 'POST', url: '/api/v1/admin/questions', data: body })
+      .then(r => r.data);
+  }
+
+  /** Download the questions for editing or for import elsewhere */
+  exportQuestions(query: { format?: 'json' | 'csv'; archived?: boolean } = {}): Promise<Blob> {
+    return this.http
+      .request<Blob>({ method: 'GET', url: '/api/v1/admin/questions/export', params: query, responseType: 'blob' })
+      .then(r => r.data);
+  }
+
+  /** Create and update questions from a file, matching them by external_key */
+  importQuestions(body: FormData, query: { dry_run?: boolean; level_map?: string } = {}): Promise<ImportReport> {
+    return this.http
+      .request<ImportReport>({ method: 'POST', url: '/api/v1/admin/questions/import', params: query, data: body })
+      .then(r => r.data);
+  }
+
+  /** Delete a survey question, or archive it if it was used */
+  deleteQuestion(id: string, query: { purge?: boolean } = {}): Promise<QuestionRemoved> {
+    return this.http
+      .request<QuestionRemoved>({ method: 'DELETE', url: `/api/v1/admin/questions/${encodeURIComponent(id)}`, params: query })
+      .then(r => r.data);
+  }
+
+  /** Update a survey question; omitted fields are unchanged */
+  updateQuestion(id: string, body: QuestionUpdate): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'PUT', url: `/api/v1/admin/questions/${encodeURIComponent(id)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** List ranking point configurations */
+  listRankingPointsConfigs(query: { level?: number } = {}): Promise<RankingPointsConfig[]> {
+    return this.http
+      .request<RankingPointsConfig[]>({ method: 'GET', url: '/api/v1/admin/ranking-points', params: query })
+      .then(r => r.data);
+  }
+
+  /** Add a ranking point configuration version for a level */
+  createRankingPointsConfig(body: RankingPointsConfigInput): Promise<RankingPointsConfigSaved> {
+    return this.http
+      .request<RankingPointsConfigSaved>({ method: 'POST', url: '/api/v1/admin/ranking-points', data: body })
+      .then(r => r.data);
+  }
+
+  /** Delete a configuration */
+  deleteRankingPointsConfig(id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'DELETE', url: `/api/v1/admin/ranking-points/${encodeURIComponent(id)}` })
+      .then(r => r.data);
+  }
+
+  /** Supersede a configuration with a new version */
+  updateRankingPointsConfig(id: string, body: RankingPointsConfigInput): Promise<RankingPointsConfigSaved> {
+    return this.http
+      .request<RankingPointsConfigSaved>({ method: 'PUT', url: `/api/v1/admin/ranking-points/${encodeURIComponent(id)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Activate a configuration in place of its level's active one, or deactivate it */
+  setRankingPointsConfigActive(id: string, body: {
+    is_active: boolean;
+  }): Promise<RankingPointsConfigActive> {
+    return this.http
+      .request<RankingPointsConfigActive>({ method: 'PUT', url: `/api/v1/admin/ranking-points/${encodeURIComponent(id)}/active`, data: body })
+      .then(r => r.data);
+  }
+
+  /** The level's moderator rubric */
+  getRubric(level: string): Promise<Rubric> {
+    return this.http
+      .request<Rubric>({ method: 'GET', url: `/api/v1/admin/rubrics/${encodeURIComponent(level)}` })
+      .then(r => r.data);
+  }
+
+  /** Replace the level's moderator rubric */
+  updateRubric(level: string, body: RubricInput): Promise<Rubric> {
+    return this.http
+      .request<Rubric>({ method: 'PUT', url: `/api/v1/admin/rubrics/${encodeURIComponent(level)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Upcoming sessions */
+  listSessions(): Promise<SessionSlot[]> {
+    return this.http
+      .request<SessionSlot[]>({ method: 'GET', url: '/api/v1/admin/sessions' })
+      .then(r => r.data);
+  }
+
+  /** Schedule several sessions at once */
+  createSessions(body: {
+    sessions: SessionRequest[];
+  }): Promise<CreatedSessions> {
+    return this.http
+      .request<CreatedSessions>({ method: 'POST', url: '/api/v1/admin/sessions', data: body })
+      .then(r => r.data);
+  }
+
+  /** Create a pending session for every free slot of the venues' availability */
+  generateSessions(body: SessionGenerationInput, query: { dry_run?: boolean } = {}): Promise<GeneratedSessions> {
+    return this.http
+      .request<GeneratedSessions>({ method: 'POST', url: '/api/v1/admin/sessions/generate', params: query, data: body })
+      .then(r => r.data);
+  }
+
+  /** Feedback left for a session */
+  listSessionFeedback(session_id: string): Promise<SessionFeedbacks> {
+    return this.http
+      .request<SessionFeedbacks>({ method: 'GET', url: `/api/v1/admin/sessions/${encodeURIComponent(session_id)}/feedback` })
+      .then(r => r.data);
+  }
+
+  /** Moderator marks and feedback for each participant */
+  listRubricScores(session_id: string): Promise<ModeratorReview[]> {
+    return this.http
+      .request<ModeratorReview[]>({ method: 'GET', url: `/api/v1/admin/sessions/${encodeURIComponent(session_id)}/rubric-scores` })
+      .then(r => r.data);
+  }
+
+  /** Mark a participant against the level's rubric */
+  scoreParticipant(session_id: string, student_id: string, body: RubricScoreInput): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'PUT', url: `/api/v1/admin/sessions/${encodeURIComponent(session_id)}/rubric-scores/${encodeURIComponent(student_id)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Phase durations in minutes */
+  getSessionRules(session_id: string): Promise<SessionRules> {
+    return this.http
+      .request<SessionRules>({ method: 'GET', url: `/api/v1/admin/sessions/${encodeURIComponent(session_id)}/rules` })
+      .then(r => r.data);
+  }
+
+  /** Change the phase durations of a session */
+  updateSessionRules(session_id: string, body: SessionRulesUpdate): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'PUT', url: `/api/v1/admin/sessions/${encodeURIComponent(session_id)}/rules`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Student progress through the levels */
+  listStudentProgress(): Promise<StudentProgress[]> {
+    return this.http
+      .request<StudentProgress[]>({ method: 'GET', url: '/api/v1/admin/students' })
+      .then(r => r.data);
+  }
+
+  /** Search and filter topics with their usage */
+  listTopics(query: { level?: number; q?: string; category?: string; tag?: string; difficulty?: 'easy' | 'medium' | 'hard'; inactive?: boolean; page?: number; page_size?: number } = {}): Promise<Topic[]> {
+    return this.http
+      .request<Topic[]>({ method: 'GET', url: '/api/v1/admin/topics', params: query })
+      .then(r => r.data);
+  }
+
+  /** Create a topic */
+  createTopic(body: TopicInput, query: { force?: boolean } = {}): Promise<Topic> {
+    return this.http
+      .request<Topic>({ method: 'POST', url: '/api/v1/admin/topics', params: query, data: body })
+      .then(r => r.data);
+  }
+
+  /** Download every topic for editing or for import elsewhere */
+  exportTopics(query: { format?: 'json' | 'csv' } = {}): Promise<Blob> {
+    return this.http
+      .request<Blob>({ method: 'GET', url: '/api/v1/admin/topics/export', params: query, responseType: 'blob' })
+      .then(r => r.data);
+  }
+
+  /** Create and update topics from a file, matching them by external_key */
+  importTopics(body: FormData, query: { dry_run?: boolean; level_map?: string } = {}): Promise<ImportReport> {
+    return this.http
+      .request<ImportReport>({ method: 'POST', url: '/api/v1/admin/topics/import', params: query, data: body })
+      .then(r => r.data);
+  }
+
+  /** Delete a topic */
+  deleteTopic(id: string): Promise<Message> {
+    return this.http
+      .request<Message>({ method: 'DELETE', url: `/api/v1/admin/topics/${encodeURIComponent(id)}` })
+      .then(r => r.data);
+  }
+
+  /** Update a topic */
+  updateTopic(id: string, body: TopicInput, query: { force?: boolean } = {}): Promise<Message> {
+    return this.http
+      .request<Message>({ method: 'PUT', url: `/api/v1/admin/topics/${encodeURIComponent(id)}`, params: query, data: body })
+      .then(r => r.data);
+  }
+
+  /** Attach a PDF or image after the topic's other materials */
+  uploadPrepMaterial(topic_id: string, body: FormData): Promise<PrepMaterial> {
+    return this.http
+      .request<PrepMaterial>({ method: 'POST', url: `/api/v1/admin/topics/${encodeURIComponent(topic_id)}/attachments`, data: body })
+      .then(r => r.data);
+  }
+
+  /** A topic's prep materials in display order */
+  listPrepMaterials(topic_id: string): Promise<PrepMaterial[]> {
+    return this.http
+      .request<PrepMaterial[]>({ method: 'GET', url: `/api/v1/admin/topics/${encodeURIComponent(topic_id)}/materials` })
+      .then(r => r.data);
+  }
+
+  /** Add a link, key point or argument after the topic's other materials */
+  createPrepMaterial(topic_id: string, body: PrepMaterialInput): Promise<PrepMaterial> {
+    return this.http
+      .request<PrepMaterial>({ method: 'POST', url: `/api/v1/admin/topics/${encodeURIComponent(topic_id)}/materials`, data: body })
+      .then(r => r.data);
+  }
+
+  /** List active venues */
+  listVenues(): Promise<Venue[]> {
+    return this.http
+      .request<Venue[]>({ method: 'GET', url: '/api/v1/admin/venues' })
+      .then(r => r.data);
+  }
+
+  /** Create a venue */
+  createVenue(body: VenueInput): Promise<Venue> {
+    return this.http
+      .request<Venue>({ method: 'POST', url: '/api/v1/admin/venues', data: body })
+      .then(r => r.data);
+  }
+
+  /** Update a venue */
+  updateVenue(id: string, body: VenueUpdate): Promise<Message> {
+    return this.http
+      .request<Message>({ method: 'PUT', url: `/api/v1/admin/venues/${encodeURIComponent(id)}`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Set the weekly windows sessions are generated in at a venue */
+  setVenueAvailability(venue_id: string, body: VenueAvailabilityInput): Promise<VenueAvailability> {
+    return this.http
+      .request<VenueAvailability>({ method: 'PUT', url: `/api/v1/admin/venues/${encodeURIComponent(venue_id)}/availability`, data: body })
+      .then(r => r.data);
+  }
+
+  /** List a venue's active QR codes */
+  listVenueQRGroups(venue_id: string): Promise<VenueQRSummary[]> {
+    return this.http
+      .request<VenueQRSummary[]>({ method: 'GET', url: `/api/v1/admin/venues/${encodeURIComponent(venue_id)}/qr-groups` })
+      .then(r => r.data);
+  }
+
+  /** Return the venue's usable QR code, generating one if needed */
+  issueVenueQRGroup(venue_id: string, query: { force_new?: boolean; auto_generate?: boolean } = {}): Promise<VenueQR> {
+    return this.http
+      .request<VenueQR>({ method: 'POST', url: `/api/v1/admin/venues/${encodeURIComponent(venue_id)}/qr-groups`, params: query })
+      .then(r => r.data);
+  }
+
+  /** Book a seat at a venue */
+  createBooking(body: BookingRequest): Promise<Booking> {
+    return this.http
+      .request<Booking>({ method: 'POST', url: '/api/v1/student/bookings', data: body })
+      .then(r => r.data);
+  }
+
+  /** Cancel a booking */
+  cancelBooking(venue_id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'DELETE', url: `/api/v1/student/bookings/${encodeURIComponent(venue_id)}` })
+      .then(r => r.data);
+  }
+
+  /** Whether the student has booked a venue */
+  getBooking(venue_id: string): Promise<BookingCheck> {
+    return this.http
+      .request<BookingCheck>({ method: 'GET', url: `/api/v1/student/bookings/${encodeURIComponent(venue_id)}` })
+      .then(r => r.data);
+  }
+
+  /** Join a session by scanning its QR code */
+  checkIn(body: CheckIn): Promise<CheckedIn> {
+    return this.http
+      .request<CheckedIn>({ method: 'POST', url: '/api/v1/student/check-ins', data: body })
+      .then(r => r.data);
+  }
+
+  /** Exchange student credentials for a token */
+  studentLogin(body: StudentLoginRequest): Promise<StudentToken> {
+    return this.http
+      .request<StudentToken>({ method: 'POST', url: '/api/v1/student/login', data: body })
+      .then(r => r.data);
+  }
+
+  /** Session details and agenda */
+  getSession(session_id: string): Promise<SessionDetails> {
+    return this.http
+      .request<SessionDetails>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}` })
+      .then(r => r.data);
+  }
+
+  /** The student's feedback for the session, or an empty object */
+  getFeedback(session_id: string): Promise<Feedback> {
+    return this.http
+      .request<Feedback>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/feedback` })
+      .then(r => r.data);
+  }
+
+  /** Rate the session */
+  submitFeedback(session_id: string, body: FeedbackInput): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'POST', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/feedback`, data: body })
+      .then(r => r.data);
+  }
+
+  /** An attachment of the session's topic, while it is visible to the student */
+  getPrepMaterialFile(session_id: string, material_id: string): Promise<Blob> {
+    return this.http
+      .request<Blob>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/materials/${encodeURIComponent(material_id)}`, responseType: 'blob' })
+      .then(r => r.data);
+  }
+
+  /** Other participants present in the session */
+  listSessionParticipants(session_id: string): Promise<SessionParticipants> {
+    return this.http
+      .request<SessionParticipants>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/participants` })
+      .then(r => r.data);
+  }
+
+  /** Survey questions served to this student, in their order */
+  listSurveyQuestions(session_id: string): Promise<SurveyQuestion[]> {
+    return this.http
+      .request<SurveyQuestion[]>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/questions` })
+      .then(r => r.data);
+  }
+
+  /** Ranked results for the session */
+  getSessionResults(session_id: string): Promise<SessionResults> {
+    return this.http
+      .request<SessionResults>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/results` })
+      .then(r => r.data);
+  }
+
+  /** Phase durations in minutes */
+  getStudentSessionRules(session_id: string): Promise<SessionRules> {
+    return this.http
+      .request<SessionRules>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/rules` })
+      .then(r => r.data);
+  }
+
+  /** Move the session to another phase */
+  updateSessionStatus(session_id: string, body: {
+    status: 'pending' | 'lobby' | 'active' | 'completed';
+  }): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'PUT', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/status`, data: body })
+      .then(r => r.data);
+  }
+
+  /** How many participants have finished the survey */
+  getSurveyCompletion(session_id: string): Promise<SurveyCompletion> {
+    return this.http
+      .request<SurveyCompletion>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/completion` })
+      .then(r => r.data);
+  }
+
+  /** Record that the student finished the survey */
+  markSurveyCompleted(session_id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'PUT', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/completion` })
+      .then(r => r.data);
+  }
+
+  /** Finalize the session once the survey is over */
+  applySurveyPenalties(session_id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'POST', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/penalties` })
+      .then(r => r.data);
+  }
+
+  /** Penalise a timed-out question */
+  applyQuestionPenalty(session_id: string, question_id: string, body: {
+    student_id?: string;
+  }): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'POST', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/questions/${encodeURIComponent(question_id)}/penalties`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Time left on one question */
+  getQuestionTimer(session_id: string, question_id: string): Promise<Timeout> {
+    return this.http
+      .request<Timeout>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/questions/${encodeURIComponent(question_id)}/timer` })
+      .then(r => r.data);
+  }
+
+  /** Start the timer for one question */
+  startQuestionTimer(session_id: string, question_id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'POST', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/questions/${encodeURIComponent(question_id)}/timer` })
+      .then(r => r.data);
+  }
+
+  /** Submit rankings for one or more questions */
+  submitSurveyResponses(session_id: string, body: SurveyResponses): Promise<SurveyProgress> {
+    return this.http
+      .request<SurveyProgress>({ method: 'POST', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/responses`, data: body })
+      .then(r => r.data);
+  }
+
+  /** Time left in the survey */
+  getSurveyTimer(session_id: string): Promise<Timeout> {
+    return this.http
+      .request<Timeout>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/timer` })
+      .then(r => r.data);
+  }
+
+  /** Start the survey timer */
+  startSurveyTimer(session_id: string): Promise<Status> {
+    return this.http
+      .request<Status>({ method: 'POST', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/survey/timer` })
+      .then(r => r.data);
+  }
+
+  /** The session's topic, chosen once for all of its participants */
+  getSessionTopic(session_id: string): Promise<TopicForLevel> {
+    return this.http
+      .request<TopicForLevel>({ method: 'GET', url: `/api/v1/student/sessions/${encodeURIComponent(session_id)}/topic` })
+      .then(r => r.data);
+  }
+
+  /** A topic for the level with its preparation material */
+  getTopicForLevel(query: { level: number }): Promise<TopicForLevel> {
+    return this.http
+      .request<TopicForLevel>({ method: 'GET', url: '/api/v1/student/topic', params: query })
+      .then(r => r.data);
+  }
+
+  /** Venues open for booking at a level */
+  listAvailableVenues(query: { level: number }): Promise<AvailableVenue[]> {
+    return this.http
+      .request<AvailableVenue[]>({ method: 'GET', url: '/api/v1/student/venues', params: query })
+      .then(r => r.data);
+  }
+
+  /** Liveness probe */
+  liveness(): Promise<{
+    status: string;
+  }> {
+    return this.http
+      .request<{
+    status: string;
+  }>({ method: 'GET', url: '/healthz' })
+      .then(r => r.data);
+  }
+
+  /** Prometheus metrics; requires METRICS_TOKEN as a bearer token when set */
+  metrics(): Promise<string> {
+    return this.http
+      .request<string>({ method: 'GET', url: '/metrics' })
+      .then(r => r.data);
+  }
+
+  /** This document */
+  openAPI(): Promise<Record<string, unknown>> {
+    return this.http
+      .request<Record<string, unknown>>({ method: 'GET', url: '/openapi.json' })
+      .then(r => r.data);
+  }
+
+  /** Readiness probe; 503 lists the failing checks */
+  readiness(): Promise<{
+    checks?: Record<string, string>;
+    status: string;
+  }> {
+    return this.http
+      .request<{
+    checks?: Record<string, string>;
+    status: string;
+  }>({ method: 'GET', url: '/readyz' })
+      .then(r => r.data);
+  }
+}
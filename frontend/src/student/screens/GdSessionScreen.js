@@ -133,9 +133,9 @@ export default function GdSessionScreen({ navigation, route }) {
         const sessionData = response.data;
         setSession(sessionData);
 
-        // Fetch topic for the session's level - FIXED THIS PART
+        // Fetch the topic chosen for this session
         try {
-          const topicResponse = await api.student.getTopicForLevel(sessionData.level);
+          const topicResponse = await api.student.getSessionTopic(sessionId);
           
           // Check if the response structure is correct
           if (topicResponse.data && topicResponse.data.topic_text) {
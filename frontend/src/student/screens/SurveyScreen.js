@@ -475,14 +475,13 @@ const proceedToNextQuestion = async (isPartial = false) => {
         const currentSelections = selections[currentQuestion] || {};
         if (Object.keys(currentSelections).length > 0) {
             const shuffledQuestion = shuffledQuestions[currentQuestion];
-            const questionNumber = currentQuestion + 1;
             
             const isFinal = !isPartial && (currentQuestion === shuffledQuestions.length - 1);
             
             const responseData = {
                 sessionId,
                 responses: {
-                    [questionNumber]: currentSelections
+                    [shuffledQuestion.id]: currentSelections
                 },
                 isPartial: isPartial,
                 isFinal: isFinal
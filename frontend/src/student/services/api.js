@@ -207,10 +207,9 @@ submitSurvey: (data, isFinal = false) => {
     console.log('[API] Submitting survey with data:', JSON.stringify(data, null, 2));
     return api.post('/student/survey', {
         session_id: data.sessionId,
-        responses: Object.keys(data.responses).reduce((acc, questionKey) => {
-            const questionNum = parseInt(questionKey);
-            const rankings = data.responses[questionKey];
-            console.log(`[API] Processing question ${questionNum} with rankings:`, rankings);
+        responses: Object.keys(data.responses).reduce((acc, questionId) => {
+            const rankings = data.responses[questionId];
+            console.log(`[API] Processing question ${questionId} with rankings:`, rankings);
             
             const formattedRankings = {};
             Object.keys(rankings).forEach(rank => {
@@ -221,7 +220,7 @@ submitSurvey: (data, isFinal = false) => {
             });
             
             if (Object.keys(formattedRankings).length > 0) {
-                acc[questionNum] = formattedRankings;
+                acc[questionId] = formattedRankings;
             }
             return acc;
         }, {}),
@@ -406,8 +405,8 @@ checkQuestionTimeout: (sessionId, questionId) => api.get('/student/survey/check-
     }
 },
 
-getSessionTopic: (level) => api.get('/student/topic', { 
-  params: { level },
+getSessionTopic: (sessionId) => api.get('/student/topic', { 
+  params: { session_id: sessionId },
   validateStatus: function (status) {
     return status < 500;
   },